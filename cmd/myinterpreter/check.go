@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"interpreter/internal/checker"
+	"interpreter/internal/parser"
+	scanner "interpreter/internal/scanner"
+)
+
+// runCheck reports the type mismatches in a script without running it. It
+// exits with 65 if there are any, like a syntax error.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text or json")
+
+	files, err := parseInterleaved(flags, args)
+	if err != nil {
+		return 64
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(stderr, "Usage: ./your_program.sh check [--format text|json] <filename>")
+		return 64
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "Unknown format: %s\n", *format)
+		return 64
+	}
+
+	filename := files[0]
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	tokens, err := scanner.NewScanner(string(source)).ScanTokens()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+
+	diagnostics := checker.Check(statements)
+	if *format == "json" {
+		if diagnostics == nil {
+			diagnostics = []checker.Diagnostic{}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"file":        filename,
+			"diagnostics": diagnostics,
+		})
+	} else {
+		for _, d := range diagnostics {
+			fmt.Fprintf(stdout, "%s:%s\n", filename, d)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return 65
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckAcceptsWorkingScripts checks that scripts which run without error
+// pass the checker, annotated or not.
+func TestCheckAcceptsWorkingScripts(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range files {
+		t.Run(path, func(t *testing.T) {
+			if runCommand("evaluate", path).code != 0 {
+				t.Skip("script fails")
+			}
+			var stdout, stderr bytes.Buffer
+			if code := runCheck([]string{path}, &stdout, &stderr); code != 0 {
+				t.Errorf("check exited %d:\n%s%s", code, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestCheckReportsMismatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.lox")
+	source := "var x: number = 1;\nx = \"one\";\nprint x;\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{path}, &stdout, &stderr)
+	want := path + ":2: Cannot assign string to 'x' of type number.\n"
+	if code != 65 || stdout.String() != want {
+		t.Errorf("check = %d %q, want 65 %q", code, stdout.String(), want)
+	}
+	// Checking is separate from running, which still works.
+	if got := runCommand("evaluate", path); got.code != 0 || got.stdout != "one\n" {
+		t.Errorf("evaluate = %+v", got)
+	}
+}
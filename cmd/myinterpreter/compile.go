@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"interpreter/internal/bytecode"
+	"interpreter/internal/expression"
+	"interpreter/internal/parser"
+	scanner "interpreter/internal/scanner"
+	"interpreter/internal/wat"
+)
+
+// runCompile compiles a script into a .loxc file that evaluate can run
+// without scanning or parsing it again, or with --target=wat into a
+// WebAssembly text module.
+func runCompile(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "write the compiled script to this file (default: the source with a .loxc or .wat extension)")
+	target := flags.String("target", "bytecode", "what to compile to: bytecode or wat (experimental)")
+
+	files, err := parseInterleaved(flags, args)
+	if err != nil {
+		return 64
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(stderr, "Usage: ./your_program.sh compile [--target=bytecode|wat] <filename> [-o output]")
+		return 64
+	}
+	if *target != "bytecode" && *target != "wat" {
+		fmt.Fprintf(stderr, "Unknown target: %s\n", *target)
+		return 64
+	}
+
+	filename := files[0]
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	tokens, err := scanner.NewScanner(string(source)).ScanTokens()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+	p := parser.NewParser(tokens)
+	statements, err := p.Parse()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+	for _, warning := range p.Warnings() {
+		fmt.Fprintf(stderr, "Warning: %v\n", warning)
+	}
+	if *target == "wat" {
+		return compileWAT(statements, filename, *output, stderr)
+	}
+	script, err := bytecode.Compile(statements)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+		return 65
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, filepath.Ext(filename)) + bytecode.Extension
+	}
+	sourcePath, err := filepath.Rel(filepath.Dir(*output), filename)
+	if err != nil {
+		if sourcePath, err = filepath.Abs(filename); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := bytecode.NewFile(source, filepath.ToSlash(sourcePath), script).Encode(&buf); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(stderr, "Error writing file: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func compileWAT(statements []expression.Stmt, filename, output string, stderr io.Writer) int {
+	module, err := wat.Compile(statements)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+	if output == "" {
+		output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wat"
+	}
+	if err := os.WriteFile(output, []byte(module), 0o644); err != nil {
+		fmt.Fprintf(stderr, "Error writing file: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseInterleaved parses flags that may come after the file names as well
+// as before them, and returns the file names.
+func parseInterleaved(flags *flag.FlagSet, args []string) ([]string, error) {
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return files, nil
+		}
+		files = append(files, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// loadCompiled decodes a .loxc file for evaluate. It returns the path and
+// contents of the source the file was compiled from, for reports and module
+// resolution, falling back to the compiled file itself if the source is gone.
+// A file whose source has changed since is stale and refused.
+func loadCompiled(path string, data []byte) ([]expression.Stmt, string, []byte, error) {
+	file, err := bytecode.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	sourcePath, source := path, []byte(nil)
+	if file.Source != "" {
+		candidate := filepath.FromSlash(file.Source)
+		if !filepath.IsAbs(candidate) {
+			candidate = filepath.Join(filepath.Dir(path), candidate)
+		}
+		if contents, err := os.ReadFile(candidate); err == nil {
+			if !file.Fresh(contents) {
+				return nil, "", nil, fmt.Errorf("%s is stale: %s has changed since it was compiled; recompile it", path, candidate)
+			}
+			sourcePath, source = candidate, contents
+		}
+	}
+
+	statements, err := file.Script.Statements()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return statements, sourcePath, source, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"interpreter/internal/bytecode"
+)
+
+func compile(t *testing.T, source, output string) {
+	t.Helper()
+	var stderr bytes.Buffer
+	if code := runCompile([]string{source, "-o", output}, &stderr); code != 0 {
+		t.Fatalf("compile %s exited %d: %s", source, code, stderr.String())
+	}
+}
+
+// withoutWarnings drops parser warnings from stderr. The compiler reports
+// them, so a compiled file runs without repeating them.
+func withoutWarnings(stderr string) string {
+	var kept []string
+	for _, line := range strings.SplitAfter(stderr, "\n") {
+		if !strings.HasPrefix(line, "Warning: ") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// TestCompiledConformance checks that every script that compiles behaves the
+// same when evaluated from its .loxc file, runtime error lines included.
+func TestCompiledConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, path := range files {
+		t.Run(path, func(t *testing.T) {
+			if runCommand("parse", path).code != 0 {
+				t.Skip("syntax error")
+			}
+			output := filepath.Join(dir, strings.ReplaceAll(path, string(filepath.Separator), "_")+"c")
+			compile(t, path, output)
+
+			want := runCommand("evaluate", path)
+			want.stderr = withoutWarnings(want.stderr)
+			if got := runCommand("evaluate", output); got != want {
+				t.Errorf("evaluate %s = %+v, want %+v", output, got, want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCompiled(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "script.lox")
+	output := filepath.Join(dir, "script.loxc")
+	write := func(path, contents string) {
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(source, "print \"compiled\";\n")
+	compile(t, source, output)
+	if got := runCommand("evaluate", output); got.code != 0 || got.stdout != "compiled\n" {
+		t.Fatalf("evaluate = %+v", got)
+	}
+
+	// Editing the source makes the compiled file stale.
+	write(source, "print \"edited\";\n")
+	got := runCommand("evaluate", output)
+	if got.code != 65 || !strings.Contains(got.stderr, "is stale") {
+		t.Errorf("evaluate of a stale file = %+v, want exit 65 and a stale error", got)
+	}
+	if got := runCommand("evaluate", source); got.stdout != "edited\n" {
+		t.Errorf("evaluate of an edited script = %+v, want the stale cache ignored", got)
+	}
+
+	// A script is parsed even beside a fresh compiled file, so its warnings
+	// are still reported.
+	write(source, "match (1) {\n  case _ => print \"any\";\n  case 1 => print \"one\";\n}\n")
+	compile(t, source, output)
+	got = runCommand("evaluate", source)
+	if got.stdout != "any\n" || !strings.Contains(got.stderr, "Warning: Case can never match") {
+		t.Errorf("evaluate of a compiled script = %+v, want its warning", got)
+	}
+
+	// Without its source the compiled file still runs.
+	write(source, "print \"compiled\";\n")
+	compile(t, source, output)
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if got := runCommand("evaluate", output); got.stdout != "compiled\n" {
+		t.Errorf("evaluate without source = %+v", got)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[5]++
+	write(output, string(data))
+	got = runCommand("evaluate", output)
+	if got.code != 65 || !strings.Contains(got.stderr, fmt.Sprintf("format version %d", bytecode.FormatVersion+1)) {
+		t.Errorf("evaluate of another format version = %+v, want exit 65 and a version error", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"interpreter/internal/dap"
+	"interpreter/internal/debugger"
+	"interpreter/internal/parser"
+	scanner "interpreter/internal/scanner"
+)
+
+// runDebug debugs a script from the terminal, or with --dap serves the Debug
+// Adapter Protocol over stdin and stdout so an editor can attach.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	useDAP := flags.Bool("dap", false, "speak the Debug Adapter Protocol over stdio")
+	searchPath := flags.String("path", os.Getenv("LOXPATH"), "directories to search for imported modules, separated by "+string(filepath.ListSeparator))
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	if *useDAP {
+		if err := dap.NewServer(os.Stdin, os.Stdout, filepath.SplitList(*searchPath)).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh debug [--dap] [--path dirs] <filename>")
+		return 64
+	}
+	filename := flags.Arg(0)
+	fileContents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	tokens, err := scanner.NewScanner(string(fileContents)).ScanTokens()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 65
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 65
+	}
+
+	terminal := debugger.NewTerminal(string(fileContents), os.Stdin, os.Stdout)
+	if err := setLoader(terminal, filename, *searchPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := terminal.Run(statements); err != nil {
+		return 70
+	}
+	return 0
+}
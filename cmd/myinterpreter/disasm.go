@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"interpreter/internal/bytecode"
+)
+
+// runDisasm prints the bytecode of a script, compiling it first unless it is
+// already a .loxc file.
+func runDisasm(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "Usage: ./your_program.sh disasm <filename>")
+		return 64
+	}
+
+	filename := flags.Arg(0)
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+
+	var script *bytecode.Chunk
+	if filepath.Ext(filename) == bytecode.Extension {
+		file, err := bytecode.Decode(bytes.NewReader(contents))
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+			return 65
+		}
+		script = file.Script
+	} else {
+		statements, err := parseSource("disasm", contents, io.Discard, stderr)
+		if err != nil {
+			return 65
+		}
+		if script, err = bytecode.Compile(statements); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+			return 65
+		}
+	}
+
+	if err := bytecode.Disassemble(stdout, script); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"interpreter/internal/lint"
+	"interpreter/internal/parser"
+	scanner "interpreter/internal/scanner"
+)
+
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to a JSON lint config")
+	format := flags.String("format", "text", "output format: text or json")
+	listRules := flags.Bool("list-rules", false, "print every rule with its default severity")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *listRules {
+		for _, r := range lint.Rules() {
+			fmt.Printf("%-24s %-8s %s\n", r.ID, r.Severity, r.Description)
+		}
+		return 0
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh lint [--config file] [--format text|json] <filename>")
+		return 64
+	}
+
+	config := lint.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	filename := flags.Arg(0)
+	fileContents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+
+	tokens, err := scanner.NewScanner(string(fileContents)).ScanTokens()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 65
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 65
+	}
+
+	diagnostics := lint.Lint(statements, config)
+
+	switch *format {
+	case "json":
+		if diagnostics == nil {
+			diagnostics = []lint.Diagnostic{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"file":        filename,
+			"diagnostics": diagnostics,
+		})
+	case "text":
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%s\n", filename, d)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+		return 64
+	}
+
+	for _, d := range diagnostics {
+		if d.Severity == lint.Error {
+			return 1
+		}
+	}
+	return 0
+}
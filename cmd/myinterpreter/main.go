@@ -1,67 +1,263 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"interpreter/internal/bytecode"
+	"interpreter/internal/coverage"
+	"interpreter/internal/expression"
 	"interpreter/internal/interpreter"
+	"interpreter/internal/module"
 	"interpreter/internal/parser"
+	"interpreter/internal/profiler"
 	scanner "interpreter/internal/scanner"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh tokenize <filename>")
-		os.Exit(1)
+	if len(os.Args) >= 2 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLSP(os.Args[2:]))
+		case "debug":
+			os.Exit(runDebug(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		case "compile":
+			os.Exit(runCompile(os.Args[2:], os.Stderr))
+		case "disasm":
+			os.Exit(runDisasm(os.Args[2:], os.Stdout, os.Stderr))
+		case "transpile":
+			os.Exit(runTranspile(os.Args[2:], os.Stdout, os.Stderr))
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run carries out the tokenize, parse and evaluate commands and returns the
+// exit status: 65 for a syntax error and 70 for a runtime error. evaluate
+// also runs .loxc files when given one; a script is always parsed, so that
+// its warnings are reported.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 2 {
+		fmt.Fprintln(stderr, "Usage: ./your_program.sh tokenize <filename>")
+		return 1
 	}
 
-	command := os.Args[1]
+	command := args[0]
 
 	if command != "tokenize" && command != "parse" && command != "evaluate" {
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Unknown command: %s\n", command)
+		return 1
+	}
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var profilePath, coveragePath, searchPath, clock *string
+	if command == "evaluate" {
+		searchPath = flags.String("path", os.Getenv("LOXPATH"), "directories to search for imported modules, separated by "+string(filepath.ListSeparator))
+		profilePath = flags.String("profile", "", "write a pprof profile to this file and a line report to stderr")
+		coveragePath = flags.String("coverage", "", "merge line and branch coverage into this LCOV file and write an HTML report beside it")
+		clock = flags.String("clock", "real", "clock for timers and clock(): real, or fake to start at zero and jump straight to each timer")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "Usage: ./your_program.sh %s <filename>\n", command)
+		return 1
+	}
+	if command == "evaluate" && *profilePath != "" && *coveragePath != "" {
+		fmt.Fprintln(stderr, "--profile and --coverage cannot be used together")
+		return 1
+	}
+	if command == "evaluate" && *clock != "real" && *clock != "fake" {
+		fmt.Fprintf(stderr, "Unknown clock: %s\n", *clock)
+		return 64
 	}
 
-	filename := os.Args[2]
+	filename := flags.Arg(0)
 	fileContents, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
 	}
 
+	var statements []expression.Stmt
+	if command == "evaluate" && filepath.Ext(filename) == bytecode.Extension {
+		statements, filename, fileContents, err = loadCompiled(filename, fileContents)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 65
+		}
+	} else {
+		if statements, err = parseSource(command, fileContents, stdout, stderr); err != nil {
+			return 65
+		}
+		if command != "evaluate" {
+			return 0
+		}
+	}
+
+	i := interpreter.NewInterpreter()
+	i.SetOutput(stdout)
+	if *clock == "fake" {
+		i.SetClock(interpreter.NewFakeClock(time.Unix(0, 0)))
+	}
+	if err := setLoader(i, filename, *searchPath); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var prof *profiler.Profiler
+	if *profilePath != "" {
+		prof = profiler.New(filename, string(fileContents))
+		i.SetHook(prof)
+	}
+	var tracker *coverage.Tracker
+	if *coveragePath != "" {
+		tracker = coverage.NewTracker(filename, statements)
+		i.SetHook(tracker)
+	}
+
+	err = i.Interpret(statements)
+	if prof != nil {
+		if err := writeProfile(prof, *profilePath, stderr); err != nil {
+			fmt.Fprintf(stderr, "Error writing profile: %v\n", err)
+		}
+	}
+	if tracker != nil {
+		if err := writeCoverage(tracker.Report(), *coveragePath); err != nil {
+			fmt.Fprintf(stderr, "Error writing coverage: %v\n", err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 70
+	}
+	return 0
+}
+
+// parseSource scans and parses a script, printing the tokens or syntax tree
+// for the tokenize and parse commands and any syntax error to stderr.
+func parseSource(command string, fileContents []byte, stdout, stderr io.Writer) ([]expression.Stmt, error) {
 	s := scanner.NewScanner(string(fileContents))
 	tokens, err := s.ScanTokens()
 
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(65)
+		fmt.Fprintln(stderr, err)
+		return nil, err
 	}
 
 	if command == "tokenize" {
 		for _, t := range tokens {
-			fmt.Printf("%v\n", t)
-
+			fmt.Fprintf(stdout, "%v\n", t)
 		}
+		return nil, nil
 	}
 
 	p := parser.NewParser(tokens)
-	expr, err := p.Parse()
+	statements, err := p.Parse()
 
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(70)
+		fmt.Fprintln(stderr, err)
+		return nil, err
+	}
+	for _, warning := range p.Warnings() {
+		fmt.Fprintf(stderr, "Warning: %v\n", warning)
 	}
 
-	/* if command == "parse" {
-
+	if command == "parse" {
 		printer := &expression.AstPrinter{}
-		fmt.Println(printer.Print(expr))
+		for _, stmt := range statements {
+			fmt.Fprintln(stdout, printer.PrintStmt(stmt))
+		}
+		return nil, nil
+	}
+	return statements, nil
+}
 
-	} */
-	if command == "evaluate" {
-		i := interpreter.NewInterpreter()
-		i.Interpret(expr)
-		/* 	fmt.Println(expr) */
+// loaderSetter is what runs a script: an interpreter, or a debugger
+// front end that makes one.
+type loaderSetter interface {
+	SetLoader(loader interpreter.ModuleLoader, path string)
+}
+
+// setLoader lets the script import modules from the file system, relative
+// to itself or from the directories in searchPath.
+func setLoader(i loaderSetter, filename, searchPath string) error {
+	loader, err := module.NewOSLoader(filepath.SplitList(searchPath))
+	if err != nil {
+		return err
+	}
+	path, err := module.FSPath(filename)
+	if err != nil {
+		return err
+	}
+	i.SetLoader(loader, path)
+	return nil
+}
+
+func writeProfile(prof *profiler.Profiler, path string, report io.Writer) error {
+	if err := prof.WriteReport(report); err != nil {
+		return err
 	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := prof.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
+// writeCoverage merges report into the LCOV file at path, if there is one,
+// and writes the result back along with an HTML report of the same name.
+func writeCoverage(report *coverage.Report, path string) error {
+	if f, err := os.Open(path); err == nil {
+		previous, err := coverage.ReadLCOV(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		previous.Merge(report)
+		report = previous
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteLCOV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	htmlPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".html"
+	h, err := os.Create(htmlPath)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteHTML(h, os.ReadFile); err != nil {
+		h.Close()
+		return err
+	}
+	return h.Close()
 }
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Run `go test ./cmd/myinterpreter -run Conformance -update` after changing
+// the interpreter's output on purpose, and review the diff.
+var update = flag.Bool("update", false, "rewrite the expectations in testdata to match the actual output")
+
+// Expectations are written as comments in the scripts under testdata, in the
+// style of the Crafting Interpreters test suite:
+//
+//	print 1 + 2; // expect: 3
+//	print nil + 1; // expect runtime error: Operands must be two numbers or two strings.
+//	print 1 +; // expect error: unexpected token: SEMICOLON ; null
+//
+// "expect" lines are the output of evaluate in order, and a runtime error is
+// expected on the line that carries it. "expect error" is a syntax error on
+// its line, which parse and evaluate must report with exit code 65 and which
+// tokenize may report too. "expect warning" is a parser warning on its
+// line, which parse and evaluate print before anything else. Optional
+// "expect parse" and "expect tokenize" lines hold the full output of those
+// commands.
+const (
+	kindOutput       = "expect"
+	kindRuntimeError = "expect runtime error"
+	kindError        = "expect error"
+	kindWarning      = "expect warning"
+	kindParse        = "expect parse"
+	kindTokenize     = "expect tokenize"
+)
+
+// lineBound kinds are tied to the line they are written on; the others are
+// matched up in order.
+var lineBound = map[string]bool{kindRuntimeError: true, kindError: true, kindWarning: true}
+
+var annotationPattern = regexp.MustCompile(`^//\s*(expect(?: runtime error| error| warning| parse| tokenize)?):(.*)$`)
+
+type annotation struct {
+	kind string
+	line int
+	text string
+}
+
+// parseAnnotations finds every annotation in source. A line may carry more
+// than one, each starting with "// expect".
+func parseAnnotations(source string) []annotation {
+	var annotations []annotation
+	for index, line := range strings.Split(source, "\n") {
+		for _, comment := range comments(line) {
+			if m := annotationPattern.FindStringSubmatch(comment); m != nil {
+				text := strings.TrimPrefix(strings.TrimRight(m[2], " \t\r"), " ")
+				annotations = append(annotations, annotation{kind: m[1], line: index + 1, text: text})
+			}
+		}
+	}
+	return annotations
+}
+
+// comments splits the annotation comments off the end of a line.
+func comments(line string) []string {
+	start := strings.Index(line, "// expect")
+	if start < 0 {
+		return nil
+	}
+	var parts []string
+	rest := line[start:]
+	for {
+		next := strings.Index(rest[1:], "// expect")
+		if next < 0 {
+			return append(parts, rest)
+		}
+		parts = append(parts, strings.TrimRight(rest[:next+1], " "))
+		rest = rest[next+1:]
+	}
+}
+
+type result struct {
+	stdout, stderr string
+	code           int
+}
+
+// runCommand runs command on the script at path. Scripts are evaluated on a
+// fake clock, so that those using timers are quick and deterministic.
+func runCommand(command, path string) result {
+	args := []string{command, path}
+	if command == "evaluate" {
+		args = []string{command, "--clock=fake", path}
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return result{stdout.String(), stderr.String(), code}
+}
+
+func TestConformance(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".lox") {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scripts found in testdata")
+	}
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.ToSlash(strings.TrimPrefix(path, "testdata"+string(filepath.Separator))), ".lox")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := map[string]result{}
+			for _, command := range []string{"tokenize", "parse", "evaluate"} {
+				results[command] = runCommand(command, path)
+			}
+
+			annotations := parseAnnotations(string(source))
+			if *update {
+				updated := rewrite(string(source), annotations, actualAnnotations(results, annotations))
+				if updated != string(source) {
+					if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return
+			}
+			check(t, annotations, results)
+		})
+	}
+}
+
+func check(t *testing.T, annotations []annotation, results map[string]result) {
+	t.Helper()
+	want := map[string][]string{}
+	has := map[string]bool{}
+	for _, a := range annotations {
+		has[a.kind] = true
+		switch a.kind {
+		case kindError:
+			want[a.kind] = append(want[a.kind], fmt.Sprintf("%s at line %d", a.text, a.line))
+		case kindWarning:
+			want[a.kind] = append(want[a.kind], fmt.Sprintf("Warning: %s at line %d", a.text, a.line))
+		case kindRuntimeError:
+			want[a.kind] = append(want[a.kind], a.text, fmt.Sprintf("[line %d]", a.line))
+		default:
+			want[a.kind] = append(want[a.kind], a.text)
+		}
+	}
+
+	expect := func(command string, got result, stdout, stderr []string, code int) {
+		t.Helper()
+		if stdout != nil && got.stdout != joinLines(stdout) {
+			t.Errorf("%s stdout:\n%s\nwant:\n%s", command, got.stdout, joinLines(stdout))
+		}
+		if got.stderr != joinLines(stderr) {
+			t.Errorf("%s stderr:\n%s\nwant:\n%s", command, got.stderr, joinLines(stderr))
+		}
+		if got.code != code {
+			t.Errorf("%s exit code = %d, want %d", command, got.code, code)
+		}
+	}
+
+	tokenize := results["tokenize"]
+	if has[kindError] {
+		// Only scanner errors stop tokenize, so it may fail or succeed.
+		if tokenize.code != 0 {
+			expect("tokenize", tokenize, nil, want[kindError], 65)
+		}
+		expect("parse", results["parse"], []string{}, want[kindError], 65)
+		expect("evaluate", results["evaluate"], []string{}, want[kindError], 65)
+		return
+	}
+
+	var tokens, tree []string
+	if has[kindTokenize] {
+		tokens = want[kindTokenize]
+	}
+	if has[kindParse] {
+		tree = want[kindParse]
+	}
+	expect("tokenize", tokenize, tokens, nil, 0)
+	expect("parse", results["parse"], tree, want[kindWarning], 0)
+
+	code := 0
+	if has[kindRuntimeError] {
+		code = 70
+	}
+	stderr := append(want[kindWarning], want[kindRuntimeError]...)
+	expect("evaluate", results["evaluate"], want[kindOutput], stderr, code)
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+var (
+	syntaxErrorPattern  = regexp.MustCompile(`^(.*) at line (\d+)$`)
+	warningPattern      = regexp.MustCompile(`^Warning: (.*) at line (\d+)$`)
+	runtimeErrorPattern = regexp.MustCompile(`^\[line (\d+)\]$`)
+)
+
+// actualAnnotations describes the commands' output as annotations. The
+// tokenize and parse output is only recorded for scripts that already
+// expect it.
+func actualAnnotations(results map[string]result, existing []annotation) []annotation {
+	has := map[string]bool{}
+	for _, a := range existing {
+		has[a.kind] = true
+	}
+
+	var annotations []annotation
+	add := func(kind string, line int, text string) {
+		annotations = append(annotations, annotation{kind: kind, line: line, text: text})
+	}
+	lines := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+
+	evaluate := results["evaluate"]
+	switch evaluate.code {
+	case 65:
+		for _, l := range lines(evaluate.stderr) {
+			if m := syntaxErrorPattern.FindStringSubmatch(l); m != nil {
+				line, _ := strconv.Atoi(m[2])
+				add(kindError, line, m[1])
+			}
+		}
+		return annotations
+	}
+	for _, l := range lines(evaluate.stderr) {
+		if m := warningPattern.FindStringSubmatch(l); m != nil {
+			line, _ := strconv.Atoi(m[2])
+			add(kindWarning, line, m[1])
+		}
+	}
+	switch evaluate.code {
+	case 70:
+		stderr := lines(evaluate.stderr)
+		for index := 1; index < len(stderr); index++ {
+			if m := runtimeErrorPattern.FindStringSubmatch(stderr[index]); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				add(kindRuntimeError, line, stderr[index-1])
+			}
+		}
+	}
+	for _, l := range lines(evaluate.stdout) {
+		add(kindOutput, 0, l)
+	}
+	for _, kind := range []string{kindParse, kindTokenize} {
+		if !has[kind] {
+			continue
+		}
+		command := strings.TrimPrefix(kind, "expect ")
+		for _, l := range lines(results[command].stdout) {
+			add(kind, 0, l)
+		}
+	}
+	return annotations
+}
+
+// rewrite replaces the annotations in source with actual. Output annotations
+// keep their places where there are as many as before; extra ones go at the
+// end of the file. Errors are written on the line they are reported for.
+func rewrite(source string, existing, actual []annotation) string {
+	lines := strings.Split(source, "\n")
+
+	// Strip every annotation. Lines holding nothing else are dropped unless
+	// a new annotation takes their place.
+	places := map[string][]int{}
+	for _, a := range existing {
+		if !lineBound[a.kind] {
+			places[a.kind] = append(places[a.kind], a.line-1)
+		}
+	}
+	standalone := map[int]bool{}
+	for index, line := range lines {
+		start := strings.Index(line, "// expect")
+		if start < 0 {
+			continue
+		}
+		if strings.TrimSpace(line[:start]) == "" {
+			standalone[index] = true
+			lines[index] = line[:start]
+		} else {
+			lines[index] = strings.TrimRight(line[:start], " \t")
+		}
+	}
+
+	var appended []string
+	for _, a := range actual {
+		comment := strings.TrimRight(fmt.Sprintf("// %s: %s", a.kind, a.text), " ")
+		index := -1
+		if lineBound[a.kind] && a.line >= 1 && a.line <= len(lines) {
+			index = a.line - 1
+		} else if !lineBound[a.kind] && len(places[a.kind]) > 0 {
+			index = places[a.kind][0]
+			places[a.kind] = places[a.kind][1:]
+		}
+
+		switch {
+		case index < 0:
+			appended = append(appended, comment)
+		case standalone[index] || strings.TrimSpace(lines[index]) == "":
+			lines[index] += comment
+			delete(standalone, index)
+		default:
+			lines[index] += " " + comment
+		}
+	}
+
+	var out []string
+	for index, line := range lines {
+		if !standalone[index] {
+			out = append(out, line)
+		}
+	}
+	if len(appended) > 0 {
+		if len(out) > 0 && out[len(out)-1] == "" {
+			out = out[:len(out)-1]
+		}
+		out = append(out, appended...)
+		out = append(out, "")
+	}
+	return strings.Join(out, "\n")
+}
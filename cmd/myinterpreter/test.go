@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"interpreter/internal/testrunner"
+)
+
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only the tests whose names match this regular expression")
+	junitPath := flags.String("junit", "", "also write the results as JUnit XML to this file")
+	parallel := flags.Int("parallel", 0, "number of files to run at once (default GOMAXPROCS)")
+	verbose := flags.Bool("v", false, "list every test and its output")
+	searchPath := flags.String("path", os.Getenv("LOXPATH"), "directories to search for imported modules, separated by "+string(filepath.ListSeparator))
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	opts := testrunner.Options{Parallel: *parallel, SearchPath: filepath.SplitList(*searchPath)}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --run pattern: %v\n", err)
+			return 64
+		}
+		opts.Run = re
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testrunner.Discover(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	results := testrunner.Run(files, opts)
+	testrunner.WriteText(os.Stdout, results, *verbose)
+
+	if *junitPath != "" {
+		f, err := os.Create(*junitPath)
+		if err == nil {
+			err = testrunner.WriteJUnit(f, results)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JUnit report: %v\n", err)
+			return 1
+		}
+	}
+
+	for _, result := range results {
+		if !result.Passed() {
+			return 1
+		}
+	}
+	return 0
+}
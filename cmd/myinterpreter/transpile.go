@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/transpile"
+)
+
+// targets maps the names accepted by --target to their translators.
+var targets = map[string]func(statements []expression.Stmt, name string) (string, error){
+	"go": transpile.Go,
+	"js": transpile.JavaScript,
+}
+
+// runTranspile translates a script into another language, writing the result
+// to stdout or the file named by -o.
+func runTranspile(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("transpile", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	target := flags.String("target", "go", "language to translate to: go or js")
+	output := flags.String("o", "", "write the translation to this file instead of stdout")
+	files, err := parseInterleaved(flags, args)
+	if err != nil {
+		return 64
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(stderr, "Usage: ./your_program.sh transpile --target=go|js <filename> [-o output]")
+		return 64
+	}
+	translate, ok := targets[*target]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown target: %s\n", *target)
+		return 64
+	}
+
+	filename := files[0]
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	statements, err := parseSource("transpile", contents, io.Discard, stderr)
+	if err != nil {
+		return 65
+	}
+	source, err := translate(statements, filepath.Base(filename))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 65
+	}
+
+	if *output == "" {
+		io.WriteString(stdout, source)
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(source), 0o644); err != nil {
+		fmt.Fprintf(stderr, "Error writing file: %v\n", err)
+		return 1
+	}
+	return 0
+}
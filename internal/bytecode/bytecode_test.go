@@ -0,0 +1,197 @@
+package bytecode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func parse(t *testing.T, source string) ([]expression.Stmt, bool) {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		return nil, false
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, false
+	}
+	return statements, true
+}
+
+func printAll(statements []expression.Stmt) string {
+	printer := &expression.AstPrinter{}
+	var lines []string
+	for _, stmt := range statements {
+		lines = append(lines, printer.PrintStmt(stmt))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roundTrip compiles source, encodes and decodes the result and rebuilds the
+// syntax tree, which must print the same as the one parsed.
+// Sources with syntax errors are skipped unless mustParse is set.
+func roundTrip(t *testing.T, source string, mustParse bool) {
+	t.Helper()
+	statements, ok := parse(t, source)
+	if !ok {
+		if mustParse {
+			t.Fatalf("cannot parse %q", source)
+		}
+		return
+	}
+	chunk, err := Compile(statements)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewFile([]byte(source), "script.lox", chunk).Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	file, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	decoded, err := file.Script.Statements()
+	if err != nil {
+		t.Fatalf("Statements() error = %v", err)
+	}
+	if got, want := printAll(decoded), printAll(statements); got != want {
+		t.Errorf("round trip =\n%s\nwant\n%s", got, want)
+	}
+	for i := range statements {
+		if got, want := decoded[i].Line(), statements[i].Line(); got != want {
+			t.Errorf("statement %d line = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"Literals", `print nil; print true; print false; print 1.5; print "s";`},
+		{"Operators", `print -(1 + 2) * 3 / 4 >= 5 == !(6 < 7) != (8 <= 9 > 0);`},
+		{"Comma and ternary", `print (1, 2) ? a ? "x" : "y" : "z";`},
+		{"Logical", `print a and b or (c and (d or e));`},
+		{"Variables", "var a;\nvar b = 1;\na = b = 2;\n"},
+		{"If and else", "if (a) print 1; else if (b) print 2; else { print 3; }\nif (c) if (d) print 4;"},
+		{"Loops", "while (a) { a = a - 1; }\nfor (var i = 0; i < 3; i = i + 1) print i;\nif (b) while (c) print c;"},
+		{"Functions", "fun add(a, b) {\n  fun inner() { return; }\n  return a + b;\n}\nprint add(1, 2);"},
+		{"Tests and imports", "import \"util/strings\" as s;\nimport \"math\";\ntest \"adds\" { print s.upper(\"a\"); }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roundTrip(t, tt.source, true)
+		})
+	}
+}
+
+func TestRoundTripTestdata(t *testing.T) {
+	paths, err := filepath.Glob("../../cmd/myinterpreter/testdata/*/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no testdata found: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTrip(t, string(source), false)
+		})
+	}
+}
+
+func encoded(t *testing.T, source string) []byte {
+	t.Helper()
+	statements, _ := parse(t, source)
+	chunk, err := Compile(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewFile([]byte(source), "script.lox", chunk).Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRejects(t *testing.T) {
+	good := encoded(t, "fun f(a) { return a; }\nprint f(1);")
+
+	newer := append([]byte(nil), good...)
+	newer[len(magic)+1] = FormatVersion + 1
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    error
+		wantMsg string
+	}{
+		{"Not compiled", []byte("print 1;"), ErrNotCompiled, ""},
+		{"Other version", newer, nil, fmt.Sprintf("format version %d", FormatVersion+1)},
+		{"Truncated", good[:len(good)-3], nil, "corrupt compiled file: unexpected end of file"},
+		{"Trailing data", append(append([]byte(nil), good...), 0), nil, "corrupt compiled file: trailing data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatal("Decode() succeeded")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Decode() error = %q, want it to contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+
+	var versionErr *VersionError
+	if _, err := Decode(bytes.NewReader(newer)); !errors.As(err, &versionErr) || versionErr.Version != FormatVersion+1 {
+		t.Errorf("Decode() error = %v, want a *VersionError", err)
+	}
+}
+
+func TestStatementsRejectsMalformedCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+	}{
+		{"Stack underflow", []byte{byte(OpAdd)}},
+		{"Value left on the stack", []byte{byte(OpNil)}},
+		{"Unknown opcode", []byte{0xff}},
+		{"Constant out of range", []byte{byte(OpConstant), 0, 9, byte(OpPrint)}},
+		{"Jump past the end", []byte{byte(OpTrue), byte(OpJumpIfFalse), 0, 9}},
+		{"Unterminated scope", []byte{byte(OpBeginScope)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := &Chunk{Code: tt.code, Lines: []LineStart{{0, 1}}}
+			if _, err := chunk.Statements(); err == nil || !strings.HasPrefix(err.Error(), "malformed bytecode") {
+				t.Errorf("Statements() error = %v, want malformed bytecode", err)
+			}
+		})
+	}
+}
+
+func TestFresh(t *testing.T) {
+	file := NewFile([]byte("print 1;"), "a.lox", &Chunk{})
+	if !file.Fresh([]byte("print 1;")) {
+		t.Error("Fresh() = false for the compiled source")
+	}
+	if file.Fresh([]byte("print 2;")) {
+		t.Error("Fresh() = true for changed source")
+	}
+}
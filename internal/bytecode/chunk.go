@@ -0,0 +1,108 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ChunkKind says what a chunk's code belongs to.
+type ChunkKind byte
+
+const (
+	ScriptChunk ChunkKind = iota
+	FunctionChunk
+	TestChunk
+	AsyncFunctionChunk
+)
+
+// Chunk is the compiled code of a script, function or test block. Function
+// and test chunks are stored in the constant pool of the chunk declaring them.
+type Chunk struct {
+	Kind   ChunkKind
+	Name   string
+	Params []string
+	// Line is the line of the declaration's name.
+	Line      int
+	Code      []byte
+	Lines     []LineStart
+	Constants []interface{} // float64, string or *Chunk
+}
+
+// LineStart records that the instructions from Offset onwards, up to the
+// next entry, were compiled from Line.
+type LineStart struct {
+	Offset int
+	Line   int
+}
+
+// LineAt returns the source line of the instruction at offset.
+func (c *Chunk) LineAt(offset int) int {
+	line := 0
+	for _, start := range c.Lines {
+		if start.Offset > offset {
+			break
+		}
+		line = start.Line
+	}
+	return line
+}
+
+func (c *Chunk) write(op OpCode, line int, operands ...byte) int {
+	offset := len(c.Code)
+	if n := len(c.Lines); n == 0 || c.Lines[n-1].Line != line {
+		c.Lines = append(c.Lines, LineStart{Offset: offset, Line: line})
+	}
+	c.Code = append(c.Code, byte(op))
+	c.Code = append(c.Code, operands...)
+	return offset
+}
+
+// addConstant returns the index of value in the pool, adding it if needed.
+// Nested chunks are never shared.
+func (c *Chunk) addConstant(value interface{}) (int, error) {
+	if _, ok := value.(*Chunk); !ok {
+		for i, existing := range c.Constants {
+			if existing == value {
+				return i, nil
+			}
+		}
+	}
+	if len(c.Constants) > 0xffff {
+		return 0, fmt.Errorf("too many constants in %s", c.describe())
+	}
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1, nil
+}
+
+func (c *Chunk) describe() string {
+	switch c.Kind {
+	case FunctionChunk:
+		return fmt.Sprintf("function %s", c.Name)
+	case AsyncFunctionChunk:
+		return fmt.Sprintf("async function %s", c.Name)
+	case TestChunk:
+		return fmt.Sprintf("test %q", c.Name)
+	}
+	return "script"
+}
+
+func (c *Chunk) u8(offset int) int {
+	return int(c.Code[offset])
+}
+
+func (c *Chunk) u16(offset int) int {
+	return int(binary.BigEndian.Uint16(c.Code[offset:]))
+}
+
+// JumpTarget returns the offset a jump or loop instruction at offset goes to.
+func (c *Chunk) JumpTarget(offset int) int {
+	distance := c.u16(offset + 1)
+	if OpCode(c.Code[offset]) == OpLoop {
+		return offset + 3 - distance
+	}
+	return offset + 3 + distance
+}
+
+func u16(n int) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(n))
+}
@@ -0,0 +1,639 @@
+package bytecode
+
+import (
+	"fmt"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// compileError aborts compilation from deep inside the visitors.
+type compileError struct {
+	err error
+}
+
+// Compile translates a parsed script into a chunk. Expressions are emitted in
+// evaluation order, operands before their operator, and control flow becomes
+// jumps over the code it skips.
+func Compile(statements []expression.Stmt) (chunk *Chunk, err error) {
+	c := &compiler{chunk: &Chunk{Kind: ScriptChunk, Name: "<script>"}}
+	defer func() {
+		if r := recover(); r != nil {
+			ce, ok := r.(compileError)
+			if !ok {
+				panic(r)
+			}
+			chunk, err = nil, ce.err
+		}
+	}()
+	c.statements(statements)
+	return c.chunk, nil
+}
+
+type compiler struct {
+	chunk *Chunk
+	// line is the line of the innermost token seen, used for instructions
+	// such as literals that have no token of their own.
+	line int
+}
+
+func (c *compiler) fail(format string, args ...interface{}) {
+	panic(compileError{fmt.Errorf(format, args...)})
+}
+
+func (c *compiler) statements(statements []expression.Stmt) {
+	for _, stmt := range statements {
+		c.line = stmt.Line()
+		stmt.Accept(c)
+	}
+}
+
+func (c *compiler) expr(expr expression.Expr) {
+	expr.Accept(c)
+}
+
+func (c *compiler) emit(op OpCode, operands ...byte) int {
+	return c.chunk.write(op, c.line, operands...)
+}
+
+func (c *compiler) constant(value interface{}) []byte {
+	index, err := c.chunk.addConstant(value)
+	if err != nil {
+		panic(compileError{err})
+	}
+	return u16(index)
+}
+
+// emitJump writes a forward jump whose distance is filled in by patchJump.
+func (c *compiler) emitJump(op OpCode) int {
+	return c.emit(op, 0, 0)
+}
+
+func (c *compiler) patchJump(offset int) {
+	distance := len(c.chunk.Code) - offset - 3
+	if distance > 0xffff {
+		c.fail("too much code to jump over at line %d", c.chunk.LineAt(offset))
+	}
+	copy(c.chunk.Code[offset+1:], u16(distance))
+}
+
+func (c *compiler) emitLoop(start int) {
+	distance := len(c.chunk.Code) + 3 - start
+	if distance > 0xffff {
+		c.fail("loop body too large at line %d", c.line)
+	}
+	c.emit(OpLoop, u16(distance)...)
+}
+
+// nested compiles a function or test body into a chunk of its own.
+func (c *compiler) nested(chunk *Chunk, body []expression.Stmt) {
+	inner := &compiler{chunk: chunk, line: chunk.Line}
+	inner.statements(body)
+}
+
+func (c *compiler) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	c.expr(stmt.Expr)
+	c.line = stmt.Line()
+	c.emit(OpPop)
+	return nil
+}
+
+func (c *compiler) VisitPrintStmt(stmt *expression.Print) interface{} {
+	c.expr(stmt.Expression)
+	c.line = stmt.Line()
+	c.emit(OpPrint)
+	return nil
+}
+
+func (c *compiler) VisitVarStmt(stmt *expression.Var) interface{} {
+	if stmt.Initializer == nil {
+		c.emit(OpDeclare, c.constant(stmt.Name.Lexeme)...)
+		return nil
+	}
+	c.expr(stmt.Initializer)
+	c.line = stmt.Line()
+	c.emit(OpDefine, c.constant(stmt.Name.Lexeme)...)
+	return nil
+}
+
+func (c *compiler) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	c.expr(stmt.Initializer)
+	c.line = stmt.Line()
+	c.emit(OpDefinePattern)
+	c.destructuringTarget(stmt.Pattern)
+	return nil
+}
+
+// destructuringTarget writes out a target, its parts after it.
+func (c *compiler) destructuringTarget(target expression.Target) {
+	c.line = target.Line()
+	switch t := target.(type) {
+	case *expression.NameTarget:
+		c.emit(OpNameTarget, c.constant(t.Name.Lexeme)...)
+	case *expression.ListTarget:
+		if len(t.Elements) > 0xffff {
+			c.fail("too many pattern elements at line %d", t.Bracket.Line)
+		}
+		var rest byte
+		if t.Rest != nil {
+			rest = 1
+		}
+		c.emit(OpListTarget, append(u16(len(t.Elements)), rest)...)
+		for _, element := range t.Elements {
+			c.destructuringTarget(element)
+		}
+		if t.Rest != nil {
+			c.destructuringTarget(&expression.NameTarget{Name: *t.Rest})
+		}
+	case *expression.MapTarget:
+		if len(t.Keys) > 0xffff {
+			c.fail("too many pattern entries at line %d", t.Brace.Line)
+		}
+		c.emit(OpMapTarget, u16(len(t.Keys))...)
+		for index, value := range t.Values {
+			c.line = t.Keys[index].Line
+			c.emit(OpKey, c.constant(t.Key(index))...)
+			c.destructuringTarget(value)
+		}
+	default:
+		c.fail("unsupported target %s at line %d", target, c.line)
+	}
+}
+
+func (c *compiler) VisitWhileStmt(stmt *expression.While) interface{} {
+	start := len(c.chunk.Code)
+	c.expr(stmt.Condition)
+	c.line = stmt.Line()
+	exit := c.emitJump(OpJumpIfFalse)
+	c.statements([]expression.Stmt{stmt.Body})
+	c.line = stmt.Line()
+	c.emitLoop(start)
+	c.patchJump(exit)
+	return nil
+}
+
+func (c *compiler) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	c.expr(stmt.Iterable)
+	c.line = stmt.Line()
+	c.emit(OpIterate)
+	start := len(c.chunk.Code)
+	exit := c.emit(OpForNext, append([]byte{0, 0}, c.constant(stmt.Name.Lexeme)...)...)
+	c.statements([]expression.Stmt{stmt.Body})
+	c.line = stmt.Line()
+	c.emitLoop(start)
+	c.patchJump(exit)
+	return nil
+}
+
+func (c *compiler) VisitBlockStmt(stmt *expression.Block) interface{} {
+	c.emit(OpBeginScope)
+	c.statements(stmt.Statements)
+	c.line = stmt.Line()
+	c.emit(OpEndScope)
+	return nil
+}
+
+func (c *compiler) VisitIfStmt(stmt *expression.If) interface{} {
+	c.expr(stmt.Condition)
+	c.line = stmt.Line()
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.statements([]expression.Stmt{stmt.ThenBranch})
+	if stmt.ElseBranch == nil {
+		c.patchJump(thenJump)
+		return nil
+	}
+	c.line = stmt.Line()
+	elseJump := c.emitJump(OpElse)
+	c.patchJump(thenJump)
+	c.statements([]expression.Stmt{stmt.ElseBranch})
+	c.patchJump(elseJump)
+	return nil
+}
+
+func (c *compiler) VisitMatchStmt(stmt *expression.Match) interface{} {
+	c.expr(stmt.Subject)
+	c.line = stmt.Line()
+	c.emit(OpMatch)
+	var exits []int
+	for _, mc := range stmt.Cases {
+		c.line = mc.Keyword.Line
+		for _, pattern := range mc.Patterns {
+			c.pattern(pattern)
+		}
+		c.line = mc.Keyword.Line
+		next := []int{c.emitJump(OpCase)}
+		if mc.Guard != nil {
+			c.expr(mc.Guard)
+			c.line = mc.Keyword.Line
+			next = append(next, c.emitJump(OpGuard))
+		}
+		c.statements([]expression.Stmt{mc.Body})
+		c.line = mc.Keyword.Line
+		exits = append(exits, c.emitJump(OpEndCase))
+		for _, jump := range next {
+			c.patchJump(jump)
+		}
+	}
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+	c.line = stmt.Line()
+	c.emit(OpEndMatch)
+	return nil
+}
+
+func (c *compiler) pattern(pattern expression.Pattern) {
+	switch p := pattern.(type) {
+	case *expression.LiteralPattern:
+		c.VisitLiteralExpr(expression.NewLiteral(p.Value))
+		c.emit(OpMatchValue)
+	case *expression.WildcardPattern:
+		c.emit(OpMatchAny)
+	case *expression.BindingPattern:
+		c.emit(OpMatchBind, c.constant(p.Name.Lexeme)...)
+	case *expression.DestructurePattern:
+		c.emit(OpMatchTarget)
+		c.destructuringTarget(p.Target)
+	default:
+		c.fail("unsupported pattern %s at line %d", pattern, c.line)
+	}
+}
+
+func (c *compiler) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	c.emit(OpFunction, c.constant(c.function(stmt))...)
+	return nil
+}
+
+func (c *compiler) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	c.emit(OpLambda, c.constant(c.function(expr.Function))...)
+	return nil
+}
+
+// function compiles a function into a nested chunk. A lambda's chunk has no
+// name.
+func (c *compiler) function(stmt *expression.Function) *Chunk {
+	chunk := &Chunk{Kind: FunctionChunk, Name: stmt.Name.Lexeme, Line: stmt.Name.Line}
+	if stmt.Async {
+		chunk.Kind = AsyncFunctionChunk
+	}
+	for _, param := range stmt.Params {
+		chunk.Params = append(chunk.Params, param.Lexeme)
+	}
+	c.nested(chunk, stmt.Body)
+	return chunk
+}
+
+func (c *compiler) VisitReturnStmt(stmt *expression.Return) interface{} {
+	if stmt.Value == nil {
+		c.emit(OpReturnNil)
+		return nil
+	}
+	c.expr(stmt.Value)
+	c.line = stmt.Line()
+	c.emit(OpReturn)
+	return nil
+}
+
+func (c *compiler) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value == nil {
+		c.emit(OpYieldNil)
+		return nil
+	}
+	c.expr(stmt.Value)
+	c.line = stmt.Line()
+	c.emit(OpYield)
+	return nil
+}
+
+func (c *compiler) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	call := stmt.Call
+	if len(call.Arguments) > 0xff {
+		c.fail("too many arguments at line %d", call.Paren.Line)
+	}
+	c.expr(call.Callee)
+	for _, argument := range call.Arguments {
+		c.expr(argument)
+	}
+	c.line = call.Paren.Line
+	c.emit(OpSpawn, byte(len(call.Arguments)))
+	return nil
+}
+
+func (c *compiler) VisitSelectStmt(stmt *expression.Select) interface{} {
+	c.line = stmt.Line()
+	c.emit(OpSelect)
+	var exits []int
+	for _, sc := range stmt.Cases {
+		switch {
+		case sc.IsDefault():
+			c.line = sc.Operation.Line
+			c.emit(OpDefaultCase)
+		case sc.IsSend():
+			c.expr(sc.Channel)
+			c.expr(sc.Value)
+			c.line = sc.Operation.Line
+			c.emit(OpSendCase)
+		default:
+			name := ""
+			if sc.Name != nil {
+				name = sc.Name.Lexeme
+			}
+			c.expr(sc.Channel)
+			c.line = sc.Operation.Line
+			c.emit(OpRecvCase, c.constant(name)...)
+		}
+		c.statements([]expression.Stmt{sc.Body})
+		c.line = sc.Keyword.Line
+		exits = append(exits, c.emitJump(OpEndCase))
+	}
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+	c.line = stmt.Line()
+	c.emit(OpEndSelect)
+	return nil
+}
+
+func (c *compiler) VisitTestStmt(stmt *expression.Test) interface{} {
+	name, _ := stmt.Name.Literal.(string)
+	chunk := &Chunk{Kind: TestChunk, Name: name, Line: stmt.Name.Line}
+	c.nested(chunk, stmt.Body)
+	c.emit(OpTest, c.constant(chunk)...)
+	return nil
+}
+
+func (c *compiler) VisitImportStmt(stmt *expression.Import) interface{} {
+	path, _ := stmt.Path.Literal.(string)
+	operands := append(c.constant(path), c.constant(stmt.Name.Lexeme)...)
+	c.emit(OpImport, operands...)
+	return nil
+}
+
+func (c *compiler) VisitAssignExpr(expr *expression.Assign) interface{} {
+	c.expr(expr.Value)
+	c.line = expr.Name.Line
+	c.emit(OpSet, c.constant(expr.Name.Lexeme)...)
+	return nil
+}
+
+func (c *compiler) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	c.expr(expr.Value)
+	c.line = expr.Equals.Line
+	c.emit(OpSetPattern)
+	c.destructuringTarget(expr.Pattern)
+	return nil
+}
+
+// VisitCompoundExpr compiles x ??= v as COALESCE_SET jumping over the code
+// of v and a SET of x.
+func (c *compiler) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	element, isElement := expr.Target.(*expression.Index)
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL && isElement {
+		c.element(element)
+		c.line = expr.Operator.Line
+		end := c.emitJump(OpCoalesceIndex)
+		c.expr(expr.Value)
+		c.patchJump(end)
+		return nil
+	}
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		name := c.target(expr.Target, expr.Operator)
+		c.line = expr.Operator.Line
+		end := c.emit(OpCoalesceSet, append([]byte{0, 0}, name...)...)
+		c.expr(expr.Value)
+		c.line = expr.Operator.Line
+		c.emit(OpSet, name...)
+		c.patchJump(end)
+		return nil
+	}
+	o, ok := lookupOperator(compoundOperators, expr.Operator.Type)
+	if !ok {
+		c.fail("unsupported assignment operator %q at line %d", expr.Operator.Lexeme, expr.Operator.Line)
+	}
+	if isElement {
+		c.element(element)
+		c.expr(expr.Value)
+		c.line = expr.Operator.Line
+		c.emit(OpIndexUpdate, byte(o.op))
+		return nil
+	}
+	name := c.target(expr.Target, expr.Operator)
+	c.expr(expr.Value)
+	c.line = expr.Operator.Line
+	c.emit(o.op, name...)
+	return nil
+}
+
+func (c *compiler) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	table := postfixOperators
+	if expr.Prefix {
+		table = prefixOperators
+	}
+	o, ok := lookupOperator(table, expr.Operator.Type)
+	if !ok {
+		c.fail("unsupported increment operator %q at line %d", expr.Operator.Lexeme, expr.Operator.Line)
+	}
+	if element, ok := expr.Target.(*expression.Index); ok {
+		c.element(element)
+		c.line = expr.Operator.Line
+		c.emit(OpIndexUpdate, byte(o.op))
+		return nil
+	}
+	name := c.target(expr.Target, expr.Operator)
+	c.line = expr.Operator.Line
+	c.emit(o.op, name...)
+	return nil
+}
+
+// element pushes the list or map and the key of an element that a compound
+// assignment or increment updates.
+func (c *compiler) element(element *expression.Index) {
+	if element.Optional {
+		c.fail("unsupported target for an update at line %d", element.Bracket.Line)
+	}
+	c.expr(element.Object)
+	c.expr(element.Key)
+	c.line = element.Bracket.Line
+}
+
+// target returns the name operand for the variable a compound assignment or
+// increment updates.
+func (c *compiler) target(target expression.Expr, operator token.Token) []byte {
+	variable, ok := target.(*expression.Variable)
+	if !ok {
+		c.fail("unsupported target for %q at line %d", operator.Lexeme, operator.Line)
+	}
+	return c.constant(variable.Name.Lexeme)
+}
+
+func (c *compiler) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	o, ok := lookupOperator(operators, expr.Operator.Type)
+	if !ok || o.op == OpAnd || o.op == OpOr || o.op == OpCoalesce {
+		c.fail("unsupported binary operator %q at line %d", expr.Operator.Lexeme, expr.Operator.Line)
+	}
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+	c.line = expr.Operator.Line
+	c.emit(o.op)
+	return nil
+}
+
+func (c *compiler) VisitCallExpr(expr *expression.Call) interface{} {
+	if len(expr.Arguments) > 0xff {
+		c.fail("too many arguments at line %d", expr.Paren.Line)
+	}
+	c.expr(expr.Callee)
+	end := c.optional(expr.Optional, expr.Paren.Line)
+	for _, argument := range expr.Arguments {
+		c.expr(argument)
+	}
+	c.line = expr.Paren.Line
+	c.emit(OpCall, byte(len(expr.Arguments)))
+	c.patchOptional(end)
+	return nil
+}
+
+// optional emits the OPTIONAL jump of a null-safe access after its object,
+// returning -1 for an ordinary access.
+func (c *compiler) optional(optional bool, line int) int {
+	if !optional {
+		return -1
+	}
+	c.line = line
+	return c.emitJump(OpOptional)
+}
+
+// patchOptional points the jump optional emitted past the access.
+func (c *compiler) patchOptional(offset int) {
+	if offset >= 0 {
+		c.patchJump(offset)
+	}
+}
+
+func (c *compiler) VisitAwaitExpr(expr *expression.Await) interface{} {
+	c.expr(expr.Value)
+	c.line = expr.Keyword.Line
+	c.emit(OpAwait)
+	return nil
+}
+
+func (c *compiler) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	c.expr(expr.Condition)
+	c.line = expr.Operator.Line
+	falseJump := c.emitJump(OpJumpIfFalse)
+	c.expr(expr.TrueExpression)
+	c.line = expr.Operator.Line
+	endJump := c.emitJump(OpElse)
+	c.patchJump(falseJump)
+	c.expr(expr.FalseExpression)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *compiler) VisitListExpr(expr *expression.List) interface{} {
+	if len(expr.Elements) > 0xffff {
+		c.fail("too many list elements at line %d", expr.Bracket.Line)
+	}
+	for _, element := range expr.Elements {
+		c.expr(element)
+	}
+	c.line = expr.Bracket.Line
+	c.emit(OpList, u16(len(expr.Elements))...)
+	return nil
+}
+
+// VisitMapExpr pushes each key followed by its value.
+func (c *compiler) VisitMapExpr(expr *expression.Map) interface{} {
+	if len(expr.Keys) > 0xffff {
+		c.fail("too many map entries at line %d", expr.Brace.Line)
+	}
+	for index, key := range expr.Keys {
+		c.expr(key)
+		c.expr(expr.Values[index])
+	}
+	c.line = expr.Brace.Line
+	c.emit(OpMap, u16(len(expr.Keys))...)
+	return nil
+}
+
+func (c *compiler) VisitGetExpr(expr *expression.Get) interface{} {
+	c.expr(expr.Object)
+	end := c.optional(expr.Optional, expr.Name.Line)
+	c.line = expr.Name.Line
+	c.emit(OpGetProperty, c.constant(expr.Name.Lexeme)...)
+	c.patchOptional(end)
+	return nil
+}
+
+func (c *compiler) VisitIndexExpr(expr *expression.Index) interface{} {
+	c.expr(expr.Object)
+	end := c.optional(expr.Optional, expr.Bracket.Line)
+	c.expr(expr.Key)
+	c.line = expr.Bracket.Line
+	c.emit(OpIndex)
+	c.patchOptional(end)
+	return nil
+}
+
+func (c *compiler) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	c.expr(expr.Object)
+	c.expr(expr.Key)
+	c.expr(expr.Value)
+	c.line = expr.Bracket.Line
+	c.emit(OpSetIndex)
+	return nil
+}
+
+func (c *compiler) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	c.expr(expr.Expr)
+	c.emit(OpGroup)
+	return nil
+}
+
+func (c *compiler) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	switch value := expr.Value.(type) {
+	case nil:
+		c.emit(OpNil)
+	case bool:
+		if value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case float64, string:
+		c.emit(OpConstant, c.constant(value)...)
+	default:
+		c.fail("cannot compile literal %v", value)
+	}
+	return nil
+}
+
+func (c *compiler) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	o, ok := lookupOperator(operators, expr.Operator.Type)
+	if !ok || (o.op != OpAnd && o.op != OpOr && o.op != OpCoalesce) {
+		c.fail("unsupported logical operator %q at line %d", expr.Operator.Lexeme, expr.Operator.Line)
+	}
+	c.expr(expr.Left)
+	c.line = expr.Operator.Line
+	end := c.emitJump(o.op)
+	c.expr(expr.Right)
+	c.patchJump(end)
+	return nil
+}
+
+func (c *compiler) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	o, ok := lookupOperator(unaryOperators, expr.Operator.Type)
+	if !ok {
+		c.fail("unsupported unary operator %q at line %d", expr.Operator.Lexeme, expr.Operator.Line)
+	}
+	c.expr(expr.Right)
+	c.line = expr.Operator.Line
+	c.emit(o.op)
+	return nil
+}
+
+func (c *compiler) VisitVariableExpr(expr *expression.Variable) interface{} {
+	c.line = expr.Name.Line
+	c.emit(OpGet, c.constant(expr.Name.Lexeme)...)
+	return nil
+}
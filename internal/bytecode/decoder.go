@@ -0,0 +1,673 @@
+package bytecode
+
+import (
+	"fmt"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// decodeError aborts decoding of malformed code.
+type decodeError struct {
+	err error
+}
+
+// Statements rebuilds the syntax tree a script chunk was compiled from, so
+// that the interpreter can run it without scanning or parsing the source.
+// Type annotations are not compiled, so the tree has none.
+func (c *Chunk) Statements() (statements []expression.Stmt, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			de, ok := r.(decodeError)
+			if !ok {
+				panic(r)
+			}
+			statements, err = nil, de.err
+		}
+	}()
+	return decodeBody(c), nil
+}
+
+func decodeBody(c *Chunk) []expression.Stmt {
+	d := &decoder{chunk: c}
+	r := d.decode(0, len(c.Code))
+	if r.terminator != nil || len(r.exprs) != 0 {
+		d.fail(len(c.Code), "unexpected end of code")
+	}
+	return r.stmts
+}
+
+type decoder struct {
+	chunk *Chunk
+}
+
+// region is what decoding a range of code produced: statements, any
+// expressions left on the stack, and the jump or END_SCOPE that ended it
+// early, if any.
+type region struct {
+	stmts      []expression.Stmt
+	exprs      []expression.Expr
+	terminator *OpCode
+	end        int
+}
+
+func (d *decoder) fail(offset int, format string, args ...interface{}) {
+	panic(decodeError{fmt.Errorf("malformed bytecode in %s at offset %04d: %s", d.chunk.describe(), offset, fmt.Sprintf(format, args...))})
+}
+
+func (d *decoder) constant(offset int) interface{} {
+	index := d.chunk.u16(offset)
+	if index >= len(d.chunk.Constants) {
+		d.fail(offset, "constant %d out of range", index)
+	}
+	return d.chunk.Constants[index]
+}
+
+func (d *decoder) name(offset int) string {
+	name, ok := d.constant(offset).(string)
+	if !ok {
+		d.fail(offset, "expected a name constant")
+	}
+	return name
+}
+
+func (d *decoder) nestedChunk(offset int, kind ChunkKind) *Chunk {
+	chunk, ok := d.constant(offset).(*Chunk)
+	if !ok || chunk.Kind != kind {
+		d.fail(offset, "expected a nested chunk")
+	}
+	return chunk
+}
+
+// function decodes the nested chunk of a function or lambda instruction,
+// which may be async.
+func (d *decoder) function(pc, line int) *expression.Function {
+	chunk, ok := d.constant(pc + 1).(*Chunk)
+	if !ok || chunk.Kind != FunctionChunk && chunk.Kind != AsyncFunctionChunk {
+		d.fail(pc+1, "expected a nested chunk")
+	}
+	params := make([]token.Token, len(chunk.Params))
+	for i, param := range chunk.Params {
+		params[i] = token.Token{Type: token.IDENTIFIER, Lexeme: param, Line: chunk.Line}
+	}
+	name := token.Token{Type: token.IDENTIFIER, Lexeme: chunk.Name, Line: chunk.Line}
+	return expression.NewFunction(name, params, nil, nil, decodeBody(chunk), chunk.Kind == AsyncFunctionChunk, line)
+}
+
+// single decodes the code of one statement or expression.
+func (d *decoder) single(from, to int, wantExpr bool) region {
+	r := d.decode(from, to)
+	if wantExpr && (len(r.stmts) != 0 || len(r.exprs) != 1) {
+		d.fail(from, "expected one expression")
+	}
+	if !wantExpr && (len(r.stmts) != 1 || len(r.exprs) != 0) {
+		d.fail(from, "expected one statement")
+	}
+	return r
+}
+
+// decode decodes the code from from up to to. The code may consume values
+// already on the stack, which start as stack.
+func (d *decoder) decode(from, to int, stack ...expression.Expr) region {
+	r := region{exprs: stack}
+	pop := func(offset int) expression.Expr {
+		if len(r.exprs) == 0 {
+			d.fail(offset, "stack underflow")
+		}
+		expr := r.exprs[len(r.exprs)-1]
+		r.exprs = r.exprs[:len(r.exprs)-1]
+		return expr
+	}
+	push := func(expr expression.Expr) {
+		r.exprs = append(r.exprs, expr)
+	}
+	statement := func(offset int, stmt expression.Stmt) {
+		if len(r.exprs) != 0 {
+			d.fail(offset, "statement with values left on the stack")
+		}
+		r.stmts = append(r.stmts, stmt)
+	}
+
+	for pc := from; pc < to; {
+		op := OpCode(d.chunk.Code[pc])
+		if op.String() == "UNKNOWN" {
+			d.fail(pc, "unknown opcode %d", op)
+		}
+		next := pc + op.Size()
+		if next > to {
+			d.fail(pc, "truncated instruction")
+		}
+		line := d.chunk.LineAt(pc)
+		tok := func(typ token.TokenType, lexeme string, literal interface{}) token.Token {
+			return token.Token{Type: typ, Lexeme: lexeme, Literal: literal, Line: line}
+		}
+
+		switch op {
+		case OpConstant:
+			value := d.constant(pc + 1)
+			if _, ok := value.(*Chunk); ok {
+				d.fail(pc, "chunk used as a value")
+			}
+			push(expression.NewLiteral(value))
+		case OpNil:
+			push(expression.NewLiteral(nil))
+		case OpTrue:
+			push(expression.NewLiteral(true))
+		case OpFalse:
+			push(expression.NewLiteral(false))
+		case OpGet:
+			push(expression.NewVariable(tok(token.IDENTIFIER, d.name(pc+1), nil)))
+		case OpSet:
+			value := pop(pc)
+			push(expression.NewAssign(tok(token.IDENTIFIER, d.name(pc+1), nil), value))
+		case OpAddSet, OpSubtractSet, OpMultiplySet, OpDivideSet, OpModuloSet:
+			o, _ := lookupOpCode(compoundOperators, op)
+			target := expression.NewVariable(tok(token.IDENTIFIER, d.name(pc+1), nil))
+			push(expression.NewCompound(target, tok(o.typ, o.lexeme, nil), pop(pc)))
+		case OpIncrement, OpDecrement:
+			o, _ := lookupOpCode(prefixOperators, op)
+			target := expression.NewVariable(tok(token.IDENTIFIER, d.name(pc+1), nil))
+			push(expression.NewIncrement(target, tok(o.typ, o.lexeme, nil), true))
+		case OpPostIncrement, OpPostDecrement:
+			o, _ := lookupOpCode(postfixOperators, op)
+			target := expression.NewVariable(tok(token.IDENTIFIER, d.name(pc+1), nil))
+			push(expression.NewIncrement(target, tok(o.typ, o.lexeme, nil), false))
+		case OpIndexUpdate:
+			target := func() *expression.Index {
+				key := pop(pc)
+				return expression.NewIndex(pop(pc), tok(token.LEFT_BRACKET, "[", nil), key, false)
+			}
+			update := OpCode(d.chunk.u8(pc + 1))
+			if o, ok := lookupOpCode(compoundOperators, update); ok {
+				value := pop(pc)
+				push(expression.NewCompound(target(), tok(o.typ, o.lexeme, nil), value))
+			} else if o, ok := lookupOpCode(prefixOperators, update); ok {
+				push(expression.NewIncrement(target(), tok(o.typ, o.lexeme, nil), true))
+			} else if o, ok := lookupOpCode(postfixOperators, update); ok {
+				push(expression.NewIncrement(target(), tok(o.typ, o.lexeme, nil), false))
+			} else {
+				d.fail(pc, "cannot update an element as %s", update)
+			}
+		case OpCoalesceIndex:
+			end := d.target(pc, to)
+			value := d.single(next, end, true).exprs[0]
+			key := pop(pc)
+			target := expression.NewIndex(pop(pc), tok(token.LEFT_BRACKET, "[", nil), key, false)
+			push(expression.NewCompound(target, tok(token.QUESTION_QUESTION_EQUAL, "??=", nil), value))
+			next = end
+		case OpGetProperty:
+			object := pop(pc)
+			push(expression.NewGet(object, tok(token.IDENTIFIER, d.name(pc+1), nil), false))
+		case OpSetIndex:
+			value := pop(pc)
+			key := pop(pc)
+			push(expression.NewSetIndex(pop(pc), tok(token.LEFT_BRACKET, "[", nil), key, value))
+		case OpIndex:
+			key := pop(pc)
+			object := pop(pc)
+			push(expression.NewIndex(object, tok(token.LEFT_BRACKET, "[", nil), key, false))
+		case OpOptional:
+			end := d.target(pc, to)
+			push(d.optional(pc, next, end, pop(pc)))
+			next = end
+		case OpCoalesceSet:
+			end := d.target(pc, to)
+			name := d.name(pc + 3)
+			assign, ok := d.single(next, end, true).exprs[0].(*expression.Assign)
+			if !ok || assign.Name.Lexeme != name {
+				d.fail(pc, "expected an assignment to '%s'", name)
+			}
+			target := expression.NewVariable(tok(token.IDENTIFIER, name, nil))
+			push(expression.NewCompound(target, tok(token.QUESTION_QUESTION_EQUAL, "??=", nil), assign.Value))
+			next = end
+		case OpDefinePattern:
+			value := pop(pc)
+			var pattern expression.Target
+			pattern, next = d.destructuringTarget(next, to)
+			statement(pc, expression.NewVarDestructure(pattern, value, line))
+		case OpSetPattern:
+			value := pop(pc)
+			var pattern expression.Target
+			pattern, next = d.destructuringTarget(next, to)
+			push(expression.NewDestructure(pattern, tok(token.EQUAL, "=", nil), value))
+		case OpNameTarget, OpListTarget, OpMapTarget, OpKey:
+			d.fail(pc, "unexpected %s", op)
+		case OpGroup:
+			push(expression.NewGrouping(pop(pc)))
+		case OpAwait:
+			push(expression.NewAwait(tok(token.AWAIT, "await", nil), pop(pc)))
+		case OpList:
+			elements := d.values(pc, d.chunk.u16(pc+1), &r)
+			push(expression.NewList(tok(token.LEFT_BRACKET, "[", nil), elements))
+		case OpMap:
+			entries := d.values(pc, 2*d.chunk.u16(pc+1), &r)
+			var keys, values []expression.Expr
+			for index := 0; index < len(entries); index += 2 {
+				keys, values = append(keys, entries[index]), append(values, entries[index+1])
+			}
+			push(expression.NewMap(tok(token.LEFT_BRACE, "{", nil), keys, values))
+		case OpAdd, OpSubtract, OpMultiply, OpDivide, OpModulo, OpPower,
+			OpBitAnd, OpBitOr, OpBitXor, OpShiftLeft, OpShiftRight, OpGreater, OpGreaterEqual,
+			OpLess, OpLessEqual, OpEqual, OpNotEqual, OpComma:
+			o, _ := lookupOpCode(operators, op)
+			right := pop(pc)
+			left := pop(pc)
+			push(expression.NewBinary(left, tok(o.typ, o.lexeme, nil), right))
+		case OpNegate, OpNot, OpBitNot:
+			o, _ := lookupOpCode(unaryOperators, op)
+			push(expression.NewUnary(tok(o.typ, o.lexeme, nil), pop(pc)))
+		case OpAnd, OpOr, OpCoalesce:
+			o, _ := lookupOpCode(operators, op)
+			left := pop(pc)
+			end := d.target(pc, to)
+			right := d.single(next, end, true).exprs[0]
+			push(expression.NewLogical(left, tok(o.typ, o.lexeme, nil), right))
+			next = end
+		case OpCall:
+			args := d.values(pc, d.chunk.u8(pc+1), &r)
+			callee := pop(pc)
+			push(expression.NewCall(callee, tok(token.RIGHT_PAREN, ")", nil), args, false))
+		case OpPrint:
+			value := pop(pc)
+			statement(pc, expression.NewPrint(value, line))
+		case OpPop:
+			value := pop(pc)
+			statement(pc, expression.NewExpression(value, line))
+		case OpDefine:
+			value := pop(pc)
+			statement(pc, expression.NewVar(tok(token.IDENTIFIER, d.name(pc+1), nil), nil, value, line))
+		case OpDeclare:
+			statement(pc, expression.NewVar(tok(token.IDENTIFIER, d.name(pc+1), nil), nil, nil, line))
+		case OpBeginScope:
+			body := d.decode(next, to)
+			if body.terminator == nil || *body.terminator != OpEndScope || len(body.exprs) != 0 {
+				d.fail(pc, "unterminated scope")
+			}
+			statement(pc, expression.NewBlock(body.stmts, line))
+			next = body.end
+		case OpEndScope:
+			r.terminator, r.end = &op, next
+			return r
+		case OpJumpIfFalse:
+			condition := pop(pc)
+			next = d.branch(pc, next, d.target(pc, to), to, line, condition, &r)
+		case OpElse, OpLoop:
+			if next != to {
+				d.fail(pc, "unexpected %s", op)
+			}
+			r.terminator, r.end = &op, pc
+			return r
+		case OpMatch:
+			subject := pop(pc)
+			var match expression.Stmt
+			match, next = d.match(next, to, line, subject)
+			statement(pc, match)
+		case OpGuard, OpEndCase:
+			r.terminator, r.end = &op, pc
+			return r
+		case OpMatchValue, OpMatchAny, OpMatchBind, OpMatchTarget, OpCase, OpEndMatch:
+			d.fail(pc, "unexpected %s", op)
+		case OpIterate:
+			iterable := pop(pc)
+			var loop expression.Stmt
+			loop, next = d.forIn(next, to, line, iterable)
+			statement(pc, loop)
+		case OpForNext:
+			d.fail(pc, "unexpected %s", op)
+		case OpFunction:
+			statement(pc, d.function(pc, line))
+		case OpLambda:
+			push(expression.NewLambda(tok(token.FUN, "fun", nil), d.function(pc, line)))
+		case OpReturn:
+			value := pop(pc)
+			statement(pc, expression.NewReturn(tok(token.RETURN, "return", nil), value, line))
+		case OpReturnNil:
+			statement(pc, expression.NewReturn(tok(token.RETURN, "return", nil), nil, line))
+		case OpYield:
+			value := pop(pc)
+			statement(pc, expression.NewYield(tok(token.YIELD, "yield", nil), value, line))
+		case OpYieldNil:
+			statement(pc, expression.NewYield(tok(token.YIELD, "yield", nil), nil, line))
+		case OpSpawn:
+			args := d.values(pc, d.chunk.u8(pc+1), &r)
+			callee := pop(pc)
+			call := expression.NewCall(callee, tok(token.RIGHT_PAREN, ")", nil), args, false)
+			statement(pc, expression.NewSpawn(tok(token.SPAWN, "spawn", nil), call, line))
+		case OpSelect:
+			var sel expression.Stmt
+			sel, next = d.selectCases(next, to, line)
+			statement(pc, sel)
+		case OpRecvCase, OpSendCase, OpDefaultCase:
+			r.terminator, r.end = &op, pc
+			return r
+		case OpEndSelect:
+			d.fail(pc, "unexpected %s", op)
+		case OpTest:
+			chunk := d.nestedChunk(pc+1, TestChunk)
+			name := token.Token{Type: token.STRING, Lexeme: `"` + chunk.Name + `"`, Literal: chunk.Name, Line: chunk.Line}
+			statement(pc, expression.NewTest(name, decodeBody(chunk), line))
+		case OpImport:
+			path := d.name(pc + 1)
+			statement(pc, expression.NewImport(
+				tok(token.IMPORT, "import", nil),
+				tok(token.STRING, `"`+path+`"`, path),
+				tok(token.IDENTIFIER, d.name(pc+3), nil),
+				line))
+		}
+		pc = next
+	}
+	r.end = to
+	return r
+}
+
+// values pops the top count expressions of r, in the order they were pushed.
+func (d *decoder) values(pc, count int, r *region) []expression.Expr {
+	if len(r.exprs) < count {
+		d.fail(pc, "stack underflow")
+	}
+	values := append([]expression.Expr(nil), r.exprs[len(r.exprs)-count:]...)
+	r.exprs = r.exprs[:len(r.exprs)-count]
+	return values
+}
+
+// forIn decodes the FOR_NEXT instruction at pc that follows an ITERATE, and
+// the loop body after it, returning the statement and the offset after the
+// loop.
+func (d *decoder) forIn(pc, limit, line int, iterable expression.Expr) (expression.Stmt, int) {
+	if pc+OpForNext.Size() > limit || OpCode(d.chunk.Code[pc]) != OpForNext {
+		d.fail(pc, "expected %s", OpForNext)
+	}
+	exit := d.target(pc, limit)
+	body := d.decode(pc+OpForNext.Size(), exit)
+	if body.terminator == nil || *body.terminator != OpLoop || d.chunk.JumpTarget(body.end) != pc ||
+		len(body.stmts) != 1 || len(body.exprs) != 0 {
+		d.fail(pc, "expected one statement in loop body")
+	}
+	keyword := token.Token{Type: token.FOR, Lexeme: "for", Line: line}
+	name := token.Token{Type: token.IDENTIFIER, Lexeme: d.name(pc + 3), Line: line}
+	return expression.NewForIn(keyword, name, iterable, body.stmts[0], line), exit
+}
+
+// match decodes the cases that follow a MATCH instruction at pc and returns
+// the statement and the offset after its END_MATCH.
+func (d *decoder) match(pc, limit, line int, subject expression.Expr) (expression.Stmt, int) {
+	keyword := token.Token{Type: token.MATCH, Lexeme: "match", Line: line}
+	var cases []*expression.MatchCase
+	end := -1
+	for pc < limit {
+		if OpCode(d.chunk.Code[pc]) == OpEndMatch {
+			if end >= 0 && end != pc {
+				d.fail(pc, "case body jumps past its match")
+			}
+			return expression.NewMatch(keyword, subject, cases, line), pc + 1
+		}
+		mc := &expression.MatchCase{}
+		pc = d.patterns(pc, limit, mc)
+		mc.Keyword = token.Token{Type: token.CASE, Lexeme: "case", Line: d.chunk.LineAt(pc)}
+		nextCase := d.target(pc, limit)
+		body := d.decode(pc+OpCase.Size(), nextCase)
+		if body.terminator != nil && *body.terminator == OpGuard {
+			if len(body.stmts) != 0 || len(body.exprs) != 1 || d.target(body.end, limit) != nextCase {
+				d.fail(pc, "malformed guard")
+			}
+			mc.Guard = body.exprs[0]
+			body = d.decode(body.end+OpGuard.Size(), nextCase)
+		}
+		if body.terminator == nil || *body.terminator != OpEndCase || body.end+OpEndCase.Size() != nextCase ||
+			len(body.stmts) != 1 || len(body.exprs) != 0 {
+			d.fail(pc, "expected one statement in case body")
+		}
+		exit := d.target(body.end, limit)
+		if end >= 0 && exit != end {
+			d.fail(body.end, "case bodies leave the match at different offsets")
+		}
+		end = exit
+		mc.Body = body.stmts[0]
+		cases = append(cases, mc)
+		pc = nextCase
+	}
+	d.fail(pc, "unterminated match")
+	return nil, 0
+}
+
+// selectCases decodes the cases that follow a SELECT instruction at pc and
+// returns the statement and the offset after its END_SELECT.
+func (d *decoder) selectCases(pc, limit, line int) (expression.Stmt, int) {
+	keyword := token.Token{Type: token.SELECT, Lexeme: "select", Line: line}
+	var cases []*expression.SelectCase
+	end := -1
+	for pc < limit {
+		if OpCode(d.chunk.Code[pc]) == OpEndSelect {
+			if end >= 0 && end != pc {
+				d.fail(pc, "case body jumps past its select")
+			}
+			return expression.NewSelect(keyword, cases, line), pc + 1
+		}
+		operands := d.decode(pc, limit)
+		if operands.terminator == nil || len(operands.stmts) != 0 {
+			d.fail(pc, "expected a select case")
+		}
+		op, at := *operands.terminator, operands.end
+		caseLine := d.chunk.LineAt(at)
+		sc := &expression.SelectCase{}
+		operation := func(lexeme string, count int) {
+			if len(operands.exprs) != count {
+				d.fail(at, "expected %d operands for %s", count, op)
+			}
+			sc.Operation = token.Token{Type: token.IDENTIFIER, Lexeme: lexeme, Line: caseLine}
+		}
+		switch op {
+		case OpRecvCase:
+			operation("recv", 1)
+			sc.Channel = operands.exprs[0]
+			if name := d.name(at + 1); name != "" {
+				sc.Name = &token.Token{Type: token.IDENTIFIER, Lexeme: name, Line: caseLine}
+			}
+		case OpSendCase:
+			operation("send", 2)
+			sc.Channel, sc.Value = operands.exprs[0], operands.exprs[1]
+		case OpDefaultCase:
+			operation("_", 0)
+		default:
+			d.fail(at, "expected a select case")
+		}
+		start := at + op.Size()
+		body := d.decode(start, limit)
+		if body.terminator == nil || *body.terminator != OpEndCase || len(body.stmts) != 1 || len(body.exprs) != 0 {
+			d.fail(start, "expected one statement in case body")
+		}
+		sc.Keyword = token.Token{Type: token.CASE, Lexeme: "case", Line: d.chunk.LineAt(body.end)}
+		exit := d.target(body.end, limit)
+		if end >= 0 && exit != end {
+			d.fail(body.end, "case bodies leave the select at different offsets")
+		}
+		end = exit
+		sc.Body = body.stmts[0]
+		cases = append(cases, sc)
+		pc = body.end + OpEndCase.Size()
+	}
+	d.fail(pc, "unterminated select")
+	return nil, 0
+}
+
+// patterns decodes the patterns of a match case into mc, returning the
+// offset of the CASE instruction that follows them.
+func (d *decoder) patterns(pc, limit int, mc *expression.MatchCase) int {
+	for pc < limit {
+		op := OpCode(d.chunk.Code[pc])
+		next := pc + op.Size()
+		if next > limit {
+			d.fail(pc, "truncated instruction")
+		}
+		line := d.chunk.LineAt(pc)
+		switch op {
+		case OpCase:
+			if len(mc.Patterns) == 0 {
+				d.fail(pc, "case without patterns")
+			}
+			return pc
+		case OpMatchAny:
+			mc.Patterns = append(mc.Patterns, &expression.WildcardPattern{Token: token.Token{Type: token.IDENTIFIER, Lexeme: "_", Line: line}})
+		case OpMatchBind:
+			name := token.Token{Type: token.IDENTIFIER, Lexeme: d.name(pc + 1), Line: line}
+			mc.Patterns = append(mc.Patterns, &expression.BindingPattern{Name: name})
+		case OpMatchTarget:
+			var target expression.Target
+			target, next = d.destructuringTarget(next, limit)
+			mc.Patterns = append(mc.Patterns, &expression.DestructurePattern{Target: target})
+		case OpConstant, OpNil, OpTrue, OpFalse:
+			literal := d.single(pc, next, true).exprs[0].(*expression.Literal)
+			if next >= limit || OpCode(d.chunk.Code[next]) != OpMatchValue {
+				d.fail(next, "expected %s", OpMatchValue)
+			}
+			next++
+			typ := map[OpCode]token.TokenType{OpConstant: token.NUMBER, OpNil: token.NIL, OpTrue: token.TRUE, OpFalse: token.FALSE}[op]
+			if _, ok := literal.Value.(string); ok {
+				typ = token.STRING
+			}
+			value := token.Token{Type: typ, Literal: literal.Value, Line: line}
+			mc.Patterns = append(mc.Patterns, &expression.LiteralPattern{Token: value, Value: literal.Value})
+		default:
+			d.fail(pc, "unexpected %s in case patterns", op)
+		}
+		pc = next
+	}
+	d.fail(pc, "unterminated match case")
+	return 0
+}
+
+// destructuringTarget decodes the target written out from pc and returns it
+// with the offset after it.
+func (d *decoder) destructuringTarget(pc, limit int) (expression.Target, int) {
+	if pc >= limit {
+		d.fail(pc, "expected a destructuring target")
+	}
+	op := OpCode(d.chunk.Code[pc])
+	next := pc + op.Size()
+	if next > limit {
+		d.fail(pc, "truncated instruction")
+	}
+	line := d.chunk.LineAt(pc)
+	switch op {
+	case OpNameTarget:
+		return &expression.NameTarget{Name: token.Token{Type: token.IDENTIFIER, Lexeme: d.name(pc + 1), Line: line}}, next
+	case OpListTarget:
+		t := &expression.ListTarget{Bracket: token.Token{Type: token.LEFT_BRACKET, Lexeme: "[", Line: line}}
+		for count := d.chunk.u16(pc + 1); len(t.Elements) < count; {
+			var element expression.Target
+			element, next = d.destructuringTarget(next, limit)
+			t.Elements = append(t.Elements, element)
+		}
+		if d.chunk.u8(pc+3) != 0 {
+			var rest expression.Target
+			rest, next = d.destructuringTarget(next, limit)
+			name, ok := rest.(*expression.NameTarget)
+			if !ok {
+				d.fail(pc, "expected a name for the rest")
+			}
+			t.Rest = &name.Name
+		}
+		return t, next
+	case OpMapTarget:
+		t := &expression.MapTarget{Brace: token.Token{Type: token.LEFT_BRACE, Lexeme: "{", Line: line}}
+		for count := d.chunk.u16(pc + 1); len(t.Keys) < count; {
+			if next+OpKey.Size() > limit || OpCode(d.chunk.Code[next]) != OpKey {
+				d.fail(next, "expected %s", OpKey)
+			}
+			key, ok := d.constant(next + 1).(string)
+			if !ok {
+				d.fail(next, "expected a string key")
+			}
+			t.Keys = append(t.Keys, token.Token{Type: token.STRING, Lexeme: `"` + key + `"`, Literal: key, Line: d.chunk.LineAt(next)})
+			var value expression.Target
+			value, next = d.destructuringTarget(next+OpKey.Size(), limit)
+			t.Values = append(t.Values, value)
+		}
+		return t, next
+	}
+	d.fail(pc, "expected a destructuring target, not %s", op)
+	return nil, 0
+}
+
+// optional decodes the access an OPTIONAL at offset jumps over, from start
+// to end, on object and marks it null-safe.
+func (d *decoder) optional(offset, start, end int, object expression.Expr) expression.Expr {
+	r := d.decode(start, end, object)
+	if r.terminator == nil && len(r.stmts) == 0 && len(r.exprs) == 1 {
+		switch access := r.exprs[0].(type) {
+		case *expression.Get:
+			if access.Object == object {
+				access.Optional = true
+				return access
+			}
+		case *expression.Index:
+			if access.Object == object {
+				access.Optional = true
+				return access
+			}
+		case *expression.Call:
+			if access.Callee == object {
+				access.Optional = true
+				return access
+			}
+		}
+	}
+	d.fail(offset, "expected a property, index or call")
+	return nil
+}
+
+// target returns where a forward jump at offset lands, which must lie within
+// the code being decoded.
+func (d *decoder) target(offset, limit int) int {
+	target := d.chunk.JumpTarget(offset)
+	if target > limit {
+		d.fail(offset, "jump past the end of its code")
+	}
+	return target
+}
+
+// branch decodes the code guarded by a JUMP_IF_FALSE: a while loop when it
+// ends by looping back, an if statement or a ternary expression otherwise.
+// It returns the offset after the whole construct.
+func (d *decoder) branch(offset, start, target, limit, line int, condition expression.Expr, r *region) int {
+	then := d.decode(start, target)
+	ended := func(op OpCode) bool {
+		return then.terminator != nil && *then.terminator == op
+	}
+	statement := func(stmt expression.Stmt) {
+		if len(r.exprs) != 0 {
+			d.fail(offset, "statement with values left on the stack")
+		}
+		r.stmts = append(r.stmts, stmt)
+	}
+
+	switch {
+	case ended(OpLoop):
+		if len(then.stmts) != 1 || len(then.exprs) != 0 {
+			d.fail(offset, "expected one statement in loop body")
+		}
+		statement(expression.NewWhile(condition, then.stmts[0], line))
+		return target
+	case ended(OpElse):
+		end := d.target(then.end, limit)
+		if len(then.exprs) == 1 && len(then.stmts) == 0 {
+			otherwise := d.single(target, end, true)
+			operator := token.Token{Type: token.QUESTION_MARK, Lexeme: "?", Line: line}
+			r.exprs = append(r.exprs, expression.NewTernary(condition, operator, then.exprs[0], otherwise.exprs[0]))
+			return end
+		}
+		if len(then.stmts) != 1 || len(then.exprs) != 0 {
+			d.fail(offset, "expected one statement in then branch")
+		}
+		otherwise := d.single(target, end, false)
+		statement(expression.NewIf(condition, then.stmts[0], otherwise.stmts[0], line))
+		return end
+	case then.terminator != nil:
+		d.fail(offset, "unexpected %s", *then.terminator)
+	}
+	if len(then.stmts) != 1 || len(then.exprs) != 0 {
+		d.fail(offset, "expected one statement in then branch")
+	}
+	statement(expression.NewIf(condition, then.stmts[0], nil, line))
+	return target
+}
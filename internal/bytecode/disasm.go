@@ -0,0 +1,122 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Disassemble writes a listing of chunk followed by the chunks nested in its
+// constant pool, depth first. Each instruction shows its offset, its source
+// line ("|" when unchanged), its opcode and its operands, with constants
+// resolved and jumps shown as "from -> to". Numbers are printed bare, strings
+// in double quotes and variable names in single quotes.
+func Disassemble(w io.Writer, chunk *Chunk) error {
+	var b strings.Builder
+	disassemble(&b, chunk)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func disassemble(b *strings.Builder, chunk *Chunk) {
+	fmt.Fprintf(b, "== %s ==\n", chunkTitle(chunk))
+	for offset := 0; offset < len(chunk.Code); {
+		offset = DisassembleInstruction(b, chunk, offset)
+	}
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.(*Chunk); ok {
+			b.WriteString("\n")
+			disassemble(b, nested)
+		}
+	}
+}
+
+func chunkTitle(chunk *Chunk) string {
+	switch chunk.Kind {
+	case FunctionChunk:
+		return fmt.Sprintf("fun %s(%s) line %d", chunk.Name, strings.Join(chunk.Params, ", "), chunk.Line)
+	case AsyncFunctionChunk:
+		return fmt.Sprintf("async fun %s(%s) line %d", chunk.Name, strings.Join(chunk.Params, ", "), chunk.Line)
+	case TestChunk:
+		return fmt.Sprintf("test %q line %d", chunk.Name, chunk.Line)
+	}
+	return chunk.Name
+}
+
+// DisassembleInstruction writes the instruction at offset and returns the
+// offset of the next one.
+func DisassembleInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	line := chunk.LineAt(offset)
+	if offset > 0 && line == chunk.LineAt(offset-1) {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", line)
+	}
+
+	op := OpCode(chunk.Code[offset])
+	next := offset + op.Size()
+	if op.String() == "UNKNOWN" || next > len(chunk.Code) {
+		fmt.Fprintf(w, "%s 0x%02x\n", op, byte(op))
+		return offset + 1
+	}
+
+	switch op {
+	case OpConstant, OpFunction, OpLambda, OpTest, OpKey:
+		index := chunk.u16(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d %s\n", op, index, describeConstant(chunk, index))
+	case OpGet, OpSet, OpGetProperty, OpDefine, OpDeclare,
+		OpAddSet, OpSubtractSet, OpMultiplySet, OpDivideSet, OpModuloSet,
+		OpIncrement, OpDecrement, OpPostIncrement, OpPostDecrement, OpMatchBind, OpRecvCase, OpNameTarget:
+		index := chunk.u16(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d %s\n", op, index, describeName(chunk, index))
+	case OpImport:
+		path, name := chunk.u16(offset+1), chunk.u16(offset+3)
+		fmt.Fprintf(w, "%-16s %4d %s as %d %s\n", op, path, describeConstant(chunk, path), name, describeName(chunk, name))
+	case OpCall, OpSpawn:
+		fmt.Fprintf(w, "%-16s %4d\n", op, chunk.u8(offset+1))
+	case OpIndexUpdate:
+		fmt.Fprintf(w, "%-16s %s\n", op, OpCode(chunk.u8(offset+1)))
+	case OpList, OpMap, OpMapTarget:
+		fmt.Fprintf(w, "%-16s %4d\n", op, chunk.u16(offset+1))
+	case OpListTarget:
+		rest := ""
+		if chunk.u8(offset+3) != 0 {
+			rest = " ...rest"
+		}
+		fmt.Fprintf(w, "%-16s %4d%s\n", op, chunk.u16(offset+1), rest)
+	case OpForNext, OpCoalesceSet:
+		name := chunk.u16(offset + 3)
+		fmt.Fprintf(w, "%-16s %04d -> %04d %d %s\n", op, offset, chunk.JumpTarget(offset), name, describeName(chunk, name))
+	case OpAnd, OpOr, OpCoalesce, OpCoalesceIndex, OpOptional, OpJumpIfFalse, OpElse, OpLoop, OpCase, OpGuard, OpEndCase:
+		fmt.Fprintf(w, "%-16s %04d -> %04d\n", op, offset, chunk.JumpTarget(offset))
+	default:
+		fmt.Fprintf(w, "%s\n", op)
+	}
+	return next
+}
+
+func describeConstant(chunk *Chunk, index int) string {
+	if index >= len(chunk.Constants) {
+		return "<invalid>"
+	}
+	switch value := chunk.Constants[index].(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case string:
+		return strconv.Quote(value)
+	case *Chunk:
+		return "<" + chunkTitle(value) + ">"
+	}
+	return "<invalid>"
+}
+
+func describeName(chunk *Chunk, index int) string {
+	if index < len(chunk.Constants) {
+		if name, ok := chunk.Constants[index].(string); ok {
+			return "'" + name + "'"
+		}
+	}
+	return "<invalid>"
+}
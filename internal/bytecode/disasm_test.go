@@ -0,0 +1,70 @@
+package bytecode
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden .disasm files")
+
+// TestDisassembleGolden compares the listing of each testdata script with
+// the .disasm file beside it.
+func TestDisassembleGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no testdata found: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, ok := parse(t, string(source))
+			if !ok {
+				t.Fatalf("cannot parse %s", path)
+			}
+			chunk, err := Compile(statements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := Disassemble(&got, chunk); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(path, ".lox") + ".disasm"
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("Disassemble() =\n%s\nwant\n%s", got.String(), want)
+			}
+		})
+	}
+}
+
+func TestDisassembleInstructionMalformed(t *testing.T) {
+	chunk := &Chunk{Code: []byte{0xff, byte(OpConstant), 0, 7, byte(OpLoop)}}
+	var got bytes.Buffer
+	for offset := 0; offset < len(chunk.Code); {
+		offset = DisassembleInstruction(&got, chunk, offset)
+	}
+	want := "0000    0 UNKNOWN 0xff\n" +
+		"0001    | CONSTANT            7 <invalid>\n" +
+		"0004    | LOOP 0x31\n"
+	if got.String() != want {
+		t.Errorf("DisassembleInstruction() =\n%s\nwant\n%s", got.String(), want)
+	}
+}
@@ -0,0 +1,235 @@
+package bytecode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// FormatVersion is bumped whenever the instruction set or the file layout
+// changes, so that older .loxc files are rejected rather than misread.
+const FormatVersion = 14
+
+// Extension is the file extension of compiled scripts.
+const Extension = ".loxc"
+
+var magic = []byte("LOXC")
+
+// ErrNotCompiled is returned when a file does not start with the magic bytes.
+var ErrNotCompiled = errors.New("not a compiled Lox file")
+
+// VersionError is returned for a file written in another format version.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("compiled with format version %d, but this interpreter reads version %d; recompile it", e.Version, FormatVersion)
+}
+
+// File is the contents of a .loxc file: the compiled script and the source
+// it was compiled from, identified by path and SHA-256 hash.
+type File struct {
+	Version    int
+	Source     string
+	SourceHash [sha256.Size]byte
+	Script     *Chunk
+}
+
+// NewFile wraps script, compiled from source, which was read from sourcePath.
+func NewFile(source []byte, sourcePath string, script *Chunk) *File {
+	return &File{
+		Version:    FormatVersion,
+		Source:     sourcePath,
+		SourceHash: sha256.Sum256(source),
+		Script:     script,
+	}
+}
+
+// Fresh reports whether the file was compiled from source.
+func (f *File) Fresh(source []byte) bool {
+	return f.SourceHash == sha256.Sum256(source)
+}
+
+// Encode writes f in the .loxc format: the magic bytes, a big-endian uint16
+// format version, the source hash and path, and then the script chunk.
+// Integers after the header are unsigned varints.
+func (f *File) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, FormatVersion))
+	buf.Write(f.SourceHash[:])
+	writeString(&buf, f.Source)
+	writeChunk(&buf, f.Script)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// constant tags in the encoded constant pool.
+const (
+	tagNumber byte = iota
+	tagString
+	tagChunk
+)
+
+func writeUvarint(buf *bytes.Buffer, n int) {
+	buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, len(s))
+	buf.WriteString(s)
+}
+
+func writeChunk(buf *bytes.Buffer, c *Chunk) {
+	buf.WriteByte(byte(c.Kind))
+	writeString(buf, c.Name)
+	writeUvarint(buf, c.Line)
+	writeUvarint(buf, len(c.Params))
+	for _, param := range c.Params {
+		writeString(buf, param)
+	}
+	writeUvarint(buf, len(c.Code))
+	buf.Write(c.Code)
+	writeUvarint(buf, len(c.Lines))
+	for _, start := range c.Lines {
+		writeUvarint(buf, start.Offset)
+		writeUvarint(buf, start.Line)
+	}
+	writeUvarint(buf, len(c.Constants))
+	for _, constant := range c.Constants {
+		switch value := constant.(type) {
+		case float64:
+			buf.WriteByte(tagNumber)
+			buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(value)))
+		case string:
+			buf.WriteByte(tagString)
+			writeString(buf, value)
+		case *Chunk:
+			buf.WriteByte(tagChunk)
+			writeChunk(buf, value)
+		}
+	}
+}
+
+// Decode reads a file written by Encode. It returns ErrNotCompiled or a
+// *VersionError for files it cannot read at all, and an error describing the
+// damage for truncated or corrupt ones.
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return nil, ErrNotCompiled
+	}
+	if len(data) < len(magic)+2 {
+		return nil, errors.New("corrupt compiled file: truncated header")
+	}
+	f := &File{Version: int(binary.BigEndian.Uint16(data[len(magic):]))}
+	if f.Version != FormatVersion {
+		return nil, &VersionError{Version: f.Version}
+	}
+
+	d := &reader{data: data, pos: len(magic) + 2}
+	copy(f.SourceHash[:], d.bytes(sha256.Size))
+	f.Source = d.string()
+	f.Script = d.chunk(0)
+	if d.err == nil && d.pos != len(d.data) {
+		d.err = errors.New("trailing data")
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("corrupt compiled file: %v", d.err)
+	}
+	if f.Script.Kind != ScriptChunk {
+		return nil, errors.New("corrupt compiled file: top-level chunk is not a script")
+	}
+	return f, nil
+}
+
+// maxNesting bounds how deeply chunks may contain one another.
+const maxNesting = 1000
+
+// reader decodes the body of a file, remembering the first error so that
+// callers need check only once.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.err = errors.New("unexpected end of file")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data[r.pos:])
+	if size <= 0 || n > math.MaxInt32 {
+		r.err = errors.New("invalid integer")
+		return 0
+	}
+	r.pos += size
+	return int(n)
+}
+
+func (r *reader) string() string {
+	return string(r.bytes(r.uvarint()))
+}
+
+func (r *reader) chunk(depth int) *Chunk {
+	if depth > maxNesting {
+		r.err = errors.New("chunks nested too deeply")
+		return nil
+	}
+	c := &Chunk{Kind: ChunkKind(r.byte()), Name: r.string(), Line: r.uvarint()}
+	if c.Kind > AsyncFunctionChunk {
+		r.err = fmt.Errorf("unknown chunk kind %d", c.Kind)
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		c.Params = append(c.Params, r.string())
+	}
+	c.Code = append([]byte(nil), r.bytes(r.uvarint())...)
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		c.Lines = append(c.Lines, LineStart{Offset: r.uvarint(), Line: r.uvarint()})
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		switch tag := r.byte(); tag {
+		case tagNumber:
+			if b := r.bytes(8); b != nil {
+				c.Constants = append(c.Constants, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			}
+		case tagString:
+			c.Constants = append(c.Constants, r.string())
+		case tagChunk:
+			c.Constants = append(c.Constants, r.chunk(depth+1))
+		default:
+			if r.err == nil {
+				r.err = fmt.Errorf("unknown constant tag %d", tag)
+			}
+		}
+	}
+	return c
+}
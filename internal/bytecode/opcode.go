@@ -0,0 +1,359 @@
+// Package bytecode compiles syntax trees into chunks of stack-ordered
+// instructions, stores them in versioned .loxc files and decodes them back
+// into trees for the interpreter.
+package bytecode
+
+import (
+	"interpreter/internal/token"
+)
+
+type OpCode byte
+
+const (
+	// Expressions push their value.
+	OpConstant      OpCode = iota // u16 constant: push a number or string
+	OpNil                         // push nil
+	OpTrue                        // push true
+	OpFalse                       // push false
+	OpGet                         // u16 name: push a variable
+	OpSet                         // u16 name: assign the top of the stack, leaving it there
+	OpAddSet                      // u16 name: add the top of the stack to a variable, replacing it with the sum
+	OpSubtractSet                 // u16 name: likewise for -=
+	OpMultiplySet                 // u16 name: likewise for *=
+	OpDivideSet                   // u16 name: likewise for /=
+	OpModuloSet                   // u16 name: likewise for %=
+	OpIncrement                   // u16 name: add one to a variable and push the result
+	OpDecrement                   // u16 name: subtract one from a variable and push the result
+	OpPostIncrement               // u16 name: add one to a variable and push its old value
+	OpPostDecrement               // u16 name: subtract one from a variable and push its old value
+	OpGetProperty                 // u16 name: replace a module with one of its exports, or a map with its value under the name
+	OpGroup                       // mark a parenthesized expression; does nothing
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+	OpPower
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpEqual
+	OpNotEqual
+	OpComma
+	OpNegate
+	OpNot
+	OpBitNot
+	OpAnd  // u16 offset: if the top is falsy, jump forward keeping it; else pop it
+	OpOr   // u16 offset: if the top is truthy, jump forward keeping it; else pop it
+	OpCall // u8 argument count: call the callee below the arguments
+
+	// Statements leave the stack as they found it.
+	OpPrint       // pop and print
+	OpPop         // pop an expression statement's value
+	OpDefine      // u16 name: pop into a new variable
+	OpDeclare     // u16 name: define a variable without an initializer
+	OpBeginScope  // enter a block
+	OpEndScope    // leave a block
+	OpJumpIfFalse // u16 offset: pop a condition and jump forward if it is falsy
+	OpElse        // u16 offset: jump forward over an else branch
+	OpLoop        // u16 offset: jump back to a loop condition
+	OpFunction    // u16 constant: declare the function in a nested chunk
+	OpLambda      // u16 constant: push the lambda in a nested chunk
+	OpReturn      // pop and return
+	OpReturnNil   // return without a value
+	OpTest        // u16 constant: declare the test block in a nested chunk
+	OpImport      // u16 path, u16 name: import a module
+
+	// A match statement pops its subject with MATCH and ends at END_MATCH.
+	// Each case lists its patterns, then CASE, then an optional guard, then
+	// its body.
+	OpMatch      // pop the subject of a match statement
+	OpMatchValue // pop a literal and match it against the subject
+	OpMatchAny   // match anything, for the wildcard _
+	OpMatchBind  // u16 name: match anything and bind it to a name
+	OpCase       // u16 offset: jump to the next case unless a pattern matched
+	OpGuard      // u16 offset: pop a guard and jump to the next case if it is falsy
+	OpEndCase    // u16 offset: jump past the match after a case body
+	OpEndMatch   // drop the subject
+
+	OpList // u16 count: replace that many values with a list of them
+	OpMap  // u16 count: replace that many key and value pairs with a map
+
+	// A for-in loop pops its iterable with ITERATE. FOR_NEXT then binds the
+	// next value in a fresh scope for the body, which ends with a LOOP back
+	// to it, or jumps past the loop once there are no values left.
+	OpIterate // pop the iterable of a for-in loop
+	OpForNext // u16 offset, u16 name: bind the next value or jump past the loop
+
+	OpYield    // pop and yield from a generator
+	OpYieldNil // yield without a value
+
+	OpSpawn // u8 count: pop that many arguments and a callee, and call it on a new goroutine
+
+	// A select statement is a SELECT followed by its cases and an
+	// END_SELECT. Each case evaluates its operands, then names its kind
+	// with RECV_CASE, SEND_CASE or DEFAULT_CASE, and its body ends with an
+	// END_CASE jump past the select.
+	OpSelect      // start a select statement
+	OpRecvCase    // u16 name: pop the channel of a receive case, binding the value to name unless it is empty
+	OpSendCase    // pop the value and channel of a send case
+	OpDefaultCase // the default case
+	OpEndSelect   // end a select statement
+
+	OpAwait // pop a promise and push its value once it settles
+
+	// A null-safe access a?.b, a?.[i] or f?.() is an OPTIONAL jumping over
+	// the rest of the access, which is then a GET_PROPERTY, INDEX or CALL.
+	OpIndex       // pop a key and replace the list or map below it with its element
+	OpOptional    // u16 offset: if the top is nil, jump forward keeping it
+	OpCoalesce    // u16 offset: if the top is not nil, jump forward keeping it; else pop it
+	OpCoalesceSet // u16 offset, u16 name: push a variable and jump forward unless it is nil, when the SET that follows assigns it
+
+	// DEFINE_PATTERN and SET_PATTERN are followed by the target of a
+	// destructuring declaration or assignment, written out prefix first.
+	OpDefinePattern // pop a value and take it apart into new variables
+	OpSetPattern    // take the top value apart into existing variables, leaving it there
+	OpNameTarget    // u16 name: store the whole value
+	OpListTarget    // u16 count, u8 rest: the targets of that many elements follow, then a NAME_TARGET for the rest if rest is 1
+	OpMapTarget     // u16 count: that many KEYs follow, each followed by its target
+	OpKey           // u16 constant: the key a map target reads
+
+	OpMatchTarget // match a list or map pattern, written out as for DEFINE_PATTERN after it
+
+	// Updating an element a[i] evaluates the list or map and the key first.
+	OpIndexUpdate   // u8 opcode: pop a compound assignment's operand, if any, and the key, and update the element as that variable opcode would
+	OpCoalesceIndex // u16 offset: pop the key and jump forward unless the element is nil, when the value that follows is stored there
+	OpSetIndex      // pop a value and the key and store the value as the element, leaving the value in place of the list or map
+)
+
+var opNames = [...]string{
+	OpConstant:      "CONSTANT",
+	OpNil:           "NIL",
+	OpTrue:          "TRUE",
+	OpFalse:         "FALSE",
+	OpGet:           "GET",
+	OpSet:           "SET",
+	OpAddSet:        "ADD_SET",
+	OpSubtractSet:   "SUBTRACT_SET",
+	OpMultiplySet:   "MULTIPLY_SET",
+	OpDivideSet:     "DIVIDE_SET",
+	OpModuloSet:     "MODULO_SET",
+	OpIncrement:     "INCREMENT",
+	OpDecrement:     "DECREMENT",
+	OpPostIncrement: "POST_INCREMENT",
+	OpPostDecrement: "POST_DECREMENT",
+	OpGetProperty:   "GET_PROPERTY",
+	OpGroup:         "GROUP",
+	OpAdd:           "ADD",
+	OpSubtract:      "SUBTRACT",
+	OpMultiply:      "MULTIPLY",
+	OpDivide:        "DIVIDE",
+	OpModulo:        "MODULO",
+	OpPower:         "POWER",
+	OpBitAnd:        "BIT_AND",
+	OpBitOr:         "BIT_OR",
+	OpBitXor:        "BIT_XOR",
+	OpShiftLeft:     "SHIFT_LEFT",
+	OpShiftRight:    "SHIFT_RIGHT",
+	OpGreater:       "GREATER",
+	OpGreaterEqual:  "GREATER_EQUAL",
+	OpLess:          "LESS",
+	OpLessEqual:     "LESS_EQUAL",
+	OpEqual:         "EQUAL",
+	OpNotEqual:      "NOT_EQUAL",
+	OpComma:         "COMMA",
+	OpNegate:        "NEGATE",
+	OpNot:           "NOT",
+	OpBitNot:        "BIT_NOT",
+	OpAnd:           "AND",
+	OpOr:            "OR",
+	OpCall:          "CALL",
+	OpPrint:         "PRINT",
+	OpPop:           "POP",
+	OpDefine:        "DEFINE",
+	OpDeclare:       "DECLARE",
+	OpBeginScope:    "BEGIN_SCOPE",
+	OpEndScope:      "END_SCOPE",
+	OpJumpIfFalse:   "JUMP_IF_FALSE",
+	OpElse:          "ELSE",
+	OpLoop:          "LOOP",
+	OpFunction:      "FUNCTION",
+	OpLambda:        "LAMBDA",
+	OpReturn:        "RETURN",
+	OpReturnNil:     "RETURN_NIL",
+	OpTest:          "TEST",
+	OpImport:        "IMPORT",
+	OpMatch:         "MATCH",
+	OpMatchValue:    "MATCH_VALUE",
+	OpMatchAny:      "MATCH_ANY",
+	OpMatchBind:     "MATCH_BIND",
+	OpCase:          "CASE",
+	OpGuard:         "GUARD",
+	OpEndCase:       "END_CASE",
+	OpEndMatch:      "END_MATCH",
+	OpList:          "LIST",
+	OpMap:           "MAP",
+	OpIterate:       "ITERATE",
+	OpForNext:       "FOR_NEXT",
+	OpYield:         "YIELD",
+	OpYieldNil:      "YIELD_NIL",
+	OpSpawn:         "SPAWN",
+	OpSelect:        "SELECT",
+	OpRecvCase:      "RECV_CASE",
+	OpSendCase:      "SEND_CASE",
+	OpDefaultCase:   "DEFAULT_CASE",
+	OpEndSelect:     "END_SELECT",
+	OpAwait:         "AWAIT",
+	OpIndex:         "INDEX",
+	OpOptional:      "OPTIONAL",
+	OpCoalesce:      "COALESCE",
+	OpCoalesceSet:   "COALESCE_SET",
+	OpDefinePattern: "DEFINE_PATTERN",
+	OpSetPattern:    "SET_PATTERN",
+	OpNameTarget:    "NAME_TARGET",
+	OpListTarget:    "LIST_TARGET",
+	OpMapTarget:     "MAP_TARGET",
+	OpKey:           "KEY",
+	OpMatchTarget:   "MATCH_TARGET",
+	OpIndexUpdate:   "INDEX_UPDATE",
+	OpCoalesceIndex: "COALESCE_INDEX",
+	OpSetIndex:      "SET_INDEX",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) && opNames[op] != "" {
+		return opNames[op]
+	}
+	return "UNKNOWN"
+}
+
+// operandSizes gives the number of operand bytes following each opcode.
+var operandSizes = map[OpCode]int{
+	OpConstant:      2,
+	OpGet:           2,
+	OpSet:           2,
+	OpAddSet:        2,
+	OpSubtractSet:   2,
+	OpMultiplySet:   2,
+	OpDivideSet:     2,
+	OpModuloSet:     2,
+	OpIncrement:     2,
+	OpDecrement:     2,
+	OpPostIncrement: 2,
+	OpPostDecrement: 2,
+	OpGetProperty:   2,
+	OpAnd:           2,
+	OpOr:            2,
+	OpCall:          1,
+	OpDefine:        2,
+	OpDeclare:       2,
+	OpJumpIfFalse:   2,
+	OpElse:          2,
+	OpLoop:          2,
+	OpFunction:      2,
+	OpLambda:        2,
+	OpTest:          2,
+	OpImport:        4,
+	OpMatchBind:     2,
+	OpCase:          2,
+	OpGuard:         2,
+	OpEndCase:       2,
+	OpList:          2,
+	OpMap:           2,
+	OpForNext:       4,
+	OpSpawn:         1,
+	OpRecvCase:      2,
+	OpOptional:      2,
+	OpCoalesce:      2,
+	OpCoalesceSet:   4,
+	OpNameTarget:    2,
+	OpListTarget:    3,
+	OpMapTarget:     2,
+	OpKey:           2,
+	OpIndexUpdate:   1,
+	OpCoalesceIndex: 2,
+}
+
+// Size returns the length of an instruction, opcode included.
+func (op OpCode) Size() int {
+	return 1 + operandSizes[op]
+}
+
+// operator pairs an opcode with the token it was compiled from.
+type operator struct {
+	op     OpCode
+	typ    token.TokenType
+	lexeme string
+}
+
+var operators = []operator{
+	{OpAdd, token.PLUS, "+"},
+	{OpSubtract, token.MINUS, "-"},
+	{OpMultiply, token.STAR, "*"},
+	{OpDivide, token.SLASH, "/"},
+	{OpModulo, token.PERCENT, "%"},
+	{OpPower, token.STAR_STAR, "**"},
+	{OpBitAnd, token.AMPERSAND, "&"},
+	{OpBitOr, token.PIPE, "|"},
+	{OpBitXor, token.CARET, "^"},
+	{OpShiftLeft, token.LESS_LESS, "<<"},
+	{OpShiftRight, token.GREATER_GREATER, ">>"},
+	{OpGreater, token.GREATER, ">"},
+	{OpGreaterEqual, token.GREATER_EQUAL, ">="},
+	{OpLess, token.LESS, "<"},
+	{OpLessEqual, token.LESS_EQUAL, "<="},
+	{OpEqual, token.EQUAL_EQUAL, "=="},
+	{OpNotEqual, token.BANG_EQUAL, "!="},
+	{OpComma, token.COMMA, ","},
+	{OpAnd, token.AND, "and"},
+	{OpOr, token.OR, "or"},
+	{OpCoalesce, token.QUESTION_QUESTION, "??"},
+}
+
+var compoundOperators = []operator{
+	{OpAddSet, token.PLUS_EQUAL, "+="},
+	{OpSubtractSet, token.MINUS_EQUAL, "-="},
+	{OpMultiplySet, token.STAR_EQUAL, "*="},
+	{OpDivideSet, token.SLASH_EQUAL, "/="},
+	{OpModuloSet, token.PERCENT_EQUAL, "%="},
+}
+
+var prefixOperators = []operator{
+	{OpIncrement, token.PLUS_PLUS, "++"},
+	{OpDecrement, token.MINUS_MINUS, "--"},
+}
+
+var postfixOperators = []operator{
+	{OpPostIncrement, token.PLUS_PLUS, "++"},
+	{OpPostDecrement, token.MINUS_MINUS, "--"},
+}
+
+var unaryOperators = []operator{
+	{OpNegate, token.MINUS, "-"},
+	{OpNot, token.BANG, "!"},
+	{OpBitNot, token.TILDE, "~"},
+}
+
+func lookupOperator(table []operator, typ token.TokenType) (operator, bool) {
+	for _, o := range table {
+		if o.typ == typ {
+			return o, true
+		}
+	}
+	return operator{}, false
+}
+
+func lookupOpCode(table []operator, op OpCode) (operator, bool) {
+	for _, o := range table {
+		if o.op == op {
+			return o, true
+		}
+	}
+	return operator{}, false
+}
@@ -0,0 +1,706 @@
+// Package checker reports type mismatches in a script before it runs.
+//
+// Types come from optional annotations, as in `var x: number = 1;` and
+// `fun f(a: string): bool`, and are inferred from literals, operators and
+// variables that are never assigned after their declaration. Everything else
+// has type any, so unannotated code that the interpreter accepts passes too:
+// an operator is only reported when no value of its operands' types could
+// work, and a value is only refused by an annotation when its type is known.
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/resolver"
+	"interpreter/internal/token"
+)
+
+// Diagnostic is a mismatch the script would run into if it reached the line.
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d: %s", d.Line, d.Message)
+}
+
+// variable is what the checker knows about a binding in scope.
+type variable struct {
+	typ Type
+	// declared is set for annotated bindings, whose assignments are checked.
+	declared bool
+	// sig describes the function a binding always holds, if it does.
+	sig *signature
+}
+
+type Checker struct {
+	scopes []map[string]*variable
+	// fixed holds the declarations that are never assigned afterwards, so
+	// their value always has the type of their initializer.
+	fixed       map[token.Token]bool
+	function    *signature
+	diagnostics []Diagnostic
+}
+
+// Check infers the types in statements and returns the mismatches, ordered
+// by line.
+func Check(statements []expression.Stmt) []Diagnostic {
+	c := &Checker{
+		scopes: []map[string]*variable{{
+			"clock": {typ: Function, sig: &signature{name: "clock", result: Number}},
+			"range": {typ: Function, sig: &signature{name: "range", params: []Type{Number, Number, Number}, result: Range}},
+
+			"channel":   {typ: Function, sig: &signature{name: "channel", params: []Type{Number}, result: Channel}},
+			"send":      {typ: Function, sig: &signature{name: "send", params: []Type{Channel, Any}, result: Nil}},
+			"recv":      {typ: Function, sig: &signature{name: "recv", params: []Type{Channel}, result: Any}},
+			"close":     {typ: Function, sig: &signature{name: "close", params: []Type{Channel}, result: Nil}},
+			"waitGroup": {typ: Function, sig: &signature{name: "waitGroup", result: WaitGroup}},
+			"groupAdd":  {typ: Function, sig: &signature{name: "groupAdd", params: []Type{WaitGroup, Number}, result: Nil}},
+			"groupDone": {typ: Function, sig: &signature{name: "groupDone", params: []Type{WaitGroup}, result: Nil}},
+			"groupWait": {typ: Function, sig: &signature{name: "groupWait", params: []Type{WaitGroup}, result: Nil}},
+
+			"sleep":      {typ: Function, sig: &signature{name: "sleep", params: []Type{Number}, result: Promise}},
+			"setTimeout": {typ: Function, sig: &signature{name: "setTimeout", params: []Type{Function, Number}, result: Nil}},
+		}},
+		fixed: fixedBindings(resolver.Resolve(statements)),
+	}
+
+	// Top-level functions may call each other whatever order they are
+	// declared in.
+	for _, stmt := range statements {
+		if fn, ok := stmt.(*expression.Function); ok {
+			c.declareFunction(fn, c.signature(fn, false))
+		}
+	}
+	c.checkStatements(statements)
+
+	sort.SliceStable(c.diagnostics, func(a, b int) bool {
+		return c.diagnostics[a].Line < c.diagnostics[b].Line
+	})
+	return c.diagnostics
+}
+
+func fixedBindings(resolution *resolver.Resolution) map[token.Token]bool {
+	// A global declared twice is a single binding to the resolver.
+	globals := map[string]int{}
+	for _, binding := range resolution.Bindings {
+		if binding.Depth == 0 {
+			globals[binding.Name.Lexeme]++
+		}
+	}
+	fixed := map[token.Token]bool{}
+	for _, binding := range resolution.Bindings {
+		if len(binding.Writes()) == 0 && (binding.Depth > 0 || globals[binding.Name.Lexeme] == 1) {
+			fixed[binding.Name] = true
+		}
+	}
+	return fixed
+}
+
+func (c *Checker) report(line int, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Checker) checkStatements(statements []expression.Stmt) {
+	for _, stmt := range statements {
+		stmt.Accept(c)
+	}
+}
+
+func (c *Checker) typeOf(expr expression.Expr) Type {
+	return expr.Accept(c).(Type)
+}
+
+// annotation returns the type an annotation names, or Any if there is none.
+func (c *Checker) annotation(annotation *expression.TypeAnnotation, report bool) Type {
+	if annotation == nil {
+		return Any
+	}
+	t, ok := lookupType(annotation.Name.Lexeme)
+	if !ok {
+		if report {
+			c.report(annotation.Name.Line, "Unknown type '%s'.", annotation.Name.Lexeme)
+		}
+		return Any
+	}
+	if annotation.Optional {
+		t |= Nil
+	}
+	return t
+}
+
+func (c *Checker) signature(fn *expression.Function, report bool) *signature {
+	sig := &signature{name: fn.Name.Lexeme, result: c.annotation(fn.ReturnType, report)}
+	if sig.name == "" {
+		sig.name = "lambda"
+	}
+	if expression.IsGenerator(fn.Body) {
+		if report && !assignable(Generator, sig.result) {
+			c.report(fn.Line(), "'%s' must return %s, not generator.", sig.name, sig.result)
+		}
+		sig.result, sig.generator = Generator, true
+	}
+	sig.async = fn.Async
+	for i := range fn.Params {
+		var annotation *expression.TypeAnnotation
+		if i < len(fn.ParamTypes) {
+			annotation = fn.ParamTypes[i]
+		}
+		sig.params = append(sig.params, c.annotation(annotation, report))
+	}
+	return sig
+}
+
+func (c *Checker) declare(name token.Token, v *variable) {
+	c.scopes[len(c.scopes)-1][name.Lexeme] = v
+}
+
+func (c *Checker) declareFunction(fn *expression.Function, sig *signature) {
+	if c.fixed[fn.Name] {
+		c.declare(fn.Name, &variable{typ: Function, sig: sig})
+	} else {
+		c.declare(fn.Name, &variable{typ: Any})
+	}
+}
+
+func (c *Checker) lookup(name token.Token) *variable {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if v, ok := c.scopes[i][name.Lexeme]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, map[string]*variable{})
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	c.typeOf(stmt.Expr)
+	return nil
+}
+
+func (c *Checker) VisitPrintStmt(stmt *expression.Print) interface{} {
+	c.typeOf(stmt.Expression)
+	return nil
+}
+
+func (c *Checker) VisitVarStmt(stmt *expression.Var) interface{} {
+	value := Nil
+	if stmt.Initializer != nil {
+		value = c.typeOf(stmt.Initializer)
+	}
+
+	v := &variable{typ: Any}
+	switch {
+	case stmt.Type != nil:
+		v.typ, v.declared = c.annotation(stmt.Type, true), true
+		if assignable(value, v.typ) {
+			break
+		}
+		if stmt.Initializer == nil {
+			c.report(stmt.Line(), "'%s' of type %s must be initialized.", stmt.Name.Lexeme, v.typ)
+		} else {
+			c.report(stmt.Line(), "Cannot initialize '%s' of type %s with %s.", stmt.Name.Lexeme, v.typ, value)
+		}
+	case c.fixed[stmt.Name]:
+		v.typ = value
+		if lambda, ok := stmt.Initializer.(*expression.Lambda); ok {
+			v.sig = c.signature(lambda.Function, false)
+			v.sig.name = stmt.Name.Lexeme
+		}
+	}
+	c.declare(stmt.Name, v)
+	return nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt *expression.While) interface{} {
+	c.typeOf(stmt.Condition)
+	stmt.Body.Accept(c)
+	return nil
+}
+
+// VisitForInStmt gives the loop variable the type of the values a string or
+// range produces, unless it is assigned in the body.
+func (c *Checker) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	iterable := c.typeOf(stmt.Iterable)
+	if !iterable.may(String | List | Map | Range | Generator | Channel | Module) {
+		c.report(stmt.Line(), "Cannot iterate over %s.", iterable)
+	}
+	v := &variable{typ: Any}
+	if c.fixed[stmt.Name] {
+		switch {
+		case iterable.within(String):
+			v.typ = String
+		case iterable.within(Range):
+			v.typ = Number
+		}
+	}
+	c.beginScope()
+	c.declare(stmt.Name, v)
+	stmt.Body.Accept(c)
+	c.endScope()
+	return nil
+}
+
+func (c *Checker) VisitBlockStmt(stmt *expression.Block) interface{} {
+	c.beginScope()
+	c.checkStatements(stmt.Statements)
+	c.endScope()
+	return nil
+}
+
+func (c *Checker) VisitIfStmt(stmt *expression.If) interface{} {
+	c.typeOf(stmt.Condition)
+	stmt.ThenBranch.Accept(c)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(c)
+	}
+	return nil
+}
+
+// VisitMatchStmt gives names bound by a case the subject's type, unless
+// they are assigned later.
+func (c *Checker) VisitMatchStmt(stmt *expression.Match) interface{} {
+	subject := c.typeOf(stmt.Subject)
+	for _, mc := range stmt.Cases {
+		c.beginScope()
+		for _, pattern := range mc.Patterns {
+			for _, name := range pattern.Bindings() {
+				v := &variable{typ: Any}
+				// Only a plain name binds the subject itself.
+				if _, whole := pattern.(*expression.BindingPattern); whole && c.fixed[name] {
+					v.typ = subject
+				}
+				c.declare(name, v)
+			}
+		}
+		if mc.Guard != nil {
+			c.typeOf(mc.Guard)
+		}
+		mc.Body.Accept(c)
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *Checker) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	c.typeOf(stmt.Call)
+	return nil
+}
+
+// VisitSelectStmt checks that every case operates on a channel.
+func (c *Checker) VisitSelectStmt(stmt *expression.Select) interface{} {
+	for _, sc := range stmt.Cases {
+		if sc.Channel != nil {
+			if channel := c.typeOf(sc.Channel); !channel.may(Channel) {
+				c.report(sc.Operation.Line, "Cannot select on %s.", channel)
+			}
+		}
+		if sc.Value != nil {
+			c.typeOf(sc.Value)
+		}
+	}
+	for _, sc := range stmt.Cases {
+		c.beginScope()
+		if sc.Name != nil {
+			c.declare(*sc.Name, &variable{typ: Any})
+		}
+		sc.Body.Accept(c)
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *Checker) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	sig := c.signature(stmt, true)
+	c.declareFunction(stmt, sig)
+	c.checkBody(stmt, sig)
+	return nil
+}
+
+func (c *Checker) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	c.checkBody(expr.Function, c.signature(expr.Function, true))
+	return Function
+}
+
+// checkBody checks the body of a function against its signature.
+func (c *Checker) checkBody(fn *expression.Function, sig *signature) {
+	if !sig.generator && !sig.result.may(Nil) && !returns(fn.Body) {
+		c.report(fn.Line(), "'%s' may finish without returning %s.", sig.name, sig.result)
+	}
+
+	params := make([]*variable, len(fn.Params))
+	for i := range fn.Params {
+		params[i] = &variable{typ: sig.params[i], declared: i < len(fn.ParamTypes) && fn.ParamTypes[i] != nil}
+	}
+	c.checkFunction(sig, fn.Params, params, fn.Body)
+}
+
+func (c *Checker) VisitTestStmt(stmt *expression.Test) interface{} {
+	c.checkFunction(&signature{name: "test", result: Any}, nil, nil, stmt.Body)
+	return nil
+}
+
+func (c *Checker) checkFunction(sig *signature, names []token.Token, params []*variable, body []expression.Stmt) {
+	enclosing := c.function
+	c.function = sig
+	c.beginScope()
+	for i, name := range names {
+		c.declare(name, params[i])
+	}
+	c.checkStatements(body)
+	c.endScope()
+	c.function = enclosing
+}
+
+func (c *Checker) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value != nil {
+		c.typeOf(stmt.Value)
+	}
+	return nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt *expression.Return) interface{} {
+	value := Nil
+	if stmt.Value != nil {
+		value = c.typeOf(stmt.Value)
+	}
+	if c.function != nil && !c.function.generator && !assignable(value, c.function.result) {
+		c.report(stmt.Line(), "'%s' must return %s, not %s.", c.function.name, c.function.result, value)
+	}
+	return nil
+}
+
+// VisitVarDestructureStmt declares the names the pattern stores into. Only
+// the shape of the whole value is known, so the pieces are Any.
+func (c *Checker) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	c.checkPattern(stmt.Pattern, c.typeOf(stmt.Initializer))
+	for _, name := range stmt.Pattern.Names() {
+		c.declare(name, &variable{typ: Any})
+	}
+	return nil
+}
+
+// checkPattern reports a list or map pattern applied to a value that can be
+// neither.
+func (c *Checker) checkPattern(pattern expression.Target, value Type) {
+	switch p := pattern.(type) {
+	case *expression.ListTarget:
+		if !value.may(List) {
+			c.report(p.Bracket.Line, "Cannot destructure %s with a list pattern.", value)
+		}
+	case *expression.MapTarget:
+		if !value.may(Map) {
+			c.report(p.Brace.Line, "Cannot destructure %s with a map pattern.", value)
+		}
+	}
+}
+
+func (c *Checker) VisitImportStmt(stmt *expression.Import) interface{} {
+	v := &variable{typ: Any}
+	if c.fixed[stmt.Name] {
+		v.typ = Module
+	}
+	c.declare(stmt.Name, v)
+	return nil
+}
+
+func (c *Checker) VisitAssignExpr(expr *expression.Assign) interface{} {
+	value := c.typeOf(expr.Value)
+	if v := c.lookup(expr.Name); v != nil && v.declared && !assignable(value, v.typ) {
+		c.report(expr.Name.Line, "Cannot assign %s to '%s' of type %s.", value, expr.Name.Lexeme, v.typ)
+	}
+	return value
+}
+
+func (c *Checker) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	value := c.typeOf(expr.Value)
+	c.checkPattern(expr.Pattern, value)
+	return value
+}
+
+// VisitCompoundExpr infers the value stored. A ??= keeps a target that is
+// not nil and otherwise stores the value.
+func (c *Checker) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	target := c.typeOf(expr.Target)
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		value := c.typeOf(expr.Value)
+		c.checkAssignment(expr.Target, value)
+		return target&^Nil | value
+	}
+	value := c.binary(expr.BinaryOperator(), target, c.typeOf(expr.Value))
+	c.checkAssignment(expr.Target, value)
+	return value
+}
+
+func (c *Checker) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	if target := c.typeOf(expr.Target); !target.may(Number) {
+		c.report(expr.Operator.Line, "Operand of '%s' must be a number, not %s.", expr.Operator.Lexeme, target)
+	}
+	c.checkAssignment(expr.Target, Number)
+	return Number
+}
+
+// checkAssignment checks a value stored by a compound assignment or an
+// increment against the target's declared type.
+func (c *Checker) checkAssignment(target expression.Expr, value Type) {
+	variable, ok := target.(*expression.Variable)
+	if !ok {
+		return
+	}
+	if v := c.lookup(variable.Name); v != nil && v.declared && !assignable(value, v.typ) {
+		c.report(variable.Name.Line, "Cannot assign %s to '%s' of type %s.", value, variable.Name.Lexeme, v.typ)
+	}
+}
+
+func (c *Checker) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	return c.binary(expr.Operator, c.typeOf(expr.Left), c.typeOf(expr.Right))
+}
+
+// binary infers the result of a binary operator, reporting operands it can
+// never accept.
+func (c *Checker) binary(operator token.Token, left, right Type) Type {
+	switch operator.Type {
+	case token.MINUS, token.STAR, token.SLASH, token.PERCENT, token.STAR_STAR,
+		token.AMPERSAND, token.PIPE, token.CARET, token.LESS_LESS, token.GREATER_GREATER:
+		c.checkNumbers(operator, left, right)
+		return Number
+	case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		c.checkNumbers(operator, left, right)
+		return Bool
+	case token.PLUS:
+		var result Type
+		if left.may(Number) && right.may(Number) {
+			result |= Number
+		}
+		if left.may(String) && right.may(String) {
+			result |= String
+		}
+		if result == 0 {
+			c.report(operator.Line, "Operands of '+' must be two numbers or two strings, not %s and %s.", left, right)
+			return Number | String
+		}
+		return result
+	case token.EQUAL_EQUAL, token.BANG_EQUAL:
+		return Bool
+	}
+	// The comma operator evaluates both operands for their effects.
+	return Nil
+}
+
+func (c *Checker) checkNumbers(operator token.Token, left, right Type) {
+	if !left.may(Number) || !right.may(Number) {
+		c.report(operator.Line, "Operands of '%s' must be numbers, not %s and %s.", operator.Lexeme, left, right)
+	}
+}
+
+func (c *Checker) VisitCallExpr(expr *expression.Call) interface{} {
+	callee := c.typeOf(expr.Callee)
+	if expr.Optional {
+		if callee == Nil {
+			return Nil
+		}
+		callee &^= Nil
+	}
+	arguments := make([]Type, len(expr.Arguments))
+	for i, argument := range expr.Arguments {
+		arguments[i] = c.typeOf(argument)
+	}
+	if !callee.may(Function) {
+		c.report(expr.Paren.Line, "Can only call functions and classes, not %s.", callee)
+		return Any
+	}
+	if expr.Optional {
+		return c.call(expr, arguments) | Nil
+	}
+	return c.call(expr, arguments)
+}
+
+// call infers the result of calling a function with arguments of the given
+// types, checking them against its signature when it is known.
+func (c *Checker) call(expr *expression.Call, arguments []Type) Type {
+	variable, ok := expr.Callee.(*expression.Variable)
+	if !ok {
+		return Any
+	}
+	v := c.lookup(variable.Name)
+	if v == nil || v.sig == nil {
+		return Any
+	}
+	if len(arguments) != len(v.sig.params) {
+		c.report(expr.Paren.Line, "Expected %d arguments but got %d.", len(v.sig.params), len(arguments))
+		return v.sig.call()
+	}
+	for i, argument := range arguments {
+		if !assignable(argument, v.sig.params[i]) {
+			c.report(expr.Paren.Line, "Argument %d to '%s' must be %s, not %s.", i+1, v.sig.name, v.sig.params[i], argument)
+		}
+	}
+	return v.sig.call()
+}
+
+func (c *Checker) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	c.typeOf(expr.Condition)
+	return c.typeOf(expr.TrueExpression) | c.typeOf(expr.FalseExpression)
+}
+
+func (c *Checker) VisitListExpr(expr *expression.List) interface{} {
+	for _, element := range expr.Elements {
+		c.typeOf(element)
+	}
+	return List
+}
+
+func (c *Checker) VisitMapExpr(expr *expression.Map) interface{} {
+	for index, key := range expr.Keys {
+		c.typeOf(key)
+		c.typeOf(expr.Values[index])
+	}
+	return Map
+}
+
+func (c *Checker) VisitGetExpr(expr *expression.Get) interface{} {
+	object := c.typeOf(expr.Object)
+	if expr.Optional {
+		if object == Nil {
+			return Nil
+		}
+		object &^= Nil
+	}
+	if !object.may(Module | Map) {
+		c.report(expr.Name.Line, "Only modules and maps have properties, not %s.", object)
+	}
+	return Any
+}
+
+func (c *Checker) VisitIndexExpr(expr *expression.Index) interface{} {
+	object := c.typeOf(expr.Object)
+	if expr.Optional {
+		if object == Nil {
+			return Nil
+		}
+		object &^= Nil
+	}
+	key := c.typeOf(expr.Key)
+	if !object.may(List | Map) {
+		c.report(expr.Bracket.Line, "Can only index lists and maps, not %s.", object)
+	} else if object == List && !key.may(Number) {
+		c.report(expr.Bracket.Line, "List index must be a number, not %s.", key)
+	}
+	return Any
+}
+
+// VisitSetIndexExpr checks the element as a read of it would be checked and
+// infers the value stored.
+func (c *Checker) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	c.VisitIndexExpr(expression.NewIndex(expr.Object, expr.Bracket, expr.Key, false))
+	return c.typeOf(expr.Value)
+}
+
+func (c *Checker) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	return c.typeOf(expr.Expr)
+}
+
+func (c *Checker) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	switch expr.Value.(type) {
+	case nil:
+		return Nil
+	case bool:
+		return Bool
+	case float64:
+		return Number
+	case string:
+		return String
+	}
+	return Any
+}
+
+// VisitLogicalExpr infers the type of the operand the expression yields:
+// `or` yields its left operand only if it is truthy, `and` only if it is
+// falsey and ?? only if it is not nil.
+func (c *Checker) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	left, right := c.typeOf(expr.Left), c.typeOf(expr.Right)
+	if expr.Operator.Type == token.OR || expr.Operator.Type == token.QUESTION_QUESTION {
+		return left&^Nil | right
+	}
+	return left&(Nil|Bool) | right
+}
+
+func (c *Checker) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	right := c.typeOf(expr.Right)
+	if expr.Operator.Type == token.BANG {
+		return Bool
+	}
+	if !right.may(Number) {
+		c.report(expr.Operator.Line, "Operand of '%s' must be a number, not %s.", expr.Operator.Lexeme, right)
+	}
+	return Number
+}
+
+// VisitAwaitExpr knows nothing of the value a promise settles with, but
+// awaiting anything else gives the value itself.
+func (c *Checker) VisitAwaitExpr(expr *expression.Await) interface{} {
+	value := c.typeOf(expr.Value)
+	if value.may(Promise) {
+		return Any
+	}
+	return value
+}
+
+func (c *Checker) VisitVariableExpr(expr *expression.Variable) interface{} {
+	if v := c.lookup(expr.Name); v != nil {
+		return v.typ
+	}
+	return Any
+}
+
+// returns reports whether running statements always ends in a return
+// statement or a loop that never exits.
+func returns(statements []expression.Stmt) bool {
+	for _, stmt := range statements {
+		if alwaysReturns(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+func alwaysReturns(stmt expression.Stmt) bool {
+	switch s := stmt.(type) {
+	case *expression.Return:
+		return true
+	case *expression.Block:
+		return returns(s.Statements)
+	case *expression.If:
+		return s.ElseBranch != nil && alwaysReturns(s.ThenBranch) && alwaysReturns(s.ElseBranch)
+	case *expression.While:
+		literal, ok := s.Condition.(*expression.Literal)
+		return ok && literal.Value == true
+	case *expression.Match:
+		for _, c := range s.Cases {
+			if !alwaysReturns(c.Body) {
+				return false
+			}
+			if c.Irrefutable() {
+				return true
+			}
+		}
+	case *expression.Select:
+		for _, c := range s.Cases {
+			if !alwaysReturns(c.Body) {
+				return false
+			}
+		}
+		return len(s.Cases) > 0
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func check(t *testing.T, source string) []string {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var messages []string
+	for _, d := range Check(statements) {
+		messages = append(messages, d.String())
+	}
+	return messages
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"Unannotated", "var a = 1;\na = \"one\";\nprint a - 1;", nil},
+		{"Literal operands", "print \"a\" - 1;", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"Inferred variable", "var a = \"a\";\nprint -a;", []string{"2: Operand of '-' must be a number, not string."}},
+		{"Redeclared global", "var a = \"a\";\nvar a = 1;\nprint a - 1;", nil},
+		{"Plus", "print 1 + \"a\";\nprint \"a\" + \"b\";", []string{"1: Operands of '+' must be two numbers or two strings, not number and string."}},
+		{"Comparison result", "print (1 < 2) + 1;", []string{"1: Operands of '+' must be two numbers or two strings, not bool and number."}},
+		{"Ternary", "var a = true ? 1 : \"one\";\nvar b: number = a;\nprint a * 2;", []string{"2: Cannot initialize 'b' of type number with number | string."}},
+		{"Or", "print (nil or \"a\") - 1;", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"And", "print (1 and \"a\") - 1;", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"Assignment value", "var a;\nprint (a = \"a\") - 1;", []string{"2: Operands of '-' must be numbers, not string and number."}},
+		{"Annotated assignment", "var a: number = 1;\na = nil;", []string{"2: Cannot assign nil to 'a' of type number."}},
+		{"Optional", "var a: number? = nil;\na = 1;\nprint a + 1;", nil},
+		{"Uninitialized", "var a: bool;", []string{"1: 'a' of type bool must be initialized."}},
+		{"Unknown type", "var a: int = 1;", []string{"1: Unknown type 'int'."}},
+		{"Unknown value", "fun f(a) { var b: number = a; return b; }", nil},
+		{"Parameters", "fun f(a: string, b) {}\nf(1, 2);\nf(\"a\");", []string{
+			"2: Argument 1 to 'f' must be string, not number.",
+			"3: Expected 2 arguments but got 1.",
+		}},
+		{"Parameter type in body", "fun f(a: string) { return a - 1; }", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"Result", "fun f(): number { return 1; }\nprint f() + \"a\";", []string{"2: Operands of '+' must be two numbers or two strings, not number and string."}},
+		{"Called before declared", "print f() - 1;\nfun f(): string { return \"a\"; }", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"Return type", "fun f(): bool { return 1; }", []string{"1: 'f' must return bool, not number."}},
+		{"Missing return", "fun f(a): bool { if (a) return true; }", []string{"1: 'f' may finish without returning bool."}},
+		{"Reassigned function", "fun f(a: number) {}\nf = clock;\nf(\"a\", 1);", nil},
+		{"Not callable", "var a = 1;\na();", []string{"2: Can only call functions and classes, not number."}},
+		{"Lambda", "var f = (a: number): string => a;\nf(\"a\");\nprint f - 1;", []string{
+			"1: 'lambda' must return string, not number.",
+			"2: Argument 1 to 'f' must be number, not string.",
+			"3: Operands of '-' must be numbers, not fun and number.",
+		}},
+		{"Native", "print clock() + \"s\";", []string{"1: Operands of '+' must be two numbers or two strings, not number and string."}},
+		{"For-in", "for (c in \"ab\") print c - 1;\nfor (n in range(0, 3, 1)) print n - 1;", []string{"1: Operands of '-' must be numbers, not string and number."}},
+		{"Generator", "fun g(n: number) { yield n; return; }\nfor (x in g(1)) print x;\nprint g(1) - 1;", []string{"3: Operands of '-' must be numbers, not generator and number."}},
+		{"Generator result", "fun g(): number { yield 1; }\nfun h(): generator { yield 1; }", []string{"1: 'g' must return number, not generator."}},
+		{"Channel", "var c = channel(1);\nfor (x in c) print x;\nprint recv(c) - 1;\nprint c + 1;", []string{"4: Operands of '+' must be two numbers or two strings, not channel and number."}},
+		{"Select", "var c = channel(0);\nselect { case v = recv(c) => print v - 1; case send(1, 2) => {} }", []string{"2: Cannot select on number."}},
+		{"Wait group", "var g = waitGroup();\ngroupAdd(g, 1);\ngroupAdd(1, g);", []string{"3: Argument 1 to 'groupAdd' must be waitgroup, not number.", "3: Argument 2 to 'groupAdd' must be number, not waitgroup."}},
+		{"Async", "async fun f(n: number): number { await sleep(n); return n; }\nprint f(1) - 1;\nprint await f(1) - 1;\nprint await 1 - \"a\";", []string{"2: Operands of '-' must be numbers, not promise and number.", "4: Operands of '-' must be numbers, not number and string."}},
+		{"Null safety", "var n: number? = nil;\nprint (n ?? 1) - 1;\nprint nil?.x;\nprint 1?.x;\nprint [1][\"a\"];\nprint 2[0];\nn ??= \"a\";", []string{"4: Only modules and maps have properties, not number.", "5: List index must be a number, not string.", "6: Can only index lists and maps, not number.", "7: Cannot assign string to 'n' of type number?."}},
+		{"Not iterable", "for (x in 1) print x;\nfor (x in [1]) print x;", []string{"1: Cannot iterate over number."}},
+		{"Destructuring", "var [a, b] = 1;\nvar {c} = [1];\nvar [d] = [1];\nvar e: number = 1;\n[e] = [\"s\"];\nprint d - e;", []string{"1: Cannot destructure number with a list pattern.", "2: Cannot destructure list with a map pattern."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(t, tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	tests := map[Type]string{
+		Number:          "number",
+		Number | Nil:    "number?",
+		Bool | String:   "string | bool",
+		Nil:             "nil",
+		Any:             "any",
+		Function | Nil:  "fun?",
+		String | Number: "number | string",
+	}
+	for typ, want := range tests {
+		if got := typ.String(); got != want {
+			t.Errorf("%016b.String() = %q, want %q", uint16(typ), got, want)
+		}
+	}
+}
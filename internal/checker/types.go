@@ -0,0 +1,115 @@
+package checker
+
+import "strings"
+
+// Type is the set of kinds of value an expression may produce. Unions arise
+// from optional annotations such as `string?` and from expressions whose
+// operands differ, as in `a ? 1 : "one"`.
+type Type uint16
+
+const (
+	Nil Type = 1 << iota
+	Bool
+	Number
+	String
+	Function
+	Module
+	List
+	Map
+	Range
+	Generator
+	Channel
+	WaitGroup
+	Promise
+
+	// Any is the type of unannotated code, about which nothing is known.
+	Any = Nil | Bool | Number | String | Function | Module | List | Map | Range | Generator | Channel | WaitGroup | Promise
+)
+
+// names holds the name of each kind, as written in annotations.
+var names = []struct {
+	kind Type
+	name string
+}{
+	{Number, "number"},
+	{String, "string"},
+	{Bool, "bool"},
+	{Function, "fun"},
+	{List, "list"},
+	{Map, "map"},
+	{Module, "module"},
+	{Range, "range"},
+	{Generator, "generator"},
+	{Channel, "channel"},
+	{WaitGroup, "waitgroup"},
+	{Promise, "promise"},
+	{Nil, "nil"},
+}
+
+// lookupType returns the type an annotation names. Modules and ranges can't
+// be written.
+func lookupType(name string) (Type, bool) {
+	if name == "any" {
+		return Any, true
+	}
+	for _, n := range names {
+		if n.name == name && n.kind != Module && n.kind != Range {
+			return n.kind, true
+		}
+	}
+	return 0, false
+}
+
+func (t Type) String() string {
+	if t == Any {
+		return "any"
+	}
+	var parts []string
+	for _, n := range names {
+		if t&n.kind != 0 {
+			parts = append(parts, n.name)
+		}
+	}
+	if len(parts) == 2 && t&Nil != 0 {
+		return parts[0] + "?"
+	}
+	return strings.Join(parts, " | ")
+}
+
+// may reports whether a value of type t can be of some kind in kinds.
+func (t Type) may(kinds Type) bool {
+	return t&kinds != 0
+}
+
+// within reports whether every value of type t is of some kind in kinds.
+func (t Type) within(kinds Type) bool {
+	return t&^kinds == 0
+}
+
+// assignable reports whether a value of type value may be stored where
+// declared is expected. Values of unknown type are always accepted.
+func assignable(value, declared Type) bool {
+	return value == Any || value.within(declared)
+}
+
+// signature describes a function the checker has seen declared. Unannotated
+// parameters and results are Any.
+type signature struct {
+	name   string
+	params []Type
+	result Type
+	// generator is set for functions that yield, whose return statements
+	// carry no value.
+	generator bool
+	// async is set for async functions, whose calls give a promise of
+	// result instead.
+	async bool
+}
+
+// call returns the type of a call to the function.
+func (s *signature) call() Type {
+	if s.async {
+		return Promise
+	}
+	return s.result
+}
@@ -0,0 +1,140 @@
+package coverage
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+const source = `var a = 1;
+if (a > 2) {
+  print "big";
+} else {
+  print "small";
+}
+print a == 1 ? "one" : "other";
+print a or false;
+`
+
+func parse(t *testing.T, source string) []expression.Stmt {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statements
+}
+
+func run(t *testing.T) *Report {
+	t.Helper()
+	statements := parse(t, source)
+	tracker := NewTracker("test.lox", statements)
+	i := interpreter.NewInterpreter()
+	i.SetOutput(io.Discard)
+	i.SetHook(tracker)
+	if err := i.Interpret(statements); err != nil {
+		t.Fatal(err)
+	}
+	return tracker.Report()
+}
+
+func TestTracker(t *testing.T) {
+	file := run(t).Files["test.lox"]
+
+	lines := map[int]int64{1: 1, 2: 1, 3: 0, 4: 1, 5: 1, 7: 1, 8: 1}
+	for line, want := range lines {
+		if got, ok := file.Lines[line]; !ok || got != want {
+			t.Errorf("line %d: got %d (present %v), want %d", line, got, ok, want)
+		}
+	}
+	if _, ok := file.Lines[6]; ok {
+		t.Errorf("line 6 has no statement but was reported")
+	}
+
+	branches := []struct {
+		line, branch int
+		taken        int64
+	}{
+		{2, 0, 0}, {2, 1, 1},
+		{7, 0, 1}, {7, 1, 0},
+		{8, 0, 1}, {8, 1, 0},
+	}
+	for _, want := range branches {
+		b := file.Branches[branchKey{want.line, 0, want.branch}]
+		if b == nil || !b.Evaluated || b.Taken != want.taken {
+			t.Errorf("branch %d/%d: got %+v, want taken %d", want.line, want.branch, b, want.taken)
+		}
+	}
+}
+
+func TestLCOVRoundTripAndMerge(t *testing.T) {
+	var first bytes.Buffer
+	if err := run(t).WriteLCOV(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := ReadLCOV(strings.NewReader(first.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip bytes.Buffer
+	merged.WriteLCOV(&roundTrip)
+	if roundTrip.String() != first.String() {
+		t.Fatalf("round trip changed the report:\n%s\nwant:\n%s", roundTrip.String(), first.String())
+	}
+
+	merged.Merge(run(t))
+	file := merged.Files["test.lox"]
+	if file.Lines[1] != 2 || file.Lines[3] != 0 {
+		t.Errorf("merged line counts: got %v", file.Lines)
+	}
+	if b := file.Branches[branchKey{2, 0, 1}]; b.Taken != 2 {
+		t.Errorf("merged branch: got %+v", b)
+	}
+	if found, hit, _, _ := file.Summary(); found != 7 || hit != 6 {
+		t.Errorf("summary: got %d of %d lines hit", hit, found)
+	}
+}
+
+func TestReadLCOVNotEvaluated(t *testing.T) {
+	report, err := ReadLCOV(strings.NewReader("SF:x.lox\nBRDA:3,0,1,-\nDA:3,0\nend_of_record\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := report.Files["x.lox"].Branches[branchKey{3, 0, 1}]; b == nil || b.Evaluated {
+		t.Errorf("got %+v, want an unevaluated branch", b)
+	}
+
+	if _, err := ReadLCOV(strings.NewReader("DA:1,1\n")); err == nil {
+		t.Errorf("expected an error for a record outside a file")
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out bytes.Buffer
+	err := run(t).WriteHTML(&out, func(string) ([]byte, error) { return []byte(source), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		`<tr class="partial" title="condition 0 never truthy"><td class="number">2</td>`,
+		`<tr class="miss" title=""><td class="number">3</td><td class="count">0</td><td class="code">  print &#34;big&#34;;</td>`,
+		`<tr class="hit" title=""><td class="number">5</td>`,
+		`Lines: 6 of 7 (85.7%). Branches: 3 of 6 (50.0%).`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
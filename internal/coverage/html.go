@@ -0,0 +1,124 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage report</title>
+<style>
+body { font-family: sans-serif; }
+table.source { border-collapse: collapse; font-family: monospace; }
+table.source td { padding: 0 8px; white-space: pre; }
+td.number, td.count { text-align: right; color: #888; }
+tr.hit td.code { background: #dfd; }
+tr.partial td.code { background: #ffc; }
+tr.miss td.code { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Coverage report</h1>
+{{range .}}
+<h2 id="{{.Path}}">{{.Path}}</h2>
+<p>Lines: {{.LinesHit}} of {{.LinesFound}} ({{.LinePercent}}). Branches: {{.BranchesHit}} of {{.BranchesFound}} ({{.BranchPercent}}).</p>
+{{if .Error}}<p>{{.Error}}</p>{{else}}
+<table class="source">
+{{range .Lines}}<tr class="{{.Class}}" title="{{.Title}}"><td class="number">{{.Number}}</td><td class="count">{{.Count}}</td><td class="code">{{.Source}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+type htmlFile struct {
+	Path          string
+	LinesFound    int
+	LinesHit      int
+	BranchesFound int
+	BranchesHit   int
+	LinePercent   string
+	BranchPercent string
+	Error         string
+	Lines         []htmlLine
+}
+
+type htmlLine struct {
+	Number int
+	Count  string
+	Class  string
+	Title  string
+	Source string
+}
+
+// WriteHTML writes a page showing each file's source annotated with its line
+// counts. Lines with untaken branches are marked as partially covered.
+func (r *Report) WriteHTML(w io.Writer, readSource func(path string) ([]byte, error)) error {
+	var files []htmlFile
+	for _, file := range r.sortedFiles() {
+		linesFound, linesHit, branchesFound, branchesHit := file.Summary()
+		hf := htmlFile{
+			Path:          file.Path,
+			LinesFound:    linesFound,
+			LinesHit:      linesHit,
+			BranchesFound: branchesFound,
+			BranchesHit:   branchesHit,
+			LinePercent:   percent(linesHit, linesFound),
+			BranchPercent: percent(branchesHit, branchesFound),
+		}
+
+		source, err := readSource(file.Path)
+		if err != nil {
+			hf.Error = err.Error()
+		} else {
+			hf.Lines = annotate(file, string(source))
+		}
+		files = append(files, hf)
+	}
+	return htmlTemplate.Execute(w, files)
+}
+
+func annotate(file *File, source string) []htmlLine {
+	missed := map[int][]string{}
+	for _, b := range file.SortedBranches() {
+		if b.Taken == 0 {
+			outcome := "truthy"
+			if b.Branch == 1 {
+				outcome = "falsy"
+			}
+			missed[b.Line] = append(missed[b.Line], fmt.Sprintf("condition %d never %s", b.Block, outcome))
+		}
+	}
+
+	var lines []htmlLine
+	for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		number := i + 1
+		line := htmlLine{Number: number, Source: text}
+		if count, ok := file.Lines[number]; ok {
+			line.Count = fmt.Sprint(count)
+			switch {
+			case count == 0:
+				line.Class = "miss"
+			case len(missed[number]) > 0:
+				line.Class = "partial"
+				line.Title = strings.Join(missed[number], "; ")
+			default:
+				line.Class = "hit"
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func percent(hit, found int) string {
+	if found == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(found))
+}
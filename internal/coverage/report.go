@@ -0,0 +1,206 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Branch is one outcome of a branch point. Branch 0 is the outcome where the
+// deciding condition was truthy, branch 1 where it was not. Block numbers
+// the branch points that share a line.
+type Branch struct {
+	Line      int
+	Block     int
+	Branch    int
+	Evaluated bool
+	Taken     int64
+}
+
+type branchKey struct {
+	line, block, branch int
+}
+
+// File holds the coverage of one source file.
+type File struct {
+	Path     string
+	Lines    map[int]int64
+	Branches map[branchKey]*Branch
+}
+
+func newFile(path string) *File {
+	return &File{Path: path, Lines: map[int]int64{}, Branches: map[branchKey]*Branch{}}
+}
+
+func (f *File) addBranch(b Branch) {
+	key := branchKey{b.Line, b.Block, b.Branch}
+	if existing, ok := f.Branches[key]; ok {
+		existing.Evaluated = existing.Evaluated || b.Evaluated
+		existing.Taken += b.Taken
+		return
+	}
+	f.Branches[key] = &b
+}
+
+// SortedBranches returns the branches ordered by line, block and outcome.
+func (f *File) SortedBranches() []*Branch {
+	branches := make([]*Branch, 0, len(f.Branches))
+	for _, b := range f.Branches {
+		branches = append(branches, b)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		x, y := branches[i], branches[j]
+		if x.Line != y.Line {
+			return x.Line < y.Line
+		}
+		if x.Block != y.Block {
+			return x.Block < y.Block
+		}
+		return x.Branch < y.Branch
+	})
+	return branches
+}
+
+func (f *File) sortedLines() []int {
+	lines := make([]int, 0, len(f.Lines))
+	for line := range f.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Summary counts the lines and branch outcomes found and hit.
+func (f *File) Summary() (linesFound, linesHit, branchesFound, branchesHit int) {
+	for _, count := range f.Lines {
+		linesFound++
+		if count > 0 {
+			linesHit++
+		}
+	}
+	for _, b := range f.Branches {
+		branchesFound++
+		if b.Taken > 0 {
+			branchesHit++
+		}
+	}
+	return
+}
+
+// Report is the coverage of any number of files, possibly from several runs.
+type Report struct {
+	Files map[string]*File
+}
+
+func NewReport() *Report {
+	return &Report{Files: map[string]*File{}}
+}
+
+// Merge adds the counts from other into r.
+func (r *Report) Merge(other *Report) {
+	for path, file := range other.Files {
+		into, ok := r.Files[path]
+		if !ok {
+			into = newFile(path)
+			r.Files[path] = into
+		}
+		for line, count := range file.Lines {
+			into.Lines[line] += count
+		}
+		for _, b := range file.Branches {
+			into.addBranch(*b)
+		}
+	}
+}
+
+func (r *Report) sortedFiles() []*File {
+	files := make([]*File, 0, len(r.Files))
+	for _, file := range r.Files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// WriteLCOV writes the report as an LCOV tracefile.
+func (r *Report) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, file := range r.sortedFiles() {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", file.Path)
+		for _, b := range file.SortedBranches() {
+			taken := "-"
+			if b.Evaluated {
+				taken = strconv.FormatInt(b.Taken, 10)
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.Line, b.Block, b.Branch, taken)
+		}
+		linesFound, linesHit, branchesFound, branchesHit := file.Summary()
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", branchesFound, branchesHit)
+		for _, line := range file.sortedLines() {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, file.Lines[line])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", linesFound, linesHit)
+	}
+	return bw.Flush()
+}
+
+// ReadLCOV parses the records written by WriteLCOV. Summary lines are
+// recomputed rather than trusted, and unknown records are ignored.
+func ReadLCOV(r io.Reader) (*Report, error) {
+	report := NewReport()
+	var file *File
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		record, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		switch record {
+		case "SF":
+			file = newFile(value)
+			report.Files[value] = file
+		case "DA", "BRDA":
+			if file == nil {
+				return nil, fmt.Errorf("lcov line %d: %s outside of a file record", lineNumber, record)
+			}
+			fields := strings.Split(value, ",")
+			if err := readRecord(file, record, fields); err != nil {
+				return nil, fmt.Errorf("lcov line %d: %v", lineNumber, err)
+			}
+		case "end_of_record":
+			file = nil
+		}
+	}
+	return report, scanner.Err()
+}
+
+func readRecord(file *File, record string, fields []string) error {
+	numbers := make([]int64, len(fields))
+	for i, field := range fields {
+		if field == "-" && record == "BRDA" && i == 3 {
+			continue
+		}
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s record", record)
+		}
+		numbers[i] = n
+	}
+
+	switch {
+	case record == "DA" && len(fields) >= 2:
+		file.Lines[int(numbers[0])] += numbers[1]
+	case record == "BRDA" && len(fields) == 4:
+		file.addBranch(Branch{
+			Line:      int(numbers[0]),
+			Block:     int(numbers[1]),
+			Branch:    int(numbers[2]),
+			Evaluated: fields[3] != "-",
+			Taken:     numbers[3],
+		})
+	default:
+		return fmt.Errorf("invalid %s record", record)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package coverage
+
+import (
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+)
+
+// branchPoint is a conditional whose two outcomes are counted separately.
+type branchPoint struct {
+	line   int
+	block  int
+	truthy int64
+	falsy  int64
+}
+
+// Tracker is an interpreter.BranchHook that counts executed statements and
+// the outcomes of every If, While, ForIn, Logical, Ternary, match case and
+// null-safe access in one file.
+type Tracker struct {
+	path       string
+	statements map[expression.Stmt]int64
+	order      []expression.Stmt
+	branches   map[interface{}]*branchPoint
+	branchList []*branchPoint
+}
+
+// NewTracker registers every statement and branch point up front so that
+// code which never runs is reported with a zero count.
+func NewTracker(path string, statements []expression.Stmt) *Tracker {
+	t := &Tracker{
+		path:       path,
+		statements: map[expression.Stmt]int64{},
+		branches:   map[interface{}]*branchPoint{},
+	}
+	w := &walker{tracker: t, blocks: map[int]int{}}
+	for _, stmt := range statements {
+		w.stmt(stmt)
+	}
+	return t
+}
+
+func (t *Tracker) BeforeStatement(stmt expression.Stmt, env *environment.Environment) {
+	t.statements[stmt]++
+}
+
+func (t *Tracker) AfterStatement(stmt expression.Stmt) {}
+
+func (t *Tracker) Branch(node interface{}, truthy bool) {
+	point, ok := t.branches[node]
+	if !ok {
+		return
+	}
+	if truthy {
+		point.truthy++
+	} else {
+		point.falsy++
+	}
+}
+
+// Report summarizes the counts. A line's count is the largest count of the
+// statements that start on it.
+func (t *Tracker) Report() *Report {
+	file := newFile(t.path)
+	for _, stmt := range t.order {
+		line := stmt.Line()
+		if count := t.statements[stmt]; count > file.Lines[line] {
+			file.Lines[line] = count
+		} else if _, ok := file.Lines[line]; !ok {
+			file.Lines[line] = 0
+		}
+	}
+	for _, point := range t.branchList {
+		evaluated := point.truthy+point.falsy > 0
+		file.addBranch(Branch{Line: point.line, Block: point.block, Branch: 0, Evaluated: evaluated, Taken: point.truthy})
+		file.addBranch(Branch{Line: point.line, Block: point.block, Branch: 1, Evaluated: evaluated, Taken: point.falsy})
+	}
+
+	report := NewReport()
+	report.Files[t.path] = file
+	return report
+}
+
+// walker visits every node to find the statements and branch points.
+type walker struct {
+	tracker *Tracker
+	// blocks numbers the branch points on each line.
+	blocks map[int]int
+}
+
+func (w *walker) stmt(stmt expression.Stmt) {
+	if stmt == nil {
+		return
+	}
+	w.tracker.statements[stmt] = 0
+	w.tracker.order = append(w.tracker.order, stmt)
+	stmt.Accept(w)
+}
+
+func (w *walker) expr(expr expression.Expr) {
+	expr.Accept(w)
+}
+
+func (w *walker) branch(node interface{}, line int) {
+	point := &branchPoint{line: line, block: w.blocks[line]}
+	w.blocks[line]++
+	w.tracker.branches[node] = point
+	w.tracker.branchList = append(w.tracker.branchList, point)
+}
+
+func (w *walker) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	w.expr(stmt.Expr)
+	return nil
+}
+
+func (w *walker) VisitPrintStmt(stmt *expression.Print) interface{} {
+	w.expr(stmt.Expression)
+	return nil
+}
+
+func (w *walker) VisitVarStmt(stmt *expression.Var) interface{} {
+	if stmt.Initializer != nil {
+		w.expr(stmt.Initializer)
+	}
+	return nil
+}
+
+func (w *walker) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	w.expr(stmt.Initializer)
+	return nil
+}
+
+func (w *walker) VisitWhileStmt(stmt *expression.While) interface{} {
+	w.branch(stmt, stmt.Line())
+	w.expr(stmt.Condition)
+	w.stmt(stmt.Body)
+	return nil
+}
+
+func (w *walker) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	w.branch(stmt, stmt.Line())
+	w.expr(stmt.Iterable)
+	w.stmt(stmt.Body)
+	return nil
+}
+
+func (w *walker) VisitBlockStmt(stmt *expression.Block) interface{} {
+	for _, s := range stmt.Statements {
+		w.stmt(s)
+	}
+	return nil
+}
+
+func (w *walker) VisitIfStmt(stmt *expression.If) interface{} {
+	w.branch(stmt, stmt.Line())
+	w.expr(stmt.Condition)
+	w.stmt(stmt.ThenBranch)
+	w.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (w *walker) VisitMatchStmt(stmt *expression.Match) interface{} {
+	w.expr(stmt.Subject)
+	for _, c := range stmt.Cases {
+		w.branch(c, c.Keyword.Line)
+		if c.Guard != nil {
+			w.expr(c.Guard)
+		}
+		w.stmt(c.Body)
+	}
+	return nil
+}
+
+func (w *walker) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	w.expr(stmt.Call)
+	return nil
+}
+
+func (w *walker) VisitSelectStmt(stmt *expression.Select) interface{} {
+	for _, c := range stmt.Cases {
+		w.branch(c, c.Keyword.Line)
+		if c.Channel != nil {
+			w.expr(c.Channel)
+		}
+		if c.Value != nil {
+			w.expr(c.Value)
+		}
+		w.stmt(c.Body)
+	}
+	return nil
+}
+
+func (w *walker) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	for _, s := range stmt.Body {
+		w.stmt(s)
+	}
+	return nil
+}
+
+func (w *walker) VisitReturnStmt(stmt *expression.Return) interface{} {
+	if stmt.Value != nil {
+		w.expr(stmt.Value)
+	}
+	return nil
+}
+
+func (w *walker) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value != nil {
+		w.expr(stmt.Value)
+	}
+	return nil
+}
+
+func (w *walker) VisitTestStmt(stmt *expression.Test) interface{} {
+	for _, s := range stmt.Body {
+		w.stmt(s)
+	}
+	return nil
+}
+
+func (w *walker) VisitImportStmt(stmt *expression.Import) interface{} {
+	return nil
+}
+
+func (w *walker) VisitAssignExpr(expr *expression.Assign) interface{} {
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w *walker) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w *walker) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	w.expr(expr.Target)
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w *walker) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	w.expr(expr.Target)
+	return nil
+}
+
+func (w *walker) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	w.expr(expr.Left)
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w *walker) VisitCallExpr(expr *expression.Call) interface{} {
+	if expr.Optional {
+		w.branch(expr, expr.Paren.Line)
+	}
+	w.expr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		w.expr(argument)
+	}
+	return nil
+}
+
+func (w *walker) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	w.branch(expr, expr.Operator.Line)
+	w.expr(expr.Condition)
+	w.expr(expr.TrueExpression)
+	w.expr(expr.FalseExpression)
+	return nil
+}
+
+func (w *walker) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	return w.VisitFunctionStmt(expr.Function)
+}
+
+func (w *walker) VisitListExpr(expr *expression.List) interface{} {
+	for _, element := range expr.Elements {
+		w.expr(element)
+	}
+	return nil
+}
+
+func (w *walker) VisitMapExpr(expr *expression.Map) interface{} {
+	for index, key := range expr.Keys {
+		w.expr(key)
+		w.expr(expr.Values[index])
+	}
+	return nil
+}
+
+func (w *walker) VisitGetExpr(expr *expression.Get) interface{} {
+	if expr.Optional {
+		w.branch(expr, expr.Name.Line)
+	}
+	w.expr(expr.Object)
+	return nil
+}
+
+func (w *walker) VisitIndexExpr(expr *expression.Index) interface{} {
+	if expr.Optional {
+		w.branch(expr, expr.Bracket.Line)
+	}
+	w.expr(expr.Object)
+	w.expr(expr.Key)
+	return nil
+}
+
+func (w *walker) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	w.expr(expr.Object)
+	w.expr(expr.Key)
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w *walker) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	w.expr(expr.Expr)
+	return nil
+}
+
+func (w *walker) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	return nil
+}
+
+func (w *walker) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	w.branch(expr, expr.Operator.Line)
+	w.expr(expr.Left)
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w *walker) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	w.expr(expr.Right)
+	return nil
+}
+
+func (w *walker) VisitAwaitExpr(expr *expression.Await) interface{} {
+	w.expr(expr.Value)
+	return nil
+}
+
+func (w *walker) VisitVariableExpr(expr *expression.Variable) interface{} {
+	return nil
+}
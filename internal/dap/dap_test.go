@@ -0,0 +1,318 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"interpreter/internal/jsonrpc"
+)
+
+// message is any response or event read back from the server.
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type testClient struct {
+	t        *testing.T
+	writer   io.Writer
+	seq      int
+	messages chan message
+}
+
+func newTestClient(t *testing.T, searchPath ...string) *testClient {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	go func() {
+		NewServer(serverReader, serverWriter, searchPath).Run()
+		serverWriter.Close()
+	}()
+
+	c := &testClient{t: t, writer: clientWriter, messages: make(chan message, 64)}
+	go func() {
+		reader := bufio.NewReader(clientReader)
+		for {
+			body, err := jsonrpc.ReadFrame(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg message
+			json.Unmarshal(body, &msg)
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { clientWriter.Close() })
+	return c
+}
+
+func (c *testClient) send(command string, arguments interface{}) {
+	c.t.Helper()
+	c.seq++
+	data, _ := json.Marshal(request{Seq: c.seq, Type: "request", Command: command, Arguments: mustMarshal(arguments)})
+	if err := jsonrpc.WriteFrame(c.writer, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// expect reads messages until one matches kind ("response" or "event") and
+// name, collecting program output along the way.
+func (c *testClient) expect(kind, name string, body interface{}, output *string) {
+	c.t.Helper()
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("connection closed waiting for %s %s", kind, name)
+			}
+			if msg.Type == "event" && msg.Event == "output" && output != nil {
+				var out OutputEventBody
+				json.Unmarshal(msg.Body, &out)
+				*output += out.Output
+			}
+			if msg.Type != kind || (msg.Command != name && msg.Event != name) {
+				continue
+			}
+			if kind == "response" && !msg.Success {
+				c.t.Fatalf("%s failed: %s", name, msg.Message)
+			}
+			if body != nil {
+				json.Unmarshal(msg.Body, body)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("timed out waiting for %s %s", kind, name)
+		}
+	}
+}
+
+func (c *testClient) request(command string, arguments, body interface{}) {
+	c.t.Helper()
+	c.send(command, arguments)
+	c.expect("response", command, body, nil)
+}
+
+func TestDebugSession(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.lox")
+	source := "var a = 1;\n{\n  var b = a + 1;\n  print b;\n}\nprint a;\n"
+	if err := os.WriteFile(program, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t)
+	c.request("initialize", map[string]string{"adapterID": "lox"}, nil)
+	c.expect("event", "initialized", nil, nil)
+	c.request("launch", LaunchArguments{Program: program}, nil)
+
+	var breakpoints SetBreakpointsResponseBody
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 4}, {Line: 5}},
+	}, &breakpoints)
+	if got := breakpoints.Breakpoints; !got[0].Verified || got[1].Verified {
+		t.Errorf("breakpoints = %+v, want only line 4 verified", got)
+	}
+
+	c.request("configurationDone", nil, nil)
+	var stopped StoppedEventBody
+	c.expect("event", "stopped", &stopped, nil)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("stopped reason = %q, want breakpoint", stopped.Reason)
+	}
+
+	var trace StackTraceResponseBody
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 4 {
+		t.Errorf("stack trace = %+v, want one frame on line 4", trace)
+	}
+
+	var scopes ScopesResponseBody
+	c.request("scopes", map[string]int{"frameId": 1}, &scopes)
+	wantScopes := []Scope{{Name: "Locals", VariablesReference: 1}, {Name: "Globals", VariablesReference: 2}}
+	if !reflect.DeepEqual(scopes.Scopes, wantScopes) {
+		t.Errorf("scopes = %+v, want %+v", scopes.Scopes, wantScopes)
+	}
+
+	var variables VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: 1}, &variables)
+	if want := []Variable{{Name: "b", Value: "2"}}; !reflect.DeepEqual(variables.Variables, want) {
+		t.Errorf("variables = %+v, want %+v", variables.Variables, want)
+	}
+
+	var evaluated EvaluateResponseBody
+	c.request("evaluate", EvaluateArguments{Expression: "a"}, &evaluated)
+	if evaluated.Result != "1" {
+		t.Errorf("evaluate a = %q, want 1", evaluated.Result)
+	}
+
+	var output string
+	c.send("next", map[string]int{"threadId": threadID})
+	c.expect("event", "stopped", &stopped, &output)
+	if stopped.Reason != "step" {
+		t.Errorf("stopped reason = %q, want step", stopped.Reason)
+	}
+
+	c.send("continue", map[string]int{"threadId": threadID})
+	var exited ExitedEventBody
+	c.expect("event", "exited", &exited, &output)
+	c.expect("event", "terminated", nil, nil)
+	if output != "2\n1\n" || exited.ExitCode != 0 {
+		t.Errorf("output = %q, exit code %d; want \"2\\n1\\n\", 0", output, exited.ExitCode)
+	}
+
+	c.request("disconnect", nil, nil)
+}
+
+func TestImports(t *testing.T) {
+	dir, lib := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "main.lox"):   "import \"local\" as l;\nimport \"shared\" as s;\nprint l.name + s.name;\n",
+		filepath.Join(dir, "local.lox"):  "var name = \"local \";\n",
+		filepath.Join(lib, "shared.lox"): "var name = \"shared\";\n",
+	}
+	for name, source := range files {
+		if err := os.WriteFile(name, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newTestClient(t, lib)
+	c.request("initialize", map[string]string{"adapterID": "lox"}, nil)
+	c.expect("event", "initialized", nil, nil)
+	c.request("launch", LaunchArguments{Program: filepath.Join(dir, "main.lox")}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var output string
+	var exited ExitedEventBody
+	c.expect("event", "exited", &exited, &output)
+	if output != "local shared\n" || exited.ExitCode != 0 {
+		t.Errorf("output = %q, exit code %d; want \"local shared\\n\", 0", output, exited.ExitCode)
+	}
+	c.request("disconnect", nil, nil)
+}
+
+func TestStackFrames(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.lox")
+	source := "fun plus(a, b) {\n  var sum = a + b;\n  return sum;\n}\nvar total = plus(1, 2);\nprint total;\n"
+	if err := os.WriteFile(program, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t)
+	c.request("initialize", map[string]string{"adapterID": "lox"}, nil)
+	c.expect("event", "initialized", nil, nil)
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 3}},
+	}, nil)
+	c.request("configurationDone", nil, nil)
+	c.expect("event", "stopped", nil, nil)
+
+	var trace StackTraceResponseBody
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	var frames []string
+	for _, frame := range trace.StackFrames {
+		frames = append(frames, fmt.Sprintf("%d %s:%d", frame.ID, frame.Name, frame.Line))
+	}
+	if want := []string{"1 plus:3", "2 main:5"}; !reflect.DeepEqual(frames, want) || trace.TotalFrames != 2 {
+		t.Errorf("frames = %q (total %d), want %q", frames, trace.TotalFrames, want)
+	}
+
+	var evaluated EvaluateResponseBody
+	c.request("evaluate", EvaluateArguments{Expression: "sum", FrameID: 1}, &evaluated)
+	if evaluated.Result != "3" {
+		t.Errorf("evaluate sum in plus = %q, want 3", evaluated.Result)
+	}
+	var scopes ScopesResponseBody
+	c.request("scopes", map[string]int{"frameId": 2}, &scopes)
+	if len(scopes.Scopes) != 1 || scopes.Scopes[0].Name != "Globals" {
+		t.Fatalf("scopes of main = %+v, want only Globals", scopes.Scopes)
+	}
+	var variables VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &variables)
+	found := false
+	for _, v := range variables.Variables {
+		found = found || v.Name == "plus"
+	}
+	if !found {
+		t.Errorf("globals = %+v, want plus among them", variables.Variables)
+	}
+
+	c.send("continue", map[string]int{"threadId": threadID})
+	c.expect("event", "terminated", nil, nil)
+	c.request("disconnect", nil, nil)
+}
+
+func TestModuleBreakpoints(t *testing.T) {
+	dir := t.TempDir()
+	program, helper := filepath.Join(dir, "main.lox"), filepath.Join(dir, "util", "h.lox")
+	if err := os.MkdirAll(filepath.Dir(helper), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, source := range map[string]string{
+		program: "import \"util/h\" as h;\n\nvar x = h.twice(2);\nprint x;\n",
+		helper:  "fun twice(n) {\n  return n * 2;\n}\n",
+	} {
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newTestClient(t)
+	c.request("initialize", map[string]string{"adapterID": "lox"}, nil)
+	c.expect("event", "initialized", nil, nil)
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	var breakpoints SetBreakpointsResponseBody
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 2}, {Line: 4}},
+	}, &breakpoints)
+	if got := breakpoints.Breakpoints; got[0].Verified || !got[1].Verified {
+		t.Errorf("breakpoints in main = %+v, want only line 4 verified", got)
+	}
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: helper},
+		Breakpoints: []SourceBreakpoint{{Line: 2}},
+	}, nil)
+	c.request("configurationDone", nil, nil)
+
+	for _, want := range [][]string{
+		{"twice " + helper + ":2", "main " + program + ":3"},
+		{"main " + program + ":4"},
+	} {
+		c.expect("event", "stopped", nil, nil)
+		var trace StackTraceResponseBody
+		c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+		var frames []string
+		for _, frame := range trace.StackFrames {
+			frames = append(frames, fmt.Sprintf("%s %s:%d", frame.Name, frame.Source.Path, frame.Line))
+		}
+		if !reflect.DeepEqual(frames, want) {
+			t.Errorf("frames = %q, want %q", frames, want)
+		}
+		c.send("continue", map[string]int{"threadId": threadID})
+	}
+	c.expect("event", "terminated", nil, nil)
+	c.request("disconnect", nil, nil)
+}
@@ -0,0 +1,391 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"interpreter/internal/debugger"
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+	"interpreter/internal/jsonrpc"
+	"interpreter/internal/module"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// The program is reported as a single thread: only the goroutine that
+// stopped is shown, with its frames numbered from 1, innermost first.
+const threadID = 1
+
+// Server is a Debug Adapter Protocol server for a single launch.
+type Server struct {
+	reader *bufio.Reader
+	writer io.Writer
+
+	searchPath []string
+
+	mu          sync.Mutex
+	seq         int
+	loader      *module.Loader
+	modulePath  string
+	statements  []expression.Stmt
+	breakpoints map[string][]int // lines by the loader's path of their file
+	debugger    *debugger.Debugger
+	stop        *debugger.Stop
+	// scopes are those handed out since the program stopped; a scope's
+	// variablesReference is its index plus one.
+	scopes []debugger.Scope
+}
+
+// NewServer returns a server whose programs import modules from beside
+// themselves or from the directories in searchPath.
+func NewServer(r io.Reader, w io.Writer, searchPath []string) *Server {
+	return &Server{reader: bufio.NewReader(r), writer: w, searchPath: searchPath, breakpoints: map[string][]int{}}
+}
+
+// Run serves requests until the client disconnects.
+func (s *Server) Run() error {
+	for {
+		body, err := jsonrpc.ReadFrame(s.reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.respond(req, nil, nil)
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+func (s *Server) handle(req request) {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil)
+		s.sendEvent("initialized", nil)
+	case "launch":
+		var args LaunchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		s.respond(req, nil, s.launch(args))
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		s.respond(req, s.setBreakpoints(args), nil)
+	case "configurationDone":
+		s.respond(req, nil, s.start())
+	case "threads":
+		s.respond(req, ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil)
+	case "stackTrace":
+		s.withStop(req, func(stop *debugger.Stop) interface{} {
+			s.mu.Lock()
+			loader := s.loader
+			s.mu.Unlock()
+			frames := []StackFrame{}
+			for index, frame := range stop.Frames {
+				path := loader.Display(frame.File)
+				frames = append(frames, StackFrame{
+					ID:     index + 1,
+					Name:   frame.Name,
+					Source: Source{Name: filepath.Base(path), Path: path},
+					Line:   frame.Line,
+					Column: 1,
+				})
+			}
+			return StackTraceResponseBody{StackFrames: frames, TotalFrames: len(frames)}
+		})
+	case "scopes":
+		var args ScopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		s.withStop(req, func(stop *debugger.Stop) interface{} {
+			scopes := []Scope{}
+			for _, scope := range debugger.Scopes(frameEnv(stop, args.FrameID)) {
+				s.mu.Lock()
+				s.scopes = append(s.scopes, scope)
+				ref := len(s.scopes)
+				s.mu.Unlock()
+				scopes = append(scopes, Scope{Name: scope.Name, VariablesReference: ref})
+			}
+			return ScopesResponseBody{Scopes: scopes}
+		})
+	case "variables":
+		var args VariablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		s.withStop(req, func(stop *debugger.Stop) interface{} {
+			variables := []Variable{}
+			s.mu.Lock()
+			if ref := args.VariablesReference; ref >= 1 && ref <= len(s.scopes) {
+				for _, v := range s.scopes[ref-1].Variables {
+					variables = append(variables, Variable{Name: v.Name, Value: v.Value})
+				}
+			}
+			s.mu.Unlock()
+			return VariablesResponseBody{Variables: variables}
+		})
+	case "evaluate":
+		var args EvaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		stop := s.currentStop()
+		if stop == nil {
+			s.respond(req, nil, errors.New("the program is not paused"))
+			return
+		}
+		value, ok := debugger.Lookup(frameEnv(stop, args.FrameID), args.Expression)
+		if !ok {
+			s.respond(req, nil, fmt.Errorf("Undefined variable '%s'.", args.Expression))
+			return
+		}
+		s.respond(req, EvaluateResponseBody{Result: value}, nil)
+	case "continue":
+		s.resume(req, ContinueResponseBody{AllThreadsContinued: true}, (*debugger.Debugger).Continue)
+	case "next":
+		s.resume(req, nil, (*debugger.Debugger).StepOver)
+	case "stepIn":
+		s.resume(req, nil, (*debugger.Debugger).StepIn)
+	case "stepOut":
+		s.resume(req, nil, (*debugger.Debugger).StepOut)
+	case "pause":
+		s.mu.Lock()
+		d := s.debugger
+		s.mu.Unlock()
+		if d != nil {
+			d.Pause()
+		}
+		s.respond(req, nil, nil)
+	default:
+		s.respond(req, nil, fmt.Errorf("unsupported request: %s", req.Command))
+	}
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	source, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	tokens, err := scanner.NewScanner(string(source)).ScanTokens()
+	if err != nil {
+		return err
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		return err
+	}
+	loader, err := module.NewOSLoader(s.searchPath)
+	if err != nil {
+		return err
+	}
+	modulePath, err := module.FSPath(args.Program)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loader, s.modulePath = loader, modulePath
+	s.statements = statements
+	s.debugger = debugger.New(args.StopOnEntry)
+	for file, lines := range s.breakpoints {
+		s.debugger.SetBreakpoints(file, lines)
+	}
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints in the source named, keeping
+// those of other files.
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) SetBreakpointsResponseBody {
+	file, err := module.FSPath(args.Source.Path)
+	if err != nil {
+		file = args.Source.Path
+	}
+	lines := statementLines(args.Source.Path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	var set []int
+	for _, bp := range args.Breakpoints {
+		verified := lines == nil || lines[bp.Line]
+		breakpoint := Breakpoint{Verified: verified, Line: bp.Line}
+		if verified {
+			set = append(set, bp.Line)
+		} else {
+			breakpoint.Message = "no statement starts on this line"
+		}
+		body.Breakpoints = append(body.Breakpoints, breakpoint)
+	}
+	s.breakpoints[file] = set
+	if s.debugger != nil {
+		s.debugger.SetBreakpoints(file, set)
+	}
+	return body
+}
+
+// statementLines returns the lines of the file at path that a breakpoint
+// can stop on, or nil, so that any line is taken, when it can't be parsed.
+func statementLines(path string) map[int]bool {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	tokens, err := scanner.NewScanner(string(source)).ScanTokens()
+	if err != nil {
+		return nil
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		return nil
+	}
+	return debugger.StatementLines(statements)
+}
+
+func (s *Server) start() error {
+	s.mu.Lock()
+	d, statements := s.debugger, s.statements
+	loader, modulePath := s.loader, s.modulePath
+	s.mu.Unlock()
+	if d == nil {
+		return errors.New("configurationDone received before launch")
+	}
+
+	i := interpreter.NewInterpreter()
+	i.SetOutput(outputWriter{s})
+	i.SetLoader(d.Loader(loader, modulePath), modulePath)
+	done := d.Start(i, statements)
+
+	go func() {
+		for {
+			select {
+			case stop := <-d.Stops():
+				s.mu.Lock()
+				s.stop, s.scopes = &stop, nil
+				s.mu.Unlock()
+				s.sendEvent("stopped", StoppedEventBody{
+					Reason:            string(stop.Reason),
+					ThreadID:          threadID,
+					AllThreadsStopped: true,
+				})
+			case err := <-done:
+				exitCode := 0
+				if err != nil {
+					exitCode = 70
+					s.sendEvent("output", OutputEventBody{Category: "stderr", Output: err.Error() + "\n"})
+				}
+				s.sendEvent("exited", ExitedEventBody{ExitCode: exitCode})
+				s.sendEvent("terminated", nil)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// frameEnv returns the scope of the frame with id, or of the innermost
+// frame when there is no such frame.
+func frameEnv(stop *debugger.Stop, id int) *environment.Environment {
+	if id >= 1 && id <= len(stop.Frames) {
+		return stop.Frames[id-1].Env
+	}
+	return stop.Env
+}
+
+func (s *Server) currentStop() *debugger.Stop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop
+}
+
+func (s *Server) withStop(req request, body func(stop *debugger.Stop) interface{}) {
+	stop := s.currentStop()
+	if stop == nil {
+		s.respond(req, nil, errors.New("the program is not paused"))
+		return
+	}
+	s.respond(req, body(stop), nil)
+}
+
+// resume answers req and then lets the paused program continue, so that the
+// response always precedes the next stopped event.
+func (s *Server) resume(req request, body interface{}, action func(*debugger.Debugger)) {
+	s.mu.Lock()
+	d, stop := s.debugger, s.stop
+	s.stop = nil
+	s.mu.Unlock()
+	if stop == nil {
+		s.respond(req, nil, errors.New("the program is not paused"))
+		return
+	}
+	s.respond(req, body, nil)
+	action(d)
+}
+
+func (s *Server) respond(req request, body interface{}, err error) {
+	resp := response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(func(seq int) interface{} {
+		resp.Seq = seq
+		return resp
+	})
+}
+
+func (s *Server) sendEvent(name string, body interface{}) {
+	s.send(func(seq int) interface{} {
+		return event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// send numbers and writes a message. The lock keeps sequence numbers in the
+// same order as the messages on the wire.
+func (s *Server) send(message func(seq int) interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	data, err := json.Marshal(message(s.seq))
+	if err != nil {
+		return
+	}
+	jsonrpc.WriteFrame(s.writer, data)
+}
+
+// outputWriter forwards the program's print output as output events.
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.sendEvent("output", OutputEventBody{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
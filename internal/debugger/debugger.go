@@ -0,0 +1,507 @@
+package debugger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+)
+
+type StopReason string
+
+const (
+	StopEntry      StopReason = "entry"
+	StopBreakpoint StopReason = "breakpoint"
+	StopStep       StopReason = "step"
+	StopPause      StopReason = "pause"
+)
+
+// Stop describes the statement the program is paused before, at Line of
+// File.
+type Stop struct {
+	Reason StopReason
+	File   string
+	Line   int
+	Env    *environment.Environment
+	// Frames are the calls of the goroutine that stopped, innermost first,
+	// so the first is at Line in Env.
+	Frames []Frame
+}
+
+// Frame is a function call in progress, or the top level of the script,
+// named "main". File and Line are where it is, and Env its innermost scope
+// there.
+type Frame struct {
+	Name string
+	File string
+	Line int
+	Env  *environment.Environment
+}
+
+// Breakpoint is a line of a file. Files are named by the paths of the
+// module loader, the script being debugged by the path given to Loader.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+type mode int
+
+const (
+	running mode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// Debugger pauses the program at breakpoints and after steps. The
+// interpreter runs on its own goroutine; a front end receives Stops and
+// resumes the program with Continue or one of the steps.
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints map[Breakpoint]bool
+	mode        mode
+	pause       bool
+	entry       bool
+	// program is the file of the script being debugged and files that of
+	// every statement in a module it imports.
+	program string
+	files   map[expression.Stmt]string
+	// stepping is the goroutine that was paused when a step over or out was
+	// asked for, and stepDepth its depth then. Other goroutines have their
+	// own stacks, so they do not end the step.
+	stepping  *thread
+	stepDepth int
+
+	stops  chan Stop
+	resume chan mode
+}
+
+// thread is the interpreter.Hook for one goroutine of the program, holding
+// its stacks.
+type thread struct {
+	d *Debugger
+	// stack holds the statements currently being executed, outermost
+	// first, and calls the functions running, each with the length stack
+	// had when it was called.
+	stack []statement
+	calls []call
+}
+
+type statement struct {
+	stmt expression.Stmt
+	env  *environment.Environment
+}
+
+type call struct {
+	fn    *expression.Function
+	depth int
+}
+
+// New returns a debugger. With stopOnEntry set it pauses before the first
+// statement.
+func New(stopOnEntry bool) *Debugger {
+	return &Debugger{
+		breakpoints: map[Breakpoint]bool{},
+		files:       map[expression.Stmt]string{},
+		entry:       stopOnEntry,
+		stops:       make(chan Stop),
+		resume:      make(chan mode),
+	}
+}
+
+// Start runs statements under the debugger on a new goroutine. The returned
+// channel receives the program's result once it finishes.
+func (d *Debugger) Start(i *interpreter.Interpreter, statements []expression.Stmt) <-chan error {
+	done := make(chan error, 1)
+	i.SetHook(&thread{d: d})
+	go func() {
+		done <- i.Interpret(statements)
+	}()
+	return done
+}
+
+// Loader returns a module loader for the program that loads modules with
+// loader, noting which file each of their statements is in so that
+// breakpoints stop in the right one. path is the file of the script being
+// debugged, as given to Interpreter.SetLoader.
+func (d *Debugger) Loader(loader interpreter.ModuleLoader, path string) interpreter.ModuleLoader {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.program = path
+	return fileLoader{loader, d}
+}
+
+// fileLoader is the loader returned by Debugger.Loader.
+type fileLoader struct {
+	interpreter.ModuleLoader
+	d *Debugger
+}
+
+func (l fileLoader) Load(path string) ([]expression.Stmt, error) {
+	statements, err := l.ModuleLoader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	l.d.mu.Lock()
+	defer l.d.mu.Unlock()
+	walkStatements(statements, func(stmt expression.Stmt) {
+		l.d.files[stmt] = path
+	})
+	return statements, nil
+}
+
+// file returns the file stmt is in. d.mu must be held.
+func (d *Debugger) file(stmt expression.Stmt) string {
+	if file, ok := d.files[stmt]; ok {
+		return file
+	}
+	return d.program
+}
+
+// Stops delivers a Stop every time the program pauses.
+func (d *Debugger) Stops() <-chan Stop {
+	return d.stops
+}
+
+// SetBreakpoints replaces the breakpoints in file with one on each of
+// lines, leaving those in other files alone.
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for bp := range d.breakpoints {
+		if bp.File == file {
+			delete(d.breakpoints, bp)
+		}
+	}
+	for _, line := range lines {
+		d.breakpoints[Breakpoint{file, line}] = true
+	}
+}
+
+// Breakpoints returns every breakpoint, ordered by file and then line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	breakpoints := make([]Breakpoint, 0, len(d.breakpoints))
+	for bp := range d.breakpoints {
+		breakpoints = append(breakpoints, bp)
+	}
+	sort.Slice(breakpoints, func(a, b int) bool {
+		if breakpoints[a].File != breakpoints[b].File {
+			return breakpoints[a].File < breakpoints[b].File
+		}
+		return breakpoints[a].Line < breakpoints[b].Line
+	})
+	return breakpoints
+}
+
+func (d *Debugger) AddBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[Breakpoint{file, line}] = true
+}
+
+func (d *Debugger) RemoveBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, Breakpoint{file, line})
+}
+
+// Pause asks a running program to stop before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// The following resume a paused program. They must only be called after a
+// Stop has been received.
+
+func (d *Debugger) Continue() { d.resume <- running }
+func (d *Debugger) StepIn()   { d.resume <- stepIn }
+func (d *Debugger) StepOver() { d.resume <- stepOver }
+func (d *Debugger) StepOut()  { d.resume <- stepOut }
+
+func (t *thread) BeforeStatement(stmt expression.Stmt, env *environment.Environment) {
+	t.stack = append(t.stack, statement{stmt, env})
+	depth := len(t.stack)
+
+	d := t.d
+	d.mu.Lock()
+	file := d.file(stmt)
+	// A line is reported once, however many statements start on it.
+	if depth > 1 && t.stack[depth-2].stmt.Line() == stmt.Line() && d.file(t.stack[depth-2].stmt) == file {
+		d.mu.Unlock()
+		return
+	}
+	reason, stop := d.shouldStop(t, Breakpoint{file, stmt.Line()}, depth)
+	d.pause = false
+	var frames []Frame
+	if stop {
+		frames = t.frames()
+	}
+	d.mu.Unlock()
+	if !stop {
+		return
+	}
+
+	d.stops <- Stop{Reason: reason, File: file, Line: stmt.Line(), Env: env, Frames: frames}
+	mode := <-d.resume
+	d.mu.Lock()
+	d.mode, d.stepping, d.stepDepth = mode, t, depth
+	d.mu.Unlock()
+}
+
+func (d *Debugger) shouldStop(t *thread, at Breakpoint, depth int) (StopReason, bool) {
+	if d.entry {
+		d.entry = false
+		return StopEntry, true
+	}
+	if d.pause {
+		return StopPause, true
+	}
+	switch {
+	case d.mode == stepIn:
+		return StopStep, true
+	case d.mode == stepOver && t == d.stepping && depth <= d.stepDepth:
+		return StopStep, true
+	case d.mode == stepOut && t == d.stepping && depth < d.stepDepth:
+		return StopStep, true
+	}
+	if d.breakpoints[at] {
+		return StopBreakpoint, true
+	}
+	return "", false
+}
+
+func (t *thread) AfterStatement(stmt expression.Stmt) {
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// Thread gives a goroutine the program starts stacks of its own.
+func (t *thread) Thread() interpreter.Hook {
+	return &thread{d: t.d}
+}
+
+func (t *thread) EnterFunction(fn *expression.Function) {
+	t.calls = append(t.calls, call{fn, len(t.stack)})
+}
+
+func (t *thread) ExitFunction(fn *expression.Function) {
+	t.calls = t.calls[:len(t.calls)-1]
+}
+
+// frames lists the calls in progress, innermost first, each at the
+// statement it is running. A goroutine started for a function has no main
+// frame below it. t.d.mu must be held.
+func (t *thread) frames() []Frame {
+	var frames []Frame
+	frame := func(name string, current statement) Frame {
+		return Frame{Name: name, File: t.d.file(current.stmt), Line: current.stmt.Line(), Env: current.env}
+	}
+	top := len(t.stack)
+	for index := len(t.calls) - 1; index >= 0; index-- {
+		c := t.calls[index]
+		if top > c.depth {
+			frames = append(frames, frame(functionName(c.fn), t.stack[top-1]))
+		}
+		top = c.depth
+	}
+	if top > 0 {
+		frames = append(frames, frame("main", t.stack[top-1]))
+	}
+	return frames
+}
+
+func functionName(fn *expression.Function) string {
+	if fn.Name.Lexeme == "" {
+		return fmt.Sprintf("lambda@%d", fn.Line())
+	}
+	return fn.Name.Lexeme
+}
+
+// Scope is one level of the environment chain.
+type Scope struct {
+	Name      string
+	Variables []Variable
+}
+
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// Scopes lists the environment chain from the innermost scope outwards.
+func Scopes(env *environment.Environment) []Scope {
+	var scopes []Scope
+	for e := env; e != nil; e = e.Enclosing() {
+		name := "Block"
+		if len(scopes) == 0 {
+			name = "Locals"
+		}
+		if e.Enclosing() == nil {
+			name = "Globals"
+		}
+		scopes = append(scopes, Scope{Name: name, Variables: variables(e)})
+	}
+	return scopes
+}
+
+func variables(env *environment.Environment) []Variable {
+	values := env.Values()
+	vars := make([]Variable, 0, len(values))
+	for name, value := range values {
+		vars = append(vars, Variable{Name: name, Value: interpreter.Stringify(value)})
+	}
+	sort.Slice(vars, func(a, b int) bool { return vars[a].Name < vars[b].Name })
+	return vars
+}
+
+// Lookup finds the innermost variable called name visible from env.
+func Lookup(env *environment.Environment, name string) (string, bool) {
+	for e := env; e != nil; e = e.Enclosing() {
+		if value, ok := e.Values()[name]; ok {
+			return interpreter.Stringify(value), true
+		}
+	}
+	return "", false
+}
+
+// StatementLines returns every line on which a statement starts, which are
+// the only lines a breakpoint can stop on. Statements in the bodies of
+// lambdas count too.
+func StatementLines(statements []expression.Stmt) map[int]bool {
+	lines := map[int]bool{}
+	walkStatements(statements, func(stmt expression.Stmt) {
+		lines[stmt.Line()] = true
+	})
+	return lines
+}
+
+// walkStatements calls visit on each of statements and every statement
+// nested in them, including those in the bodies of functions and lambdas.
+func walkStatements(statements []expression.Stmt, visit func(expression.Stmt)) {
+	var walk func(stmt expression.Stmt)
+	var walkExpr func(expr expression.Expr)
+	walk = func(stmt expression.Stmt) {
+		if stmt == nil {
+			return
+		}
+		visit(stmt)
+		switch s := stmt.(type) {
+		case *expression.Expression:
+			walkExpr(s.Expr)
+		case *expression.Print:
+			walkExpr(s.Expression)
+		case *expression.Var:
+			walkExpr(s.Initializer)
+		case *expression.VarDestructure:
+			walkExpr(s.Initializer)
+		case *expression.Return:
+			walkExpr(s.Value)
+		case *expression.Yield:
+			walkExpr(s.Value)
+		case *expression.Block:
+			for _, inner := range s.Statements {
+				walk(inner)
+			}
+		case *expression.If:
+			walkExpr(s.Condition)
+			walk(s.ThenBranch)
+			walk(s.ElseBranch)
+		case *expression.While:
+			walkExpr(s.Condition)
+			walk(s.Body)
+		case *expression.ForIn:
+			walkExpr(s.Iterable)
+			walk(s.Body)
+		case *expression.Match:
+			walkExpr(s.Subject)
+			for _, c := range s.Cases {
+				if c.Guard != nil {
+					walkExpr(c.Guard)
+				}
+				walk(c.Body)
+			}
+		case *expression.Spawn:
+			walkExpr(s.Call)
+		case *expression.Select:
+			for _, c := range s.Cases {
+				walkExpr(c.Channel)
+				walkExpr(c.Value)
+				walk(c.Body)
+			}
+		case *expression.Function:
+			for _, inner := range s.Body {
+				walk(inner)
+			}
+		case *expression.Test:
+			for _, inner := range s.Body {
+				walk(inner)
+			}
+		}
+	}
+	walkExpr = func(expr expression.Expr) {
+		switch e := expr.(type) {
+		case *expression.Lambda:
+			for _, inner := range e.Function.Body {
+				walk(inner)
+			}
+		case *expression.Assign:
+			walkExpr(e.Value)
+		case *expression.Destructure:
+			walkExpr(e.Value)
+		case *expression.Compound:
+			walkExpr(e.Target)
+			walkExpr(e.Value)
+		case *expression.Increment:
+			walkExpr(e.Target)
+		case *expression.SetIndex:
+			walkExpr(e.Object)
+			walkExpr(e.Key)
+			walkExpr(e.Value)
+		case *expression.Binary:
+			walkExpr(e.Left)
+			walkExpr(e.Right)
+		case *expression.Logical:
+			walkExpr(e.Left)
+			walkExpr(e.Right)
+		case *expression.Call:
+			walkExpr(e.Callee)
+			for _, argument := range e.Arguments {
+				walkExpr(argument)
+			}
+		case *expression.Ternary:
+			walkExpr(e.Condition)
+			walkExpr(e.TrueExpression)
+			walkExpr(e.FalseExpression)
+		case *expression.List:
+			for _, element := range e.Elements {
+				walkExpr(element)
+			}
+		case *expression.Map:
+			for index, key := range e.Keys {
+				walkExpr(key)
+				walkExpr(e.Values[index])
+			}
+		case *expression.Get:
+			walkExpr(e.Object)
+		case *expression.Index:
+			walkExpr(e.Object)
+			walkExpr(e.Key)
+		case *expression.Grouping:
+			walkExpr(e.Expr)
+		case *expression.Unary:
+			walkExpr(e.Right)
+		case *expression.Await:
+			walkExpr(e.Value)
+		}
+	}
+	for _, stmt := range statements {
+		walk(stmt)
+	}
+}
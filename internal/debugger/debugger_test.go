@@ -0,0 +1,206 @@
+package debugger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"interpreter/internal/module"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+const program = `var a = 1;
+{
+  var b = 2;
+  print a + b;
+}
+var c = 3;
+print c;
+`
+
+func debug(t *testing.T, commands string) string {
+	t.Helper()
+	return debugSource(t, program, commands)
+}
+
+func debugSource(t *testing.T, program, commands string) string {
+	t.Helper()
+	tokens, err := scanner.NewScanner(program).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	NewTerminal(program, strings.NewReader(commands), &out).Run(statements)
+	return out.String()
+}
+
+func stops(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if i := strings.Index(line, "Stopped"); i >= 0 {
+			lines = append(lines, line[i:])
+		}
+	}
+	return lines
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands string
+		want     []string
+	}{
+		{
+			name:     "Step over skips nested statements",
+			commands: "n\nn\nn\nc\n",
+			want: []string{
+				"Stopped (entry) at line 1: var a = 1;",
+				"Stopped (step) at line 2: {",
+				"Stopped (step) at line 6: var c = 3;",
+				"Stopped (step) at line 7: print c;",
+			},
+		},
+		{
+			name:     "Step in enters blocks",
+			commands: "s\ns\ns\no\nc\n",
+			want: []string{
+				"Stopped (entry) at line 1: var a = 1;",
+				"Stopped (step) at line 2: {",
+				"Stopped (step) at line 3: var b = 2;",
+				"Stopped (step) at line 4: print a + b;",
+				"Stopped (step) at line 6: var c = 3;",
+			},
+		},
+		{
+			name:     "Breakpoints",
+			commands: "b 4\nb 7\nc\nc\nc\n",
+			want: []string{
+				"Stopped (entry) at line 1: var a = 1;",
+				"Stopped (breakpoint) at line 4: print a + b;",
+				"Stopped (breakpoint) at line 7: print c;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := debug(t, tt.commands)
+			if got := stops(output); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("stops = %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(output, "Program finished.") {
+				t.Errorf("program did not finish:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestSteppingOverGenerator(t *testing.T) {
+	// The generator's body runs on a goroutine of its own, so stepping over
+	// the loop body must not stop inside it.
+	source := `fun g() {
+  yield 1;
+  yield 2;
+}
+for (x in g())
+  print x;
+print "done";
+`
+	output := debugSource(t, source, "b 6\nc\nn\nn\nc\n")
+	want := []string{
+		"Stopped (entry) at line 1: fun g() {",
+		"Stopped (breakpoint) at line 6: print x;",
+		"Stopped (step) at line 6: print x;",
+		"Stopped (step) at line 7: print \"done\";",
+	}
+	if got := stops(output); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stops = %q, want %q", got, want)
+	}
+}
+
+func TestInspection(t *testing.T) {
+	output := debug(t, "b 4\nc\nlocals\np a\np nope\nc\n")
+	for _, want := range []string{
+		"Locals:\n  b = 2\nGlobals:\n  a = 1\n",
+		"a = 1\n",
+		"Undefined variable 'nope'.\n",
+		"3\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	source := "import \"util\" as u;\nprint u.answer;\n"
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := fstest.MapFS{"util.lox": {Data: []byte("var answer = 42;")}}
+
+	var out strings.Builder
+	terminal := NewTerminal(source, strings.NewReader("c\n"), &out)
+	terminal.SetLoader(module.NewLoader(files), "main.lox")
+	if err := terminal.Run(statements); err != nil {
+		t.Fatalf("Run: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "42\nProgram finished.") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
+
+func TestModuleBreakpoints(t *testing.T) {
+	dir := t.TempDir()
+	source := "import \"util\" as u;\n\nprint u.twice(2);\n"
+	util := filepath.Join(dir, "util.lox")
+	if err := os.WriteFile(util, []byte("fun twice(n) {\n  return n * 2;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader, err := module.NewOSLoader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := module.FSPath(filepath.Join(dir, "main.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Line 2 of the script is empty, so only the module's line 2 stops.
+	var out strings.Builder
+	terminal := NewTerminal(source, strings.NewReader("b 2\nb util:2\nbreakpoints\nc\nc\n"), &out)
+	terminal.SetLoader(loader, path)
+	if err := terminal.Run(statements); err != nil {
+		t.Fatalf("Run: %v\n%s", err, out.String())
+	}
+	want := []string{
+		"Stopped (entry) at line 1: import \"util\" as u;",
+		"Stopped (breakpoint) at " + util + " line 2: return n * 2;",
+	}
+	if got := stops(out.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stops = %q, want %q", got, want)
+	}
+	if listed := "line 2: \n" + util + " line 2: return n * 2;\n"; !strings.Contains(out.String(), listed) {
+		t.Errorf("output does not list breakpoints %q:\n%s", listed, out.String())
+	}
+}
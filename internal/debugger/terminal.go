@@ -0,0 +1,228 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+)
+
+const terminalHelp = `Commands:
+  break N, b N     set a breakpoint on line N; M:N is line N of the module
+                   imported as M
+  delete N, d N    remove the breakpoint on line N, or M:N
+  breakpoints      list breakpoints
+  continue, c      run until the next breakpoint
+  step, s          step into the next statement
+  next, n          step over nested statements
+  out, o           run until the enclosing statement finishes
+  locals, l        show every scope and its variables
+  print X, p X     show the value of variable X
+  list             show the source around the current line
+  quit, q          stop debugging
+`
+
+// Terminal is a line-oriented front end for the debugger.
+type Terminal struct {
+	lines []string
+	in    *bufio.Scanner
+	out   io.Writer
+
+	loader     interpreter.ModuleLoader
+	modulePath string
+	// modules holds the lines of the modules stopped in, read when first
+	// needed.
+	modules map[string][]string
+}
+
+func NewTerminal(source string, in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{
+		lines:   strings.Split(source, "\n"),
+		in:      bufio.NewScanner(in),
+		out:     out,
+		modules: map[string][]string{},
+	}
+}
+
+// SetLoader enables import statements in the program, as
+// Interpreter.SetLoader does.
+func (t *Terminal) SetLoader(loader interpreter.ModuleLoader, path string) {
+	t.loader, t.modulePath = loader, path
+}
+
+// Run debugs statements, pausing before the first one. Program output is
+// written to the same stream as the debugger's.
+func (t *Terminal) Run(statements []expression.Stmt) error {
+	d := New(true)
+	i := interpreter.NewInterpreter()
+	i.SetOutput(t.out)
+	if t.loader != nil {
+		i.SetLoader(d.Loader(t.loader, t.modulePath), t.modulePath)
+	}
+	done := d.Start(i, statements)
+
+	for {
+		select {
+		case stop := <-d.Stops():
+			t.showStop(stop)
+			if !t.prompt(d, stop) {
+				return nil
+			}
+		case err := <-done:
+			if err != nil {
+				fmt.Fprintln(t.out, err)
+			}
+			fmt.Fprintln(t.out, "Program finished.")
+			return err
+		}
+	}
+}
+
+func (t *Terminal) showStop(stop Stop) {
+	fmt.Fprintf(t.out, "Stopped (%s) at %s: %s\n", stop.Reason, t.where(stop.File, stop.Line), t.sourceLine(stop.File, stop.Line))
+}
+
+// where describes a line of file: just the line for the script being
+// debugged, and the module's path as well for others.
+func (t *Terminal) where(file string, line int) string {
+	if file == t.modulePath {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s line %d", t.loader.Display(file), line)
+}
+
+func (t *Terminal) sourceLine(file string, line int) string {
+	lines := t.source(file)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
+
+// source returns the lines of file. A module's are read from where the
+// loader shows it to be, and are missing when it cannot be read there.
+func (t *Terminal) source(file string) []string {
+	if file == t.modulePath {
+		return t.lines
+	}
+	lines, ok := t.modules[file]
+	if !ok {
+		if data, err := os.ReadFile(t.loader.Display(file)); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		t.modules[file] = lines
+	}
+	return lines
+}
+
+// prompt reads commands until one resumes the program. It returns false when
+// the user quits.
+func (t *Terminal) prompt(d *Debugger, stop Stop) bool {
+	for {
+		fmt.Fprint(t.out, "(debug) ")
+		if !t.in.Scan() {
+			return false
+		}
+		fields := strings.Fields(t.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "continue", "c":
+			d.Continue()
+			return true
+		case "step", "s":
+			d.StepIn()
+			return true
+		case "next", "n":
+			d.StepOver()
+			return true
+		case "out", "o":
+			d.StepOut()
+			return true
+		case "quit", "q":
+			return false
+		case "break", "b":
+			if file, line, ok := t.lineArgument(fields); ok {
+				d.AddBreakpoint(file, line)
+				fmt.Fprintf(t.out, "Breakpoint set on %s.\n", t.where(file, line))
+			}
+		case "delete", "d":
+			if file, line, ok := t.lineArgument(fields); ok {
+				d.RemoveBreakpoint(file, line)
+				fmt.Fprintf(t.out, "Breakpoint removed from %s.\n", t.where(file, line))
+			}
+		case "breakpoints":
+			for _, bp := range d.Breakpoints() {
+				fmt.Fprintf(t.out, "%s: %s\n", t.where(bp.File, bp.Line), t.sourceLine(bp.File, bp.Line))
+			}
+		case "locals", "l":
+			for _, scope := range Scopes(stop.Env) {
+				fmt.Fprintf(t.out, "%s:\n", scope.Name)
+				for _, v := range scope.Variables {
+					fmt.Fprintf(t.out, "  %s = %s\n", v.Name, v.Value)
+				}
+			}
+		case "print", "p":
+			if len(fields) != 2 {
+				fmt.Fprintln(t.out, "Usage: print NAME")
+				continue
+			}
+			if value, ok := Lookup(stop.Env, fields[1]); ok {
+				fmt.Fprintf(t.out, "%s = %s\n", fields[1], value)
+			} else {
+				fmt.Fprintf(t.out, "Undefined variable '%s'.\n", fields[1])
+			}
+		case "list":
+			lines := t.source(stop.File)
+			for line := stop.Line - 2; line <= stop.Line+2; line++ {
+				if line < 1 || line > len(lines) {
+					continue
+				}
+				marker := " "
+				if line == stop.Line {
+					marker = ">"
+				}
+				fmt.Fprintf(t.out, "%s %4d  %s\n", marker, line, lines[line-1])
+			}
+		case "help", "h":
+			fmt.Fprint(t.out, terminalHelp)
+		default:
+			fmt.Fprintf(t.out, "Unknown command: %s (try 'help')\n", fields[0])
+		}
+	}
+}
+
+// lineArgument reads the line a breakpoint command names: LINE in the script
+// being debugged, or MODULE:LINE in the module it would import as MODULE.
+func (t *Terminal) lineArgument(fields []string) (string, int, bool) {
+	if len(fields) != 2 {
+		fmt.Fprintf(t.out, "Usage: %s [MODULE:]LINE\n", fields[0])
+		return "", 0, false
+	}
+	file, number := t.modulePath, fields[1]
+	if colon := strings.LastIndex(number, ":"); colon >= 0 {
+		if t.loader == nil {
+			fmt.Fprintln(t.out, "Imports are not available.")
+			return "", 0, false
+		}
+		path, err := t.loader.Resolve(t.modulePath, number[:colon])
+		if err != nil {
+			fmt.Fprintln(t.out, err)
+			return "", 0, false
+		}
+		file, number = path, number[colon+1:]
+	}
+	line, err := strconv.Atoi(number)
+	if err != nil {
+		fmt.Fprintf(t.out, "Usage: %s [MODULE:]LINE\n", fields[0])
+		return "", 0, false
+	}
+	return file, line, true
+}
@@ -5,10 +5,16 @@ package environment
 import (
 	"fmt"
 	"interpreter/internal/token"
+	"sync"
 )
 
+// Environment is a scope mapping names to values. Closures called on
+// different goroutines may share scopes, so every access takes the scope's
+// lock; a read followed by a write, as in `x = x + 1`, is still two separate
+// accesses.
 type Environment struct {
 	enclosing *Environment
+	mu        sync.RWMutex
 	values    map[string]interface{}
 }
 
@@ -20,27 +26,69 @@ func NewEnvironment(enclosing *Environment) *Environment {
 }
 
 func (e *Environment) Define(name string, value interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.values[name] = value
 }
 
 func (e *Environment) Get(name token.Token) interface{} {
-	if value, ok := e.values[name.Lexeme]; ok {
-		return value
+	for env := e; env != nil; env = env.enclosing {
+		env.mu.RLock()
+		value, ok := env.values[name.Lexeme]
+		env.mu.RUnlock()
+		if ok {
+			return value
+		}
 	}
-	if e.enclosing != nil {
-		return e.enclosing.Get(name)
-	}
-	panic(fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
+	panic(UndefinedError{Name: name})
 }
 
 func (e *Environment) Assign(name token.Token, value interface{}) {
-	if _, ok := e.values[name.Lexeme]; ok {
-		e.values[name.Lexeme] = value
-		return
+	for env := e; env != nil; env = env.enclosing {
+		env.mu.Lock()
+		_, ok := env.values[name.Lexeme]
+		if ok {
+			env.values[name.Lexeme] = value
+		}
+		env.mu.Unlock()
+		if ok {
+			return
+		}
+	}
+	panic(UndefinedError{Name: name})
+}
+
+// Ancestor returns the scope distance levels out from e, stopping at the
+// global scope.
+func (e *Environment) Ancestor(distance int) *Environment {
+	env := e
+	for ; distance > 0 && env.enclosing != nil; distance-- {
+		env = env.enclosing
 	}
-	if e.enclosing != nil {
-		e.enclosing.Assign(name, value)
-		return
+	return env
+}
+
+// Enclosing returns the parent scope, or nil for the global scope.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Values returns a copy of the variables defined directly in this scope.
+func (e *Environment) Values() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	values := make(map[string]interface{}, len(e.values))
+	for name, value := range e.values {
+		values[name] = value
 	}
-	panic(fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
+	return values
+}
+
+// UndefinedError is raised when a name is not defined in any enclosing scope.
+type UndefinedError struct {
+	Name token.Token
+}
+
+func (e UndefinedError) Error() string {
+	return fmt.Sprintf("Undefined variable '%s'.", e.Name.Lexeme)
 }
@@ -0,0 +1,37 @@
+package expression
+
+import Token "interpreter/internal/token"
+
+// compoundOperators maps each compound assignment to the binary operator it
+// applies.
+var compoundOperators = map[Token.TokenType]Token.TokenType{
+	Token.PLUS_EQUAL:              Token.PLUS,
+	Token.MINUS_EQUAL:             Token.MINUS,
+	Token.STAR_EQUAL:              Token.STAR,
+	Token.SLASH_EQUAL:             Token.SLASH,
+	Token.PERCENT_EQUAL:           Token.PERCENT,
+	Token.QUESTION_QUESTION_EQUAL: Token.QUESTION_QUESTION,
+}
+
+// IsCompoundOperator reports whether t is a compound assignment such as +=.
+func IsCompoundOperator(t Token.TokenType) bool {
+	_, ok := compoundOperators[t]
+	return ok
+}
+
+// BinaryOperator returns the operator a compound assignment applies, as in
+// + for +=, at the same position.
+func (c *Compound) BinaryOperator() Token.Token {
+	operator := c.Operator
+	operator.Type = compoundOperators[operator.Type]
+	operator.Lexeme = operator.Lexeme[:len(operator.Lexeme)-1]
+	return operator
+}
+
+// Delta returns the amount an increment adds to its target.
+func (i *Increment) Delta() float64 {
+	if i.Operator.Type == Token.MINUS_MINUS {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,98 @@
+package expression
+
+import (
+	"strings"
+
+	Token "interpreter/internal/token"
+)
+
+// Target is the left-hand side of a destructuring declaration or
+// assignment, as in `var [a, {name}] = value;`. It takes a value apart and
+// stores the pieces in variables.
+type Target interface {
+	// Names returns the variables the target stores into, in order.
+	Names() []Token.Token
+	// Line returns the line the target starts on.
+	Line() int
+	String() string
+}
+
+// NameTarget stores the whole value in a variable.
+type NameTarget struct {
+	Name Token.Token
+}
+
+func (t *NameTarget) Names() []Token.Token { return []Token.Token{t.Name} }
+
+func (t *NameTarget) Line() int { return t.Name.Line }
+
+func (t *NameTarget) String() string { return t.Name.Lexeme }
+
+// ListTarget, written [a, b, ...rest], takes a list apart by position. Rest,
+// if there is one, receives a list of the elements after the others, and
+// otherwise the list must have exactly as many elements as the target.
+type ListTarget struct {
+	Bracket  Token.Token
+	Elements []Target
+	Rest     *Token.Token
+}
+
+func (t *ListTarget) Names() []Token.Token {
+	var names []Token.Token
+	for _, element := range t.Elements {
+		names = append(names, element.Names()...)
+	}
+	if t.Rest != nil {
+		names = append(names, *t.Rest)
+	}
+	return names
+}
+
+func (t *ListTarget) Line() int { return t.Bracket.Line }
+
+func (t *ListTarget) String() string {
+	parts := make([]string, 0, len(t.Elements)+1)
+	for _, element := range t.Elements {
+		parts = append(parts, element.String())
+	}
+	if t.Rest != nil {
+		parts = append(parts, "..."+t.Rest.Lexeme)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// MapTarget, written {name, age: years}, takes a map apart by key. Each key
+// is a name or a string, and a bare name also names the variable. The map
+// must have every key; others are ignored.
+type MapTarget struct {
+	Brace  Token.Token
+	Keys   []Token.Token
+	Values []Target
+}
+
+// Key returns the map key the i-th entry reads.
+func (t *MapTarget) Key(i int) string {
+	key := t.Keys[i]
+	if key.Type == Token.STRING {
+		return key.Literal.(string)
+	}
+	return key.Lexeme
+}
+
+func (t *MapTarget) Names() []Token.Token {
+	var names []Token.Token
+	for _, value := range t.Values {
+		names = append(names, value.Names()...)
+	}
+	return names
+}
+
+func (t *MapTarget) Line() int { return t.Brace.Line }
+
+func (t *MapTarget) String() string {
+	parts := make([]string, len(t.Keys))
+	for i, value := range t.Values {
+		parts[i] = t.Key(i) + ": " + value.String()
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
@@ -3,13 +3,24 @@ import Token "interpreter/internal/token"
 
 type ExprVisitor interface {
     VisitAssignExpr(expr *Assign) interface{}
+    VisitDestructureExpr(expr *Destructure) interface{}
+    VisitCompoundExpr(expr *Compound) interface{}
+    VisitIncrementExpr(expr *Increment) interface{}
     VisitBinaryExpr(expr *Binary) interface{}
+    VisitCallExpr(expr *Call) interface{}
     VisitTernaryExpr(expr *Ternary) interface{}
+    VisitGetExpr(expr *Get) interface{}
+    VisitIndexExpr(expr *Index) interface{}
+    VisitSetIndexExpr(expr *SetIndex) interface{}
     VisitGroupingExpr(expr *Grouping) interface{}
     VisitLiteralExpr(expr *Literal) interface{}
     VisitLogicalExpr(expr *Logical) interface{}
     VisitUnaryExpr(expr *Unary) interface{}
     VisitVariableExpr(expr *Variable) interface{}
+    VisitLambdaExpr(expr *Lambda) interface{}
+    VisitListExpr(expr *List) interface{}
+    VisitMapExpr(expr *Map) interface{}
+    VisitAwaitExpr(expr *Await) interface{}
 }
 
 type Expr interface{
@@ -32,6 +43,60 @@ func (e *Assign) Accept(visitor ExprVisitor) interface{} {
     return visitor.VisitAssignExpr(e)
 }
 
+type Destructure struct {
+    Pattern Target
+    Equals Token.Token
+    Value Expr
+}
+
+func NewDestructure(Pattern Target, Equals Token.Token, Value Expr) *Destructure {
+    return &Destructure{
+        Pattern: Pattern,
+        Equals: Equals,
+        Value: Value,
+    }
+}
+
+func (e *Destructure) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitDestructureExpr(e)
+}
+
+type Compound struct {
+    Target Expr
+    Operator Token.Token
+    Value Expr
+}
+
+func NewCompound(Target Expr, Operator Token.Token, Value Expr) *Compound {
+    return &Compound{
+        Target: Target,
+        Operator: Operator,
+        Value: Value,
+    }
+}
+
+func (e *Compound) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitCompoundExpr(e)
+}
+
+type Increment struct {
+    Target Expr
+    Operator Token.Token
+    Prefix bool
+}
+
+func NewIncrement(Target Expr, Operator Token.Token, Prefix bool) *Increment {
+    return &Increment{
+        Target: Target,
+        Operator: Operator,
+        Prefix: Prefix,
+    }
+}
+
+func (e *Increment) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitIncrementExpr(e)
+}
+
 type Binary struct {
     Left Expr
     Operator Token.Token
@@ -50,15 +115,37 @@ func (e *Binary) Accept(visitor ExprVisitor) interface{} {
     return visitor.VisitBinaryExpr(e)
 }
 
+type Call struct {
+    Callee Expr
+    Paren Token.Token
+    Arguments []Expr
+    Optional bool
+}
+
+func NewCall(Callee Expr, Paren Token.Token, Arguments []Expr, Optional bool) *Call {
+    return &Call{
+        Callee: Callee,
+        Paren: Paren,
+        Arguments: Arguments,
+        Optional: Optional,
+    }
+}
+
+func (e *Call) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitCallExpr(e)
+}
+
 type Ternary struct {
     Condition Expr
+    Operator Token.Token
     TrueExpression Expr
     FalseExpression Expr
 }
 
-func NewTernary(Condition Expr, TrueExpression Expr, FalseExpression Expr) *Ternary {
+func NewTernary(Condition Expr, Operator Token.Token, TrueExpression Expr, FalseExpression Expr) *Ternary {
     return &Ternary{
         Condition: Condition,
+        Operator: Operator,
         TrueExpression: TrueExpression,
         FalseExpression: FalseExpression,
     }
@@ -68,6 +155,64 @@ func (e *Ternary) Accept(visitor ExprVisitor) interface{} {
     return visitor.VisitTernaryExpr(e)
 }
 
+type Get struct {
+    Object Expr
+    Name Token.Token
+    Optional bool
+}
+
+func NewGet(Object Expr, Name Token.Token, Optional bool) *Get {
+    return &Get{
+        Object: Object,
+        Name: Name,
+        Optional: Optional,
+    }
+}
+
+func (e *Get) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitGetExpr(e)
+}
+
+type Index struct {
+    Object Expr
+    Bracket Token.Token
+    Key Expr
+    Optional bool
+}
+
+func NewIndex(Object Expr, Bracket Token.Token, Key Expr, Optional bool) *Index {
+    return &Index{
+        Object: Object,
+        Bracket: Bracket,
+        Key: Key,
+        Optional: Optional,
+    }
+}
+
+func (e *Index) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitIndexExpr(e)
+}
+
+type SetIndex struct {
+    Object Expr
+    Bracket Token.Token
+    Key Expr
+    Value Expr
+}
+
+func NewSetIndex(Object Expr, Bracket Token.Token, Key Expr, Value Expr) *SetIndex {
+    return &SetIndex{
+        Object: Object,
+        Bracket: Bracket,
+        Key: Key,
+        Value: Value,
+    }
+}
+
+func (e *SetIndex) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitSetIndexExpr(e)
+}
+
 type Grouping struct {
     Expr Expr
 }
@@ -144,3 +289,69 @@ func (e *Variable) Accept(visitor ExprVisitor) interface{} {
     return visitor.VisitVariableExpr(e)
 }
 
+type Lambda struct {
+    Keyword Token.Token
+    Function *Function
+}
+
+func NewLambda(Keyword Token.Token, Function *Function) *Lambda {
+    return &Lambda{
+        Keyword: Keyword,
+        Function: Function,
+    }
+}
+
+func (e *Lambda) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitLambdaExpr(e)
+}
+
+type List struct {
+    Bracket Token.Token
+    Elements []Expr
+}
+
+func NewList(Bracket Token.Token, Elements []Expr) *List {
+    return &List{
+        Bracket: Bracket,
+        Elements: Elements,
+    }
+}
+
+func (e *List) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitListExpr(e)
+}
+
+type Map struct {
+    Brace Token.Token
+    Keys []Expr
+    Values []Expr
+}
+
+func NewMap(Brace Token.Token, Keys []Expr, Values []Expr) *Map {
+    return &Map{
+        Brace: Brace,
+        Keys: Keys,
+        Values: Values,
+    }
+}
+
+func (e *Map) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitMapExpr(e)
+}
+
+type Await struct {
+    Keyword Token.Token
+    Value Expr
+}
+
+func NewAwait(Keyword Token.Token, Value Expr) *Await {
+    return &Await{
+        Keyword: Keyword,
+        Value: Value,
+    }
+}
+
+func (e *Await) Accept(visitor ExprVisitor) interface{} {
+    return visitor.VisitAwaitExpr(e)
+}
+
@@ -0,0 +1,43 @@
+package expression
+
+// Inspect calls visit for each statement of a function body, including
+// those nested in blocks, loops, conditionals and match and select cases,
+// but not those of the functions the body declares.
+func Inspect(body []Stmt, visit func(Stmt)) {
+	for _, stmt := range body {
+		if stmt == nil {
+			continue
+		}
+		visit(stmt)
+		switch s := stmt.(type) {
+		case *Block:
+			Inspect(s.Statements, visit)
+		case *If:
+			Inspect([]Stmt{s.ThenBranch, s.ElseBranch}, visit)
+		case *While:
+			Inspect([]Stmt{s.Body}, visit)
+		case *ForIn:
+			Inspect([]Stmt{s.Body}, visit)
+		case *Match:
+			for _, c := range s.Cases {
+				Inspect([]Stmt{c.Body}, visit)
+			}
+		case *Select:
+			for _, c := range s.Cases {
+				Inspect([]Stmt{c.Body}, visit)
+			}
+		}
+	}
+}
+
+// IsGenerator reports whether a function with this body is a generator,
+// which is the case when a yield statement belongs to it.
+func IsGenerator(body []Stmt) bool {
+	yields := false
+	Inspect(body, func(stmt Stmt) {
+		if _, ok := stmt.(*Yield); ok {
+			yields = true
+		}
+	})
+	return yields
+}
@@ -0,0 +1,162 @@
+package expression
+
+import Token "interpreter/internal/token"
+
+// MatchCase is one case of a match statement, as in
+// `case 1, 2 if ok => print "small";`. Body runs for the first case with a
+// matching pattern whose guard, if it has one, is truthy.
+type MatchCase struct {
+	Keyword  Token.Token
+	Patterns []Pattern
+	// Guard is nil when the case has no if clause.
+	Guard Expr
+	Body  Stmt
+}
+
+// Bindings returns the names the case's patterns bind.
+func (c *MatchCase) Bindings() []Token.Token {
+	var names []Token.Token
+	for _, pattern := range c.Patterns {
+		names = append(names, pattern.Bindings()...)
+	}
+	return names
+}
+
+// Covers reports whether c matches every value later does, so that later
+// can never run. A guarded case covers nothing, since its guard may fail.
+func (c *MatchCase) Covers(later *MatchCase) bool {
+	if c.Guard != nil {
+		return false
+	}
+	for _, q := range later.Patterns {
+		covered := false
+		for _, p := range c.Patterns {
+			if p.Covers(q) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// Irrefutable reports whether the case runs whatever the subject is.
+func (c *MatchCase) Irrefutable() bool {
+	return c.Covers(&MatchCase{Patterns: []Pattern{&WildcardPattern{}}})
+}
+
+// Pattern is tested against the subject of a match statement.
+type Pattern interface {
+	// Covers reports whether the pattern matches every value other matches.
+	Covers(other Pattern) bool
+	// Bindings returns the names the pattern binds when it matches.
+	Bindings() []Token.Token
+	String() string
+}
+
+// LiteralPattern matches values equal to a number, string, boolean or nil.
+type LiteralPattern struct {
+	Token Token.Token
+	Value interface{}
+}
+
+func (p *LiteralPattern) Covers(other Pattern) bool {
+	literal, ok := other.(*LiteralPattern)
+	return ok && literal.Value == p.Value
+}
+
+func (p *LiteralPattern) Bindings() []Token.Token { return nil }
+
+func (p *LiteralPattern) String() string { return literalString(p.Value) }
+
+// WildcardPattern, written _, matches anything.
+type WildcardPattern struct {
+	Token Token.Token
+}
+
+func (p *WildcardPattern) Covers(other Pattern) bool { return true }
+
+func (p *WildcardPattern) Bindings() []Token.Token { return nil }
+
+func (p *WildcardPattern) String() string { return "_" }
+
+// BindingPattern matches anything and binds it to Name for the guard and
+// body of its case.
+type BindingPattern struct {
+	Name Token.Token
+}
+
+func (p *BindingPattern) Covers(other Pattern) bool { return true }
+
+func (p *BindingPattern) Bindings() []Token.Token { return []Token.Token{p.Name} }
+
+func (p *BindingPattern) String() string { return p.Name.Lexeme }
+
+// DestructurePattern matches a list or map of the shape its target
+// describes, as in `case [first, ...rest]` or `case {name}`, and binds the
+// pieces. A value of another shape does not match. An element named _ binds
+// nothing.
+type DestructurePattern struct {
+	Target Target
+}
+
+func (p *DestructurePattern) Covers(other Pattern) bool {
+	q, ok := other.(*DestructurePattern)
+	return ok && targetCovers(p.Target, q.Target)
+}
+
+// targetCovers reports whether every value target q matches, p matches too.
+func targetCovers(p, q Target) bool {
+	switch p := p.(type) {
+	case *NameTarget:
+		return true
+	case *ListTarget:
+		q, ok := q.(*ListTarget)
+		if !ok || len(q.Elements) < len(p.Elements) {
+			return false
+		}
+		if p.Rest == nil && (q.Rest != nil || len(q.Elements) != len(p.Elements)) {
+			return false
+		}
+		for index, element := range p.Elements {
+			if !targetCovers(element, q.Elements[index]) {
+				return false
+			}
+		}
+		return true
+	case *MapTarget:
+		q, ok := q.(*MapTarget)
+		if !ok {
+			return false
+		}
+		for index, value := range p.Values {
+			found := false
+			for j := range q.Keys {
+				if q.Key(j) == p.Key(index) {
+					found = targetCovers(value, q.Values[j])
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (p *DestructurePattern) Bindings() []Token.Token {
+	var names []Token.Token
+	for _, name := range p.Target.Names() {
+		if name.Lexeme != "_" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (p *DestructurePattern) String() string { return p.Target.String() }
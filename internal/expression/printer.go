@@ -0,0 +1,282 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AstPrinter renders syntax trees as parenthesized prefix expressions, such
+// as (print (+ 1.0 2.0)), for the parse command.
+type AstPrinter struct{}
+
+func (p *AstPrinter) Print(expr Expr) string {
+	return expr.Accept(p).(string)
+}
+
+func (p *AstPrinter) PrintStmt(stmt Stmt) string {
+	return stmt.Accept(p).(string)
+}
+
+func (p *AstPrinter) VisitExpressionStmt(stmt *Expression) interface{} {
+	return p.Print(stmt.Expr)
+}
+
+func (p *AstPrinter) VisitPrintStmt(stmt *Print) interface{} {
+	return p.parenthesize("print", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitVarStmt(stmt *Var) interface{} {
+	if stmt.Initializer == nil {
+		return p.parenthesize("var " + stmt.Name.Lexeme)
+	}
+	return p.parenthesize("var "+stmt.Name.Lexeme, stmt.Initializer)
+}
+
+// VisitVarDestructureStmt prints the target like a list or map, as in
+// (var [a ...rest] xs) or (var {name: n} m).
+func (p *AstPrinter) VisitVarDestructureStmt(stmt *VarDestructure) interface{} {
+	return p.parenthesize("var "+stmt.Pattern.String(), stmt.Initializer)
+}
+
+func (p *AstPrinter) VisitWhileStmt(stmt *While) interface{} {
+	return p.parenthesize("while", stmt.Condition, stmt.Body)
+}
+
+func (p *AstPrinter) VisitForInStmt(stmt *ForIn) interface{} {
+	return p.parenthesize("for "+stmt.Name.Lexeme+" in", stmt.Iterable, stmt.Body)
+}
+
+func (p *AstPrinter) VisitBlockStmt(stmt *Block) interface{} {
+	return p.parenthesize("block", stmts(stmt.Statements)...)
+}
+
+func (p *AstPrinter) VisitIfStmt(stmt *If) interface{} {
+	if stmt.ElseBranch == nil {
+		return p.parenthesize("if", stmt.Condition, stmt.ThenBranch)
+	}
+	return p.parenthesize("if", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch)
+}
+
+func (p *AstPrinter) VisitFunctionStmt(stmt *Function) interface{} {
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+	}
+	name := fmt.Sprintf("fun %s(%s)", stmt.Name.Lexeme, strings.Join(params, " "))
+	if stmt.Async {
+		name = "async " + name
+	}
+	return p.parenthesize(name, stmts(stmt.Body)...)
+}
+
+// VisitLambdaExpr prints a lambda like a declaration without a name, as in
+// (fun (a) (return a)).
+func (p *AstPrinter) VisitLambdaExpr(expr *Lambda) interface{} {
+	return p.VisitFunctionStmt(expr.Function)
+}
+
+func (p *AstPrinter) VisitReturnStmt(stmt *Return) interface{} {
+	if stmt.Value == nil {
+		return p.parenthesize("return")
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+func (p *AstPrinter) VisitYieldStmt(stmt *Yield) interface{} {
+	if stmt.Value == nil {
+		return p.parenthesize("yield")
+	}
+	return p.parenthesize("yield", stmt.Value)
+}
+
+func (p *AstPrinter) VisitTestStmt(stmt *Test) interface{} {
+	return p.parenthesize("test "+stmt.Name.Lexeme, stmts(stmt.Body)...)
+}
+
+func (p *AstPrinter) VisitImportStmt(stmt *Import) interface{} {
+	return p.parenthesize(fmt.Sprintf("import %s as %s", stmt.Path.Lexeme, stmt.Name.Lexeme))
+}
+
+// VisitMatchStmt prints each case's patterns, then its guard and body, as in
+// (match x (case 1.0 n (guard (> n 0.0)) (print n))).
+func (p *AstPrinter) VisitMatchStmt(stmt *Match) interface{} {
+	var sb strings.Builder
+	sb.WriteString("(match " + p.Print(stmt.Subject))
+	for _, c := range stmt.Cases {
+		sb.WriteString(" (case")
+		for _, pattern := range c.Patterns {
+			sb.WriteString(" " + pattern.String())
+		}
+		if c.Guard != nil {
+			sb.WriteString(" " + p.parenthesize("guard", c.Guard))
+		}
+		sb.WriteString(" " + p.PrintStmt(c.Body) + ")")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (p *AstPrinter) VisitSpawnStmt(stmt *Spawn) interface{} {
+	return p.parenthesize("spawn", stmt.Call)
+}
+
+// VisitSelectStmt prints each case's channel operation and body, as in
+// (select (case (= v (recv ch)) (print v)) (case _ (block))).
+func (p *AstPrinter) VisitSelectStmt(stmt *Select) interface{} {
+	var sb strings.Builder
+	sb.WriteString("(select")
+	for _, c := range stmt.Cases {
+		operation := "_"
+		switch {
+		case c.IsSend():
+			operation = p.parenthesize("send", c.Channel, c.Value)
+		case !c.IsDefault():
+			operation = p.parenthesize("recv", c.Channel)
+			if c.Name != nil {
+				operation = "(= " + c.Name.Lexeme + " " + operation + ")"
+			}
+		}
+		sb.WriteString(" (case " + operation + " " + p.PrintStmt(c.Body) + ")")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (p *AstPrinter) VisitAssignExpr(expr *Assign) interface{} {
+	return p.parenthesize("= "+expr.Name.Lexeme, expr.Value)
+}
+
+func (p *AstPrinter) VisitDestructureExpr(expr *Destructure) interface{} {
+	return p.parenthesize("= "+expr.Pattern.String(), expr.Value)
+}
+
+func (p *AstPrinter) VisitCompoundExpr(expr *Compound) interface{} {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Target, expr.Value)
+}
+
+// VisitIncrementExpr prints ++x as (++ x) and x++ as (post++ x).
+func (p *AstPrinter) VisitIncrementExpr(expr *Increment) interface{} {
+	if expr.Prefix {
+		return p.parenthesize(expr.Operator.Lexeme, expr.Target)
+	}
+	return p.parenthesize("post"+expr.Operator.Lexeme, expr.Target)
+}
+
+func (p *AstPrinter) VisitBinaryExpr(expr *Binary) interface{} {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+// VisitCallExpr prints f(x) as (call f x) and f?.(x) as (?call f x).
+func (p *AstPrinter) VisitCallExpr(expr *Call) interface{} {
+	parts := []interface{}{expr.Callee}
+	for _, argument := range expr.Arguments {
+		parts = append(parts, argument)
+	}
+	return p.parenthesize(optional(expr.Optional)+"call", parts...)
+}
+
+func (p *AstPrinter) VisitListExpr(expr *List) interface{} {
+	parts := make([]interface{}, len(expr.Elements))
+	for i, element := range expr.Elements {
+		parts[i] = element
+	}
+	return p.parenthesize("list", parts...)
+}
+
+// VisitMapExpr prints each key before its value, as in (map a 1.0 b 2.0).
+func (p *AstPrinter) VisitMapExpr(expr *Map) interface{} {
+	var parts []interface{}
+	for i, key := range expr.Keys {
+		parts = append(parts, key, expr.Values[i])
+	}
+	return p.parenthesize("map", parts...)
+}
+
+func (p *AstPrinter) VisitGetExpr(expr *Get) interface{} {
+	return p.parenthesize(optional(expr.Optional)+"."+expr.Name.Lexeme, expr.Object)
+}
+
+// VisitIndexExpr prints a[i] as ([] a i) and a?.[i] as (?[] a i).
+func (p *AstPrinter) VisitIndexExpr(expr *Index) interface{} {
+	return p.parenthesize(optional(expr.Optional)+"[]", expr.Object, expr.Key)
+}
+
+// VisitSetIndexExpr prints a[i] = v as ([]= a i v).
+func (p *AstPrinter) VisitSetIndexExpr(expr *SetIndex) interface{} {
+	return p.parenthesize("[]=", expr.Object, expr.Key, expr.Value)
+}
+
+// optional marks the operator of a null-safe access.
+func optional(optional bool) string {
+	if optional {
+		return "?"
+	}
+	return ""
+}
+
+func (p *AstPrinter) VisitTernaryExpr(expr *Ternary) interface{} {
+	return p.parenthesize("?:", expr.Condition, expr.TrueExpression, expr.FalseExpression)
+}
+
+func (p *AstPrinter) VisitGroupingExpr(expr *Grouping) interface{} {
+	return p.parenthesize("group", expr.Expr)
+}
+
+func (p *AstPrinter) VisitLiteralExpr(expr *Literal) interface{} {
+	return literalString(expr.Value)
+}
+
+func literalString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%.1f", v)
+		}
+		return fmt.Sprintf("%g", v)
+	}
+	return fmt.Sprint(value)
+}
+
+func (p *AstPrinter) VisitLogicalExpr(expr *Logical) interface{} {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p *AstPrinter) VisitUnaryExpr(expr *Unary) interface{} {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Right)
+}
+
+func (p *AstPrinter) VisitAwaitExpr(expr *Await) interface{} {
+	return p.parenthesize("await", expr.Value)
+}
+
+func (p *AstPrinter) VisitVariableExpr(expr *Variable) interface{} {
+	return expr.Name.Lexeme
+}
+
+// parenthesize prints name followed by each part, which is an Expr or a
+// Stmt.
+func (p *AstPrinter) parenthesize(name string, parts ...interface{}) string {
+	var sb strings.Builder
+	sb.WriteString("(" + name)
+	for _, part := range parts {
+		sb.WriteString(" ")
+		switch node := part.(type) {
+		case Expr:
+			sb.WriteString(p.Print(node))
+		case Stmt:
+			sb.WriteString(p.PrintStmt(node))
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func stmts(statements []Stmt) []interface{} {
+	parts := make([]interface{}, len(statements))
+	for i, stmt := range statements {
+		parts[i] = stmt
+	}
+	return parts
+}
@@ -0,0 +1,30 @@
+package expression
+
+import Token "interpreter/internal/token"
+
+// SelectCase is one case of a select statement: a receive as in
+// `case v = recv(ch) => print v;`, a send as in `case send(ch, 1) => {}`,
+// or the default case `case _ => ...`, which runs when no other case can
+// proceed at once.
+type SelectCase struct {
+	Keyword Token.Token
+	// Operation is the recv or send identifier, or _ for the default case.
+	Operation Token.Token
+	// Channel is nil for the default case.
+	Channel Expr
+	// Value is the value a send case sends.
+	Value Expr
+	// Name is the variable a receive binds for the body, if any.
+	Name *Token.Token
+	Body Stmt
+}
+
+// IsDefault reports whether c is the default case.
+func (c *SelectCase) IsDefault() bool {
+	return c.Channel == nil
+}
+
+// IsSend reports whether c sends a value rather than receiving one.
+func (c *SelectCase) IsSend() bool {
+	return c.Value != nil
+}
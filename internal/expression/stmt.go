@@ -5,22 +5,35 @@ type StmtVisitor interface {
     VisitExpressionStmt(stmt *Expression) interface{}
     VisitPrintStmt(stmt *Print) interface{}
     VisitVarStmt(stmt *Var) interface{}
+    VisitVarDestructureStmt(stmt *VarDestructure) interface{}
     VisitWhileStmt(stmt *While) interface{}
+    VisitForInStmt(stmt *ForIn) interface{}
     VisitBlockStmt(stmt *Block) interface{}
     VisitIfStmt(stmt *If) interface{}
+    VisitFunctionStmt(stmt *Function) interface{}
+    VisitReturnStmt(stmt *Return) interface{}
+    VisitYieldStmt(stmt *Yield) interface{}
+    VisitTestStmt(stmt *Test) interface{}
+    VisitImportStmt(stmt *Import) interface{}
+    VisitMatchStmt(stmt *Match) interface{}
+    VisitSpawnStmt(stmt *Spawn) interface{}
+    VisitSelectStmt(stmt *Select) interface{}
 }
 
 type Stmt interface{
 	Accept(visitor StmtVisitor) interface{}
+	Line() int
 }
 
 type Expression struct {
     Expr Expr
+    line int
 }
 
-func NewExpression(Expr Expr) *Expression {
+func NewExpression(Expr Expr, line int) *Expression {
     return &Expression{
         Expr: Expr,
+        line: line,
     }
 }
 
@@ -28,13 +41,19 @@ func (e *Expression) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitExpressionStmt(e)
 }
 
+func (e *Expression) Line() int {
+    return e.line
+}
+
 type Print struct {
     Expression Expr
+    line int
 }
 
-func NewPrint(Expression Expr) *Print {
+func NewPrint(Expression Expr, line int) *Print {
     return &Print{
         Expression: Expression,
+        line: line,
     }
 }
 
@@ -42,15 +61,23 @@ func (e *Print) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitPrintStmt(e)
 }
 
+func (e *Print) Line() int {
+    return e.line
+}
+
 type Var struct {
     Name Token.Token
+    Type *TypeAnnotation
     Initializer Expr
+    line int
 }
 
-func NewVar(Name Token.Token, Initializer Expr) *Var {
+func NewVar(Name Token.Token, Type *TypeAnnotation, Initializer Expr, line int) *Var {
     return &Var{
         Name: Name,
+        Type: Type,
         Initializer: Initializer,
+        line: line,
     }
 }
 
@@ -58,15 +85,43 @@ func (e *Var) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitVarStmt(e)
 }
 
+func (e *Var) Line() int {
+    return e.line
+}
+
+type VarDestructure struct {
+    Pattern Target
+    Initializer Expr
+    line int
+}
+
+func NewVarDestructure(Pattern Target, Initializer Expr, line int) *VarDestructure {
+    return &VarDestructure{
+        Pattern: Pattern,
+        Initializer: Initializer,
+        line: line,
+    }
+}
+
+func (e *VarDestructure) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitVarDestructureStmt(e)
+}
+
+func (e *VarDestructure) Line() int {
+    return e.line
+}
+
 type While struct {
     Condition Expr
     Body Stmt
+    line int
 }
 
-func NewWhile(Condition Expr, Body Stmt) *While {
+func NewWhile(Condition Expr, Body Stmt, line int) *While {
     return &While{
         Condition: Condition,
         Body: Body,
+        line: line,
     }
 }
 
@@ -74,13 +129,45 @@ func (e *While) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitWhileStmt(e)
 }
 
+func (e *While) Line() int {
+    return e.line
+}
+
+type ForIn struct {
+    Keyword Token.Token
+    Name Token.Token
+    Iterable Expr
+    Body Stmt
+    line int
+}
+
+func NewForIn(Keyword Token.Token, Name Token.Token, Iterable Expr, Body Stmt, line int) *ForIn {
+    return &ForIn{
+        Keyword: Keyword,
+        Name: Name,
+        Iterable: Iterable,
+        Body: Body,
+        line: line,
+    }
+}
+
+func (e *ForIn) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitForInStmt(e)
+}
+
+func (e *ForIn) Line() int {
+    return e.line
+}
+
 type Block struct {
     Statements []Stmt
+    line int
 }
 
-func NewBlock(Statements []Stmt) *Block {
+func NewBlock(Statements []Stmt, line int) *Block {
     return &Block{
         Statements: Statements,
+        line: line,
     }
 }
 
@@ -88,17 +175,23 @@ func (e *Block) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitBlockStmt(e)
 }
 
+func (e *Block) Line() int {
+    return e.line
+}
+
 type If struct {
     Condition Expr
     ThenBranch Stmt
     ElseBranch Stmt
+    line int
 }
 
-func NewIf(Condition Expr, ThenBranch Stmt, ElseBranch Stmt) *If {
+func NewIf(Condition Expr, ThenBranch Stmt, ElseBranch Stmt, line int) *If {
     return &If{
         Condition: Condition,
         ThenBranch: ThenBranch,
         ElseBranch: ElseBranch,
+        line: line,
     }
 }
 
@@ -106,3 +199,195 @@ func (e *If) Accept(visitor StmtVisitor) interface{} {
     return visitor.VisitIfStmt(e)
 }
 
+func (e *If) Line() int {
+    return e.line
+}
+
+type Function struct {
+    Name Token.Token
+    Params []Token.Token
+    ParamTypes []*TypeAnnotation
+    ReturnType *TypeAnnotation
+    Body []Stmt
+    Async bool
+    line int
+}
+
+func NewFunction(Name Token.Token, Params []Token.Token, ParamTypes []*TypeAnnotation, ReturnType *TypeAnnotation, Body []Stmt, Async bool, line int) *Function {
+    return &Function{
+        Name: Name,
+        Params: Params,
+        ParamTypes: ParamTypes,
+        ReturnType: ReturnType,
+        Body: Body,
+        Async: Async,
+        line: line,
+    }
+}
+
+func (e *Function) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitFunctionStmt(e)
+}
+
+func (e *Function) Line() int {
+    return e.line
+}
+
+type Return struct {
+    Keyword Token.Token
+    Value Expr
+    line int
+}
+
+func NewReturn(Keyword Token.Token, Value Expr, line int) *Return {
+    return &Return{
+        Keyword: Keyword,
+        Value: Value,
+        line: line,
+    }
+}
+
+func (e *Return) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitReturnStmt(e)
+}
+
+func (e *Return) Line() int {
+    return e.line
+}
+
+type Yield struct {
+    Keyword Token.Token
+    Value Expr
+    line int
+}
+
+func NewYield(Keyword Token.Token, Value Expr, line int) *Yield {
+    return &Yield{
+        Keyword: Keyword,
+        Value: Value,
+        line: line,
+    }
+}
+
+func (e *Yield) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitYieldStmt(e)
+}
+
+func (e *Yield) Line() int {
+    return e.line
+}
+
+type Test struct {
+    Name Token.Token
+    Body []Stmt
+    line int
+}
+
+func NewTest(Name Token.Token, Body []Stmt, line int) *Test {
+    return &Test{
+        Name: Name,
+        Body: Body,
+        line: line,
+    }
+}
+
+func (e *Test) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitTestStmt(e)
+}
+
+func (e *Test) Line() int {
+    return e.line
+}
+
+type Import struct {
+    Keyword Token.Token
+    Path Token.Token
+    Name Token.Token
+    line int
+}
+
+func NewImport(Keyword Token.Token, Path Token.Token, Name Token.Token, line int) *Import {
+    return &Import{
+        Keyword: Keyword,
+        Path: Path,
+        Name: Name,
+        line: line,
+    }
+}
+
+func (e *Import) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitImportStmt(e)
+}
+
+func (e *Import) Line() int {
+    return e.line
+}
+
+type Match struct {
+    Keyword Token.Token
+    Subject Expr
+    Cases []*MatchCase
+    line int
+}
+
+func NewMatch(Keyword Token.Token, Subject Expr, Cases []*MatchCase, line int) *Match {
+    return &Match{
+        Keyword: Keyword,
+        Subject: Subject,
+        Cases: Cases,
+        line: line,
+    }
+}
+
+func (e *Match) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitMatchStmt(e)
+}
+
+func (e *Match) Line() int {
+    return e.line
+}
+
+type Spawn struct {
+    Keyword Token.Token
+    Call *Call
+    line int
+}
+
+func NewSpawn(Keyword Token.Token, Call *Call, line int) *Spawn {
+    return &Spawn{
+        Keyword: Keyword,
+        Call: Call,
+        line: line,
+    }
+}
+
+func (e *Spawn) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitSpawnStmt(e)
+}
+
+func (e *Spawn) Line() int {
+    return e.line
+}
+
+type Select struct {
+    Keyword Token.Token
+    Cases []*SelectCase
+    line int
+}
+
+func NewSelect(Keyword Token.Token, Cases []*SelectCase, line int) *Select {
+    return &Select{
+        Keyword: Keyword,
+        Cases: Cases,
+        line: line,
+    }
+}
+
+func (e *Select) Accept(visitor StmtVisitor) interface{} {
+    return visitor.VisitSelectStmt(e)
+}
+
+func (e *Select) Line() int {
+    return e.line
+}
+
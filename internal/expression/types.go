@@ -0,0 +1,19 @@
+package expression
+
+import Token "interpreter/internal/token"
+
+// TypeAnnotation is an optional type written after a variable, parameter or
+// function signature, as in `var x: number` or `fun f(a: string): bool`. The
+// interpreter ignores annotations; only the checker reads them.
+type TypeAnnotation struct {
+	Name Token.Token
+	// Optional is set by a trailing ?, as in `string?`, which also admits nil.
+	Optional bool
+}
+
+func (t *TypeAnnotation) String() string {
+	if t.Optional {
+		return t.Name.Lexeme + "?"
+	}
+	return t.Name.Lexeme
+}
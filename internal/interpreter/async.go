@@ -0,0 +1,451 @@
+package interpreter
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// Clock tells the time for the event loop's timers and clock().
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// FakeClock is a Clock that only moves when the event loop waits for a timer,
+// and then jumps straight to it. The loop first lets async natives settle, so
+// they take no time at all. Scripts that sleep finish at once and their
+// timers always fire in the same order.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// SetClock replaces the clock timers and clock() read, which is the real one
+// by default.
+func (i *Interpreter) SetClock(clock Clock) {
+	i.shared.loop.clock = clock
+}
+
+// eventLoop holds the callbacks of timers and settled promises. They run one
+// at a time on whichever goroutine waits for the loop: an await outside an
+// async function, or the end of the script.
+type eventLoop struct {
+	mu    sync.Mutex
+	clock Clock
+	// tasks are ready to run, in order.
+	tasks []func(i *Interpreter)
+	// timers are ordered by when they are due, ties by when they were set.
+	timers []timer
+	// pending counts the operations of async natives that have not settled
+	// yet, and settled wakes the loop when one does.
+	pending int
+	settled chan struct{}
+	// rejected holds the promises rejected so far, to find those whose
+	// error nothing handled.
+	rejected []*Promise
+}
+
+type timer struct {
+	due  time.Time
+	task func(i *Interpreter)
+}
+
+func newEventLoop() eventLoop {
+	return eventLoop{clock: realClock{}, settled: make(chan struct{}, 1)}
+}
+
+// after queues task to run once d has passed.
+func (l *eventLoop) after(d time.Duration, task func(i *Interpreter)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	due := l.clock.Now().Add(d)
+	index := sort.Search(len(l.timers), func(k int) bool { return l.timers[k].due.After(due) })
+	l.timers = append(l.timers, timer{})
+	copy(l.timers[index+1:], l.timers[index:])
+	l.timers[index] = timer{due: due, task: task}
+}
+
+// wake tells a waiting loop that an async native has settled.
+func (l *eventLoop) wake() {
+	select {
+	case l.settled <- struct{}{}:
+	default:
+	}
+}
+
+// runLoop runs tasks until done reports true, waiting for timers and async
+// natives when there is nothing to run. It reports false if the loop ran out
+// of work first. Waiting counts against the time limit, which is reported
+// at at when it runs out.
+func (i *Interpreter) runLoop(at token.Token, done func() bool) bool {
+	l := &i.shared.loop
+	for !done() {
+		l.mu.Lock()
+		if len(l.tasks) > 0 {
+			task := l.tasks[0]
+			l.tasks = l.tasks[1:]
+			l.mu.Unlock()
+			task(i)
+			continue
+		}
+		_, fake := l.clock.(*FakeClock)
+		if len(l.timers) > 0 && !(fake && l.pending > 0) {
+			wait := l.timers[0].due.Sub(l.clock.Now())
+			l.mu.Unlock()
+			if wait > 0 {
+				limit, stop := i.timeLimit()
+				select {
+				case <-l.clock.After(wait):
+				case <-l.settled:
+				case <-limit:
+					panic(i.runtimeError(at, "Time limit exceeded."))
+				}
+				stop()
+			}
+			l.mu.Lock()
+			now := l.clock.Now()
+			for len(l.timers) > 0 && !l.timers[0].due.After(now) {
+				l.tasks = append(l.tasks, l.timers[0].task)
+				l.timers = l.timers[1:]
+			}
+			l.mu.Unlock()
+			continue
+		}
+		if l.pending > 0 {
+			l.mu.Unlock()
+			limit, stop := i.timeLimit()
+			select {
+			case <-l.settled:
+			case <-limit:
+				panic(i.runtimeError(at, "Time limit exceeded."))
+			}
+			stop()
+			continue
+		}
+		l.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// drain runs the event loop until it has nothing left to do, then raises
+// the error of a rejected promise that nothing awaited. at is where running
+// out of time while waiting is reported.
+func (i *Interpreter) drain(at token.Token) {
+	i.runLoop(at, func() bool { return false })
+	l := &i.shared.loop
+	l.mu.Lock()
+	var err error
+	for _, p := range l.rejected {
+		if !p.handled {
+			err = p.err
+			break
+		}
+	}
+	l.rejected = nil
+	l.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+type promiseState int
+
+const (
+	promisePending promiseState = iota
+	promiseFulfilled
+	promiseRejected
+)
+
+// Promise is the eventual result of an async function, a sleep or an async
+// native. It is fulfilled with a value or rejected with a runtime error. The
+// event loop's lock guards it.
+type Promise struct {
+	state promiseState
+	value interface{}
+	err   error
+	// reactions run once the promise settles.
+	reactions []func(i *Interpreter)
+	// handled is set once something waits for the promise, so that its
+	// error is not reported twice.
+	handled bool
+}
+
+func (p *Promise) String() string {
+	return "<promise>"
+}
+
+// settle fulfills p with value, or rejects it with err, and queues its
+// reactions. Settling a promise twice does nothing. The lock must be held.
+func (l *eventLoop) settle(p *Promise, value interface{}, err error) {
+	if p.state != promisePending {
+		return
+	}
+	p.state, p.value, p.err = promiseFulfilled, value, err
+	if err != nil {
+		p.state = promiseRejected
+		l.rejected = append(l.rejected, p)
+	}
+	l.tasks = append(l.tasks, p.reactions...)
+	p.reactions = nil
+}
+
+// then arranges for reaction to run as a task once p settles, with its value
+// or error.
+func (l *eventLoop) then(p *Promise, reaction func(i *Interpreter, value interface{}, err error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p.handled = true
+	task := func(i *Interpreter) {
+		reaction(i, p.value, p.err)
+	}
+	if p.state == promisePending {
+		p.reactions = append(p.reactions, task)
+	} else {
+		l.tasks = append(l.tasks, task)
+	}
+}
+
+// coroutine runs the body of an async function call on a goroutine of its
+// own, which hands control back whenever the body awaits. Like a generator,
+// only one of the body and the code resuming it runs at a time.
+type coroutine struct {
+	thread  *Interpreter
+	promise *Promise
+	resume  chan settlement
+	results chan coroutineResult
+}
+
+// settlement is the outcome of an awaited promise.
+type settlement struct {
+	value interface{}
+	err   error
+}
+
+// coroutineResult is what the body hands back when it stops running: the
+// promise it awaits, or its end with a return value or a panic.
+type coroutineResult struct {
+	awaiting *Promise
+	value    interface{}
+	failure  interface{}
+}
+
+// startAsync calls an async function, whose body runs until it first awaits,
+// and returns the promise of its result.
+func (i *Interpreter) startAsync(f *Function, env *environment.Environment) *Promise {
+	co := &coroutine{
+		thread:  i.thread(i.spawned),
+		promise: &Promise{},
+		resume:  make(chan settlement),
+		results: make(chan coroutineResult),
+	}
+	co.thread.async = co
+	go func() {
+		<-co.resume
+		defer func() {
+			switch r := recover().(type) {
+			case nil:
+				co.results <- coroutineResult{}
+			case returnValue:
+				co.results <- coroutineResult{value: r.value}
+			default:
+				co.results <- coroutineResult{failure: r}
+			}
+		}()
+		co.thread.runBody(f.declaration, env)
+	}()
+	co.step(i, settlement{})
+	return co.promise
+}
+
+// step resumes the body with the outcome of the promise it awaited and runs
+// it until it awaits again, or finishes and settles the call's promise.
+func (co *coroutine) step(i *Interpreter, s settlement) {
+	co.thread.spawned, co.thread.depth = i.spawned, i.depth
+	co.resume <- s
+	result := <-co.results
+
+	l := &i.shared.loop
+	if result.awaiting != nil {
+		l.then(result.awaiting, func(i *Interpreter, value interface{}, err error) {
+			co.step(i, settlement{value: value, err: err})
+		})
+		return
+	}
+	var err error
+	switch r := result.failure.(type) {
+	case nil:
+	case RuntimeError:
+		err = r
+	case environment.UndefinedError:
+		err = i.runtimeError(r.Name, r.Error())
+	default:
+		panic(r)
+	}
+	l.mu.Lock()
+	l.settle(co.promise, result.value, err)
+	l.mu.Unlock()
+}
+
+// VisitAwaitExpr waits for a promise and evaluates to its value, raising its
+// error if it was rejected. Any other value is awaited as if it were a
+// fulfilled promise. In an async function the body steps aside while it
+// waits; anywhere else the event loop runs until the promise settles.
+func (i *Interpreter) VisitAwaitExpr(expr *expression.Await) interface{} {
+	value := i.evaluate(expr.Value)
+	promise, ok := value.(*Promise)
+	if !ok {
+		promise = &Promise{state: promiseFulfilled, value: value}
+	}
+
+	if co := i.async; co != nil {
+		co.results <- coroutineResult{awaiting: promise}
+		s := <-co.resume
+		if s.err != nil {
+			panic(s.err)
+		}
+		return s.value
+	}
+
+	l := &i.shared.loop
+	l.mu.Lock()
+	promise.handled = true
+	l.mu.Unlock()
+	settled := i.runLoop(expr.Keyword, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return promise.state != promisePending
+	})
+	if !settled {
+		panic(i.runtimeError(expr.Keyword, "Awaited promise can never settle."))
+	}
+	if promise.err != nil {
+		panic(promise.err)
+	}
+	return promise.value
+}
+
+// AsyncNative is a native function whose result arrives later, such as one
+// that waits on I/O. Fn starts the work and calls settle exactly once, from
+// any goroutine, with the result or an error. A script calling it gets a
+// promise of the result; an error rejects the promise with a runtime error
+// at the call.
+type AsyncNative struct {
+	Name   string
+	Params int
+	Fn     func(i *Interpreter, arguments []interface{}, settle func(value interface{}, err error))
+}
+
+func (n *AsyncNative) Arity() int {
+	return n.Params
+}
+
+func (n *AsyncNative) Call(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	return n.start(i, token.Token{}, arguments), nil
+}
+
+func (n *AsyncNative) String() string {
+	return "<native fn>"
+}
+
+// start runs Fn and returns the promise it settles. paren is where an error
+// is reported.
+func (n *AsyncNative) start(i *Interpreter, paren token.Token, arguments []interface{}) *Promise {
+	l := &i.shared.loop
+	promise := &Promise{}
+	l.mu.Lock()
+	l.pending++
+	l.mu.Unlock()
+
+	var once sync.Once
+	n.Fn(i, arguments, func(value interface{}, err error) {
+		once.Do(func() {
+			if err != nil {
+				err = i.runtimeError(paren, err.Error())
+			}
+			l.mu.Lock()
+			l.pending--
+			l.settle(promise, value, err)
+			l.mu.Unlock()
+			l.wake()
+		})
+	})
+	return promise
+}
+
+var errDelay = errors.New("Delay must be a non-negative number of milliseconds.")
+
+// delay converts a number of milliseconds to a duration.
+func delay(value interface{}) (time.Duration, error) {
+	ms, ok := value.(float64)
+	if !ok || ms < 0 {
+		return 0, errDelay
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// sleepFor returns a promise fulfilled with nil after the given
+// milliseconds.
+func sleepFor(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	d, err := delay(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	l := &i.shared.loop
+	promise := &Promise{}
+	l.after(d, func(i *Interpreter) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.settle(promise, nil, nil)
+	})
+	return promise, nil
+}
+
+// setTimer calls a function without arguments after the given
+// milliseconds.
+func setTimer(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	callback, ok := arguments[0].(Callable)
+	if !ok || callback.Arity() != 0 {
+		return nil, errors.New("Timer callback must be a function without parameters.")
+	}
+	d, err := delay(arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	i.shared.loop.after(d, func(i *Interpreter) {
+		i.call(token.Token{}, callback, nil)
+	})
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package interpreter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// fetch is an async native that settles on another goroutine, as one doing
+// I/O would.
+var fetch = &AsyncNative{Name: "fetch", Params: 1, Fn: func(i *Interpreter, arguments []interface{}, settle func(interface{}, error)) {
+	key, _ := arguments[0].(string)
+	go func() {
+		time.Sleep(time.Millisecond)
+		if key == "" {
+			settle(nil, errors.New("Nothing to fetch."))
+			return
+		}
+		settle("got "+key, nil)
+	}()
+}}
+
+// cached settles before it returns.
+var cached = &AsyncNative{Name: "cached", Params: 0, Fn: func(i *Interpreter, arguments []interface{}, settle func(interface{}, error)) {
+	settle("hit", nil)
+}}
+
+func TestAsync(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+		err    string
+	}{
+		{"Async native", "print await fetch(\"a\");", "got a\n", ""},
+		{"Settled at once", "print await cached();", "hit\n", ""},
+		{"Rejected", "print \"before\";\nawait fetch(\"\");", "before\n", "Nothing to fetch.\n[line 2]"},
+		{"Unawaited rejection", "fetch(\"\");\nprint \"after\";", "after\n", "Nothing to fetch.\n[line 1]"},
+		{"In an async function", "async fun get(key) { return await fetch(key) + \"!\"; }\nprint await get(\"b\");", "got b!\n", ""},
+		{"Timers and natives", "var p = fetch(\"c\");\nsetTimeout(fun () { print \"timer\"; }, 60000);\nprint await p;", "got c\ntimer\n", ""},
+		{"Timer callback", "setTimeout(fun (x) {}, 1);", "", "Timer callback must be a function without parameters.\n[line 1]"},
+		{"Fake clock", "await sleep(60000);\nprint clock();", "60\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := parser.NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+			i := NewInterpreter()
+			i.SetOutput(&out)
+			i.SetClock(NewFakeClock(time.Unix(0, 0)))
+			i.Define(fetch.Name, fetch)
+			i.Define(cached.Name, cached)
+			err = i.Interpret(statements)
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Interpret() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("Interpret() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package interpreter
+
+import (
+	"fmt"
+	"time"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+)
+
+// Callable is a value that can be called from a script. A Call that returns
+// an error raises a runtime error at the call site.
+type Callable interface {
+	Arity() int
+	Call(i *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+// Function is a function declared in a script together with the scope it
+// was declared in.
+type Function struct {
+	declaration *expression.Function
+	closure     *environment.Environment
+}
+
+// returnValue carries the value of a return statement up to the call.
+type returnValue struct {
+	value interface{}
+}
+
+func (f *Function) Arity() int {
+	return len(f.declaration.Params)
+}
+
+func (f *Function) Call(i *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	env := environment.NewEnvironment(f.closure)
+	for index, param := range f.declaration.Params {
+		env.Define(param.Lexeme, arguments[index])
+	}
+	if i.isGenerator(f.declaration) {
+		return &Generator{function: f, env: env}, nil
+	}
+	if f.declaration.Async {
+		return i.startAsync(f, env), nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(returnValue)
+			if !ok {
+				panic(r)
+			}
+			result = ret.value
+		}
+	}()
+	i.runBody(f.declaration, env)
+	return nil, nil
+}
+
+// runBody executes the body of fn in env, telling the call hook, if there is
+// one, when it starts and when it is left.
+func (i *Interpreter) runBody(fn *expression.Function, env *environment.Environment) {
+	if i.callHook != nil {
+		i.shared.hooks.Lock()
+		i.callHook.EnterFunction(fn)
+		i.shared.hooks.Unlock()
+		defer func() {
+			i.shared.hooks.Lock()
+			defer i.shared.hooks.Unlock()
+			i.callHook.ExitFunction(fn)
+		}()
+	}
+	i.executeBlock(fn.Body, env)
+}
+
+// Name returns the name the function was declared with, which is empty for
+// a lambda.
+func (f *Function) Name() string {
+	return f.declaration.Name.Lexeme
+}
+
+func (f *Function) String() string {
+	if f.declaration.Name.Lexeme == "" {
+		return "<fn>"
+	}
+	return fmt.Sprintf("<fn %s>", f.declaration.Name.Lexeme)
+}
+
+// NativeFunction is a function implemented in Go.
+type NativeFunction struct {
+	Name   string
+	Params int
+	Fn     func(i *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.Params
+}
+
+func (n *NativeFunction) Call(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	return n.Fn(i, arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// natives are defined in the global scope of every interpreter.
+var natives = []*NativeFunction{
+	{Name: "clock", Params: 0, Fn: func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return float64(i.shared.loop.clock.Now().UnixNano()) / float64(time.Second), nil
+	}},
+	{Name: "range", Params: 3, Fn: newRange},
+	{Name: "channel", Params: 1, Fn: newChannel},
+	{Name: "send", Params: 2, Fn: channelSend},
+	{Name: "recv", Params: 1, Fn: channelReceive},
+	{Name: "close", Params: 1, Fn: channelClose},
+	{Name: "waitGroup", Params: 0, Fn: newWaitGroup},
+	{Name: "groupAdd", Params: 2, Fn: groupAdd},
+	{Name: "groupDone", Params: 1, Fn: groupDone},
+	{Name: "groupWait", Params: 1, Fn: groupWait},
+	{Name: "sleep", Params: 1, Fn: sleepFor},
+	{Name: "setTimeout", Params: 2, Fn: setTimer},
+}
@@ -0,0 +1,412 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// List is the value of a list literal such as [1, 2]. Spawned goroutines may
+// share a list, so reading or writing an element takes its lock.
+type List struct {
+	mu       sync.RWMutex
+	elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{elements: elements}
+}
+
+// Len returns the number of elements, which never changes.
+func (l *List) Len() int {
+	return len(l.elements)
+}
+
+// Get returns the element at position, which must be in range.
+func (l *List) Get(position int) interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.elements[position]
+}
+
+// Set replaces the element at position, which must be in range.
+func (l *List) Set(position int, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.elements[position] = value
+}
+
+// Elements returns a copy of the elements.
+func (l *List) Elements() []interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]interface{}(nil), l.elements...)
+}
+
+func (l *List) String() string {
+	elements := l.Elements()
+	parts := make([]string, len(elements))
+	for index, element := range elements {
+		parts[index] = Stringify(element)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// Map is the value of a map literal such as {name: "Ada"}. Its keys keep the
+// order they were first set in. Like a list, a map may be shared between
+// goroutines, so every access takes its lock.
+type Map struct {
+	mu     sync.RWMutex
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+func NewMap() *Map {
+	return &Map{values: map[interface{}]interface{}{}}
+}
+
+// Get returns the value stored under key.
+func (m *Map) Get(key interface{}) (interface{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set stores value under key, replacing any value already there.
+func (m *Map) Set(key, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Keys returns the keys in the order they were first set.
+func (m *Map) Keys() []interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]interface{}(nil), m.keys...)
+}
+
+func (m *Map) String() string {
+	keys := m.Keys()
+	parts := make([]string, len(keys))
+	for index, key := range keys {
+		value, _ := m.Get(key)
+		parts[index] = Stringify(key) + ": " + Stringify(value)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Range is the value of range(start, end, step): the numbers from start
+// towards end, which is left out, step apart.
+type Range struct {
+	Start, End, Step float64
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("range(%s, %s, %s)", Stringify(r.Start), Stringify(r.End), Stringify(r.Step))
+}
+
+func newRange(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	var bounds [3]float64
+	for index, argument := range arguments {
+		number, ok := argument.(float64)
+		if !ok {
+			return nil, errors.New("Range arguments must be numbers.")
+		}
+		bounds[index] = number
+	}
+	if bounds[2] == 0 {
+		return nil, errors.New("Range step must not be zero.")
+	}
+	return &Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}, nil
+}
+
+// iterator produces the values a for-in loop visits, one per call, and
+// reports false once there are none left.
+type iterator func() (interface{}, bool)
+
+// noStop is the stop function of iterators with nothing to release.
+func noStop() {}
+
+// iterate returns an iterator over the characters of a string, the numbers
+// of a range, the elements of a list, the keys of a map, the values a
+// generator yields or those received from a channel until it is closed. Any
+// other object must follow the iterator protocol: either an iter method
+// returning something to iterate over, or hasNext and next methods. A map
+// holding such methods follows the protocol instead of giving its keys.
+// keyword is the for loop's, where errors are reported. stop releases what
+// the iterator holds once the loop is left, closing a generator.
+func (i *Interpreter) iterate(keyword token.Token, value interface{}) (iterator, func()) {
+	return i.iterator(keyword, value, true)
+}
+
+func (i *Interpreter) iterator(keyword token.Token, value interface{}, allowIter bool) (iterator, func()) {
+	index := 0
+	switch v := value.(type) {
+	case string:
+		characters := []rune(v)
+		return func() (interface{}, bool) {
+			if index >= len(characters) {
+				return nil, false
+			}
+			index++
+			return string(characters[index-1]), true
+		}, noStop
+	case *Range:
+		return func() (interface{}, bool) {
+			number := v.Start + float64(index)*v.Step
+			if (v.Step > 0 && number >= v.End) || (v.Step < 0 && number <= v.End) {
+				return nil, false
+			}
+			index++
+			return number, true
+		}, noStop
+	case *List:
+		return func() (interface{}, bool) {
+			if index >= v.Len() {
+				return nil, false
+			}
+			index++
+			return v.Get(index - 1), true
+		}, noStop
+	case *Map:
+		if i.followsProtocol(v, allowIter) {
+			break
+		}
+		keys := v.Keys()
+		return func() (interface{}, bool) {
+			if index >= len(keys) {
+				return nil, false
+			}
+			index++
+			return keys[index-1], true
+		}, noStop
+	case *Generator:
+		return func() (interface{}, bool) {
+			return v.next(i, keyword)
+		}, v.close
+	case *Channel:
+		return func() (interface{}, bool) {
+			value, ok, err := i.receive(v)
+			if err != nil {
+				panic(i.runtimeError(keyword, err.Error()))
+			}
+			return value, ok
+		}, noStop
+	}
+
+	if iter, ok := i.method(value, "iter"); ok && allowIter {
+		return i.iterator(keyword, i.call(keyword, iter, nil), false)
+	}
+	hasNext, hasNextOk := i.method(value, "hasNext")
+	next, nextOk := i.method(value, "next")
+	if !hasNextOk || !nextOk {
+		panic(i.runtimeError(keyword, "Can only iterate over strings, ranges, lists, maps, generators, channels and iterators."))
+	}
+	return func() (interface{}, bool) {
+		if !i.isTruthy(i.call(keyword, hasNext, nil)) {
+			return nil, false
+		}
+		return i.call(keyword, next, nil), true
+	}, noStop
+}
+
+// method looks up a method of an object for the iterator protocol: a
+// function stored under its name in a map, or an exported function of a
+// module.
+func (i *Interpreter) method(object interface{}, name string) (interface{}, bool) {
+	switch o := object.(type) {
+	case *Map:
+		value, ok := o.Get(name)
+		if _, callable := value.(Callable); ok && callable {
+			return value, true
+		}
+	case *Module:
+		return o.Export(name)
+	}
+	return nil, false
+}
+
+// followsProtocol reports whether a map holds the methods of the iterator
+// protocol.
+func (i *Interpreter) followsProtocol(m *Map, allowIter bool) bool {
+	if _, ok := i.method(m, "iter"); ok && allowIter {
+		return true
+	}
+	_, hasNext := i.method(m, "hasNext")
+	_, next := i.method(m, "next")
+	return hasNext && next
+}
+
+// VisitForInStmt runs the body once for each value of the iterable, each
+// time in a fresh environment holding the loop variable, so that closures
+// made in the body keep the value of their own iteration.
+func (i *Interpreter) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	next, stop := i.iterate(stmt.Keyword, i.evaluate(stmt.Iterable))
+	defer stop()
+	for {
+		value, ok := next()
+		i.branch(stmt, ok)
+		if !ok {
+			return nil
+		}
+		env := environment.NewEnvironment(i.environment)
+		env.Define(stmt.Name.Lexeme, value)
+		i.executeBlock([]expression.Stmt{stmt.Body}, env)
+	}
+}
+
+func (i *Interpreter) VisitListExpr(expr *expression.List) interface{} {
+	elements := make([]interface{}, len(expr.Elements))
+	for index, element := range expr.Elements {
+		elements[index] = i.evaluate(element)
+	}
+	return NewList(elements)
+}
+
+func (i *Interpreter) VisitMapExpr(expr *expression.Map) interface{} {
+	m := NewMap()
+	for index, key := range expr.Keys {
+		m.Set(i.evaluate(key), i.evaluate(expr.Values[index]))
+	}
+	return m
+}
+
+// VisitIndexExpr looks up an element of a list by its position or a value of
+// a map by its key. A key a map lacks gives nil.
+func (i *Interpreter) VisitIndexExpr(expr *expression.Index) interface{} {
+	object := i.evaluate(expr.Object)
+	if expr.Optional {
+		i.branch(expr, object != nil)
+		if object == nil {
+			return nil
+		}
+	}
+	key := i.evaluate(expr.Key)
+	value, err := index(object, key)
+	if err != nil {
+		panic(i.runtimeError(expr.Bracket, err.Error()))
+	}
+	return value
+}
+
+// VisitSetIndexExpr stores a value as an element of a list, which must
+// already have that position, or under any key of a map.
+func (i *Interpreter) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	object := i.evaluate(expr.Object)
+	key := i.evaluate(expr.Key)
+	value := i.evaluate(expr.Value)
+	if _, err := index(object, key); err != nil {
+		panic(i.runtimeError(expr.Bracket, err.Error()))
+	}
+	setIndex(object, key, value)
+	return value
+}
+
+func index(object, key interface{}) (interface{}, error) {
+	switch o := object.(type) {
+	case *List:
+		position, ok := integer(key)
+		if !ok {
+			return nil, errors.New("List index must be an integer.")
+		}
+		if position < 0 || position >= int64(o.Len()) {
+			return nil, fmt.Errorf("Index %s is out of range for a list of length %d.", Stringify(key), o.Len())
+		}
+		return o.Get(int(position)), nil
+	case *Map:
+		value, _ := o.Get(key)
+		return value, nil
+	}
+	return nil, errors.New("Can only index lists and maps.")
+}
+
+// setIndex stores value as the element of object under key, which index
+// has already read.
+func setIndex(object, key, value interface{}) {
+	switch o := object.(type) {
+	case *List:
+		position, _ := integer(key)
+		o.Set(int(position), value)
+	case *Map:
+		o.Set(key, value)
+	}
+}
+
+// destructure takes value apart as target describes and hands each piece to
+// bind along with the name it goes into. A list pattern needs a list with as
+// many elements as it has, or at least as many when it has a rest element,
+// and a map pattern needs a map with every key it reads.
+func (i *Interpreter) destructure(target expression.Target, value interface{}, bind func(token.Token, interface{})) {
+	switch t := target.(type) {
+	case *expression.NameTarget:
+		bind(t.Name, value)
+	case *expression.ListTarget:
+		list, ok := value.(*List)
+		if !ok {
+			panic(i.runtimeError(t.Bracket, "Expected a list to destructure."))
+		}
+		elements := list.Elements()
+		if n := len(elements); t.Rest == nil && n != len(t.Elements) {
+			panic(i.runtimeError(t.Bracket, fmt.Sprintf("Expected %d elements to destructure but got %d.", len(t.Elements), n)))
+		} else if n < len(t.Elements) {
+			panic(i.runtimeError(t.Bracket, fmt.Sprintf("Expected at least %d elements to destructure but got %d.", len(t.Elements), n)))
+		}
+		for index, element := range t.Elements {
+			i.destructure(element, elements[index], bind)
+		}
+		if t.Rest != nil {
+			bind(*t.Rest, NewList(elements[len(t.Elements):]))
+		}
+	case *expression.MapTarget:
+		m, ok := value.(*Map)
+		if !ok {
+			panic(i.runtimeError(t.Brace, "Expected a map to destructure."))
+		}
+		for index, element := range t.Values {
+			entry, ok := m.Get(t.Key(index))
+			if !ok {
+				panic(i.runtimeError(t.Keys[index], fmt.Sprintf("Map has no key '%s'.", t.Key(index))))
+			}
+			i.destructure(element, entry, bind)
+		}
+	}
+}
+
+// fits reports whether value has the shape target describes, so that
+// destructuring it cannot fail.
+func fits(target expression.Target, value interface{}) bool {
+	switch t := target.(type) {
+	case *expression.ListTarget:
+		list, ok := value.(*List)
+		if !ok || list.Len() < len(t.Elements) || t.Rest == nil && list.Len() != len(t.Elements) {
+			return false
+		}
+		for index, element := range t.Elements {
+			if !fits(element, list.Get(index)) {
+				return false
+			}
+		}
+	case *expression.MapTarget:
+		m, ok := value.(*Map)
+		if !ok {
+			return false
+		}
+		for index, element := range t.Values {
+			entry, ok := m.Get(t.Key(index))
+			if !ok || !fits(element, entry) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,549 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// shared is the state of a script that all the goroutines running it see.
+type shared struct {
+	// mu guards the scheduling state below, the module cache and every
+	// channel and wait group.
+	mu sync.Mutex
+	// running counts the goroutines that are not blocked, the main one
+	// included, and waiting holds those that are. When nothing is running
+	// any more, the script has deadlocked.
+	running int
+	waiting []*waiter
+	// failure is the first runtime error raised on a spawned goroutine. It
+	// stops the script as if the main goroutine had raised it.
+	failure error
+	failed  atomic.Bool
+
+	// hooks makes goroutines take turns calling the hook, and output keeps
+	// printed lines whole.
+	hooks  sync.Mutex
+	output sync.Mutex
+
+	steps      atomic.Int64
+	generators sync.Map
+	loop       eventLoop
+	// distances holds how far out from each variable use its scope is,
+	// keyed by resolver.Key, for every script and module run.
+	distances sync.Map
+}
+
+func newShared() *shared {
+	return &shared{running: 1, loop: newEventLoop()}
+}
+
+// exitGoroutine unwinds a spawned goroutine that has no reason to go on:
+// the script failed or deadlocked, which the main goroutine reports.
+type exitGoroutine struct{}
+
+var (
+	errDeadlock   = errors.New("Deadlock: every goroutine is blocked.")
+	errClosedSend = errors.New("Send on closed channel.")
+)
+
+// wakeReason says why a waiter was woken.
+type wakeReason int
+
+const (
+	completed wakeReason = iota
+	// closedWhileSending means a channel was closed under a blocked send.
+	closedWhileSending
+	deadlocked
+	failing
+)
+
+// waiter is a goroutine blocked on channel operations or a wait group.
+// Whichever goroutine lets it continue records the outcome and closes
+// ready, all with the shared lock held.
+type waiter struct {
+	ready  chan struct{}
+	done   bool
+	reason wakeReason
+	// index is the operation that completed, value what it received and ok
+	// whether the channel was still open.
+	index int
+	value interface{}
+	ok    bool
+}
+
+func newWaiter() *waiter {
+	return &waiter{ready: make(chan struct{}), index: -1}
+}
+
+// wake lets w continue and counts it as running again. It does nothing if
+// w was woken already, as happens to a select whose other channels are
+// still looking for it.
+func (s *shared) wake(w *waiter, reason wakeReason, index int, value interface{}, ok bool) {
+	if w.done {
+		return
+	}
+	w.done, w.reason, w.index, w.value, w.ok = true, reason, index, value, ok
+	s.running++
+	close(w.ready)
+}
+
+// block waits, with the shared lock held, until w is woken.
+func (s *shared) block(w *waiter) {
+	s.waiting = append(s.waiting, w)
+	s.running--
+	s.detectDeadlock()
+	s.mu.Unlock()
+	<-w.ready
+	s.mu.Lock()
+	for index, other := range s.waiting {
+		if other == w {
+			s.waiting = append(s.waiting[:index], s.waiting[index+1:]...)
+			break
+		}
+	}
+}
+
+// detectDeadlock wakes every waiter once no goroutine is left to wake them.
+func (s *shared) detectDeadlock() {
+	if s.running > 0 {
+		return
+	}
+	for _, w := range s.waiting {
+		s.wake(w, deadlocked, -1, nil, false)
+	}
+}
+
+// fail records the first runtime error of a spawned goroutine and wakes
+// every waiter, so that the main goroutine can report it. The shared lock
+// must be held.
+func (s *shared) fail(err error) {
+	if s.failure == nil {
+		s.failure = err
+		s.failed.Store(true)
+	}
+	for _, w := range s.waiting {
+		s.wake(w, failing, -1, nil, false)
+	}
+}
+
+// checkFailure stops the goroutine once a spawned goroutine has failed: the
+// main one raises the failure and any other ends quietly.
+func (i *Interpreter) checkFailure() {
+	if !i.shared.failed.Load() {
+		return
+	}
+	if i.spawned {
+		panic(exitGoroutine{})
+	}
+	s := i.shared
+	s.mu.Lock()
+	err := s.failure
+	s.failure = nil
+	s.failed.Store(false)
+	s.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// thread returns an interpreter for running code on another goroutine. It
+// shares everything with i except the current scope and the call stack.
+func (i *Interpreter) thread(spawned bool) *Interpreter {
+	t := *i
+	t.spawned = spawned
+	t.generator = nil
+	t.async = nil
+	t.depth = 0
+	t.importing = append([]string(nil), i.importing...)
+	if hook, ok := i.hook.(ThreadHook); ok {
+		t.SetHook(hook.Thread())
+	}
+	return &t
+}
+
+// VisitSpawnStmt evaluates the callee and arguments of the call, then makes
+// the call on a new goroutine. A runtime error there ends the script.
+func (i *Interpreter) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	callee := i.evaluate(stmt.Call.Callee)
+	arguments := make([]interface{}, len(stmt.Call.Arguments))
+	for index, argument := range stmt.Call.Arguments {
+		arguments[index] = i.evaluate(argument)
+	}
+	i.callable(stmt.Call.Paren, callee, len(arguments))
+
+	s := i.shared
+	s.mu.Lock()
+	s.running++
+	s.mu.Unlock()
+	go i.thread(true).run(stmt.Call.Paren, callee, arguments)
+	return nil
+}
+
+// run makes the call of a spawn statement and accounts for the goroutine
+// ending.
+func (i *Interpreter) run(paren token.Token, callee interface{}, arguments []interface{}) {
+	defer func() {
+		var err error
+		switch r := recover().(type) {
+		case nil, exitGoroutine:
+		case RuntimeError:
+			err = r
+		case environment.UndefinedError:
+			err = i.runtimeError(r.Name, r.Error())
+		default:
+			panic(r)
+		}
+		s := i.shared
+		s.mu.Lock()
+		if err != nil {
+			s.fail(err)
+		}
+		s.running--
+		s.detectDeadlock()
+		s.mu.Unlock()
+	}()
+	i.call(paren, callee, arguments)
+}
+
+// Channel is the value of channel(capacity). Sends block while capacity
+// values are waiting to be received, and receives while there are none.
+type Channel struct {
+	capacity  int
+	buffer    []interface{}
+	closed    bool
+	senders   []pending
+	receivers []pending
+}
+
+func (c *Channel) String() string {
+	return "<channel>"
+}
+
+// pending is an operation a blocked goroutine is waiting to complete.
+type pending struct {
+	waiter *waiter
+	index  int
+	value  interface{}
+}
+
+// nextPending removes the first operation of queue whose goroutine still waits.
+func nextPending(queue *[]pending) (pending, bool) {
+	for len(*queue) > 0 {
+		p := (*queue)[0]
+		*queue = (*queue)[1:]
+		if !p.waiter.done {
+			return p, true
+		}
+	}
+	return pending{}, false
+}
+
+// enqueue adds p to queue, dropping the operations of goroutines that have
+// moved on.
+func enqueue(queue *[]pending, p pending) {
+	live := (*queue)[:0]
+	for _, q := range *queue {
+		if !q.waiter.done {
+			live = append(live, q)
+		}
+	}
+	*queue = append(live, p)
+}
+
+func (c *Channel) trySend(s *shared, value interface{}) bool {
+	if receiver, ok := nextPending(&c.receivers); ok {
+		s.wake(receiver.waiter, completed, receiver.index, value, true)
+		return true
+	}
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+		return true
+	}
+	return false
+}
+
+// tryReceive reports whether a receive can complete at once, and if so the
+// value and whether the channel was still open.
+func (c *Channel) tryReceive(s *shared) (value interface{}, ok, ready bool) {
+	if len(c.buffer) > 0 {
+		value, c.buffer = c.buffer[0], c.buffer[1:]
+		if sender, found := nextPending(&c.senders); found {
+			c.buffer = append(c.buffer, sender.value)
+			s.wake(sender.waiter, completed, sender.index, nil, true)
+		}
+		return value, true, true
+	}
+	if sender, found := nextPending(&c.senders); found {
+		s.wake(sender.waiter, completed, sender.index, nil, true)
+		return sender.value, true, true
+	}
+	if c.closed {
+		return nil, false, true
+	}
+	return nil, false, false
+}
+
+// operation is a send or receive a goroutine may block on.
+type operation struct {
+	channel *Channel
+	send    bool
+	value   interface{}
+}
+
+// perform completes the first of ops that can proceed and returns its index
+// and, for a receive, the value and whether the channel was still open. If
+// none can, it returns -1 when block is false and otherwise waits until one
+// completes. The index of a failed send is returned with its error.
+func (i *Interpreter) perform(ops []operation, block bool) (index int, value interface{}, ok bool, err error) {
+	s := i.shared
+	s.mu.Lock()
+	for index, op := range ops {
+		if op.send {
+			if op.channel.closed {
+				s.mu.Unlock()
+				return index, nil, false, errClosedSend
+			}
+			if op.channel.trySend(s, op.value) {
+				s.mu.Unlock()
+				return index, nil, true, nil
+			}
+		} else if value, ok, ready := op.channel.tryReceive(s); ready {
+			s.mu.Unlock()
+			return index, value, ok, nil
+		}
+	}
+	if !block {
+		s.mu.Unlock()
+		return -1, nil, false, nil
+	}
+
+	w := newWaiter()
+	for index, op := range ops {
+		if op.send {
+			enqueue(&op.channel.senders, pending{waiter: w, index: index, value: op.value})
+		} else {
+			enqueue(&op.channel.receivers, pending{waiter: w, index: index})
+		}
+	}
+	s.block(w)
+	s.mu.Unlock()
+	if w.reason == closedWhileSending {
+		return w.index, nil, false, errClosedSend
+	}
+	return w.index, w.value, w.ok, i.woken(w)
+}
+
+// woken handles a waiter woken without completing its operation. A deadlock
+// is an error for the main goroutine and ends any other.
+func (i *Interpreter) woken(w *waiter) error {
+	switch w.reason {
+	case deadlocked:
+		if i.spawned {
+			panic(exitGoroutine{})
+		}
+		return errDeadlock
+	case failing:
+		i.checkFailure()
+		panic(exitGoroutine{})
+	}
+	return nil
+}
+
+// receive waits for a value from channel. It reports false once the channel
+// is closed and drained.
+func (i *Interpreter) receive(channel *Channel) (interface{}, bool, error) {
+	_, value, ok, err := i.perform([]operation{{channel: channel}}, true)
+	return value, ok, err
+}
+
+// VisitSelectStmt evaluates the channel and value of every case in order,
+// then runs the body of the first case whose operation can proceed. Without
+// one it waits, unless there is a default case to run instead.
+func (i *Interpreter) VisitSelectStmt(stmt *expression.Select) interface{} {
+	var ops []operation
+	var cases []*expression.SelectCase
+	var fallback *expression.SelectCase
+	for _, c := range stmt.Cases {
+		if c.IsDefault() {
+			fallback = c
+			continue
+		}
+		channel, ok := i.evaluate(c.Channel).(*Channel)
+		if !ok {
+			panic(i.runtimeError(c.Operation, "Can only select on channels."))
+		}
+		op := operation{channel: channel, send: c.IsSend()}
+		if op.send {
+			op.value = i.evaluate(c.Value)
+		}
+		ops, cases = append(ops, op), append(cases, c)
+	}
+
+	index, value, _, err := i.perform(ops, fallback == nil)
+	if err != nil {
+		at := stmt.Keyword
+		if index >= 0 {
+			at = cases[index].Operation
+		}
+		panic(i.runtimeError(at, err.Error()))
+	}
+	chosen := fallback
+	if index >= 0 {
+		chosen = cases[index]
+	}
+	for _, c := range stmt.Cases {
+		i.branch(c, c == chosen)
+	}
+
+	env := environment.NewEnvironment(i.environment)
+	if chosen.Name != nil {
+		env.Define(chosen.Name.Lexeme, value)
+	}
+	i.executeBlock([]expression.Stmt{chosen.Body}, env)
+	return nil
+}
+
+// WaitGroup is the value of waitGroup(): a counter that groupWait blocks on
+// until it drops to zero.
+type WaitGroup struct {
+	count   int
+	waiters []*waiter
+}
+
+func (g *WaitGroup) String() string {
+	return "<wait group>"
+}
+
+func (i *Interpreter) addToGroup(group *WaitGroup, delta int) error {
+	s := i.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if group.count+delta < 0 {
+		return errors.New("Wait group counter can't go negative.")
+	}
+	group.count += delta
+	if group.count == 0 {
+		for _, w := range group.waiters {
+			s.wake(w, completed, 0, nil, true)
+		}
+		group.waiters = nil
+	}
+	return nil
+}
+
+func (i *Interpreter) waitForGroup(group *WaitGroup) error {
+	s := i.shared
+	s.mu.Lock()
+	if group.count == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	w := newWaiter()
+	group.waiters = append(group.waiters, w)
+	s.block(w)
+	s.mu.Unlock()
+	return i.woken(w)
+}
+
+func newChannel(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	capacity, ok := arguments[0].(float64)
+	if !ok || capacity < 0 || capacity != float64(int(capacity)) {
+		return nil, errors.New("Channel capacity must be a non-negative integer.")
+	}
+	return &Channel{capacity: int(capacity)}, nil
+}
+
+func channelSend(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("send on", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	_, _, _, err = i.perform([]operation{{channel: channel, send: true, value: arguments[1]}}, true)
+	return nil, err
+}
+
+func channelReceive(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("receive from", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := i.receive(channel)
+	return value, err
+}
+
+// channelClose wakes everything waiting on the channel: receivers get nil
+// and senders an error.
+func channelClose(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("close", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	s := i.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if channel.closed {
+		return nil, errors.New("Close of closed channel.")
+	}
+	channel.closed = true
+	for receiver, ok := nextPending(&channel.receivers); ok; receiver, ok = nextPending(&channel.receivers) {
+		s.wake(receiver.waiter, completed, receiver.index, nil, false)
+	}
+	for sender, ok := nextPending(&channel.senders); ok; sender, ok = nextPending(&channel.senders) {
+		s.wake(sender.waiter, closedWhileSending, sender.index, nil, false)
+	}
+	return nil, nil
+}
+
+func newWaitGroup(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	return &WaitGroup{}, nil
+}
+
+func groupAdd(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	group, err := groupArgument(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	delta, ok := arguments[1].(float64)
+	if !ok || delta != float64(int(delta)) {
+		return nil, errors.New("Wait group delta must be an integer.")
+	}
+	return nil, i.addToGroup(group, int(delta))
+}
+
+func groupDone(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	group, err := groupArgument(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	return nil, i.addToGroup(group, -1)
+}
+
+func groupWait(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	group, err := groupArgument(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	return nil, i.waitForGroup(group)
+}
+
+func channelArgument(action string, value interface{}) (*Channel, error) {
+	channel, ok := value.(*Channel)
+	if !ok {
+		return nil, fmt.Errorf("Can only %s a channel.", action)
+	}
+	return channel, nil
+}
+
+func groupArgument(value interface{}) (*WaitGroup, error) {
+	group, ok := value.(*WaitGroup)
+	if !ok {
+		return nil, errors.New("Expected a wait group.")
+	}
+	return group, nil
+}
@@ -0,0 +1,59 @@
+package interpreter
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func addScripts(f *testing.F) {
+	root := filepath.Join("..", "..", "cmd", "myinterpreter", "testdata")
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".lox") {
+			if source, err := os.ReadFile(path); err == nil {
+				f.Add(string(source))
+			}
+		}
+		return nil
+	})
+}
+
+// fuzzLimits keep every input quick to evaluate.
+var fuzzLimits = Limits{Steps: 10000, Duration: time.Second, StringLength: 1 << 16}
+
+func FuzzEval(f *testing.F) {
+	addScripts(f)
+	f.Add("while (true) {}")
+	f.Add("fun f() { return f(); } f();")
+	f.Add("var s = \"a\"; while (true) s = s + s;")
+	f.Add("sleep(1e9);")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens, err := scanner.NewScanner(source).ScanTokens()
+		if err != nil {
+			return
+		}
+		statements, err := parser.NewParser(tokens).Parse()
+		if err != nil {
+			return
+		}
+
+		i := NewInterpreter()
+		i.SetOutput(io.Discard)
+		i.SetLimits(fuzzLimits)
+		if err := i.Interpret(statements); err != nil {
+			var runtimeErr RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("error %v (%T) is not a RuntimeError", err, err)
+			}
+		}
+	})
+}
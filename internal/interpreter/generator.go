@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"fmt"
+	"sync"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// Generator is the value of a call to a function whose body yields. The
+// body runs lazily on a goroutine of its own, which takes turns with the
+// code asking for values so that only one of them runs at a time: next
+// resumes the body and waits until it yields or finishes. A for-in loop
+// left before the end closes the generator, which unwinds the body from the
+// yield it is waiting at so that its goroutine exits.
+type Generator struct {
+	function *Function
+	env      *environment.Environment
+	// thread is the interpreter the body runs on.
+	thread  *Interpreter
+	resume  chan struct{}
+	results chan generatorResult
+	cancel  chan struct{}
+	// running is held while the body runs, so that it can't be resumed
+	// twice, from within or by another spawned goroutine.
+	running sync.Mutex
+	done    bool
+}
+
+// generatorResult is what the body hands back when it stops running: a
+// yielded value, the end of the body, or a panic to re-raise in the code
+// that resumed it.
+type generatorResult struct {
+	value   interface{}
+	ok      bool
+	failure interface{}
+}
+
+// generatorClosed unwinds the body of a generator that has been closed.
+type generatorClosed struct{}
+
+func (g *Generator) String() string {
+	if g.function.Name() == "" {
+		return "<generator>"
+	}
+	return fmt.Sprintf("<generator %s>", g.function.Name())
+}
+
+// isGenerator reports whether declaration yields, remembering the answer
+// since the same declaration is called over and over.
+func (i *Interpreter) isGenerator(declaration *expression.Function) bool {
+	if generator, ok := i.shared.generators.Load(declaration); ok {
+		return generator.(bool)
+	}
+	generator := expression.IsGenerator(declaration.Body)
+	i.shared.generators.Store(declaration, generator)
+	return generator
+}
+
+// next runs the body until its next yield and returns the value yielded, or
+// reports false once the body has finished. A runtime error in the body
+// surfaces here. keyword is where resuming a running generator is reported.
+func (g *Generator) next(i *Interpreter, keyword token.Token) (interface{}, bool) {
+	if !g.running.TryLock() {
+		panic(i.runtimeError(keyword, "Generator is already running."))
+	}
+	defer g.running.Unlock()
+	if g.done {
+		return nil, false
+	}
+	if g.resume == nil {
+		g.start(i)
+	}
+
+	// The body runs on behalf of i, so it counts against the same call
+	// depth and its failures are handled as i's would be.
+	g.thread.spawned, g.thread.depth = i.spawned, i.depth
+	g.resume <- struct{}{}
+	result := <-g.results
+
+	if result.failure != nil {
+		g.done = true
+		panic(result.failure)
+	}
+	if !result.ok {
+		g.done = true
+	}
+	return result.value, result.ok
+}
+
+// start launches the goroutine the body runs on, which waits to be resumed
+// for the first time.
+func (g *Generator) start(i *Interpreter) {
+	g.thread = i.thread(i.spawned)
+	g.thread.generator = g
+	g.resume = make(chan struct{})
+	g.results = make(chan generatorResult)
+	g.cancel = make(chan struct{})
+	go func() {
+		<-g.resume
+		defer func() {
+			switch r := recover().(type) {
+			case nil, returnValue, generatorClosed:
+				g.results <- generatorResult{}
+			default:
+				g.results <- generatorResult{failure: r}
+			}
+		}()
+		g.thread.runBody(g.function.declaration, g.env)
+	}()
+}
+
+// close finishes the generator early, waiting for the body to unwind if it
+// has started. A generator that is running, because the loop being left is
+// inside it, is left alone.
+func (g *Generator) close() {
+	if !g.running.TryLock() {
+		return
+	}
+	defer g.running.Unlock()
+	if g.done {
+		return
+	}
+	g.done = true
+	if g.resume != nil {
+		close(g.cancel)
+		<-g.results
+	}
+}
+
+// VisitYieldStmt hands a value to the code running the generator and waits
+// to be resumed, or closed.
+func (i *Interpreter) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = i.evaluate(stmt.Value)
+	}
+	g := i.generator
+	g.results <- generatorResult{value: value, ok: true}
+	select {
+	case <-g.resume:
+	case <-g.cancel:
+		panic(generatorClosed{})
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package interpreter
+
+import (
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// TestGeneratorsLeftEarly checks that leaving a for-in loop over a
+// generator before its end lets the generator's goroutine exit, however the
+// loop is left.
+func TestGeneratorsLeftEarly(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"Return", "fun first(g) { for (x in g) return x; }\nfor (n in range(0, 100, 1)) first(naturals());"},
+		{"Nested", "fun evens() { for (n in naturals()) if (n % 2 == 0) yield n; }\nfun first(g) { for (x in g) return x; }\nfor (n in range(0, 100, 1)) first(evens());"},
+		{"Error", "for (x in naturals()) x + nil;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "fun naturals() { var n = 0; while (true) { yield n; n += 1; } }\n" + tt.source
+			tokens, err := scanner.NewScanner(source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := parser.NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := runtime.NumGoroutine()
+			i := NewInterpreter()
+			i.SetOutput(io.Discard)
+			i.Interpret(statements)
+			// Goroutines that have been told to exit may not have yet.
+			for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before+2 && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			if after := runtime.NumGoroutine(); after > before+2 {
+				t.Errorf("%d goroutines left running, want at most 2", after-before)
+			}
+		})
+	}
+}
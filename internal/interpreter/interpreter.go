@@ -6,26 +6,182 @@ import (
 	"fmt"
 	"interpreter/internal/environment"
 	"interpreter/internal/expression"
+	"interpreter/internal/resolver"
 	"interpreter/internal/token"
+	"io"
+	"math"
+	"os"
+	"time"
 )
 
 type Interpreter struct {
+	globals     *environment.Environment
 	environment *environment.Environment
+	stdout      io.Writer
+	hook        Hook
+	branchHook  BranchHook
+	callHook    CallHook
+
+	builtins map[string]interface{}
+
+	loader     ModuleLoader
+	modulePath string
+	modules    map[string]*Module
+	// importing is the chain of modules being run, for finding cycles.
+	importing []string
+
+	limits   Limits
+	deadline time.Time
+	depth    int
+
+	// shared is what the goroutines running a script have in common. Each
+	// goroutine has an Interpreter of its own; spawned is set for all but
+	// the main one.
+	shared  *shared
+	spawned bool
+	// generator is the generator whose body is running, if any, and async
+	// the call of an async function.
+	generator *Generator
+	async     *coroutine
+}
+
+// Hook observes execution one statement at a time. BeforeStatement may block
+// to pause the program; AfterStatement runs once the statement has finished,
+// even when it is left by a runtime error. Goroutines started by spawn take
+// turns calling the hook, so the calls of different goroutines interleave.
+type Hook interface {
+	BeforeStatement(stmt expression.Stmt, env *environment.Environment)
+	AfterStatement(stmt expression.Stmt)
+}
+
+// BranchHook is a Hook that also learns which way each conditional went.
+// Branch receives the *expression.If, *expression.While, *expression.Logical
+// or *expression.Ternary node and whether its deciding condition was truthy,
+// an *expression.MatchCase and whether it matched, an *expression.SelectCase
+// and whether it was chosen, or an *expression.ForIn and whether its iterable
+// had another value.
+type BranchHook interface {
+	Hook
+	Branch(node interface{}, truthy bool)
+}
+
+// CallHook is a Hook that also learns when the body of a script function
+// starts running and when it is left, however that happens. The body of a
+// generator or async function counts as one call, on its own goroutine.
+type CallHook interface {
+	Hook
+	EnterFunction(fn *expression.Function)
+	ExitFunction(fn *expression.Function)
+}
+
+// ThreadHook is a Hook that keeps some state for each goroutine, such as a
+// stack. Thread returns the hook for a goroutine started by the one it is
+// called on, for spawn or for the body of a generator or async function.
+type ThreadHook interface {
+	Hook
+	Thread() Hook
 }
 
-// VisitTernaryExpr implements expression.ExprVisitor.
 func (i *Interpreter) VisitTernaryExpr(expr *expression.Ternary) interface{} {
-	panic("unimplemented")
+	if i.condition(expr, expr.Condition) {
+		return i.evaluate(expr.TrueExpression)
+	}
+	return i.evaluate(expr.FalseExpression)
 }
 
 func NewInterpreter() *Interpreter {
-	return &Interpreter{environment: environment.NewEnvironment(nil)}
+	globals := environment.NewEnvironment(nil)
+	i := &Interpreter{
+		globals:     globals,
+		environment: globals,
+		stdout:      os.Stdout,
+		builtins:    map[string]interface{}{},
+		shared:      newShared(),
+	}
+	for _, native := range natives {
+		i.Define(native.Name, native)
+	}
+	return i
+}
+
+// Define adds a global variable, typically a native function. Modules
+// imported later see it too.
+func (i *Interpreter) Define(name string, value interface{}) {
+	i.globals.Define(name, value)
+	i.builtins[name] = value
+}
+
+// Global returns the value of a global variable.
+func (i *Interpreter) Global(name string) (interface{}, bool) {
+	value, ok := i.globals.Values()[name]
+	return value, ok
+}
+
+// SetOutput redirects the output of print statements.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.stdout = w
 }
 
-func (i *Interpreter) Interpret(statements []expression.Stmt) {
+// SetHook installs a hook that is called around every statement. A nil hook
+// disables it again.
+func (i *Interpreter) SetHook(hook Hook) {
+	i.hook = hook
+	i.branchHook, _ = hook.(BranchHook)
+	i.callHook, _ = hook.(CallHook)
+}
+
+// Interpret runs statements and stops at the first runtime error. It then
+// runs the event loop until no timers or promises are left.
+func (i *Interpreter) Interpret(statements []expression.Stmt) (err error) {
+	defer i.recoverRuntimeError(&err)
+
+	i.resolve(statements)
 	for _, stmt := range statements {
 		i.execute(stmt)
 	}
+	var end token.Token
+	if n := len(statements); n > 0 {
+		end.Line = statements[n-1].Line()
+	}
+	i.drain(end)
+	return nil
+}
+
+// Call calls callee from Go and reports a runtime error instead of
+// unwinding past the caller.
+func (i *Interpreter) Call(callee Callable, arguments []interface{}) (result interface{}, err error) {
+	defer i.recoverRuntimeError(&err)
+
+	if callee.Arity() != len(arguments) {
+		return nil, RuntimeError{Message: fmt.Sprintf("Expected %d arguments but got %d.", callee.Arity(), len(arguments))}
+	}
+	return callee.Call(i, arguments)
+}
+
+// RunTest runs the body of a test block in a scope of its own, as if it
+// were a function without parameters, and then the event loop.
+func (i *Interpreter) RunTest(test *expression.Test) (err error) {
+	i.resolve([]expression.Stmt{test})
+	declaration := expression.NewFunction(test.Name, nil, nil, nil, test.Body, false, test.Line())
+	if _, err := i.Call(&Function{declaration: declaration, closure: i.globals}, nil); err != nil {
+		return err
+	}
+	defer i.recoverRuntimeError(&err)
+	i.drain(token.Token{Line: test.Line()})
+	return nil
+}
+
+func (i *Interpreter) recoverRuntimeError(err *error) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case RuntimeError:
+			*err = e
+		case environment.UndefinedError:
+			*err = i.runtimeError(e.Name, e.Error())
+		default:
+			panic(r)
+		}
+	}
 }
 
 func (i *Interpreter) VisitExpressionStmt(stmt *expression.Expression) interface{} {
@@ -35,7 +191,9 @@ func (i *Interpreter) VisitExpressionStmt(stmt *expression.Expression) interface
 
 func (i *Interpreter) VisitPrintStmt(stmt *expression.Print) interface{} {
 	value := i.evaluate(stmt.Expression)
-	fmt.Println(i.stringify(value))
+	i.shared.output.Lock()
+	defer i.shared.output.Unlock()
+	fmt.Fprintln(i.stdout, i.stringify(value))
 	return nil
 }
 
@@ -48,13 +206,20 @@ func (i *Interpreter) VisitVarStmt(stmt *expression.Var) interface{} {
 	return nil
 }
 
+func (i *Interpreter) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	i.destructure(stmt.Pattern, i.evaluate(stmt.Initializer), func(name token.Token, value interface{}) {
+		i.environment.Define(name.Lexeme, value)
+	})
+	return nil
+}
+
 func (i *Interpreter) VisitBlockStmt(stmt *expression.Block) interface{} {
 	i.executeBlock(stmt.Statements, environment.NewEnvironment(i.environment))
 	return nil
 }
 
 func (i *Interpreter) VisitIfStmt(stmt *expression.If) interface{} {
-	if i.isTruthy(i.evaluate(stmt.Condition)) {
+	if i.condition(stmt, stmt.Condition) {
 		i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
 		i.execute(stmt.ElseBranch)
@@ -62,32 +227,164 @@ func (i *Interpreter) VisitIfStmt(stmt *expression.If) interface{} {
 	return nil
 }
 
+// VisitMatchStmt runs the body of the first case that matches the subject.
+// Each case binds its names in a fresh environment, which its guard sees too.
+func (i *Interpreter) VisitMatchStmt(stmt *expression.Match) interface{} {
+	subject := i.evaluate(stmt.Subject)
+	for _, c := range stmt.Cases {
+		env := environment.NewEnvironment(i.environment)
+		matched := i.matchPatterns(c.Patterns, subject, env)
+		if matched && c.Guard != nil {
+			previous := i.environment
+			i.environment = env
+			matched = i.isTruthy(i.evaluate(c.Guard))
+			i.environment = previous
+		}
+		i.branch(c, matched)
+		if matched {
+			i.executeBlock([]expression.Stmt{c.Body}, env)
+			return nil
+		}
+	}
+	return nil
+}
+
+// matchPatterns reports whether any of patterns matches value, defining
+// the names it binds in env.
+func (i *Interpreter) matchPatterns(patterns []expression.Pattern, value interface{}, env *environment.Environment) bool {
+	for _, pattern := range patterns {
+		switch p := pattern.(type) {
+		case *expression.LiteralPattern:
+			if i.isEqual(p.Value, value) {
+				return true
+			}
+		case *expression.WildcardPattern:
+			return true
+		case *expression.BindingPattern:
+			env.Define(p.Name.Lexeme, value)
+			return true
+		case *expression.DestructurePattern:
+			if fits(p.Target, value) {
+				i.destructure(p.Target, value, func(name token.Token, value interface{}) {
+					if name.Lexeme != "_" {
+						env.Define(name.Lexeme, value)
+					}
+				})
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (i *Interpreter) VisitWhileStmt(stmt *expression.While) interface{} {
-	for i.isTruthy(i.evaluate(stmt.Condition)) {
+	for i.condition(stmt, stmt.Condition) {
 		i.execute(stmt.Body)
 	}
 	return nil
 }
 
+func (i *Interpreter) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	i.environment.Define(stmt.Name.Lexeme, &Function{declaration: stmt, closure: i.environment})
+	return nil
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	return &Function{declaration: expr.Function, closure: i.environment}
+}
+
+func (i *Interpreter) VisitReturnStmt(stmt *expression.Return) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = i.evaluate(stmt.Value)
+	}
+	panic(returnValue{value: value})
+}
+
+// VisitTestStmt does nothing: test blocks only run under the test runner.
+func (i *Interpreter) VisitTestStmt(stmt *expression.Test) interface{} {
+	return nil
+}
+
 func (i *Interpreter) VisitAssignExpr(expr *expression.Assign) interface{} {
 	value := i.evaluate(expr.Value)
-	i.environment.Assign(expr.Name, value)
+	i.scope(expr, expr.Name).Assign(expr.Name, value)
 	return value
 }
 
+// VisitDestructureExpr takes the value apart into existing variables. The
+// whole value is evaluated first, so [a, b] = [b, a] swaps.
+func (i *Interpreter) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	value := i.evaluate(expr.Value)
+	i.destructure(expr.Pattern, value, func(name token.Token, value interface{}) {
+		i.scope(expr, name).Assign(name, value)
+	})
+	return value
+}
+
+// VisitCompoundExpr applies the operator to the target and the value. For
+// ??= the value is only evaluated and stored when the target holds nil.
+func (i *Interpreter) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		return i.update(expr.Target, expr.Operator, func(current interface{}) (interface{}, interface{}) {
+			if current != nil {
+				return current, current
+			}
+			value := i.evaluate(expr.Value)
+			return value, value
+		})
+	}
+	return i.update(expr.Target, expr.Operator, func(current interface{}) (interface{}, interface{}) {
+		value := i.arithmetic(expr.BinaryOperator(), current, i.evaluate(expr.Value))
+		return value, value
+	})
+}
+
+func (i *Interpreter) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	return i.update(expr.Target, expr.Operator, func(current interface{}) (interface{}, interface{}) {
+		old := i.checkNumberOperand(expr.Operator, current)
+		value := old + expr.Delta()
+		if expr.Prefix {
+			return value, value
+		}
+		return value, old
+	})
+}
+
+// update evaluates an assignment target once, stores the value compute
+// derives from its current value and returns compute's result. For an
+// element that means evaluating the list or map and the key only once.
+func (i *Interpreter) update(target expression.Expr, operator token.Token, compute func(current interface{}) (value, result interface{})) interface{} {
+	switch t := target.(type) {
+	case *expression.Variable:
+		value, result := compute(i.scope(t, t.Name).Get(t.Name))
+		i.scope(t, t.Name).Assign(t.Name, value)
+		return result
+	case *expression.Index:
+		object := i.evaluate(t.Object)
+		key := i.evaluate(t.Key)
+		current, err := index(object, key)
+		if err != nil {
+			panic(i.runtimeError(t.Bracket, err.Error()))
+		}
+		value, result := compute(current)
+		setIndex(object, key, value)
+		return result
+	}
+	panic(i.runtimeError(operator, "Invalid assignment target."))
+}
+
 func (i *Interpreter) VisitBinaryExpr(expr *expression.Binary) interface{} {
 	left := i.evaluate(expr.Left)
 	right := i.evaluate(expr.Right)
 
 	switch expr.Operator.Type {
-	case token.PLUS:
-		return i.add(left, right, expr.Operator)
-	case token.MINUS:
-		return i.checkNumberOperands(expr.Operator, left, right, func(a, b float64) float64 { return a - b })
-	case token.STAR:
-		return i.checkNumberOperands(expr.Operator, left, right, func(a, b float64) float64 { return a * b })
-	case token.SLASH:
-		return i.checkNumberOperands(expr.Operator, left, right, func(a, b float64) float64 { return a / b })
+	case token.PLUS, token.MINUS, token.STAR, token.SLASH, token.PERCENT:
+		return i.arithmetic(expr.Operator, left, right)
+	case token.STAR_STAR:
+		return i.checkNumberOperands(expr.Operator, left, right, math.Pow)
+	case token.AMPERSAND, token.PIPE, token.CARET, token.LESS_LESS, token.GREATER_GREATER:
+		return i.bitwise(expr.Operator, left, right)
 	case token.GREATER:
 		return i.checkNumberOperands(expr.Operator, left, right, func(a, b float64) bool { return a > b })
 	case token.GREATER_EQUAL:
@@ -105,6 +402,53 @@ func (i *Interpreter) VisitBinaryExpr(expr *expression.Binary) interface{} {
 	return nil
 }
 
+func (i *Interpreter) VisitCallExpr(expr *expression.Call) interface{} {
+	callee := i.evaluate(expr.Callee)
+	if expr.Optional {
+		i.branch(expr, callee != nil)
+		if callee == nil {
+			return nil
+		}
+	}
+
+	arguments := make([]interface{}, len(expr.Arguments))
+	for index, argument := range expr.Arguments {
+		arguments[index] = i.evaluate(argument)
+	}
+	return i.call(expr.Paren, callee, arguments)
+}
+
+// call calls callee from a script, reporting errors at token.
+func (i *Interpreter) call(token token.Token, callee interface{}, arguments []interface{}) interface{} {
+	function := i.callable(token, callee, len(arguments))
+	if i.depth >= maxCallDepth {
+		panic(i.runtimeError(token, "Stack overflow."))
+	}
+	i.depth++
+	defer func() { i.depth-- }()
+
+	if native, ok := function.(*AsyncNative); ok {
+		return native.start(i, token, arguments)
+	}
+	result, err := function.Call(i, arguments)
+	if err != nil {
+		panic(i.runtimeError(token, err.Error()))
+	}
+	return result
+}
+
+// callable checks that callee can be called with count arguments.
+func (i *Interpreter) callable(token token.Token, callee interface{}, count int) Callable {
+	function, ok := callee.(Callable)
+	if !ok {
+		panic(i.runtimeError(token, "Can only call functions and classes."))
+	}
+	if count != function.Arity() {
+		panic(i.runtimeError(token, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), count)))
+	}
+	return function
+}
+
 func (i *Interpreter) VisitGroupingExpr(expr *expression.Grouping) interface{} {
 	return i.evaluate(expr.Expr)
 }
@@ -113,15 +457,27 @@ func (i *Interpreter) VisitLiteralExpr(expr *expression.Literal) interface{} {
 	return expr.Value
 }
 
+// VisitLogicalExpr evaluates the right operand only when the left one does
+// not decide the result: `and` stops at a falsy left operand, `or` at a
+// truthy one and ?? at any value but nil.
 func (i *Interpreter) VisitLogicalExpr(expr *expression.Logical) interface{} {
 	left := i.evaluate(expr.Left)
+	if expr.Operator.Type == token.QUESTION_QUESTION {
+		i.branch(expr, left != nil)
+		if left != nil {
+			return left
+		}
+		return i.evaluate(expr.Right)
+	}
+	truthy := i.isTruthy(left)
+	i.branch(expr, truthy)
 
 	if expr.Operator.Type == token.OR {
-		if i.isTruthy(left) {
+		if truthy {
 			return left
 		}
 	} else {
-		if !i.isTruthy(left) {
+		if !truthy {
 			return left
 		}
 	}
@@ -137,16 +493,54 @@ func (i *Interpreter) VisitUnaryExpr(expr *expression.Unary) interface{} {
 		return -i.checkNumberOperand(expr.Operator, right)
 	case token.BANG:
 		return !i.isTruthy(right)
+	case token.TILDE:
+		value, ok := integer(right)
+		if !ok {
+			panic(i.runtimeError(expr.Operator, "Operand must be an integer."))
+		}
+		return float64(^value)
 	}
 
 	return nil
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *expression.Variable) interface{} {
-	return i.environment.Get(expr.Name)
+	return i.scope(expr, expr.Name).Get(expr.Name)
+}
+
+// resolve records how far out from each variable use in statements the
+// scope declaring it is, before they run.
+func (i *Interpreter) resolve(statements []expression.Stmt) {
+	for key, distance := range resolver.Resolve(statements).Distances() {
+		i.shared.distances.Store(key, distance)
+	}
+}
+
+// scope returns the scope to look name up from where expr uses it: the one
+// the resolver found declaring it, or the current one for code that was
+// never resolved.
+func (i *Interpreter) scope(expr expression.Expr, name token.Token) *environment.Environment {
+	if distance, ok := i.shared.distances.Load(resolver.Key{Expr: expr, Name: name.Lexeme}); ok {
+		return i.environment.Ancestor(distance.(int))
+	}
+	return i.environment
 }
 
 func (i *Interpreter) execute(stmt expression.Stmt) {
+	if i.limits != (Limits{}) {
+		i.step(stmt)
+	}
+	i.checkFailure()
+	if i.hook != nil {
+		i.shared.hooks.Lock()
+		i.hook.BeforeStatement(stmt, i.environment)
+		i.shared.hooks.Unlock()
+		defer func() {
+			i.shared.hooks.Lock()
+			defer i.shared.hooks.Unlock()
+			i.hook.AfterStatement(stmt)
+		}()
+	}
 	stmt.Accept(i)
 }
 
@@ -164,7 +558,30 @@ func (i *Interpreter) executeBlock(statements []expression.Stmt, env *environmen
 	}
 }
 
+// condition evaluates the condition deciding node and reports the outcome to
+// the branch hook.
+func (i *Interpreter) condition(node interface{}, condition expression.Expr) bool {
+	truthy := i.isTruthy(i.evaluate(condition))
+	i.branch(node, truthy)
+	return truthy
+}
+
+// branch tells the branch hook, if there is one, which way node went.
+func (i *Interpreter) branch(node interface{}, truthy bool) {
+	if i.branchHook != nil {
+		i.shared.hooks.Lock()
+		defer i.shared.hooks.Unlock()
+		i.branchHook.Branch(node, truthy)
+	}
+}
+
 func (i *Interpreter) isTruthy(object interface{}) bool {
+	return Truthy(object)
+}
+
+// Truthy reports whether a value counts as true in a condition: everything
+// but nil and false does.
+func Truthy(object interface{}) bool {
 	if object == nil {
 		return false
 	}
@@ -175,6 +592,11 @@ func (i *Interpreter) isTruthy(object interface{}) bool {
 }
 
 func (i *Interpreter) isEqual(a, b interface{}) bool {
+	return Equal(a, b)
+}
+
+// Equal reports whether two values are equal as the == operator sees them.
+func Equal(a, b interface{}) bool {
 	if a == nil && b == nil {
 		return true
 	}
@@ -206,6 +628,57 @@ func (i *Interpreter) checkNumberOperands(operator token.Token, left, right inte
 	return nil
 }
 
+// arithmetic applies one of the operators shared by binary expressions and
+// compound assignments.
+func (i *Interpreter) arithmetic(operator token.Token, left, right interface{}) interface{} {
+	switch operator.Type {
+	case token.PLUS:
+		return i.add(left, right, operator)
+	case token.MINUS:
+		return i.checkNumberOperands(operator, left, right, func(a, b float64) float64 { return a - b })
+	case token.STAR:
+		return i.checkNumberOperands(operator, left, right, func(a, b float64) float64 { return a * b })
+	case token.SLASH:
+		return i.checkNumberOperands(operator, left, right, func(a, b float64) float64 { return a / b })
+	case token.PERCENT:
+		return i.checkNumberOperands(operator, left, right, math.Mod)
+	}
+	panic(fmt.Sprintf("unknown arithmetic operator %s", operator.Lexeme))
+}
+
+// bitwise applies a bitwise operator to two integral numbers.
+func (i *Interpreter) bitwise(operator token.Token, left, right interface{}) interface{} {
+	a, leftOk := integer(left)
+	b, rightOk := integer(right)
+	if !leftOk || !rightOk {
+		panic(i.runtimeError(operator, "Operands must be integers."))
+	}
+	switch operator.Type {
+	case token.AMPERSAND:
+		return float64(a & b)
+	case token.PIPE:
+		return float64(a | b)
+	case token.CARET:
+		return float64(a ^ b)
+	}
+	if b < 0 {
+		panic(i.runtimeError(operator, "Shift count must not be negative."))
+	}
+	if operator.Type == token.LESS_LESS {
+		return float64(a << b)
+	}
+	return float64(a >> b)
+}
+
+// integer converts a number with no fractional part that fits in 64 bits.
+func integer(value interface{}) (int64, bool) {
+	number, ok := value.(float64)
+	if !ok || number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
+
 func (i *Interpreter) add(left, right interface{}, operator token.Token) interface{} {
 	if leftNum, leftOk := left.(float64); leftOk {
 		if rightNum, rightOk := right.(float64); rightOk {
@@ -214,6 +687,9 @@ func (i *Interpreter) add(left, right interface{}, operator token.Token) interfa
 	}
 	if leftStr, leftOk := left.(string); leftOk {
 		if rightStr, rightOk := right.(string); rightOk {
+			if i.limits.StringLength > 0 && len(leftStr)+len(rightStr) > i.limits.StringLength {
+				panic(i.runtimeError(operator, "String length limit exceeded."))
+			}
 			return leftStr + rightStr
 		}
 	}
@@ -221,6 +697,11 @@ func (i *Interpreter) add(left, right interface{}, operator token.Token) interfa
 }
 
 func (i *Interpreter) stringify(object interface{}) string {
+	return Stringify(object)
+}
+
+// Stringify formats a value the way print displays it.
+func Stringify(object interface{}) string {
 	if object == nil {
 		return "nil"
 	}
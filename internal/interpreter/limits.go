@@ -0,0 +1,57 @@
+package interpreter
+
+import (
+	"time"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// maxCallDepth stops runaway recursion with a runtime error well before the
+// Go stack is exhausted, which would crash the process.
+const maxCallDepth = 10000
+
+// Limits bound the resources a script may use, for running code that cannot
+// be trusted to finish. A zero field means no limit.
+type Limits struct {
+	// Steps is the number of statements that may be executed.
+	Steps int64
+	// Duration is the wall-clock time the script may run for.
+	Duration time.Duration
+	// StringLength is the length in bytes of the longest string that
+	// concatenation may build.
+	StringLength int
+}
+
+// SetLimits applies limits to the following calls to Interpret. The time
+// limit starts counting when SetLimits is called.
+func (i *Interpreter) SetLimits(limits Limits) {
+	i.limits = limits
+	i.shared.steps.Store(0)
+	if limits.Duration > 0 {
+		i.deadline = time.Now().Add(limits.Duration)
+	}
+}
+
+// step counts a statement against the limits. Reading the clock is
+// comparatively slow, so the deadline is only checked every so often.
+func (i *Interpreter) step(stmt expression.Stmt) {
+	steps := i.shared.steps.Add(1)
+	if i.limits.Steps > 0 && steps > i.limits.Steps {
+		panic(i.runtimeError(token.Token{Line: stmt.Line()}, "Step limit exceeded."))
+	}
+	if i.limits.Duration > 0 && steps%256 == 0 && time.Now().After(i.deadline) {
+		panic(i.runtimeError(token.Token{Line: stmt.Line()}, "Time limit exceeded."))
+	}
+}
+
+// timeLimit returns a channel that receives once the time limit runs out,
+// or nil without one, for waits to select on, and a function that releases
+// its timer afterwards.
+func (i *Interpreter) timeLimit() (<-chan time.Time, func() bool) {
+	if i.limits.Duration <= 0 {
+		return nil, func() bool { return false }
+	}
+	timer := time.NewTimer(time.Until(i.deadline))
+	return timer.C, timer.Stop
+}
@@ -0,0 +1,54 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		limits Limits
+		want   string
+	}{
+		{"Steps", "while (true) {}", Limits{Steps: 100}, "Step limit exceeded."},
+		{"Duration", "while (true) {}", Limits{Duration: 10 * time.Millisecond}, "Time limit exceeded."},
+		{"Sleep", "sleep(5000);", Limits{Duration: 10 * time.Millisecond}, "Time limit exceeded.\n[line 1]"},
+		{"Awaited sleep", "print 1;\nawait sleep(5000);", Limits{Duration: 10 * time.Millisecond}, "Time limit exceeded.\n[line 2]"},
+		{"Timer", "setTimeout(fun () {}, 5000);", Limits{Duration: 10 * time.Millisecond}, "Time limit exceeded."},
+		{"String length", "var s = \"ab\";\nwhile (true) s = s + s;", Limits{StringLength: 64}, "String length limit exceeded."},
+		{"Within limits", "var a = 1;\nprint a;", Limits{Steps: 2}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := parser.NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i := NewInterpreter()
+			i.SetOutput(io.Discard)
+			i.SetLimits(tt.limits)
+			err = i.Interpret(statements)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Interpret() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("Interpret() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// ModuleLoader finds and parses modules for import statements. Resolve
+// turns the path written in an import into the path of a file, given the
+// path of the importing file; Load parses that file. Display turns such a
+// path into the one a user would recognise, for messages.
+type ModuleLoader interface {
+	Resolve(importer, name string) (string, error)
+	Load(path string) ([]expression.Stmt, error)
+	Display(path string) string
+}
+
+// Module is the value an import binds. Its exports are the variables and
+// functions declared at its top level, read when they are accessed.
+type Module struct {
+	Name    string
+	Path    string
+	env     *environment.Environment
+	exports map[string]bool
+
+	// loaded is false while the module's top level runs, and waiters are
+	// the goroutines importing it meanwhile. Both are guarded by shared.mu.
+	loaded  bool
+	waiters []*waiter
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// Export returns the current value of an exported name.
+func (m *Module) Export(name string) (interface{}, bool) {
+	if !m.exports[name] {
+		return nil, false
+	}
+	value, ok := m.env.Values()[name]
+	return value, ok
+}
+
+// SetLoader enables import statements. path is the file being run, against
+// which its own imports are resolved.
+func (i *Interpreter) SetLoader(loader ModuleLoader, path string) {
+	i.loader = loader
+	i.modulePath = path
+	i.modules = map[string]*Module{}
+	i.importing = []string{path}
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *expression.Import) interface{} {
+	i.environment.Define(stmt.Name.Lexeme, i.importModule(stmt.Path))
+	return nil
+}
+
+func (i *Interpreter) VisitGetExpr(expr *expression.Get) interface{} {
+	object := i.evaluate(expr.Object)
+	if expr.Optional {
+		i.branch(expr, object != nil)
+		if object == nil {
+			return nil
+		}
+	}
+	if m, ok := object.(*Map); ok {
+		value, _ := m.Get(expr.Name.Lexeme)
+		return value
+	}
+	module, ok := object.(*Module)
+	if !ok {
+		panic(i.runtimeError(expr.Name, "Only modules and maps have properties."))
+	}
+	value, ok := module.Export(expr.Name.Lexeme)
+	if !ok {
+		panic(i.runtimeError(expr.Name, fmt.Sprintf("Module '%s' has no export '%s'.", module.Name, expr.Name.Lexeme)))
+	}
+	return value
+}
+
+// importModule runs a module the first time it is imported and returns the
+// cached result afterwards.
+func (i *Interpreter) importModule(pathToken token.Token) *Module {
+	name := pathToken.Literal.(string)
+	if i.loader == nil {
+		panic(i.runtimeError(pathToken, "Imports are not available."))
+	}
+	path, err := i.loader.Resolve(i.modulePath, name)
+	if err != nil {
+		panic(i.runtimeError(pathToken, err.Error()))
+	}
+
+	for index, importing := range i.importing {
+		if importing == path {
+			var cycle []string
+			for _, p := range append(i.importing[index:], path) {
+				cycle = append(cycle, i.loader.Display(p))
+			}
+			panic(i.runtimeError(pathToken, "Import cycle: "+strings.Join(cycle, " -> ")))
+		}
+	}
+	module, ok := i.claimModule(pathToken, name, path)
+	if ok {
+		return module
+	}
+	defer i.finishModule(module)
+
+	statements, err := i.loader.Load(path)
+	if err != nil {
+		panic(i.runtimeError(pathToken, fmt.Sprintf("In module '%s': %v", name, err)))
+	}
+
+	for builtin, value := range i.builtins {
+		module.env.Define(builtin, value)
+	}
+	for _, stmt := range statements {
+		switch s := stmt.(type) {
+		case *expression.Var:
+			module.exports[s.Name.Lexeme] = true
+		case *expression.VarDestructure:
+			for _, name := range s.Pattern.Names() {
+				module.exports[name.Lexeme] = true
+			}
+		case *expression.Function:
+			module.exports[s.Name.Lexeme] = true
+		}
+	}
+
+	globals, modulePath := i.globals, i.modulePath
+	i.globals, i.modulePath = module.env, path
+	i.importing = append(i.importing, path)
+	i.resolve(statements)
+	defer func() {
+		i.globals, i.modulePath = globals, modulePath
+		i.importing = i.importing[:len(i.importing)-1]
+	}()
+	i.executeBlock(statements, module.env)
+
+	i.shared.mu.Lock()
+	module.loaded = true
+	i.shared.mu.Unlock()
+	return module
+}
+
+// claimModule returns the cached module for path and true, waiting for it if
+// another goroutine is still running it. Otherwise it caches a new module,
+// still loading, for the caller to run and returns false.
+func (i *Interpreter) claimModule(pathToken token.Token, name, path string) (*Module, bool) {
+	s := i.shared
+	s.mu.Lock()
+	for {
+		module, ok := i.modules[path]
+		if !ok {
+			break
+		}
+		if module.loaded {
+			s.mu.Unlock()
+			return module, true
+		}
+		w := newWaiter()
+		module.waiters = append(module.waiters, w)
+		s.block(w)
+		s.mu.Unlock()
+		if err := i.woken(w); err != nil {
+			panic(i.runtimeError(pathToken, err.Error()))
+		}
+		s.mu.Lock()
+	}
+	module := &Module{
+		Name:    name,
+		Path:    path,
+		env:     environment.NewEnvironment(nil),
+		exports: map[string]bool{},
+	}
+	i.modules[path] = module
+	s.mu.Unlock()
+	return module, false
+}
+
+// finishModule wakes the goroutines waiting for module. If it failed to load
+// it leaves the cache, and they try again themselves.
+func (i *Interpreter) finishModule(module *Module) {
+	s := i.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !module.loaded {
+		delete(i.modules, module.Path)
+	}
+	for _, w := range module.waiters {
+		s.wake(w, completed, 0, nil, true)
+	}
+	module.waiters = nil
+}
@@ -0,0 +1,171 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Standard JSON-RPC error codes.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// Message is a request, response or notification. Requests carry an ID and
+// a Method, notifications only a Method, and responses an ID with either a
+// Result or an Error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+func (m *Message) IsRequest() bool {
+	return m.Method != "" && m.ID != nil
+}
+
+func (m *Message) IsNotification() bool {
+	return m.Method != "" && m.ID == nil
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// ReadFrame reads one message body framed with a Content-Length header, the
+// base protocol shared by the Language Server and Debug Adapter protocols.
+func ReadFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteFrame writes body preceded by its Content-Length header.
+func WriteFrame(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// Conn exchanges framed JSON-RPC messages over a byte stream. Writes are
+// serialized so notifications can be sent from any goroutine.
+type Conn struct {
+	reader *bufio.Reader
+	writer io.Writer
+	mu     sync.Mutex
+	nextID int
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{reader: bufio.NewReader(r), writer: w}
+}
+
+func (c *Conn) Read() (*Message, error) {
+	body, err := ReadFrame(c.reader)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &Error{Code: ParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+func (c *Conn) Write(msg *Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return WriteFrame(c.writer, body)
+}
+
+// Call sends a request and returns the ID it was given. The caller is
+// responsible for reading the matching response.
+func (c *Conn) Call(method string, params interface{}) (json.RawMessage, error) {
+	raw, err := marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	c.mu.Unlock()
+	return id, c.Write(&Message{ID: id, Method: method, Params: raw})
+}
+
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.Write(&Message{Method: method, Params: raw})
+}
+
+// Reply answers the request identified by id. A nil result is sent as an
+// explicit null, as the protocol requires a result on success.
+func (c *Conn) Reply(id json.RawMessage, result interface{}, rpcErr *Error) error {
+	if rpcErr != nil {
+		return c.Write(&Message{ID: id, Error: rpcErr})
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.Write(&Message{ID: id, Result: raw})
+}
+
+func marshal(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,66 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Severity string
+
+const (
+	Off     Severity = "off"
+	Info    Severity = "info"
+	Warning Severity = "warning"
+	Error   Severity = "error"
+)
+
+// Config selects which rules run and at what severity. Rules that are not
+// mentioned keep their default severity.
+//
+//	{
+//	  "rules": {
+//	    "shadowing": "off",
+//	    "empty-block": "error"
+//	  }
+//	}
+type Config struct {
+	Rules map[string]Severity `json:"rules"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{Rules: map[string]Severity{}}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+func ParseConfig(data []byte) (*Config, error) {
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid lint config: %v", err)
+	}
+	for id, severity := range config.Rules {
+		if lookupRule(id) == nil {
+			return nil, fmt.Errorf("invalid lint config: unknown rule '%s'", id)
+		}
+		switch severity {
+		case Off, Info, Warning, Error:
+		default:
+			return nil, fmt.Errorf("invalid lint config: unknown severity '%s' for rule '%s'", severity, id)
+		}
+	}
+	return config, nil
+}
+
+func (c *Config) severity(r *rule) Severity {
+	if severity, ok := c.Rules[r.ID]; ok {
+		return severity
+	}
+	return r.Severity
+}
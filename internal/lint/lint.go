@@ -0,0 +1,549 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/resolver"
+	"interpreter/internal/token"
+)
+
+type Diagnostic struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d: %s: %s [%s]", d.Line, d.Severity, d.Message, d.Rule)
+}
+
+type Linter struct {
+	config      *Config
+	diagnostics []Diagnostic
+}
+
+// Lint runs every enabled rule over statements and returns the findings
+// ordered by line.
+func Lint(statements []expression.Stmt, config *Config) []Diagnostic {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	l := &Linter{config: config}
+
+	l.checkStatements(statements)
+	l.checkBindings(resolver.Resolve(statements))
+
+	sort.SliceStable(l.diagnostics, func(a, b int) bool {
+		return l.diagnostics[a].Line < l.diagnostics[b].Line
+	})
+	return l.diagnostics
+}
+
+func (l *Linter) report(id string, line int, format string, args ...interface{}) {
+	severity := l.config.severity(lookupRule(id))
+	if severity == Off {
+		return
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Rule:     id,
+		Severity: severity,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *Linter) checkBindings(resolution *resolver.Resolution) {
+	for _, binding := range resolution.Bindings {
+		name := binding.Name
+		if binding.Shadows != nil {
+			l.report(Shadowing, name.Line, "'%s' shadows the variable declared on line %d", name.Lexeme, binding.Shadows.Name.Line)
+		}
+
+		// Functions may be called from outside the file and parameters are
+		// part of a function's signature, so only variables must be used.
+		if binding.Kind != resolver.Variable {
+			continue
+		}
+		reads := binding.Reads()
+		if len(reads) == 0 {
+			l.report(UnusedVariable, name.Line, "'%s' is declared but never used", name.Lexeme)
+			continue
+		}
+		for _, write := range binding.Writes() {
+			if !isRead(binding, write, reads) {
+				l.report(UnusedAssignment, write.Name.Line, "value assigned to '%s' is never read", name.Lexeme)
+			}
+		}
+	}
+}
+
+// isRead reports whether some read can observe the value stored by write,
+// either because it comes later, because a loop brings it around again or
+// because it happens in another function that may run at any time.
+func isRead(binding *resolver.Binding, write *resolver.Reference, reads []*resolver.Reference) bool {
+	for _, read := range reads {
+		if read.Function != write.Function {
+			return true
+		}
+	}
+	if overwritten(binding, write, reads) {
+		return false
+	}
+	for _, read := range reads {
+		if read.Seq > write.Seq {
+			return true
+		}
+		for _, loop := range sharedLoops(write.Loops, read.Loops) {
+			if !contains(binding.Loops, loop) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overwritten reports whether another write always replaces the value stored
+// by write before anything can read it.
+func overwritten(binding *resolver.Binding, write *resolver.Reference, reads []*resolver.Reference) bool {
+	for _, next := range binding.Writes() {
+		if next.Region != write.Region || next.Seq <= write.Seq {
+			continue
+		}
+		for _, read := range reads {
+			if read.Seq > write.Seq && read.Seq < next.Seq {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func sharedLoops(a, b []expression.Stmt) []expression.Stmt {
+	var shared []expression.Stmt
+	for _, loop := range a {
+		if contains(b, loop) {
+			shared = append(shared, loop)
+		}
+	}
+	return shared
+}
+
+func contains(loops []expression.Stmt, loop expression.Stmt) bool {
+	for _, l := range loops {
+		if l == loop {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Linter) checkStatements(statements []expression.Stmt) {
+	unreachable := false
+	for _, stmt := range statements {
+		if unreachable {
+			l.report(UnreachableCode, stmt.Line(), "unreachable code")
+			break
+		}
+		l.checkStmt(stmt)
+		unreachable = terminates(stmt)
+	}
+}
+
+func (l *Linter) checkStmt(stmt expression.Stmt) {
+	stmt.Accept(l)
+}
+
+func (l *Linter) checkExpr(expr expression.Expr) {
+	expr.Accept(l)
+}
+
+func (l *Linter) checkCondition(condition expression.Expr, line int) {
+	if hasAssignment(condition) {
+		l.report(AssignmentInCondition, line, "assignment used as a condition; wrap it in parentheses if this is intended")
+	}
+}
+
+func (l *Linter) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	l.checkExpr(stmt.Expr)
+	return nil
+}
+
+func (l *Linter) VisitPrintStmt(stmt *expression.Print) interface{} {
+	l.checkExpr(stmt.Expression)
+	return nil
+}
+
+func (l *Linter) VisitVarStmt(stmt *expression.Var) interface{} {
+	if stmt.Initializer != nil {
+		l.checkExpr(stmt.Initializer)
+	}
+	return nil
+}
+
+func (l *Linter) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	l.checkExpr(stmt.Initializer)
+	return nil
+}
+
+func (l *Linter) VisitWhileStmt(stmt *expression.While) interface{} {
+	l.checkCondition(stmt.Condition, stmt.Line())
+	l.checkExpr(stmt.Condition)
+	l.checkStmt(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	l.checkExpr(stmt.Iterable)
+	l.checkStmt(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitBlockStmt(stmt *expression.Block) interface{} {
+	if len(stmt.Statements) == 0 {
+		l.report(EmptyBlock, stmt.Line(), "empty block")
+	}
+	l.checkStatements(stmt.Statements)
+	return nil
+}
+
+func (l *Linter) VisitIfStmt(stmt *expression.If) interface{} {
+	if isConstant(stmt.Condition) {
+		l.report(ConstantCondition, stmt.Line(), "if condition is constant")
+	}
+	l.checkCondition(stmt.Condition, stmt.Line())
+	l.checkExpr(stmt.Condition)
+	l.checkStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		l.checkStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (l *Linter) VisitMatchStmt(stmt *expression.Match) interface{} {
+	l.checkExpr(stmt.Subject)
+	for _, c := range stmt.Cases {
+		if c.Guard != nil {
+			l.checkCondition(c.Guard, c.Keyword.Line)
+			l.checkExpr(c.Guard)
+		}
+		l.checkStmt(c.Body)
+	}
+	return nil
+}
+
+func (l *Linter) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	l.checkExpr(stmt.Call)
+	return nil
+}
+
+func (l *Linter) VisitSelectStmt(stmt *expression.Select) interface{} {
+	for _, c := range stmt.Cases {
+		if c.Channel != nil {
+			l.checkExpr(c.Channel)
+		}
+		if c.Value != nil {
+			l.checkExpr(c.Value)
+		}
+		l.checkStmt(c.Body)
+	}
+	return nil
+}
+
+func (l *Linter) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	l.checkStatements(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	l.checkStatements(expr.Function.Body)
+	return nil
+}
+
+func (l *Linter) VisitReturnStmt(stmt *expression.Return) interface{} {
+	if stmt.Value != nil {
+		l.checkExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (l *Linter) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value != nil {
+		l.checkExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (l *Linter) VisitTestStmt(stmt *expression.Test) interface{} {
+	l.checkStatements(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitImportStmt(stmt *expression.Import) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitAssignExpr(expr *expression.Assign) interface{} {
+	l.checkExpr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	l.checkExpr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	l.checkExpr(expr.Target)
+	l.checkExpr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	l.checkExpr(expr.Target)
+	return nil
+}
+
+func (l *Linter) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	switch expr.Operator.Type {
+	case token.EQUAL_EQUAL, token.BANG_EQUAL, token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		if sameExpr(expr.Left, expr.Right) {
+			l.report(SelfComparison, expr.Operator.Line, "both sides of '%s' are the same", expr.Operator.Lexeme)
+		}
+	}
+	l.checkExpr(expr.Left)
+	l.checkExpr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitCallExpr(expr *expression.Call) interface{} {
+	l.checkExpr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		l.checkExpr(argument)
+	}
+	return nil
+}
+
+func (l *Linter) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	if line, ok := firstLine(expr.Condition); ok {
+		l.checkCondition(expr.Condition, line)
+	}
+	l.checkExpr(expr.Condition)
+	l.checkExpr(expr.TrueExpression)
+	l.checkExpr(expr.FalseExpression)
+	return nil
+}
+
+func (l *Linter) VisitListExpr(expr *expression.List) interface{} {
+	for _, element := range expr.Elements {
+		l.checkExpr(element)
+	}
+	return nil
+}
+
+func (l *Linter) VisitMapExpr(expr *expression.Map) interface{} {
+	for index, key := range expr.Keys {
+		l.checkExpr(key)
+		l.checkExpr(expr.Values[index])
+	}
+	return nil
+}
+
+func (l *Linter) VisitGetExpr(expr *expression.Get) interface{} {
+	l.checkExpr(expr.Object)
+	return nil
+}
+
+func (l *Linter) VisitIndexExpr(expr *expression.Index) interface{} {
+	l.checkExpr(expr.Object)
+	l.checkExpr(expr.Key)
+	return nil
+}
+
+func (l *Linter) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	l.checkExpr(expr.Object)
+	l.checkExpr(expr.Key)
+	l.checkExpr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	l.checkExpr(expr.Expr)
+	return nil
+}
+
+func (l *Linter) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	return nil
+}
+
+func (l *Linter) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	l.checkExpr(expr.Left)
+	l.checkExpr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	l.checkExpr(expr.Right)
+	return nil
+}
+
+func (l *Linter) VisitAwaitExpr(expr *expression.Await) interface{} {
+	l.checkExpr(expr.Value)
+	return nil
+}
+
+func (l *Linter) VisitVariableExpr(expr *expression.Variable) interface{} {
+	return nil
+}
+
+// terminates reports whether control can never continue past stmt.
+func terminates(stmt expression.Stmt) bool {
+	switch s := stmt.(type) {
+	case *expression.Return:
+		return true
+	case *expression.While:
+		// There is no way to leave a loop whose condition is always true.
+		value, ok := literalValue(s.Condition)
+		return ok && value != nil && value != false
+	case *expression.Block:
+		for _, inner := range s.Statements {
+			if terminates(inner) {
+				return true
+			}
+		}
+	case *expression.If:
+		return s.ElseBranch != nil && terminates(s.ThenBranch) && terminates(s.ElseBranch)
+	case *expression.Match:
+		// Cases after an irrefutable one never run.
+		for _, c := range s.Cases {
+			if !terminates(c.Body) {
+				return false
+			}
+			if c.Irrefutable() {
+				return true
+			}
+		}
+	case *expression.Select:
+		// Exactly one case runs.
+		for _, c := range s.Cases {
+			if !terminates(c.Body) {
+				return false
+			}
+		}
+		return len(s.Cases) > 0
+	}
+	return false
+}
+
+func literalValue(expr expression.Expr) (interface{}, bool) {
+	switch e := expr.(type) {
+	case *expression.Literal:
+		return e.Value, true
+	case *expression.Grouping:
+		return literalValue(e.Expr)
+	}
+	return nil, false
+}
+
+// isConstant reports whether expr is built only from literals.
+func isConstant(expr expression.Expr) bool {
+	switch e := expr.(type) {
+	case *expression.Literal:
+		return true
+	case *expression.Grouping:
+		return isConstant(e.Expr)
+	case *expression.Unary:
+		return isConstant(e.Right)
+	case *expression.Binary:
+		return isConstant(e.Left) && isConstant(e.Right)
+	case *expression.Logical:
+		return isConstant(e.Left) && isConstant(e.Right)
+	case *expression.Ternary:
+		return isConstant(e.Condition) && isConstant(e.TrueExpression) && isConstant(e.FalseExpression)
+	}
+	return false
+}
+
+// sameExpr reports whether a and b are the same side-effect free expression.
+func sameExpr(a, b expression.Expr) bool {
+	switch x := a.(type) {
+	case *expression.Variable:
+		y, ok := b.(*expression.Variable)
+		return ok && x.Name.Lexeme == y.Name.Lexeme
+	case *expression.Literal:
+		y, ok := b.(*expression.Literal)
+		return ok && x.Value == y.Value
+	case *expression.Grouping:
+		y, ok := b.(*expression.Grouping)
+		return ok && sameExpr(x.Expr, y.Expr)
+	case *expression.Unary:
+		y, ok := b.(*expression.Unary)
+		return ok && x.Operator.Type == y.Operator.Type && sameExpr(x.Right, y.Right)
+	case *expression.Binary:
+		y, ok := b.(*expression.Binary)
+		return ok && x.Operator.Type == y.Operator.Type && sameExpr(x.Left, y.Left) && sameExpr(x.Right, y.Right)
+	}
+	return false
+}
+
+// hasAssignment reports whether a condition assigns without the extra
+// parentheses that mark the assignment as deliberate.
+func hasAssignment(expr expression.Expr) bool {
+	switch e := expr.(type) {
+	case *expression.Assign, *expression.SetIndex, *expression.Compound:
+		return true
+	case *expression.Unary:
+		return hasAssignment(e.Right)
+	case *expression.Logical:
+		return hasAssignment(e.Left) || hasAssignment(e.Right)
+	}
+	return false
+}
+
+func firstLine(expr expression.Expr) (int, bool) {
+	switch e := expr.(type) {
+	case *expression.Assign:
+		return e.Name.Line, true
+	case *expression.Variable:
+		return e.Name.Line, true
+	case *expression.Destructure:
+		return e.Pattern.Line(), true
+	case *expression.Binary:
+		return e.Operator.Line, true
+	case *expression.Logical:
+		return e.Operator.Line, true
+	case *expression.Unary:
+		return e.Operator.Line, true
+	case *expression.Await:
+		return e.Keyword.Line, true
+	case *expression.Grouping:
+		return firstLine(e.Expr)
+	case *expression.Ternary:
+		return firstLine(e.Condition)
+	case *expression.Call:
+		return firstLine(e.Callee)
+	case *expression.Get:
+		return firstLine(e.Object)
+	case *expression.Index:
+		return firstLine(e.Object)
+	case *expression.SetIndex:
+		return firstLine(e.Object)
+	case *expression.Lambda:
+		return e.Function.Line(), true
+	case *expression.List:
+		return e.Bracket.Line, true
+	case *expression.Map:
+		return e.Brace.Line, true
+	case *expression.Compound:
+		return firstLine(e.Target)
+	case *expression.Increment:
+		if e.Prefix {
+			return e.Operator.Line, true
+		}
+		return firstLine(e.Target)
+	}
+	return 0, false
+}
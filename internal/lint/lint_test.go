@@ -0,0 +1,78 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func lintSource(t *testing.T, source string, config *Config) []Diagnostic {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return Lint(statements, config)
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"Clean", "var a = 1;\nprint a;", nil},
+		{"Unused variable", "var a = 1;", []string{UnusedVariable}},
+		{"Overwritten assignment", "var a = 1;\na = 2;\na = 3;\nprint a;", []string{UnusedAssignment}},
+		{"Assignment read in loop", "var a = 0;\nwhile (a < 3) a = a + 1;", nil},
+		{"Assignment after last read", "var a = 0;\nprint a;\na = 1;", []string{UnusedAssignment}},
+		{"Shadowing", "var a = 1;\n{ var a = 2; print a; }\nprint a;", []string{Shadowing}},
+		{"Unreachable code", "while (true) print 1;\nprint 2;", []string{UnreachableCode}},
+		{"Constant condition", "if (1 < 2) print 1;", []string{ConstantCondition}},
+		{"Self comparison", "var a = 1;\nprint a == a;", []string{SelfComparison}},
+		{"Empty block", "{}", []string{EmptyBlock}},
+		{"Assignment in condition", "var a;\nif (a = true) print a;", []string{AssignmentInCondition}},
+		{"Parenthesized assignment in condition", "var a;\nif ((a = true)) print a;", nil},
+		{"Assignment read by a function", "var a = 1;\nfun f() { print a; }\na = 2;\nf();", nil},
+		{"Unused parameter and function", "fun f(a) { return 1; }", nil},
+		{"Code after return", "fun f() {\n  return 1;\n  print 2;\n}", []string{UnreachableCode}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range lintSource(t, tt.source, nil) {
+				got = append(got, d.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"rules": {"unused-variable": "off", "empty-block": "error"}}`))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	got := lintSource(t, "var a = 1;\n{}", config)
+	want := []Diagnostic{{Rule: EmptyBlock, Severity: Error, Line: 2, Message: "empty block"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseConfig([]byte(`{"rules": {"no-such-rule": "off"}}`)); err == nil {
+		t.Error("ParseConfig() accepted an unknown rule")
+	}
+	if _, err := ParseConfig([]byte(`{"rules": {"empty-block": "fatal"}}`)); err == nil {
+		t.Error("ParseConfig() accepted an unknown severity")
+	}
+}
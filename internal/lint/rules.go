@@ -0,0 +1,54 @@
+package lint
+
+type rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+}
+
+const (
+	UnusedVariable        = "unused-variable"
+	UnusedAssignment      = "unused-assignment"
+	Shadowing             = "shadowing"
+	UnreachableCode       = "unreachable-code"
+	ConstantCondition     = "constant-condition"
+	SelfComparison        = "self-comparison"
+	EmptyBlock            = "empty-block"
+	AssignmentInCondition = "assignment-in-condition"
+)
+
+var rules = []*rule{
+	{UnusedVariable, Warning, "variable is declared but its value is never read"},
+	{UnusedAssignment, Warning, "assigned value is never read"},
+	{Shadowing, Warning, "variable shadows a variable from an outer scope"},
+	{UnreachableCode, Warning, "statement can never be executed"},
+	{ConstantCondition, Warning, "if condition always has the same value"},
+	{SelfComparison, Warning, "expression is compared with itself"},
+	{EmptyBlock, Info, "block has no statements"},
+	{AssignmentInCondition, Warning, "condition contains an assignment"},
+}
+
+func lookupRule(id string) *rule {
+	for _, r := range rules {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// RuleInfo describes a rule and its default severity.
+type RuleInfo struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+// Rules lists every rule the linter knows about.
+func Rules() []RuleInfo {
+	infos := make([]RuleInfo, len(rules))
+	for i, r := range rules {
+		infos[i] = RuleInfo{ID: r.ID, Severity: r.Severity, Description: r.Description}
+	}
+	return infos
+}
@@ -0,0 +1,223 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/parser"
+	"interpreter/internal/resolver"
+	"interpreter/internal/scanner"
+	"interpreter/internal/token"
+)
+
+// document is an open file together with everything derived from its text.
+type document struct {
+	uri         string
+	lines       []string
+	utf16       bool
+	tokens      []token.Token
+	statements  []expression.Stmt
+	resolution  *resolver.Resolution
+	diagnostics []Diagnostic
+}
+
+func analyze(uri, text string, utf16 bool) *document {
+	doc := &document{
+		uri:         uri,
+		lines:       strings.Split(text, "\n"),
+		utf16:       utf16,
+		diagnostics: []Diagnostic{},
+	}
+
+	tokens, err := scanner.NewScanner(text).ScanTokens()
+	if err != nil {
+		var scanErr scanner.ScanError
+		if errors.As(err, &scanErr) {
+			line, offset := scanErr.Line-1, scanErr.Column-1
+			start := Position{Line: line, Character: doc.character(line, offset)}
+			end := Position{Line: line, Character: doc.character(line, offset+doc.runeLength(line, offset))}
+			doc.addDiagnostic(Range{Start: start, End: end}, SeverityError, scanErr.Message)
+		}
+		doc.resolution = resolver.Resolve(nil)
+		return doc
+	}
+	doc.tokens = tokens
+
+	p := parser.NewParser(tokens)
+	doc.statements, _ = p.Parse()
+	for _, err := range p.Errors() {
+		var parseErr parser.ParseError
+		if errors.As(err, &parseErr) {
+			doc.addDiagnostic(doc.tokenRange(parseErr.Token), SeverityError, parseErr.Message)
+		}
+	}
+	for _, err := range p.Warnings() {
+		var parseErr parser.ParseError
+		if errors.As(err, &parseErr) {
+			doc.addDiagnostic(doc.tokenRange(parseErr.Token), SeverityWarning, parseErr.Message)
+		}
+	}
+	doc.resolution = resolver.Resolve(doc.statements)
+	return doc
+}
+
+func (d *document) addDiagnostic(r Range, severity DiagnosticSeverity, message string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    r,
+		Severity: severity,
+		Source:   "lox",
+		Message:  message,
+	})
+}
+
+// tokenRange converts a token's 1-based line and column into an LSP range.
+// Strings may span lines, in which case Line is where the lexeme ends.
+func (d *document) tokenRange(t token.Token) Range {
+	newlines := strings.Count(t.Lexeme, "\n")
+	startLine, startOffset := t.Line-1-newlines, t.Column-1
+	endLine, endOffset := t.Line-1, startOffset+len(t.Lexeme)
+	if newlines > 0 {
+		endOffset = len(t.Lexeme) - strings.LastIndex(t.Lexeme, "\n") - 1
+	}
+	return Range{
+		Start: Position{Line: startLine, Character: d.character(startLine, startOffset)},
+		End:   Position{Line: endLine, Character: d.character(endLine, endOffset)},
+	}
+}
+
+// character converts a byte offset into a line, both counted from zero, into
+// the character the client expects: the same offset for UTF-8, or the number
+// of UTF-16 code units before it.
+func (d *document) character(line, offset int) int {
+	if !d.utf16 || line < 0 || line >= len(d.lines) {
+		return offset
+	}
+	text := d.lines[line]
+	if offset > len(text) {
+		return utf16Length(text) + offset - len(text)
+	}
+	return utf16Length(text[:offset])
+}
+
+// runeLength returns the length in bytes of the character at offset into
+// line, at least 1.
+func (d *document) runeLength(line, offset int) int {
+	if line < 0 || line >= len(d.lines) || offset >= len(d.lines[line]) {
+		return 1
+	}
+	_, size := utf8.DecodeRuneInString(d.lines[line][offset:])
+	return size
+}
+
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// bindingAt finds the variable whose declaration or use covers pos.
+func (d *document) bindingAt(pos Position) (*resolver.Binding, token.Token, bool) {
+	for _, binding := range d.resolution.Bindings {
+		if d.tokenRange(binding.Name).contains(pos) {
+			return binding, binding.Name, true
+		}
+	}
+	for _, ref := range d.resolution.References {
+		if ref.Binding != nil && d.tokenRange(ref.Name).contains(pos) {
+			return ref.Binding, ref.Name, true
+		}
+	}
+	return nil, token.Token{}, false
+}
+
+func (d *document) location(t token.Token) Location {
+	return Location{URI: d.uri, Range: d.tokenRange(t)}
+}
+
+func (d *document) definition(pos Position) []Location {
+	binding, _, ok := d.bindingAt(pos)
+	if !ok {
+		return []Location{}
+	}
+	return []Location{d.location(binding.Name)}
+}
+
+func (d *document) references(pos Position, includeDeclaration bool) []Location {
+	binding, _, ok := d.bindingAt(pos)
+	if !ok {
+		return []Location{}
+	}
+	locations := []Location{}
+	if includeDeclaration {
+		locations = append(locations, d.location(binding.Name))
+	}
+	for _, ref := range binding.References {
+		locations = append(locations, d.location(ref.Name))
+	}
+	return locations
+}
+
+func (d *document) hover(pos Position) *Hover {
+	binding, name, ok := d.bindingAt(pos)
+	if !ok {
+		return nil
+	}
+	declaration := strings.TrimSpace(d.lines[binding.Name.Line-1])
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("```lox\n%s\n```\n%s declared on line %d", declaration, describe(binding), binding.Name.Line),
+		},
+		Range: d.tokenRange(name),
+	}
+}
+
+// describe names the kind of a binding, such as "global variable".
+func describe(binding *resolver.Binding) string {
+	if binding.Kind == resolver.Parameter {
+		return "parameter"
+	}
+	scope := "global"
+	if binding.Depth > 0 {
+		scope = "local"
+	}
+	if binding.Kind == resolver.Function {
+		return scope + " function"
+	}
+	return scope + " variable"
+}
+
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, binding := range d.resolution.Bindings {
+		if binding.Kind == resolver.Parameter {
+			continue
+		}
+		detail := "global"
+		if binding.Depth > 0 {
+			detail = "local"
+		}
+		kind := SymbolKindVariable
+		if binding.Kind == resolver.Function {
+			kind = SymbolKindFunction
+		}
+		r := d.tokenRange(binding.Name)
+		symbols = append(symbols, DocumentSymbol{
+			Name:           binding.Name.Lexeme,
+			Detail:         detail,
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: r,
+		})
+	}
+	return symbols
+}
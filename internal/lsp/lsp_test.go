@@ -0,0 +1,300 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"interpreter/internal/jsonrpc"
+)
+
+const uri = "file:///test.lox"
+
+// testClient drives a Server in-process over a pair of pipes.
+type testClient struct {
+	t             *testing.T
+	conn          *jsonrpc.Conn
+	messages      chan *jsonrpc.Message
+	notifications []*jsonrpc.Message
+	done          chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	c := &testClient{
+		t:        t,
+		conn:     jsonrpc.NewConn(clientReader, clientWriter),
+		messages: make(chan *jsonrpc.Message, 16),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer(serverReader, serverWriter).Run()
+		serverWriter.Close()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { clientWriter.Close() })
+	return c
+}
+
+func (c *testClient) next() *jsonrpc.Message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+func (c *testClient) call(method string, params, result interface{}) {
+	c.t.Helper()
+	id, err := c.conn.Call(method, params)
+	if err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	for {
+		msg := c.next()
+		if msg.IsNotification() {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("%s: response for id %s, want %s", method, msg.ID, id)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %v", method, msg.Error)
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: decoding result: %v", method, err)
+		}
+		return
+	}
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.Notify(method, params); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+func (c *testClient) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var msg *jsonrpc.Message
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		msg = c.next()
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("got %s, want textDocument/publishDiagnostics", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *testClient) open(text string) {
+	c.t.Helper()
+	c.openWith(InitializeParams{}, text)
+}
+
+// openWith initializes the server with params, then opens text. It returns
+// the position encoding the server chose.
+func (c *testClient) openWith(params InitializeParams, text string) string {
+	c.t.Helper()
+	var result InitializeResult
+	c.call("initialize", params, &result)
+	if !result.Capabilities.DefinitionProvider {
+		c.t.Fatal("server does not advertise go-to-definition")
+	}
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "lox", Version: 1, Text: text},
+	})
+	return result.Capabilities.PositionEncoding
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+const source = `var count = 0;
+{
+  var step = 2;
+  count = count + step;
+}
+print count;
+`
+
+func TestDiagnostics(t *testing.T) {
+	c := newTestClient(t)
+	c.open("var a = ;\nprint @;")
+
+	got := c.diagnostics()
+	want := []Diagnostic{{Range: span(1, 6, 7), Severity: SeverityError, Source: "lox", Message: "unexpected character: @"}}
+	if !reflect.DeepEqual(got.Diagnostics, want) {
+		t.Errorf("diagnostics = %+v, want %+v", got.Diagnostics, want)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "var a = ;"}},
+	})
+	got = c.diagnostics()
+	if len(got.Diagnostics) != 1 || got.Diagnostics[0].Range != span(0, 8, 9) {
+		t.Errorf("diagnostics = %+v, want one error at ';'", got.Diagnostics)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "var a = 1;"}},
+	})
+	if got = c.diagnostics(); len(got.Diagnostics) != 0 {
+		t.Errorf("diagnostics = %+v, want none", got.Diagnostics)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	c := newTestClient(t)
+	c.open(source)
+	c.diagnostics()
+
+	var definition []Location
+	c.call("textDocument/definition", at(3, 18), &definition)
+	if want := []Location{{URI: uri, Range: span(2, 6, 10)}}; !reflect.DeepEqual(definition, want) {
+		t.Errorf("definition = %+v, want %+v", definition, want)
+	}
+
+	var references []Location
+	c.call("textDocument/references", ReferenceParams{
+		TextDocumentPositionParams: at(5, 7),
+		Context:                    ReferenceContext{IncludeDeclaration: true},
+	}, &references)
+	want := []Location{
+		{URI: uri, Range: span(0, 4, 9)},
+		{URI: uri, Range: span(3, 10, 15)},
+		{URI: uri, Range: span(3, 2, 7)},
+		{URI: uri, Range: span(5, 6, 11)},
+	}
+	if !reflect.DeepEqual(references, want) {
+		t.Errorf("references = %+v, want %+v", references, want)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(3, 3), &hover)
+	if want := "```lox\nvar count = 0;\n```\nglobal variable declared on line 1"; hover.Contents.Value != want {
+		t.Errorf("hover = %q, want %q", hover.Contents.Value, want)
+	}
+}
+
+func TestPositionEncoding(t *testing.T) {
+	// The emoji is four bytes of UTF-8 and two UTF-16 code units.
+	text := "var s = \"😀\"; print s;\nprint \"😀\" +;"
+	tests := []struct {
+		name      string
+		encodings []string
+		want      string
+		use       int
+		at        int
+		unknown   int
+	}{
+		{"UTF-16 by default", nil, "utf-16", 20, 12, 5},
+		{"UTF-8 when offered", []string{"utf-16", "utf-8"}, "utf-8", 22, 14, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t)
+			params := InitializeParams{Capabilities: ClientCapabilities{General: GeneralClientCapabilities{PositionEncodings: tt.encodings}}}
+			if got := c.openWith(params, text); got != tt.want {
+				t.Errorf("position encoding = %q, want %q", got, tt.want)
+			}
+			diagnostics := c.diagnostics().Diagnostics
+			if len(diagnostics) != 1 || diagnostics[0].Range != span(1, tt.at, tt.at+1) {
+				t.Errorf("diagnostics = %+v, want one at character %d", diagnostics, tt.at)
+			}
+
+			var definition []Location
+			c.call("textDocument/definition", at(0, tt.use), &definition)
+			if want := []Location{{URI: uri, Range: span(0, 4, 5)}}; !reflect.DeepEqual(definition, want) {
+				t.Errorf("definition = %+v, want %+v", definition, want)
+			}
+
+			c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+				TextDocument:   TextDocumentIdentifier{URI: uri},
+				ContentChanges: []TextDocumentContentChangeEvent{{Text: "\"😀\" @"}},
+			})
+			diagnostics = c.diagnostics().Diagnostics
+			if len(diagnostics) != 1 || diagnostics[0].Range != span(0, tt.unknown, tt.unknown+1) {
+				t.Errorf("diagnostics = %+v, want one at character %d", diagnostics, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestSymbolsAndSemanticTokens(t *testing.T) {
+	c := newTestClient(t)
+	c.open("var a = 1;\nprint a;")
+	c.diagnostics()
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "a" || symbols[0].Kind != SymbolKindVariable {
+		t.Errorf("symbols = %+v, want the variable a", symbols)
+	}
+
+	var tokens SemanticTokens
+	c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &tokens)
+	want := []int{
+		0, 0, 3, semanticKeyword, 0,
+		0, 4, 1, semanticVariable, semanticDeclaration,
+		0, 2, 1, semanticOperator, 0,
+		0, 2, 1, semanticNumber, 0,
+		1, 0, 5, semanticKeyword, 0,
+		0, 6, 1, semanticVariable, 0,
+	}
+	if !reflect.DeepEqual(tokens.Data, want) {
+		t.Errorf("semantic tokens = %v, want %v", tokens.Data, want)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := newTestClient(t)
+	var result interface{}
+	c.call("shutdown", nil, &result)
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit")
+	}
+}
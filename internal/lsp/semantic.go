@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"strings"
+
+	"interpreter/internal/resolver"
+	"interpreter/internal/scanner"
+	"interpreter/internal/token"
+)
+
+// Indices into the legend advertised in the server capabilities.
+const (
+	semanticKeyword = iota
+	semanticVariable
+	semanticString
+	semanticNumber
+	semanticOperator
+	semanticFunction
+	semanticParameter
+)
+
+const semanticDeclaration = 1 << 0
+
+var semanticLegend = SemanticTokensLegend{
+	TokenTypes:     []string{"keyword", "variable", "string", "number", "operator", "function", "parameter"},
+	TokenModifiers: []string{"declaration"},
+}
+
+// semanticType maps a token type onto the legend. Punctuation is left to the
+// editor's own syntax highlighting.
+func semanticType(tokenType token.TokenType) (int, bool) {
+	if scanner.IsKeyword(tokenType) {
+		return semanticKeyword, true
+	}
+	switch tokenType {
+	case token.IDENTIFIER:
+		return semanticVariable, true
+	case token.STRING:
+		return semanticString, true
+	case token.NUMBER:
+		return semanticNumber, true
+	case token.MINUS, token.PLUS, token.SLASH, token.STAR, token.QUESTION_MARK, token.COLON,
+		token.BANG, token.BANG_EQUAL, token.EQUAL, token.EQUAL_EQUAL,
+		token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL, token.ARROW,
+		token.PERCENT, token.AMPERSAND, token.PIPE, token.CARET, token.TILDE,
+		token.STAR_STAR, token.LESS_LESS, token.GREATER_GREATER,
+		token.PLUS_EQUAL, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL, token.PERCENT_EQUAL,
+		token.PLUS_PLUS, token.MINUS_MINUS,
+		token.QUESTION_DOT, token.QUESTION_QUESTION, token.QUESTION_QUESTION_EQUAL, token.DOT_DOT_DOT:
+		return semanticOperator, true
+	}
+	return 0, false
+}
+
+// semanticTokens encodes the document's tokens in the relative form the
+// protocol expects: line delta, start delta, length, type and modifiers.
+func (d *document) semanticTokens() SemanticTokens {
+	declarations := map[token.Token]bool{}
+	kinds := map[token.Token]resolver.BindingKind{}
+	for _, binding := range d.resolution.Bindings {
+		declarations[binding.Name] = true
+		kinds[binding.Name] = binding.Kind
+		for _, ref := range binding.References {
+			kinds[ref.Name] = binding.Kind
+		}
+	}
+
+	data := []int{}
+	line, character := 0, 0
+	for _, t := range d.tokens {
+		tokenType, ok := semanticType(t.Type)
+		// Multi-line tokens are not supported by every client.
+		if !ok || strings.Contains(t.Lexeme, "\n") {
+			continue
+		}
+		if tokenType == semanticVariable {
+			switch kinds[t] {
+			case resolver.Function:
+				tokenType = semanticFunction
+			case resolver.Parameter:
+				tokenType = semanticParameter
+			}
+		}
+		modifiers := 0
+		if declarations[t] {
+			modifiers |= semanticDeclaration
+		}
+
+		r := d.tokenRange(t)
+		if r.Start.Line != line {
+			character = 0
+		}
+		data = append(data, r.Start.Line-line, r.Start.Character-character, r.End.Character-r.Start.Character, tokenType, modifiers)
+		line, character = r.Start.Line, r.Start.Character
+	}
+	return SemanticTokens{Data: data}
+}
@@ -0,0 +1,201 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"interpreter/internal/jsonrpc"
+)
+
+// Server answers Language Server Protocol requests for lox scripts. Documents
+// are re-analyzed in full on every change.
+type Server struct {
+	conn      *jsonrpc.Conn
+	documents map[string]*document
+	shutdown  bool
+	// utf16 is set unless the client accepted UTF-8 positions, so that
+	// characters are counted in UTF-16 code units as the protocol defaults to.
+	utf16 bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn:      jsonrpc.NewConn(r, w),
+		documents: map[string]*document{},
+		utf16:     true,
+	}
+}
+
+// Run serves requests until the client sends exit or closes the stream.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rpcErr *jsonrpc.Error
+			if errors.As(err, &rpcErr) {
+				s.conn.Reply(nil, nil, rpcErr)
+				continue
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit received before shutdown")
+			}
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *Server) handle(msg *jsonrpc.Message) {
+	result, rpcErr := s.dispatch(msg)
+	if msg.IsRequest() {
+		s.conn.Reply(msg.ID, result, rpcErr)
+	}
+}
+
+func (s *Server) dispatch(msg *jsonrpc.Message) (interface{}, *jsonrpc.Error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		// Full synchronization: the last change holds the whole text.
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return doc.definition(params.Position), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return doc.references(params.Position, params.Context.IncludeDeclaration), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		if hover := doc.hover(params.Position); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return doc.symbols(), nil
+	case "textDocument/semanticTokens/full":
+		var params SemanticTokensParams
+		doc, err := s.document(msg, &params, &params.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return doc.semanticTokens(), nil
+	}
+
+	if msg.IsRequest() {
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	// Unknown notifications, such as $/cancelRequest, are ignored.
+	return nil, nil
+}
+
+// initialize picks UTF-8 positions if the client offers them, which need no
+// conversion, and UTF-16 otherwise.
+func (s *Server) initialize(params InitializeParams) InitializeResult {
+	encoding := "utf-16"
+	for _, offered := range params.Capabilities.General.PositionEncodings {
+		if offered == "utf-8" {
+			encoding = offered
+		}
+	}
+	s.utf16 = encoding == "utf-16"
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding:       encoding,
+			TextDocumentSync:       1,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			SemanticTokensProvider: SemanticTokensOptions{Legend: semanticLegend, Full: true},
+		},
+		ServerInfo: ServerInfo{Name: "myinterpreter"},
+	}
+}
+
+func (s *Server) update(uri, text string) {
+	doc := analyze(uri, text, s.utf16)
+	s.documents[uri] = doc
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// document decodes the request parameters and looks up the document they
+// refer to.
+func (s *Server) document(msg *jsonrpc.Message, params interface{}, id *TextDocumentIdentifier) (*document, *jsonrpc.Error) {
+	if err := decode(msg, params); err != nil {
+		return nil, err
+	}
+	doc, ok := s.documents[id.URI]
+	if !ok {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "unknown document: " + id.URI}
+	}
+	return doc, nil
+}
+
+func decode(msg *jsonrpc.Message, v interface{}) *jsonrpc.Error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
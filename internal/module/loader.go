@@ -0,0 +1,133 @@
+// Package module finds and parses the files named by import statements.
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// Loader reads modules from a file system. Paths are slash-separated and
+// relative to the root of the file system, as fs.FS requires.
+type Loader struct {
+	fsys       fs.FS
+	searchPath []string
+	// root is the OS directory fsys is rooted at, for a loader from
+	// NewOSLoader.
+	root string
+}
+
+// NewLoader returns a loader reading from fsys. Imports that are not found
+// next to the importing file are looked up in each directory of searchPath
+// in turn.
+func NewLoader(fsys fs.FS, searchPath ...string) *Loader {
+	return &Loader{fsys: fsys, searchPath: searchPath}
+}
+
+// NewOSLoader returns a loader for the local file system. Every path it
+// deals with is an OS path turned into a file system path by FSPath.
+func NewOSLoader(searchPath []string) (*Loader, error) {
+	root, err := osRoot()
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, len(searchPath))
+	for index, dir := range searchPath {
+		if dirs[index], err = FSPath(dir); err != nil {
+			return nil, err
+		}
+	}
+	l := NewLoader(os.DirFS(root), dirs...)
+	l.root = root
+	return l, nil
+}
+
+// FSPath converts an OS path into the path a loader from NewOSLoader uses.
+func FSPath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	p := filepath.ToSlash(strings.TrimPrefix(abs, root))
+	if p == "" {
+		p = "."
+	}
+	return p, nil
+}
+
+func osRoot() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.VolumeName(wd) + string(filepath.Separator), nil
+}
+
+// NotFoundError reports an import that matches no file.
+type NotFoundError struct {
+	Import string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("Module '%s' not found.", e.Import)
+}
+
+// Resolve finds the file imported as name by the file at importer. Names
+// starting with "./" or "../" are only looked up relative to the importer.
+// The ".lox" extension may be left out.
+func (l *Loader) Resolve(importer, name string) (string, error) {
+	file := name
+	if path.Ext(file) == "" {
+		file += ".lox"
+	}
+
+	dirs := []string{path.Dir(importer)}
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		dirs = append(dirs, l.searchPath...)
+	}
+	for _, dir := range dirs {
+		candidate := path.Join(dir, file)
+		if !fs.ValidPath(candidate) {
+			continue
+		}
+		info, err := fs.Stat(l.fsys, candidate)
+		if err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", NotFoundError{Import: name}
+}
+
+// Display returns the OS path of p for a loader from NewOSLoader, and p
+// itself otherwise.
+func (l *Loader) Display(p string) string {
+	if l.root == "" {
+		return p
+	}
+	return filepath.Join(l.root, filepath.FromSlash(p))
+}
+
+// Load reads, scans and parses the module at p, a path returned by Resolve.
+func (l *Loader) Load(p string) ([]expression.Stmt, error) {
+	source, err := fs.ReadFile(l.fsys, p)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := scanner.NewScanner(string(source)).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	return parser.NewParser(tokens).Parse()
+}
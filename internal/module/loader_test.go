@@ -0,0 +1,146 @@
+package module
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"interpreter/internal/interpreter"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+var files = fstest.MapFS{
+	"app/main.lox":         {Data: []byte("")},
+	"app/local.lox":        {Data: []byte(`var where = "app";`)},
+	"app/lib/helper.lox":   {Data: []byte(`import "../local" as l; var where = "helper " + l.where;`)},
+	"lib/local.lox":        {Data: []byte(`var where = "lib";`)},
+	"lib/shared.lox":       {Data: []byte(`print "loading shared"; var count = 1;`)},
+	"lib/slow.lox":         {Data: []byte(`print "loading slow"; var start = clock(); while (clock() < start + 0.05) {} var done = true;`)},
+	"lib/dir.lox/file.lox": {Data: []byte(``)},
+	"app/cycle_a.lox":      {Data: []byte(`import "cycle_b" as b;`)},
+	"app/cycle_b.lox":      {Data: []byte(`import "cycle_a" as a;`)},
+	"app/broken.lox":       {Data: []byte(`var = 1;`)},
+}
+
+func TestResolve(t *testing.T) {
+	loader := NewLoader(files, "lib")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"local", "app/local.lox"},
+		{"local.lox", "app/local.lox"},
+		{"shared", "lib/shared.lox"},
+		{"lib/helper", "app/lib/helper.lox"},
+		{"./shared", ""},
+		{"../lib/local", "lib/local.lox"},
+		{"../../escape", ""},
+		{"dir.lox", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loader.Resolve("app/main.lox", tt.name)
+			if tt.want == "" {
+				if _, ok := err.(NotFoundError); !ok {
+					t.Errorf("Resolve() = %q, %v; want NotFoundError", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func run(t *testing.T, source string) (string, error) {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.SetOutput(&out)
+	i.SetLoader(NewLoader(files, "lib"), "app/main.lox")
+	err = i.Interpret(statements)
+	return out.String(), err
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr string
+	}{
+		{"Relative import", `import "lib/helper" as h; print h.where;`, "helper app\n", ""},
+		{"Parent directory", `import "../lib/local" as l; print l.where;`, "lib\n", ""},
+		{"Search path, run once", `import "shared" as a; import "shared" as b; print a.count + b.count;`, "loading shared\n2\n", ""},
+		{"Spawned imports, run once", `var group = waitGroup();
+fun load() { import "slow" as s; groupDone(group); }
+groupAdd(group, 3); spawn load(); spawn load(); spawn load(); groupWait(group);
+import "slow" as s; print s.done;`, "loading slow\ntrue\n", ""},
+		{"Cycle", `import "cycle_a" as a;`, "", "Import cycle: app/cycle_a.lox -> app/cycle_b.lox -> app/cycle_a.lox"},
+		{"Importing itself", `import "main" as m;`, "", "Import cycle: app/main.lox -> app/main.lox"},
+		{"Syntax error", `import "broken" as b;`, "", "In module 'broken': Expect variable name. at line 1"},
+		{"Not found", `import "missing" as m;`, "", "Module 'missing' not found."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(t, tt.source)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSLoaderCycle(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.lox"), filepath.Join(dir, "b.lox")
+	if err := os.WriteFile(a, []byte(`import "b" as b;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(`import "a" as a;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := NewOSLoader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := FSPath(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := loader.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := interpreter.NewInterpreter()
+	i.SetLoader(loader, path)
+	err = i.Interpret(statements)
+	want := "Import cycle: " + a + " -> " + b + " -> " + a
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("error = %v, want %q", err, want)
+	}
+}
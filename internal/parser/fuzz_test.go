@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"interpreter/internal/scanner"
+)
+
+func addScripts(f *testing.F) {
+	root := filepath.Join("..", "..", "cmd", "myinterpreter", "testdata")
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".lox") {
+			if source, err := os.ReadFile(path); err == nil {
+				f.Add(string(source))
+			}
+		}
+		return nil
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	addScripts(f)
+	f.Add("(")
+	f.Add("fun f(a, { return")
+	f.Add("test \"t\" {")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens, err := scanner.NewScanner(source).ScanTokens()
+		if err != nil {
+			return
+		}
+		p := NewParser(tokens)
+		statements, err := p.Parse()
+		if err == nil {
+			return
+		}
+		if len(p.Errors()) == 0 {
+			t.Fatalf("Parse returned %v but Errors is empty", err)
+		}
+		for _, e := range p.Errors() {
+			var parseErr ParseError
+			if !errors.As(e, &parseErr) {
+				t.Fatalf("error %v (%T) is not a ParseError", e, e)
+			}
+		}
+		for _, stmt := range statements {
+			if stmt == nil {
+				t.Fatal("Parse returned a nil statement")
+			}
+		}
+	})
+}
@@ -1,19 +1,50 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 
 	"interpreter/internal/expression"
 	"interpreter/internal/token"
 )
 
+// maxArguments limits the parameters and arguments of a single call.
+const maxArguments = 255
+
 type Parser struct {
 	tokens  []token.Token
 	current int
+	errors  []error
+	// warnings holds ParseErrors for code that parses but is likely a
+	// mistake.
+	warnings []error
+	// functions counts the function bodies enclosing the current token.
+	functions int
+	// awaits reports whether await may be used at the current token: in
+	// top-level code, tests and async functions.
+	awaits bool
+}
+
+// ParseError reports a syntax error at a specific token.
+type ParseError struct {
+	Token   token.Token
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s at line %d", e.Message, e.Token.Line)
 }
 
 func NewParser(tokens []token.Token) *Parser {
-	return &Parser{tokens: tokens}
+	// Everything below relies on the stream ending in EOF.
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.EOF {
+		line := 1
+		if len(tokens) > 0 {
+			line = tokens[len(tokens)-1].Line
+		}
+		tokens = append(tokens[:len(tokens):len(tokens)], token.Token{Type: token.EOF, Line: line})
+	}
+	return &Parser{tokens: tokens, awaits: true}
 }
 
 func (p *Parser) Parse() ([]expression.Stmt, error) {
@@ -22,34 +53,46 @@ func (p *Parser) Parse() ([]expression.Stmt, error) {
 	for !p.isAtEnd() {
 		stmt, err := p.Declaration()
 		if err != nil {
+			p.errors = append(p.errors, err)
 			p.synchronize()
-			fmt.Printf("Error occured: %s", err)
 		} else {
 			statements = append(statements, stmt)
 		}
 	}
-	if !p.isAtEnd() {
-		return nil, fmt.Errorf("unexpected token: %v", p.peek())
+	// Like go/parser, hand back whatever parsed cleanly alongside the errors
+	// so tools can still work with a partially broken file.
+	if len(p.errors) > 0 {
+		return statements, errors.Join(p.errors...)
 	}
 
 	return statements, nil
 }
+
+// Errors returns every syntax error reported by the last call to Parse.
+func (p *Parser) Errors() []error {
+	return p.errors
+}
+
+// Warnings returns the warnings reported by the last call to Parse, such as
+// match cases that can never run.
+func (p *Parser) Warnings() []error {
+	return p.warnings
+}
+
+func (p *Parser) warn(t token.Token, message string) {
+	p.warnings = append(p.warnings, ParseError{Token: t, Message: message})
+}
 func (p *Parser) assignment() (expression.Expr, error) {
-	expr, err := p.or()
+	if p.patternAhead() {
+		return p.destructure()
+	}
+	expr, err := p.binary(0)
 	if err != nil {
 		return nil, err
 	}
 
-	for p.match(token.COMMA) {
-		operator := p.previous()
-		right, err := p.equality()
-		if err != nil {
-			return nil, err
-		}
-		expr = expression.NewBinary(expr, operator, right)
-	}
-
 	if p.match(token.QUESTION_MARK) {
+		operator := p.previous()
 		trueExpr, err := p.Expression()
 		if err != nil {
 			return nil, err
@@ -59,12 +102,24 @@ func (p *Parser) assignment() (expression.Expr, error) {
 			return nil, err
 		}
 
-		falseExpr, err := p.Expression()
+		falseExpr, err := p.assignment()
 		if err != nil {
 			return nil, err
 		}
 
-		expr = expression.NewTernary(expr, trueExpr, falseExpr)
+		expr = expression.NewTernary(expr, operator, trueExpr, falseExpr)
+	}
+
+	if p.match(token.PLUS_EQUAL, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL, token.PERCENT_EQUAL, token.QUESTION_QUESTION_EQUAL) {
+		operator := p.previous()
+		value, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		if !isTarget(expr) {
+			return nil, ParseError{Token: operator, Message: "Invalid assignment target"}
+		}
+		return expression.NewCompound(expr, operator, value), nil
 	}
 
 	if p.match(token.EQUAL) {
@@ -78,25 +133,27 @@ func (p *Parser) assignment() (expression.Expr, error) {
 
 			return expression.NewAssign(name, value), nil
 		}
+		if e, ok := expr.(*expression.Index); ok && !e.Optional {
+			return expression.NewSetIndex(e.Object, e.Bracket, e.Key, value), nil
+		}
 
-		return nil, fmt.Errorf("invalid assignment target at %s", equals)
+		return nil, ParseError{Token: equals, Message: "Invalid assignment target"}
 	}
 
 	return expr, nil
 }
-func (p *Parser) Expression() (expression.Expr, error) {
-	return p.assignment()
-}
 
-func (p *Parser) equality() (expression.Expr, error) {
-	expr, err := p.comparison()
+// comma parses the comma operator. It binds loosest of all, so call
+// arguments are parsed with assignment instead.
+func (p *Parser) comma() (expression.Expr, error) {
+	expr, err := p.assignment()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
+	for p.match(token.COMMA) {
 		operator := p.previous()
-		right, err := p.comparison()
+		right, err := p.assignment()
 		if err != nil {
 			return nil, err
 		}
@@ -106,49 +163,104 @@ func (p *Parser) equality() (expression.Expr, error) {
 	return expr, nil
 }
 
-func (p *Parser) comparison() (expression.Expr, error) {
-	expr, err := p.term()
+func (p *Parser) Expression() (expression.Expr, error) {
+	return p.comma()
+}
+
+// precedence lists the binary operators from the loosest to the tightest
+// binding. Every level is left-associative and takes its operands from the
+// level after it; the last level's operands are unary expressions. Adding a
+// binary operator means adding its token here.
+var precedence = []struct {
+	operators []token.TokenType
+	// logical levels short-circuit and build Logical nodes.
+	logical bool
+}{
+	{operators: []token.TokenType{token.QUESTION_QUESTION}, logical: true},
+	{operators: []token.TokenType{token.OR}, logical: true},
+	{operators: []token.TokenType{token.AND}, logical: true},
+	{operators: []token.TokenType{token.PIPE}},
+	{operators: []token.TokenType{token.CARET}},
+	{operators: []token.TokenType{token.AMPERSAND}},
+	{operators: []token.TokenType{token.BANG_EQUAL, token.EQUAL_EQUAL}},
+	{operators: []token.TokenType{token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL}},
+	{operators: []token.TokenType{token.LESS_LESS, token.GREATER_GREATER}},
+	{operators: []token.TokenType{token.PLUS, token.MINUS}},
+	{operators: []token.TokenType{token.STAR, token.SLASH, token.PERCENT}},
+}
+
+// binary parses the operators at precedence[level] and tighter.
+func (p *Parser) binary(level int) (expression.Expr, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+
+	expr, err := p.binary(level + 1)
 	if err != nil {
 		return nil, err
 	}
 
-	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+	for p.match(precedence[level].operators...) {
 		operator := p.previous()
-		right, err := p.term()
+		right, err := p.binary(level + 1)
 		if err != nil {
 			return nil, err
 		}
-		expr = expression.NewBinary(expr, operator, right)
+		if precedence[level].logical {
+			expr = expression.NewLogical(expr, operator, right)
+		} else {
+			expr = expression.NewBinary(expr, operator, right)
+		}
 	}
 
 	return expr, nil
 }
 
-func (p *Parser) term() (expression.Expr, error) {
-	expr, err := p.factor()
-	if err != nil {
-		return nil, err
+func (p *Parser) unary() (expression.Expr, error) {
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
+		operator := p.previous()
+		target, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if !isTarget(target) {
+			return nil, ParseError{Token: operator, Message: "Invalid increment target"}
+		}
+		return expression.NewIncrement(target, operator, true), nil
 	}
-
-	for p.match(token.PLUS, token.MINUS) {
+	if p.match(token.BANG, token.MINUS, token.TILDE) {
 		operator := p.previous()
-		right, err := p.factor()
+		right, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		expr = expression.NewBinary(expr, operator, right)
+		return expression.NewUnary(operator, right), nil
+	}
+	if p.match(token.AWAIT) {
+		keyword := p.previous()
+		if !p.awaits {
+			p.errors = append(p.errors, ParseError{Token: keyword, Message: "Can't use 'await' outside an async function."})
+		}
+		value, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return expression.NewAwait(keyword, value), nil
 	}
 
-	return expr, nil
+	return p.power()
 }
 
-func (p *Parser) factor() (expression.Expr, error) {
-	expr, err := p.unary()
+// power parses the right-associative **, which binds tighter than a unary
+// operator on its left but not on its right, so -2 ** 2 is -4 and 2 ** -1
+// is 0.5.
+func (p *Parser) power() (expression.Expr, error) {
+	expr, err := p.call()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.match(token.STAR, token.SLASH) {
+	if p.match(token.STAR_STAR) {
 		operator := p.previous()
 		right, err := p.unary()
 		if err != nil {
@@ -160,17 +272,101 @@ func (p *Parser) factor() (expression.Expr, error) {
 	return expr, nil
 }
 
-func (p *Parser) unary() (expression.Expr, error) {
-	if p.match(token.BANG, token.MINUS) {
+func (p *Parser) call() (expression.Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// After ?. comes a property name, an index or call arguments, which
+		// are only evaluated when the object is not nil.
+		optional := p.match(token.QUESTION_DOT)
+		if p.match(token.LEFT_PAREN) {
+			expr, err = p.finishCall(expr, optional)
+			if err != nil {
+				return nil, err
+			}
+		} else if p.match(token.LEFT_BRACKET) {
+			expr, err = p.index(expr, optional)
+			if err != nil {
+				return nil, err
+			}
+		} else if optional || p.match(token.DOT) {
+			message := "Expect property name after '.'."
+			if optional {
+				message = "Expect property name, '[' or '(' after '?.'."
+			}
+			name, err := p.consume(token.IDENTIFIER, message)
+			if err != nil {
+				return nil, err
+			}
+			expr = expression.NewGet(expr, name, optional)
+		} else {
+			break
+		}
+	}
+
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
 		operator := p.previous()
-		right, err := p.unary()
-		if err != nil {
-			return nil, err
+		if !isTarget(expr) {
+			return nil, ParseError{Token: operator, Message: "Invalid increment target"}
 		}
-		return expression.NewUnary(operator, right), nil
+		expr = expression.NewIncrement(expr, operator, false)
+	}
+
+	return expr, nil
+}
+
+// isTarget reports whether expr names something a compound assignment or an
+// increment can update: a variable, or an element of a list or map reached
+// without ?.[.
+func isTarget(expr expression.Expr) bool {
+	switch e := expr.(type) {
+	case *expression.Variable:
+		return true
+	case *expression.Index:
+		return !e.Optional
 	}
+	return false
+}
 
-	return p.primary()
+// index parses the key of `a[key]` after the opening bracket.
+func (p *Parser) index(object expression.Expr, optional bool) (expression.Expr, error) {
+	bracket := p.previous()
+	key, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+		return nil, err
+	}
+	return expression.NewIndex(object, bracket, key, optional), nil
+}
+
+func (p *Parser) finishCall(callee expression.Expr, optional bool) (expression.Expr, error) {
+	var arguments []expression.Expr
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(arguments) >= maxArguments {
+				p.errors = append(p.errors, ParseError{Token: p.peek(), Message: "Can't have more than 255 arguments."})
+			}
+			argument, err := p.assignment()
+			if err != nil {
+				return nil, err
+			}
+			arguments = append(arguments, argument)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	paren, err := p.consume(token.RIGHT_PAREN, "Expect ')' after arguments.")
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewCall(callee, paren, arguments, optional), nil
 }
 
 func (p *Parser) primary() (expression.Expr, error) {
@@ -192,6 +388,19 @@ func (p *Parser) primary() (expression.Expr, error) {
 		return expression.NewVariable(p.previous()), nil
 	}
 
+	if p.match(token.FUN) {
+		return p.lambda(false)
+	}
+	if p.match(token.ASYNC) {
+		if _, err := p.consume(token.FUN, "Expect 'fun' after 'async'."); err != nil {
+			return nil, err
+		}
+		return p.lambda(true)
+	}
+	if p.check(token.LEFT_PAREN) && p.arrowAhead() {
+		return p.arrowFunction()
+	}
+
 	if p.match(token.LEFT_PAREN) {
 		expr, err := p.Expression()
 		if err != nil {
@@ -203,8 +412,286 @@ func (p *Parser) primary() (expression.Expr, error) {
 		}
 		return expression.NewGrouping(expr), nil
 	}
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
+	}
 
-	return nil, fmt.Errorf("unexpected token: %v", p.peek())
+	return nil, ParseError{Token: p.peek(), Message: fmt.Sprintf("unexpected token: %v", p.peek())}
+}
+
+// list parses the elements of `[a, b]` after the opening bracket. A trailing
+// comma is allowed.
+func (p *Parser) list() (expression.Expr, error) {
+	bracket := p.previous()
+	var elements []expression.Expr
+	for !p.check(token.RIGHT_BRACKET) {
+		element, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements."); err != nil {
+		return nil, err
+	}
+	return expression.NewList(bracket, elements), nil
+}
+
+// mapLiteral parses the entries of `{key: value}` after the opening brace.
+// A bare name as a key stands for the string, as in `{name: "Ada"}`; any
+// other key is an expression.
+func (p *Parser) mapLiteral() (expression.Expr, error) {
+	brace := p.previous()
+	var keys, values []expression.Expr
+	for !p.check(token.RIGHT_BRACE) {
+		var key expression.Expr
+		if p.check(token.IDENTIFIER) && p.checkNext(token.COLON) {
+			key = expression.NewLiteral(p.advance().Lexeme)
+		} else {
+			var err error
+			if key, err = p.assignment(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.consume(token.COLON, "Expect ':' after map key."); err != nil {
+			return nil, err
+		}
+		value, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		keys, values = append(keys, key), append(values, value)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after map entries."); err != nil {
+		return nil, err
+	}
+	return expression.NewMap(brace, keys, values), nil
+}
+
+// lambda parses `fun (params) { body }` after the fun keyword, which an
+// async keyword may precede.
+func (p *Parser) lambda(async bool) (expression.Expr, error) {
+	keyword := p.previous()
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'fun'."); err != nil {
+		return nil, err
+	}
+	fn, err := p.functionRest(anonymous(keyword), "function", async)
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewLambda(keyword, fn), nil
+}
+
+// arrowFunction parses `(params) => expression`, which returns the value of
+// the expression, or `(params) => { body }`.
+func (p *Parser) arrowFunction() (expression.Expr, error) {
+	start := p.advance()
+	params, paramTypes, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	returnType, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+	arrow, err := p.consume(token.ARROW, "Expect '=>' after parameters.")
+	if err != nil {
+		return nil, err
+	}
+
+	var body []expression.Stmt
+	if p.check(token.LEFT_BRACE) {
+		if body, err = p.functionBody("function", false); err != nil {
+			return nil, err
+		}
+	} else {
+		p.functions++
+		awaits := p.awaits
+		p.awaits = false
+		value, err := p.assignment()
+		p.functions--
+		p.awaits = awaits
+		if err != nil {
+			return nil, err
+		}
+		body = []expression.Stmt{expression.NewReturn(arrow, value, arrow.Line)}
+	}
+	fn := expression.NewFunction(anonymous(start), params, paramTypes, returnType, body, false, start.Line)
+	return expression.NewLambda(arrow, fn), nil
+}
+
+// patternAhead reports whether the bracket or brace at the current token
+// opens the target of a destructuring assignment rather than a list or map:
+// whether the matching bracket or brace is followed by =.
+func (p *Parser) patternAhead() bool {
+	if !p.check(token.LEFT_BRACKET) && !p.check(token.LEFT_BRACE) {
+		return false
+	}
+	depth := 0
+	for i := p.current; p.tokens[i].Type != token.EOF; i++ {
+		switch p.tokens[i].Type {
+		case token.LEFT_PAREN, token.LEFT_BRACKET, token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACKET, token.RIGHT_BRACE:
+			if depth--; depth == 0 {
+				return p.tokens[i+1].Type == token.EQUAL
+			}
+		}
+	}
+	return false
+}
+
+// destructure parses `[a, b] = value` or `{name} = value`.
+func (p *Parser) destructure() (expression.Expr, error) {
+	pattern, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	equals, err := p.consume(token.EQUAL, "Expect '=' after destructuring pattern.")
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewDestructure(pattern, equals, value), nil
+}
+
+// target parses what a destructuring declaration or assignment stores
+// into: a name, a list pattern such as [a, b, ...rest] or a map pattern such
+// as {name, age: years}. Patterns nest.
+func (p *Parser) target() (expression.Target, error) {
+	if p.match(token.IDENTIFIER) {
+		return &expression.NameTarget{Name: p.previous()}, nil
+	}
+	if p.match(token.LEFT_BRACKET) {
+		return p.listTarget()
+	}
+	if p.match(token.LEFT_BRACE) {
+		return p.mapTarget()
+	}
+	return nil, ParseError{Token: p.peek(), Message: "Expect variable name or pattern."}
+}
+
+// listTarget parses the elements of `[a, b, ...rest]` after the opening
+// bracket. The rest element comes last; a trailing comma is allowed.
+func (p *Parser) listTarget() (expression.Target, error) {
+	t := &expression.ListTarget{Bracket: p.previous()}
+	for !p.check(token.RIGHT_BRACKET) {
+		if p.match(token.DOT_DOT_DOT) {
+			rest, err := p.consume(token.IDENTIFIER, "Expect name after '...'.")
+			if err != nil {
+				return nil, err
+			}
+			t.Rest = &rest
+			break
+		}
+		element, err := p.target()
+		if err != nil {
+			return nil, err
+		}
+		t.Elements = append(t.Elements, element)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	message := "Expect ']' after list pattern."
+	if t.Rest != nil {
+		message = "Expect ']' after rest element."
+	}
+	if _, err := p.consume(token.RIGHT_BRACKET, message); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// mapTarget parses the entries of `{name, age: years}` after the opening
+// brace. A key is a name or a string; only a name can stand alone, when it
+// also names the variable.
+func (p *Parser) mapTarget() (expression.Target, error) {
+	t := &expression.MapTarget{Brace: p.previous()}
+	for !p.check(token.RIGHT_BRACE) {
+		if !p.match(token.IDENTIFIER, token.STRING) {
+			return nil, ParseError{Token: p.peek(), Message: "Expect name or string key in map pattern."}
+		}
+		key := p.previous()
+		var value expression.Target = &expression.NameTarget{Name: key}
+		if p.match(token.COLON) {
+			var err error
+			if value, err = p.target(); err != nil {
+				return nil, err
+			}
+		} else if key.Type == token.STRING {
+			return nil, ParseError{Token: p.peek(), Message: "Expect ':' after string key."}
+		}
+		t.Keys, t.Values = append(t.Keys, key), append(t.Values, value)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after map pattern."); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// arrowAhead reports whether the parenthesis at the current token opens the
+// parameters of an arrow function rather than a grouping: whether the
+// matching parenthesis is followed by =>, possibly after a return type.
+func (p *Parser) arrowAhead() bool {
+	depth := 0
+	i := p.current
+	for ; p.tokens[i].Type != token.EOF; i++ {
+		if p.tokens[i].Type == token.LEFT_PAREN {
+			depth++
+		} else if p.tokens[i].Type == token.RIGHT_PAREN {
+			if depth--; depth == 0 {
+				break
+			}
+		}
+	}
+	if p.tokens[i].Type == token.EOF {
+		return false
+	}
+
+	next := func() token.TokenType {
+		if p.tokens[i].Type != token.EOF {
+			i++
+		}
+		return p.tokens[i].Type
+	}
+	switch next() {
+	case token.ARROW:
+		return true
+	case token.COLON:
+		switch next() {
+		case token.IDENTIFIER, token.NIL, token.FUN:
+		default:
+			return false
+		}
+		t := next()
+		if t == token.QUESTION_MARK {
+			t = next()
+		}
+		return t == token.ARROW
+	}
+	return false
+}
+
+// anonymous names a lambda after the token that starts it. Its lexeme is
+// empty, as the function has no name in the script.
+func anonymous(start token.Token) token.Token {
+	return token.Token{Type: token.IDENTIFIER, Line: start.Line, Column: start.Column}
 }
 
 func (p *Parser) match(types ...token.TokenType) bool {
@@ -225,6 +712,13 @@ func (p *Parser) check(tokenType token.TokenType) bool {
 	return p.peek().Type == tokenType
 }
 
+func (p *Parser) checkNext(tokenType token.TokenType) bool {
+	if p.isAtEnd() || p.tokens[p.current+1].Type == token.EOF {
+		return false
+	}
+	return p.tokens[p.current+1].Type == tokenType
+}
+
 func (p *Parser) advance() token.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -241,6 +735,9 @@ func (p *Parser) peek() token.Token {
 }
 
 func (p *Parser) previous() token.Token {
+	if p.current == 0 {
+		return p.tokens[0]
+	}
 	return p.tokens[p.current-1]
 }
 
@@ -249,7 +746,7 @@ func (p *Parser) consume(tokenType token.TokenType, message string) (token.Token
 		return p.advance(), nil
 	}
 
-	return token.Token{}, fmt.Errorf("%s at line %d", message, p.peek().Line)
+	return token.Token{}, ParseError{Token: p.peek(), Message: message}
 }
 
 func (p *Parser) synchronize() {
@@ -261,7 +758,7 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case token.CLASS, token.FUN, token.VAR, token.IF, token.WHILE, token.PRINT, token.RETURN:
+		case token.CLASS, token.FUN, token.VAR, token.IMPORT, token.IF, token.MATCH, token.WHILE, token.PRINT, token.RETURN, token.YIELD, token.SPAWN, token.SELECT, token.ASYNC:
 			return
 		}
 
@@ -0,0 +1,424 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/scanner"
+	"interpreter/internal/token"
+)
+
+func TestParseWithoutEOF(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []token.Token
+	}{
+		{"No tokens", nil},
+		{"Missing EOF", []token.Token{{Type: token.NUMBER, Lexeme: "1", Literal: 1.0, Line: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the absence of a panic matters here.
+			NewParser(tt.tokens).Parse()
+		})
+	}
+}
+
+func TestParseLambdas(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"(a);", "(group a)"},
+		{"(a) + (b);", "(+ (group a) (group b))"},
+		{"(a) => a;", "(fun (a) (return a))"},
+		{"() => 1;", "(fun () (return 1.0))"},
+		{"(a, b) => { return a; };", "(fun (a b) (return a))"},
+		{"(a: number): number => a;", "(fun (a) (return a))"},
+		{"c ? (a) : b;", "(?: c (group a) b)"},
+		{"fun (a) { print a; };", "(fun (a) (print a))"},
+		{"f((a) => a, b);", "(call f (fun (a) (return a)) b)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCollections(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"[];", "(list)"},
+		{"[1, [a], ];", "(list 1.0 (list a))"},
+		{"print {name: 1, \"b\": 2, c: {}};", "(print (map name 1.0 b 2.0 c (map)))"},
+		{"for (x in [a]) print x;", "(for x in (list a) (print x))"},
+		{"for (x in xs) { print x; }", "(for x in xs (block (print x)))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYield(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "fun f() { yield 1; yield; }", want: "(fun f() (yield 1.0) (yield))"},
+		{source: "fun f() { if (a) return; while (b) yield a; }", want: "(fun f() (if a (return)) (while b (yield a)))"},
+		{source: "fun f() { fun g() { return 1; } yield g; }", want: "(fun f() (fun g() (return 1.0)) (yield g))"},
+		{source: "yield 1;", err: "Can't yield from top-level code. at line 1"},
+		{source: "fun f() { yield 1; return 2; }", err: "Can't return a value from a generator. at line 1"},
+		{source: "test \"gen\" { yield 1; }", err: "Can't yield from a test. at line 1"},
+		{source: "fun f() { yield 1 }", err: "Expect ';' after yield value. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAsync(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "async fun f(a) { return await a; }", want: "(async fun f(a) (return (await a)))"},
+		{source: "var f = async fun () { await g(); };", want: "(var f (async fun () (await (call g))))"},
+		{source: "print await a + 1;", want: "(print (+ (await a) 1.0))"},
+		{source: "test \"waits\" { await sleep(1); }", want: "(test \"waits\" (await (call sleep 1.0)))"},
+		{source: "fun f() { await 1; }", err: "Can't use 'await' outside an async function. at line 1"},
+		{source: "async fun f() { var g = () => await 1; }", err: "Can't use 'await' outside an async function. at line 1"},
+		{source: "async fun f() { yield 1; }", err: "Can't yield from an async function. at line 1"},
+		{source: "var f = async () => 1;", err: "Expect 'fun' after 'async'. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNullSafety(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "a?.b;", want: "(?.b a)"},
+		{source: "a?.[i]?.(x).c;", want: "(.c (?call (?[] a i) x))"},
+		{source: "a[0][1];", want: "([] ([] a 0.0) 1.0)"},
+		{source: "a ?? b or c;", want: "(?? a (or b c))"},
+		{source: "a ?? b ? c : d;", want: "(?: (?? a b) c d)"},
+		{source: "a ??= b ?? c;", want: "(??= a (?? b c))"},
+		{source: "a?.;", err: "Expect property name, '[' or '(' after '?.'. at line 1"},
+		{source: "a[i] ??= 1;", want: "(??= ([] a i) 1.0)"},
+		{source: "a[i][j] += 1;", want: "(+= ([] ([] a i) j) 1.0)"},
+		{source: "a[i]++;", want: "(post++ ([] a i))"},
+		{source: "a[i][j] = b[k] = 1;", want: "([]= ([] a i) j ([]= b k 1.0))"},
+		{source: "a?.[i] = 1;", err: "Invalid assignment target at line 1"},
+		{source: "a?.b ??= 1;", err: "Invalid assignment target at line 1"},
+		{source: "a?.[i] += 1;", err: "Invalid assignment target at line 1"},
+		{source: "--a?.[i];", err: "Invalid increment target at line 1"},
+		{source: "spawn f?.();", err: "Expect function call after 'spawn'. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDestructuring(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "var [a, b, ...rest] = list;", want: "(var [a b ...rest] list)"},
+		{source: "var {name, age: years, \"x y\": [z]} = m;", want: "(var {name: name age: years x y: [z]} m)"},
+		{source: "[a, b] = [b, a];", want: "(= [a b] (list b a))"},
+		{source: "{name} = m;", want: "(= {name: name} m)"},
+		{source: "{ a; }", want: "(block a)"},
+		{source: "x = [a, b] = c;", want: "(= x (= [a b] c))"},
+		{source: "var [a] ;", err: "Expect '=' after destructuring pattern. at line 1"},
+		{source: "var [...a, b] = c;", err: "Expect ']' after rest element. at line 1"},
+		{source: "var {1} = c;", err: "Expect name or string key in map pattern. at line 1"},
+		{source: "var [1] = c;", err: "Expect variable name or pattern. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConcurrency(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{source: "spawn f(1, 2);", want: "(spawn (call f 1.0 2.0))"},
+		{source: "spawn g.run();", want: "(spawn (call (.run g)))"},
+		{source: "select { case v = recv(c) => print v; case send(c, 1) => {} case _ => print 0; }",
+			want: "(select (case (= v (recv c)) (print v)) (case (send c 1.0) (block)) (case _ (print 0.0)))"},
+		{source: "select { case recv(c) => {} }", want: "(select (case (recv c) (block)))"},
+		{source: "spawn f;", err: "Expect function call after 'spawn'. at line 1"},
+		{source: "spawn f()", err: "Expect ';' after spawn call. at line 1"},
+		{source: "select { case v = send(c, 1) => {} }", err: "Only a recv case can bind a name. at line 1"},
+		{source: "select { case close(c) => {} }", err: "Expect recv, send or _ in select case. at line 1"},
+		{source: "select { case _ => {} case _ => {} }", err: "A select can have only one default case. at line 1"},
+		{source: "select { case recv(c) {} }", err: "Expect '=>' after select case. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"a or b and c;", "(or a (and b c))"},
+		{"a and b or c and d;", "(or (and a b) (and c d))"},
+		{"a or b or c;", "(or (or a b) c)"},
+		{"a | b ^ c & d;", "(| a (^ b (& c d)))"},
+		{"a & b == c;", "(& a (== b c))"},
+		{"a == b < c;", "(== a (< b c))"},
+		{"a < b << c;", "(< a (<< b c))"},
+		{"a << b + c;", "(<< a (+ b c))"},
+		{"a >> b >> c;", "(>> (>> a b) c)"},
+		{"a + b % c;", "(+ a (% b c))"},
+		{"a * b % c;", "(% (* a b) c)"},
+		{"a * b ** c;", "(* a (** b c))"},
+		{"a ** b ** c;", "(** a (** b c))"},
+		{"-a ** b;", "(- (** a b))"},
+		{"a ** -b;", "(** a (- b))"},
+		{"~a & b;", "(& (~ a) b)"},
+		{"a ** f(b);", "(** a (call f b))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			statements, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMatch(t *testing.T) {
+	tests := []struct {
+		source   string
+		want     string
+		err      string
+		warnings []string
+	}{
+		{
+			source: "match (x) { case 1, -2 => print x; case n if n > 0 => print n; case _ => {} }",
+			want:   "(match x (case 1.0 -2.0 (print x)) (case n (guard (> n 0.0)) (print n)) (case _ (block)))",
+		},
+		{
+			source: "match (x) {}",
+			want:   "(match x)",
+		},
+		{
+			source:   "match (x) {\ncase n => print 1;\ncase \"a\" => print 2;\n}",
+			want:     "(match x (case n (print 1.0)) (case a (print 2.0)))",
+			warnings: []string{"Case can never match; the case on line 2 covers it at line 3"},
+		},
+		{
+			source:   "match (x) { case 1, 2 => print 1; case 2, 1 => print 2; case 2, 3 => print 3; }",
+			want:     "(match x (case 1.0 2.0 (print 1.0)) (case 2.0 1.0 (print 2.0)) (case 2.0 3.0 (print 3.0)))",
+			warnings: []string{"Case can never match; the case on line 1 covers it at line 1"},
+		},
+		{
+			source: "match (x) { case n if n => print 1; case 1 => print 2; }",
+			want:   "(match x (case n (guard n) (print 1.0)) (case 1.0 (print 2.0)))",
+		},
+		{
+			source: "match (x) { case [a, ...rest] => print a; case {name, age: [y]} => print y; case [] => {} }",
+			want:   "(match x (case [a ...rest] (print a)) (case {name: name age: [y]} (print y)) (case [] (block)))",
+		},
+		{
+			source:   "match (x) {\ncase [a, ...rest] => print 1;\ncase [b, c] => print 2;\ncase [d] => print 3;\ncase [] => print 4;\n}",
+			want:     "(match x (case [a ...rest] (print 1.0)) (case [b c] (print 2.0)) (case [d] (print 3.0)) (case [] (print 4.0)))",
+			warnings: []string{"Case can never match; the case on line 2 covers it at line 3", "Case can never match; the case on line 2 covers it at line 4"},
+		},
+		{
+			source:   "match (x) {\ncase {name} => print 1;\ncase {age, name: [n]} => print 2;\ncase {age} => print 3;\n}",
+			want:     "(match x (case {name: name} (print 1.0)) (case {age: age name: [n]} (print 2.0)) (case {age: age} (print 3.0)))",
+			warnings: []string{"Case can never match; the case on line 2 covers it at line 3"},
+		},
+		{source: "match x { case 1 => print 1; }", err: "Expect '(' after 'match'. at line 1"},
+		{source: "match (x) case 1 => print 1;", err: "Expect '{' before match cases. at line 1"},
+		{source: "match (x) { 1 => print 1; }", err: "Expect 'case'. at line 1"},
+		{source: "match (x) { case 1 print 1; }", err: "Expect '=>' after case pattern. at line 1"},
+		{source: "match (x) { case 1, n => print n; }", err: "Cannot bind a name in alternative patterns at line 1"},
+		{source: "match (x) { case (1) => print 1; }", err: "Expect pattern. at line 1"},
+		{source: "match (x) { case -a => print 1; }", err: "Expect number after '-' in pattern. at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tokens, err := scanner.NewScanner(tt.source).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewParser(tokens)
+			statements, err := p.Parse()
+			if tt.err != "" {
+				if errs := p.Errors(); len(errs) == 0 || errs[0].Error() != tt.err {
+					t.Fatalf("errors = %v, want %s first", errs, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			printer := &expression.AstPrinter{}
+			if got := printer.PrintStmt(statements[0]); got != tt.want {
+				t.Errorf("parse = %s, want %s", got, tt.want)
+			}
+			var warnings []string
+			for _, warning := range p.Warnings() {
+				warnings = append(warnings, warning.Error())
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
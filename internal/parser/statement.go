@@ -1,7 +1,11 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
+
 	"interpreter/internal/expression"
+	"interpreter/internal/scanner"
 	"interpreter/internal/token"
 )
 
@@ -12,16 +16,34 @@ func (p *Parser) Statement() (expression.Stmt, error) {
 	if p.match(token.IF) {
 		return p.ifStatement()
 	}
+	if p.match(token.MATCH) {
+		return p.matchStatement()
+	}
 	if p.match(token.PRINT) {
 		return p.printStatement()
 	}
+	if p.match(token.RETURN) {
+		return p.returnStatement()
+	}
 	if p.match(token.WHILE) {
 		return p.whileStatement()
 	}
-	if p.match(token.LEFT_BRACE) {
+	if p.match(token.YIELD) {
+		return p.yieldStatement()
+	}
+	if p.match(token.SPAWN) {
+		return p.spawnStatement()
+	}
+	if p.match(token.SELECT) {
+		return p.selectStatement()
+	}
+	// A brace followed, after its match, by = starts a destructuring
+	// assignment rather than a block.
+	if !p.patternAhead() && p.match(token.LEFT_BRACE) {
+		line := p.previous().Line
 		if val, err := p.block(); err == nil {
 
-			return expression.NewBlock(val), nil
+			return expression.NewBlock(val, line), nil
 		} else {
 			return nil, err
 		}
@@ -31,9 +53,28 @@ func (p *Parser) Statement() (expression.Stmt, error) {
 }
 
 func (p *Parser) Declaration() (expression.Stmt, error) {
+	// fun followed by a parameter list starts a lambda expression.
+	if p.check(token.FUN) && !p.checkNext(token.LEFT_PAREN) {
+		p.advance()
+		return p.function("function", false)
+	}
+	// So does async fun, which declares an async function.
+	if p.check(token.ASYNC) && p.checkNext(token.FUN) && p.tokens[p.current+2].Type != token.LEFT_PAREN {
+		p.current += 2
+		return p.function("function", true)
+	}
 	if p.match(token.VAR) {
 		return p.varDeclaration()
 	}
+	if p.match(token.IMPORT) {
+		return p.importDeclaration()
+	}
+	// test is not a keyword, so it only starts a test block when a name
+	// follows it and can still be used as an identifier elsewhere.
+	if p.check(token.IDENTIFIER) && p.peek().Lexeme == "test" && p.checkNext(token.STRING) {
+		p.advance()
+		return p.testDeclaration()
+	}
 
 	stmt, err := p.Statement()
 	if err != nil {
@@ -44,10 +85,17 @@ func (p *Parser) Declaration() (expression.Stmt, error) {
 
 }
 func (p *Parser) varDeclaration() (expression.Stmt, error) {
+	if p.check(token.LEFT_BRACKET) || p.check(token.LEFT_BRACE) {
+		return p.varDestructure()
+	}
 	name, err := p.consume(token.IDENTIFIER, "Expect variable name.")
 	if err != nil {
 		return nil, err
 	}
+	annotation, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
 
 	var initializer expression.Expr
 	if p.match(token.EQUAL) {
@@ -57,13 +105,235 @@ func (p *Parser) varDeclaration() (expression.Stmt, error) {
 		}
 	}
 
-	_, err = p.consume(token.SEMICOLON, "Expect ';' after variable declaration\n")
+	_, err = p.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewVar(name, annotation, initializer, name.Line), nil
+}
+
+// varDestructure parses `var [a, b] = value;` or `var {name} = value;`,
+// which must have an initializer.
+func (p *Parser) varDestructure() (expression.Stmt, error) {
+	line := p.peek().Line
+	pattern, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.EQUAL, "Expect '=' after destructuring pattern."); err != nil {
+		return nil, err
+	}
+	initializer, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after variable declaration."); err != nil {
+		return nil, err
+	}
+	return expression.NewVarDestructure(pattern, initializer, line), nil
+}
+
+func (p *Parser) function(kind string, async bool) (expression.Stmt, error) {
+	name, err := p.consume(token.IDENTIFIER, "Expect "+kind+" name.")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after "+kind+" name."); err != nil {
+		return nil, err
+	}
+	fn, err := p.functionRest(name, kind, async)
+	if err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// functionRest parses the parameters, return type and body of a function
+// whose name and opening parenthesis have been consumed.
+func (p *Parser) functionRest(name token.Token, kind string, async bool) (*expression.Function, error) {
+	params, paramTypes, err := p.parameters()
 	if err != nil {
 		return nil, err
 	}
-	return expression.NewVar(name, initializer), nil
+	returnType, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.functionBody(kind, async)
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewFunction(name, params, paramTypes, returnType, body, async, name.Line), nil
+}
+
+// parameters parses a parameter list up to and including its closing
+// parenthesis.
+func (p *Parser) parameters() ([]token.Token, []*expression.TypeAnnotation, error) {
+	var params []token.Token
+	var paramTypes []*expression.TypeAnnotation
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(params) >= maxArguments {
+				p.errors = append(p.errors, ParseError{Token: p.peek(), Message: "Can't have more than 255 parameters."})
+			}
+			param, err := p.consume(token.IDENTIFIER, "Expect parameter name.")
+			if err != nil {
+				return nil, nil, err
+			}
+			annotation, err := p.typeAnnotation()
+			if err != nil {
+				return nil, nil, err
+			}
+			params = append(params, param)
+			paramTypes = append(paramTypes, annotation)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after parameters."); err != nil {
+		return nil, nil, err
+	}
+	return params, paramTypes, nil
+}
+
+// typeAnnotation parses an optional `: type` after a name or a parameter
+// list. Types are names, checked later by the checker, except that nil and
+// fun are keywords.
+func (p *Parser) typeAnnotation() (*expression.TypeAnnotation, error) {
+	if !p.match(token.COLON) {
+		return nil, nil
+	}
+	if !p.match(token.IDENTIFIER, token.NIL, token.FUN) {
+		return nil, ParseError{Token: p.peek(), Message: "Expect type after ':'."}
+	}
+	annotation := &expression.TypeAnnotation{Name: p.previous()}
+	annotation.Optional = p.match(token.QUESTION_MARK)
+	return annotation, nil
+}
+
+func (p *Parser) functionBody(kind string, async bool) ([]expression.Stmt, error) {
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' before "+kind+" body."); err != nil {
+		return nil, err
+	}
+	p.functions++
+	awaits := p.awaits
+	p.awaits = async || kind == "test"
+	defer func() { p.functions, p.awaits = p.functions-1, awaits }()
+	body, err := p.block()
+	if err != nil || !expression.IsGenerator(body) {
+		return body, err
+	}
+	expression.Inspect(body, func(stmt expression.Stmt) {
+		switch s := stmt.(type) {
+		case *expression.Yield:
+			if kind == "test" {
+				p.errors = append(p.errors, ParseError{Token: s.Keyword, Message: "Can't yield from a test."})
+			} else if async {
+				p.errors = append(p.errors, ParseError{Token: s.Keyword, Message: "Can't yield from an async function."})
+			}
+		case *expression.Return:
+			if s.Value != nil {
+				p.errors = append(p.errors, ParseError{Token: s.Keyword, Message: "Can't return a value from a generator."})
+			}
+		}
+	})
+	return body, nil
+}
+
+func (p *Parser) testDeclaration() (expression.Stmt, error) {
+	keyword := p.previous()
+	name := p.advance()
+	body, err := p.functionBody("test", false)
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewTest(name, body, keyword.Line), nil
+}
+
+func (p *Parser) returnStatement() (expression.Stmt, error) {
+	keyword := p.previous()
+	if p.functions == 0 {
+		p.errors = append(p.errors, ParseError{Token: keyword, Message: "Can't return from top-level code."})
+	}
+
+	var value expression.Expr
+	if !p.check(token.SEMICOLON) {
+		var err error
+		value, err = p.Expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after return value."); err != nil {
+		return nil, err
+	}
+	return expression.NewReturn(keyword, value, keyword.Line), nil
+}
+
+// yieldStatement parses `yield value;`, which makes the enclosing function a
+// generator.
+func (p *Parser) yieldStatement() (expression.Stmt, error) {
+	keyword := p.previous()
+	if p.functions == 0 {
+		p.errors = append(p.errors, ParseError{Token: keyword, Message: "Can't yield from top-level code."})
+	}
+
+	var value expression.Expr
+	if !p.check(token.SEMICOLON) {
+		var err error
+		value, err = p.Expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after yield value."); err != nil {
+		return nil, err
+	}
+	return expression.NewYield(keyword, value, keyword.Line), nil
+}
+
+// importDeclaration parses `import "path" as name;`. Without a name the
+// module is bound to the last element of its path.
+func (p *Parser) importDeclaration() (expression.Stmt, error) {
+	keyword := p.previous()
+	path, err := p.consume(token.STRING, "Expect module path after 'import'.")
+	if err != nil {
+		return nil, err
+	}
+
+	var name token.Token
+	if p.check(token.IDENTIFIER) && p.peek().Lexeme == "as" {
+		p.advance()
+		if name, err = p.consume(token.IDENTIFIER, "Expect module name after 'as'."); err != nil {
+			return nil, err
+		}
+	} else {
+		base := path.Literal.(string)
+		base = base[strings.LastIndex(base, "/")+1:]
+		base = strings.TrimSuffix(base, ".lox")
+		if !isIdentifier(base) {
+			return nil, ParseError{Token: path, Message: "Expect 'as' and a name for this module."}
+		}
+		name = token.Token{Type: token.IDENTIFIER, Lexeme: base, Line: path.Line, Column: path.Column}
+	}
+
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after import."); err != nil {
+		return nil, err
+	}
+	return expression.NewImport(keyword, path, name, keyword.Line), nil
 }
+
+func isIdentifier(s string) bool {
+	tokens, err := scanner.NewScanner(s).ScanTokens()
+	return err == nil && len(tokens) == 2 && tokens[0].Type == token.IDENTIFIER && tokens[0].Lexeme == s
+}
+
 func (p *Parser) whileStatement() (expression.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.consume(token.LEFT_PAREN, "Expected '(' after 'while'.")
 	if err != nil {
 		return nil, err
@@ -80,14 +350,18 @@ func (p *Parser) whileStatement() (expression.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return expression.NewWhile(condition, body), nil
+	return expression.NewWhile(condition, body, keyword.Line), nil
 
 }
 func (p *Parser) forStatement() (expression.Stmt, error) {
-	_, err := p.consume(token.LEFT_PAREN, "Expected '(' after 'while'.")
+	keyword := p.previous()
+	_, err := p.consume(token.LEFT_PAREN, "Expected '(' after 'for'.")
 	if err != nil {
 		return nil, err
 	}
+	if p.check(token.IDENTIFIER) && p.checkNext(token.IN) {
+		return p.forInStatement(keyword)
+	}
 
 	var initializer expression.Stmt
 	if p.match(token.SEMICOLON) {
@@ -134,38 +408,64 @@ func (p *Parser) forStatement() (expression.Stmt, error) {
 		return nil, err
 	}
 	if increment != nil {
-		body = expression.NewBlock([]expression.Stmt{body, expression.NewExpression(increment)})
+		body = expression.NewBlock([]expression.Stmt{body, expression.NewExpression(increment, keyword.Line)}, keyword.Line)
 	}
 
 	if condition == nil {
 		condition = expression.NewLiteral(true)
 	}
-	body = expression.NewWhile(condition, body)
+	body = expression.NewWhile(condition, body, keyword.Line)
 
 	if initializer != nil {
-		body = expression.NewBlock([]expression.Stmt{initializer, body})
+		body = expression.NewBlock([]expression.Stmt{initializer, body}, keyword.Line)
 	}
 	return body, nil
 }
 
+// forInStatement parses the rest of `for (name in iterable) body`. Unlike
+// the C-style loop it is not desugared, since each iteration needs a fresh
+// variable.
+func (p *Parser) forInStatement(keyword token.Token) (expression.Stmt, error) {
+	name := p.advance()
+	p.advance()
+	iterable, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after for-in iterable."); err != nil {
+		return nil, err
+	}
+	body, err := p.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return expression.NewForIn(keyword, name, iterable, body, keyword.Line), nil
+}
+
 func (p *Parser) printStatement() (expression.Stmt, error) {
+	keyword := p.previous()
 	value, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.SEMICOLON, "Expect ';' after value.")
-	return expression.NewPrint(value), nil
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after value."); err != nil {
+		return nil, err
+	}
+	return expression.NewPrint(value, keyword.Line), nil
 }
 
 func (p *Parser) expressionStatement() (expression.Stmt, error) {
+	line := p.peek().Line
 	value, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
 
-	p.consume(token.SEMICOLON, "Expect ';' after value.")
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after expression."); err != nil {
+		return nil, err
+	}
 
-	return expression.NewExpression(value), nil
+	return expression.NewExpression(value, line), nil
 }
 
 func (p *Parser) block() ([]expression.Stmt, error) {
@@ -180,18 +480,25 @@ func (p *Parser) block() ([]expression.Stmt, error) {
 		stmts = append(stmts, stmt)
 	}
 
-	p.consume(token.RIGHT_BRACE, " Expected '}' after block.\n")
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after block."); err != nil {
+		return nil, err
+	}
 
 	return stmts, nil
 }
 
 func (p *Parser) ifStatement() (expression.Stmt, error) {
-	p.consume(token.LEFT_PAREN, "Expect '(' after 'if'.")
+	keyword := p.previous()
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'if'."); err != nil {
+		return nil, err
+	}
 	condition, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
-	p.consume(token.RIGHT_PAREN, "Expect ')' after if condition")
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after if condition."); err != nil {
+		return nil, err
+	}
 
 	thenBranch, err := p.Statement()
 	if err != nil {
@@ -205,39 +512,219 @@ func (p *Parser) ifStatement() (expression.Stmt, error) {
 		}
 	}
 
-	return expression.NewIf(condition, thenBranch, elseBranch), nil
+	return expression.NewIf(condition, thenBranch, elseBranch, keyword.Line), nil
 }
 
-func (p *Parser) or() (expression.Expr, error) {
-	expr, err := p.and()
+func (p *Parser) matchStatement() (expression.Stmt, error) {
+	keyword := p.previous()
+	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'match'."); err != nil {
+		return nil, err
+	}
+	subject, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
-	for p.match(token.OR) {
-		operator := p.previous()
-		right, err := p.equality()
+	if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after match subject."); err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' before match cases."); err != nil {
+		return nil, err
+	}
+
+	var cases []*expression.MatchCase
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		if _, err := p.consume(token.CASE, "Expect 'case'."); err != nil {
+			return nil, err
+		}
+		c, err := p.matchCase()
 		if err != nil {
 			return nil, err
 		}
-		expr = expression.NewLogical(expr, operator, right)
+		for _, earlier := range cases {
+			if earlier.Covers(c) {
+				p.warn(c.Keyword, fmt.Sprintf("Case can never match; the case on line %d covers it", earlier.Keyword.Line))
+				break
+			}
+		}
+		cases = append(cases, c)
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after match cases."); err != nil {
+		return nil, err
 	}
 
-	return expr, nil
+	return expression.NewMatch(keyword, subject, cases, keyword.Line), nil
 }
 
-func (p *Parser) and() (expression.Expr, error) {
-	expr, err := p.equality()
+func (p *Parser) matchCase() (*expression.MatchCase, error) {
+	c := &expression.MatchCase{Keyword: p.previous()}
+	for {
+		pattern, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		c.Patterns = append(c.Patterns, pattern)
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if names := c.Bindings(); len(names) > 0 && len(c.Patterns) > 1 {
+		return nil, ParseError{Token: names[0], Message: "Cannot bind a name in alternative patterns"}
+	}
+
+	if p.match(token.IF) {
+		guard, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		c.Guard = guard
+	}
+	if _, err := p.consume(token.ARROW, "Expect '=>' after case pattern."); err != nil {
+		return nil, err
+	}
+	body, err := p.Statement()
 	if err != nil {
 		return nil, err
 	}
-	for p.match(token.AND) {
-		operator := p.previous()
-		right, err := p.equality()
+	c.Body = body
+	return c, nil
+}
+
+// pattern parses a literal, which may be a negative number, the wildcard _,
+// a name to bind, or a list or map pattern like those of destructuring.
+func (p *Parser) pattern() (expression.Pattern, error) {
+	switch {
+	case p.check(token.LEFT_BRACKET), p.check(token.LEFT_BRACE):
+		target, err := p.target()
 		if err != nil {
 			return nil, err
 		}
-		expr = expression.NewLogical(expr, operator, right)
+		return &expression.DestructurePattern{Target: target}, nil
+	case p.match(token.NUMBER, token.STRING):
+		return &expression.LiteralPattern{Token: p.previous(), Value: p.previous().Literal}, nil
+	case p.match(token.TRUE):
+		return &expression.LiteralPattern{Token: p.previous(), Value: true}, nil
+	case p.match(token.FALSE):
+		return &expression.LiteralPattern{Token: p.previous(), Value: false}, nil
+	case p.match(token.NIL):
+		return &expression.LiteralPattern{Token: p.previous(), Value: nil}, nil
+	case p.match(token.MINUS):
+		minus := p.previous()
+		number, err := p.consume(token.NUMBER, "Expect number after '-' in pattern.")
+		if err != nil {
+			return nil, err
+		}
+		return &expression.LiteralPattern{Token: minus, Value: -number.Literal.(float64)}, nil
+	case p.match(token.IDENTIFIER):
+		if p.previous().Lexeme == "_" {
+			return &expression.WildcardPattern{Token: p.previous()}, nil
+		}
+		return &expression.BindingPattern{Name: p.previous()}, nil
 	}
+	return nil, ParseError{Token: p.peek(), Message: "Expect pattern."}
+}
 
-	return expr, nil
+// spawnStatement parses `spawn f(x);`, which calls f on a goroutine of its
+// own.
+func (p *Parser) spawnStatement() (expression.Stmt, error) {
+	keyword := p.previous()
+	expr, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*expression.Call)
+	if !ok || call.Optional {
+		return nil, ParseError{Token: keyword, Message: "Expect function call after 'spawn'."}
+	}
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after spawn call."); err != nil {
+		return nil, err
+	}
+	return expression.NewSpawn(keyword, call, keyword.Line), nil
+}
+
+func (p *Parser) selectStatement() (expression.Stmt, error) {
+	keyword := p.previous()
+	if _, err := p.consume(token.LEFT_BRACE, "Expect '{' after 'select'."); err != nil {
+		return nil, err
+	}
+
+	var cases []*expression.SelectCase
+	hasDefault := false
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		if _, err := p.consume(token.CASE, "Expect 'case'."); err != nil {
+			return nil, err
+		}
+		c, err := p.selectCase()
+		if err != nil {
+			return nil, err
+		}
+		if c.IsDefault() {
+			if hasDefault {
+				return nil, ParseError{Token: c.Operation, Message: "A select can have only one default case."}
+			}
+			hasDefault = true
+		}
+		cases = append(cases, c)
+	}
+	if _, err := p.consume(token.RIGHT_BRACE, "Expect '}' after select cases."); err != nil {
+		return nil, err
+	}
+
+	return expression.NewSelect(keyword, cases, keyword.Line), nil
+}
+
+// selectCase parses `name = recv(channel)`, `recv(channel)`,
+// `send(channel, value)` or `_`, then the case body. recv and send are not
+// keywords, so they can still name variables elsewhere.
+func (p *Parser) selectCase() (*expression.SelectCase, error) {
+	c := &expression.SelectCase{Keyword: p.previous()}
+	if p.check(token.IDENTIFIER) && p.checkNext(token.EQUAL) {
+		name := p.advance()
+		c.Name = &name
+		p.advance()
+	}
+
+	operation, err := p.consume(token.IDENTIFIER, "Expect recv, send or _ in select case.")
+	if err != nil {
+		return nil, err
+	}
+	c.Operation = operation
+	switch operation.Lexeme {
+	case "_":
+		if c.Name != nil {
+			return nil, ParseError{Token: operation, Message: "Only a recv case can bind a name."}
+		}
+	case "recv", "send":
+		if c.Name != nil && operation.Lexeme == "send" {
+			return nil, ParseError{Token: operation, Message: "Only a recv case can bind a name."}
+		}
+		if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after '"+operation.Lexeme+"'."); err != nil {
+			return nil, err
+		}
+		if c.Channel, err = p.assignment(); err != nil {
+			return nil, err
+		}
+		if operation.Lexeme == "send" {
+			if _, err := p.consume(token.COMMA, "Expect ',' after channel."); err != nil {
+				return nil, err
+			}
+			if c.Value, err = p.assignment(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.consume(token.RIGHT_PAREN, "Expect ')' after '"+operation.Lexeme+"' arguments."); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ParseError{Token: operation, Message: "Expect recv, send or _ in select case."}
+	}
+
+	if _, err := p.consume(token.ARROW, "Expect '=>' after select case."); err != nil {
+		return nil, err
+	}
+	body, err := p.Statement()
+	if err != nil {
+		return nil, err
+	}
+	c.Body = body
+	return c, nil
 }
@@ -0,0 +1,196 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+
+	"interpreter/internal/expression"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format read
+// by `go tool pprof`. Each script function becomes a function, with the top
+// level of the script as "main", and each line of one a location in it;
+// samples carry the statement count and self time of a stack of locations.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	table := newStringTable()
+	var profile protoBuffer
+
+	for _, sampleType := range [][2]string{{"statements", "count"}, {"time", "nanoseconds"}} {
+		var vt protoBuffer
+		vt.int64(1, table.index(sampleType[0]))
+		vt.int64(2, table.index(sampleType[1]))
+		profile.message(1, vt)
+	}
+
+	locations := map[location]uint64{}
+	functions := map[*expression.Function]uint64{}
+	for _, s := range p.sortedSamples() {
+		ids := make([]uint64, len(s.stack))
+		for i, l := range s.stack {
+			id, ok := locations[l]
+			if !ok {
+				id = uint64(len(locations) + 1)
+				locations[l] = id
+			}
+			if _, ok := functions[l.fn]; !ok {
+				functions[l.fn] = uint64(len(functions) + 1)
+			}
+			ids[i] = id
+		}
+
+		var sample protoBuffer
+		sample.packedUint64(1, ids)
+		sample.packedInt64(2, []int64{s.count, int64(s.self)})
+		profile.message(2, sample)
+	}
+
+	sorted := make([]location, 0, len(locations))
+	for l := range locations {
+		sorted = append(sorted, l)
+	}
+	sort.Slice(sorted, func(a, b int) bool { return locations[sorted[a]] < locations[sorted[b]] })
+	for _, l := range sorted {
+		var line protoBuffer
+		line.uint64(1, functions[l.fn])
+		line.int64(2, int64(l.line))
+
+		var loc protoBuffer
+		loc.uint64(1, locations[l])
+		loc.message(4, line)
+		profile.message(4, loc)
+	}
+
+	fns := make([]*expression.Function, 0, len(functions))
+	for fn := range functions {
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(a, b int) bool { return functions[fns[a]] < functions[fns[b]] })
+	for _, fn := range fns {
+		start := 1
+		if fn != nil {
+			start = fn.Line()
+		}
+		var function protoBuffer
+		function.uint64(1, functions[fn])
+		function.int64(2, table.index(functionName(fn)))
+		function.int64(3, table.index(functionName(fn)))
+		function.int64(4, table.index(p.filename))
+		function.int64(5, int64(start))
+		profile.message(5, function)
+	}
+
+	var period protoBuffer
+	period.int64(1, table.index("statements"))
+	period.int64(2, table.index("count"))
+	defaultSampleType := table.index("time")
+
+	// The string table is complete once everything above has been indexed.
+	for _, s := range table.values {
+		profile.bytes(6, []byte(s))
+	}
+	profile.int64(9, p.started.UnixNano())
+	profile.int64(10, int64(p.now().Sub(p.started)))
+	profile.message(11, period)
+	profile.int64(12, 1)
+	profile.int64(14, defaultSampleType)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (p *Profiler) sortedSamples() []*sample {
+	samples := make([]*sample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(a, b int) bool {
+		x, y := samples[a].stack, samples[b].stack
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if x[i].line != y[i].line {
+				return x[i].line < y[i].line
+			}
+			if x[i].fn != y[i].fn {
+				return functionName(x[i].fn) < functionName(y[i].fn)
+			}
+		}
+		return len(x) < len(y)
+	})
+	return samples
+}
+
+type stringTable struct {
+	values  []string
+	indices map[string]int64
+}
+
+func newStringTable() *stringTable {
+	// pprof requires the first entry to be the empty string.
+	return &stringTable{values: []string{""}, indices: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indices[s]; ok {
+		return i
+	}
+	i := int64(len(t.values))
+	t.values = append(t.values, s)
+	t.indices[s] = i
+	return i
+}
+
+// protoBuffer encodes the handful of protocol buffer wire types a profile
+// needs.
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.data = append(b.data, byte(v)|0x80)
+		v >>= 7
+	}
+	b.data = append(b.data, byte(v))
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *protoBuffer) uint64(field int, v uint64) {
+	b.key(field, 0)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m.data)
+}
+
+func (b *protoBuffer) packedUint64(field int, values []uint64) {
+	var packed protoBuffer
+	for _, v := range values {
+		packed.varint(v)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *protoBuffer) packedInt64(field int, values []int64) {
+	var packed protoBuffer
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	b.bytes(field, packed.data)
+}
@@ -0,0 +1,317 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"interpreter/internal/environment"
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+)
+
+// LineStats accumulates what happened on one source line.
+type LineStats struct {
+	Line  int
+	Count int64
+	// Self is the time spent in statements starting on the line, excluding
+	// nested statements on other lines, and in calls of a function declared
+	// on it. Total includes them.
+	Self  time.Duration
+	Total time.Duration
+}
+
+// FunctionStats accumulates the calls of one script function.
+type FunctionStats struct {
+	Name  string
+	Line  int
+	Calls int64
+	// Self is the time spent in the function's own statements and in
+	// calling it, excluding the functions it calls. Total includes them.
+	Self  time.Duration
+	Total time.Duration
+}
+
+// frame is a statement being executed or, when stmt is nil, a call whose
+// body is running. fn is the function the statement runs in or the one
+// called, and nil for the top level of the script.
+type frame struct {
+	stmt  expression.Stmt
+	fn    *expression.Function
+	start time.Time
+	child time.Duration
+}
+
+// Profiler is an interpreter.CallHook that counts and times statements by
+// line and calls by function. Nothing is measured unless it is installed, so
+// an unprofiled run pays only for the interpreter's nil check. Each
+// goroutine of the script keeps a stack of its own, and all of them add to
+// the same statistics.
+type Profiler struct {
+	filename string
+	lines    []string
+	now      func() time.Time
+	started  time.Time
+
+	stack     []frame
+	stats     map[int]*LineStats
+	functions map[*expression.Function]*FunctionStats
+	samples   map[string]*sample
+}
+
+// location is a line of a function, or of the top level when fn is nil.
+type location struct {
+	fn   *expression.Function
+	line int
+}
+
+// sample aggregates every execution with the same stack of locations.
+type sample struct {
+	stack []location
+	count int64
+	self  time.Duration
+}
+
+func New(filename, source string) *Profiler {
+	p := &Profiler{
+		filename:  filename,
+		lines:     strings.Split(source, "\n"),
+		now:       time.Now,
+		stats:     map[int]*LineStats{},
+		functions: map[*expression.Function]*FunctionStats{},
+		samples:   map[string]*sample{},
+	}
+	p.started = p.now()
+	return p
+}
+
+// Thread returns a profiler for another goroutine, with a stack of its own.
+func (p *Profiler) Thread() interpreter.Hook {
+	t := *p
+	t.stack = nil
+	return &t
+}
+
+func (p *Profiler) BeforeStatement(stmt expression.Stmt, env *environment.Environment) {
+	p.stack = append(p.stack, frame{stmt: stmt, fn: p.function(), start: p.now()})
+}
+
+func (p *Profiler) AfterStatement(stmt expression.Stmt) {
+	top, elapsed, self := p.pop()
+
+	line := stmt.Line()
+	stats := p.line(line)
+	stats.Count++
+	stats.Self += self
+	// A line's total must not count a nested statement on the same line twice.
+	if !p.onStack(line) {
+		stats.Total += elapsed
+	}
+	if top.fn != nil {
+		p.functionStats(top.fn).Self += self
+	}
+
+	p.record(location{top.fn, line}, self)
+}
+
+func (p *Profiler) EnterFunction(fn *expression.Function) {
+	p.stack = append(p.stack, frame{fn: fn, start: p.now()})
+}
+
+func (p *Profiler) ExitFunction(fn *expression.Function) {
+	_, elapsed, self := p.pop()
+
+	stats := p.functionStats(fn)
+	stats.Calls++
+	stats.Self += self
+	// Likewise a recursive call must not count twice.
+	if !p.calling(fn) {
+		stats.Total += elapsed
+	}
+
+	// The call's own time, binding arguments and the like, belongs to the
+	// line declaring the function, so that the lines and the samples add up
+	// to the same time as the functions.
+	line := p.line(fn.Line())
+	line.Self += self
+	line.Total += self
+	p.record(location{fn, fn.Line()}, self)
+}
+
+// pop removes the innermost frame, returning it with the time since it was
+// pushed and the part of that not spent in nested frames.
+func (p *Profiler) pop() (top frame, elapsed, self time.Duration) {
+	top = p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	elapsed = p.now().Sub(top.start)
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].child += elapsed
+	}
+	return top, elapsed, elapsed - top.child
+}
+
+// function returns the function the current statement runs in.
+func (p *Profiler) function() *expression.Function {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1].fn
+}
+
+func (p *Profiler) line(line int) *LineStats {
+	stats, ok := p.stats[line]
+	if !ok {
+		stats = &LineStats{Line: line}
+		p.stats[line] = stats
+	}
+	return stats
+}
+
+func (p *Profiler) functionStats(fn *expression.Function) *FunctionStats {
+	stats, ok := p.functions[fn]
+	if !ok {
+		stats = &FunctionStats{Name: functionName(fn), Line: fn.Line()}
+		p.functions[fn] = stats
+	}
+	return stats
+}
+
+// functionName names a function in the report and the pprof file. Lambdas
+// have no name of their own.
+func functionName(fn *expression.Function) string {
+	if fn == nil {
+		return "main"
+	}
+	if fn.Name.Lexeme == "" {
+		return fmt.Sprintf("lambda@%d", fn.Line())
+	}
+	return fn.Name.Lexeme
+}
+
+func (p *Profiler) onStack(line int) bool {
+	for _, f := range p.stack {
+		if f.stmt != nil && f.stmt.Line() == line {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profiler) calling(fn *expression.Function) bool {
+	for _, f := range p.stack {
+		if f.stmt == nil && f.fn == fn {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds one execution to the sample for the current stack of
+// locations, innermost first as pprof expects.
+func (p *Profiler) record(at location, self time.Duration) {
+	stack := []location{at}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		f := p.stack[i]
+		if f.stmt == nil {
+			continue
+		}
+		if l := (location{f.fn, f.stmt.Line()}); l != stack[len(stack)-1] {
+			stack = append(stack, l)
+		}
+	}
+	key := fmt.Sprint(stack)
+	s, ok := p.samples[key]
+	if !ok {
+		s = &sample{stack: stack}
+		p.samples[key] = s
+	}
+	s.count++
+	s.self += self
+}
+
+// Lines returns the statistics for every executed line, hottest first.
+func (p *Profiler) Lines() []LineStats {
+	lines := make([]LineStats, 0, len(p.stats))
+	for _, stats := range p.stats {
+		lines = append(lines, *stats)
+	}
+	sort.Slice(lines, func(a, b int) bool {
+		if lines[a].Self != lines[b].Self {
+			return lines[a].Self > lines[b].Self
+		}
+		if lines[a].Count != lines[b].Count {
+			return lines[a].Count > lines[b].Count
+		}
+		return lines[a].Line < lines[b].Line
+	})
+	return lines
+}
+
+// Functions returns the statistics for every called function, hottest
+// first.
+func (p *Profiler) Functions() []FunctionStats {
+	functions := make([]FunctionStats, 0, len(p.functions))
+	for _, stats := range p.functions {
+		functions = append(functions, *stats)
+	}
+	sort.Slice(functions, func(a, b int) bool {
+		if functions[a].Self != functions[b].Self {
+			return functions[a].Self > functions[b].Self
+		}
+		if functions[a].Calls != functions[b].Calls {
+			return functions[a].Calls > functions[b].Calls
+		}
+		return functions[a].Line < functions[b].Line
+	})
+	return functions
+}
+
+// WriteReport prints a table of lines sorted by self time, followed by a
+// table of the functions called, if any, likewise sorted.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	var total time.Duration
+	lines := p.Lines()
+	for _, stats := range lines {
+		total += stats.Self
+	}
+	percent := func(self time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(self) / float64(total)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "line\tcount\tself\tself%\ttotal\t  source")
+	for _, stats := range lines {
+		fmt.Fprintf(tw, "%d\t%d\t%v\t%.1f%%\t%v\t  %s\n",
+			stats.Line, stats.Count, stats.Self, percent(stats.Self), stats.Total, p.source(stats.Line))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	functions := p.Functions()
+	if len(functions) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "line\tcalls\tself\tself%\ttotal\t  function")
+	for _, stats := range functions {
+		fmt.Fprintf(tw, "%d\t%d\t%v\t%.1f%%\t%v\t  %s\n",
+			stats.Line, stats.Calls, stats.Self, percent(stats.Self), stats.Total, stats.Name)
+	}
+	return tw.Flush()
+}
+
+func (p *Profiler) source(line int) string {
+	if line < 1 || line > len(p.lines) {
+		return ""
+	}
+	return strings.TrimSpace(p.lines[line-1])
+}
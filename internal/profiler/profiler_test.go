@@ -0,0 +1,175 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"interpreter/internal/interpreter"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+const program = `var i = 0;
+while (i < 3) {
+  i = i + 1;
+}
+print i;
+`
+
+const calls = `fun square(n) {
+  return n * n;
+}
+fun fact(n) {
+  if (n <= 1) return 1;
+  return n * fact(n - 1);
+}
+for (x in range(0, 3, 1)) print square(x);
+print fact(3);
+`
+
+func profile(t *testing.T, program string) *Profiler {
+	t.Helper()
+	tokens, err := scanner.NewScanner(program).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New("test.lox", program)
+	// Every reading of the clock advances it by a millisecond.
+	clock := time.Unix(0, 0)
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	i := interpreter.NewInterpreter()
+	i.SetOutput(io.Discard)
+	i.SetHook(p)
+	if err := i.Interpret(statements); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLines(t *testing.T) {
+	counts := map[int]int64{}
+	for _, stats := range profile(t, program).Lines() {
+		counts[stats.Line] = stats.Count
+	}
+	want := map[int]int64{1: 1, 2: 4, 3: 3, 5: 1}
+	for line, count := range want {
+		if counts[line] != count {
+			t.Errorf("line %d count = %d, want %d", line, counts[line], count)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var out strings.Builder
+	if err := profile(t, program).WriteReport(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("report has %d lines, want a header and 4 rows:\n%s", len(lines), out.String())
+	}
+	// The loop header includes the time of its three block executions.
+	if !strings.Contains(lines[1], "while (i < 3) {") {
+		t.Errorf("hottest line = %q, want the while loop", lines[1])
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t, program).WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"statements", "nanoseconds", "main", "test.lox"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("profile does not contain the string %q", s)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	got := map[string]int64{}
+	for _, stats := range profile(t, calls).Functions() {
+		got[stats.Name] = stats.Calls
+		if stats.Self <= 0 || stats.Total < stats.Self {
+			t.Errorf("%s self = %v, total = %v", stats.Name, stats.Self, stats.Total)
+		}
+	}
+	want := map[string]int64{"square": 3, "fact": 3}
+	if len(got) != len(want) || got["square"] != want["square"] || got["fact"] != want["fact"] {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+
+	var out strings.Builder
+	if err := profile(t, calls).WriteReport(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "calls") || !strings.Contains(out.String(), "square") {
+		t.Errorf("report has no function table:\n%s", out.String())
+	}
+}
+
+func TestWritePprofFunctions(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t, calls).WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"main", "square", "fact"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("profile does not contain the function %q", s)
+		}
+	}
+}
+
+// TestSelfTimesAgree checks that the lines, the functions and the pprof
+// samples account for the same time, including the time of the calls
+// themselves.
+func TestSelfTimesAgree(t *testing.T) {
+	p := profile(t, calls)
+
+	var lines, samples time.Duration
+	for _, stats := range p.Lines() {
+		lines += stats.Self
+	}
+	inside := map[string]time.Duration{}
+	for _, s := range p.samples {
+		samples += s.self
+		inside[functionName(s.stack[0].fn)] += s.self
+	}
+	if lines != samples {
+		t.Errorf("lines self = %v, samples self = %v", lines, samples)
+	}
+	for _, stats := range p.Functions() {
+		if inside[stats.Name] != stats.Self {
+			t.Errorf("%s self = %v, samples in it = %v", stats.Name, stats.Self, inside[stats.Name])
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package resolver
+
+import (
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+type BindingKind int
+
+const (
+	Variable BindingKind = iota
+	Function
+	Parameter
+)
+
+// Binding is a single variable declaration and everything that refers to it.
+type Binding struct {
+	Kind       BindingKind
+	Name       token.Token
+	Depth      int
+	Shadows    *Binding
+	References []*Reference
+	// Loops holds every loop enclosing the declaration, innermost last. Each
+	// is an *expression.While or an *expression.ForIn.
+	Loops []expression.Stmt
+	// Function is the innermost *expression.Function or *expression.Test
+	// containing the declaration, or nil at the top level.
+	Function expression.Stmt
+}
+
+// Reads returns the references that read the binding's value.
+func (b *Binding) Reads() []*Reference {
+	return b.filter(Read)
+}
+
+// Writes returns the references that assign a new value to the binding.
+func (b *Binding) Writes() []*Reference {
+	return b.filter(Write)
+}
+
+func (b *Binding) filter(kind ReferenceKind) []*Reference {
+	var refs []*Reference
+	for _, ref := range b.References {
+		if ref.Kind == kind {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+type ReferenceKind int
+
+const (
+	Read ReferenceKind = iota
+	Write
+)
+
+// Reference is a use of a variable name inside an expression.
+type Reference struct {
+	Kind    ReferenceKind
+	Name    token.Token
+	Binding *Binding
+	// Expr is the Variable, Assign or Destructure making the reference.
+	Expr expression.Expr
+	// Depth is the number of scopes enclosing the reference, 0 at the top
+	// level.
+	Depth int
+	// Seq orders references by the point at which they are evaluated.
+	Seq int
+	// Loops holds every loop enclosing the reference within its function,
+	// innermost last.
+	Loops []expression.Stmt
+	// Function is the innermost function or test containing the reference.
+	// Code in another function may run at any time relative to it.
+	Function expression.Stmt
+	// Region identifies the innermost conditionally executed piece of code
+	// containing the reference. References in the same region run in Seq
+	// order whenever any of them runs.
+	Region int
+}
+
+// Distance returns how many scopes out from the reference its binding is
+// declared. A global, or a name declared nowhere, is at the top level.
+func (ref *Reference) Distance() int {
+	if ref.Binding == nil {
+		return ref.Depth
+	}
+	return ref.Depth - ref.Binding.Depth
+}
+
+// Key identifies a reference: a destructuring assignment makes one for each
+// name it stores into.
+type Key struct {
+	Expr expression.Expr
+	Name string
+}
+
+// Resolution is the result of resolving a list of statements.
+type Resolution struct {
+	Bindings   []*Binding
+	References []*Reference
+	Unresolved []*Reference
+}
+
+// Distances maps every reference to its Distance, for looking variables up
+// in the scope that declares them rather than the nearest that defines the
+// name.
+func (r *Resolution) Distances() map[Key]int {
+	distances := make(map[Key]int, len(r.References))
+	for _, ref := range r.References {
+		distances[Key{ref.Expr, ref.Name.Lexeme}] = ref.Distance()
+	}
+	return distances
+}
+
+type Resolver struct {
+	scopes     []map[string]*Binding
+	loops      []expression.Stmt
+	function   expression.Stmt
+	seq        int
+	region     int
+	regions    int
+	resolution *Resolution
+}
+
+// Resolve binds every variable reference in statements to its declaration.
+// Globals are bound late, so a top-level reference may precede its declaration.
+func Resolve(statements []expression.Stmt) *Resolution {
+	r := &Resolver{
+		scopes:     []map[string]*Binding{{}},
+		resolution: &Resolution{},
+	}
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+	r.resolveGlobals()
+	return r.resolution
+}
+
+func (r *Resolver) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	r.resolveExpr(stmt.Expr)
+	return nil
+}
+
+func (r *Resolver) VisitPrintStmt(stmt *expression.Print) interface{} {
+	r.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitVarStmt(stmt *expression.Var) interface{} {
+	if stmt.Initializer != nil {
+		r.resolveExpr(stmt.Initializer)
+	}
+	r.declare(stmt.Name, Variable)
+	return nil
+}
+
+func (r *Resolver) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	r.resolveExpr(stmt.Initializer)
+	for _, name := range stmt.Pattern.Names() {
+		r.declare(name, Variable)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	r.declare(stmt.Name, Function)
+	r.resolveFunction(stmt, stmt.Params, stmt.Body)
+	return nil
+}
+
+func (r *Resolver) VisitReturnStmt(stmt *expression.Return) interface{} {
+	if stmt.Value != nil {
+		r.resolveExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value != nil {
+		r.resolveExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitTestStmt(stmt *expression.Test) interface{} {
+	r.resolveFunction(stmt, nil, stmt.Body)
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt *expression.While) interface{} {
+	r.loops = append(r.loops, stmt)
+	r.branch(func() {
+		r.resolveExpr(stmt.Condition)
+		r.branch(func() { r.resolveStmt(stmt.Body) })
+	})
+	r.loops = r.loops[:len(r.loops)-1]
+	return nil
+}
+
+// VisitForInStmt declares the loop variable inside the loop, since each
+// iteration has a fresh one.
+func (r *Resolver) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	r.resolveExpr(stmt.Iterable)
+	r.loops = append(r.loops, stmt)
+	r.branch(func() {
+		r.beginScope()
+		r.declare(stmt.Name, Variable)
+		r.resolveStmt(stmt.Body)
+		r.endScope()
+	})
+	r.loops = r.loops[:len(r.loops)-1]
+	return nil
+}
+
+func (r *Resolver) VisitBlockStmt(stmt *expression.Block) interface{} {
+	r.beginScope()
+	for _, s := range stmt.Statements {
+		r.resolveStmt(s)
+	}
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt *expression.If) interface{} {
+	r.resolveExpr(stmt.Condition)
+	r.branch(func() { r.resolveStmt(stmt.ThenBranch) })
+	if stmt.ElseBranch != nil {
+		r.branch(func() { r.resolveStmt(stmt.ElseBranch) })
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMatchStmt(stmt *expression.Match) interface{} {
+	r.resolveExpr(stmt.Subject)
+	for _, c := range stmt.Cases {
+		r.branch(func() {
+			r.beginScope()
+			for _, name := range c.Bindings() {
+				r.declare(name, Variable)
+			}
+			if c.Guard != nil {
+				r.resolveExpr(c.Guard)
+			}
+			r.branch(func() { r.resolveStmt(c.Body) })
+			r.endScope()
+		})
+	}
+	return nil
+}
+
+func (r *Resolver) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	r.resolveExpr(stmt.Call)
+	return nil
+}
+
+// VisitSelectStmt resolves the operands of every case up front, since they
+// are all evaluated, and each body as a branch of its own.
+func (r *Resolver) VisitSelectStmt(stmt *expression.Select) interface{} {
+	for _, c := range stmt.Cases {
+		if c.Channel != nil {
+			r.resolveExpr(c.Channel)
+		}
+		if c.Value != nil {
+			r.resolveExpr(c.Value)
+		}
+	}
+	for _, c := range stmt.Cases {
+		r.branch(func() {
+			r.beginScope()
+			if c.Name != nil {
+				r.declare(*c.Name, Variable)
+			}
+			r.resolveStmt(c.Body)
+			r.endScope()
+		})
+	}
+	return nil
+}
+
+func (r *Resolver) VisitImportStmt(stmt *expression.Import) interface{} {
+	r.declare(stmt.Name, Variable)
+	return nil
+}
+
+func (r *Resolver) VisitAssignExpr(expr *expression.Assign) interface{} {
+	r.resolveExpr(expr.Value)
+	r.reference(expr, expr.Name, Write)
+	return nil
+}
+
+func (r *Resolver) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	r.resolveExpr(expr.Value)
+	for _, name := range expr.Pattern.Names() {
+		r.reference(expr, name, Write)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		r.resolveTarget(expr.Target, func() { r.branch(func() { r.resolveExpr(expr.Value) }) })
+		return nil
+	}
+	r.resolveTarget(expr.Target, func() { r.resolveExpr(expr.Value) })
+	return nil
+}
+
+func (r *Resolver) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	r.resolveTarget(expr.Target, func() {})
+	return nil
+}
+
+// resolveTarget resolves a target that is read, then updated once operand
+// has been resolved.
+func (r *Resolver) resolveTarget(target expression.Expr, operand func()) {
+	variable, ok := target.(*expression.Variable)
+	if !ok {
+		r.resolveExpr(target)
+		operand()
+		return
+	}
+	r.reference(variable, variable.Name, Read)
+	operand()
+	r.reference(variable, variable.Name, Write)
+}
+
+func (r *Resolver) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(expr *expression.Call) interface{} {
+	r.resolveExpr(expr.Callee)
+	r.optional(expr.Optional, func() {
+		for _, argument := range expr.Arguments {
+			r.resolveExpr(argument)
+		}
+	})
+	return nil
+}
+
+// optional resolves what follows a ?., which only runs when the object is
+// not nil, as a branch.
+func (r *Resolver) optional(optional bool, resolve func()) {
+	if optional {
+		r.branch(resolve)
+	} else {
+		resolve()
+	}
+}
+
+func (r *Resolver) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	r.resolveExpr(expr.Condition)
+	r.branch(func() { r.resolveExpr(expr.TrueExpression) })
+	r.branch(func() { r.resolveExpr(expr.FalseExpression) })
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(expr *expression.List) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(expr *expression.Map) interface{} {
+	for index, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[index])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(expr *expression.Get) interface{} {
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *expression.Index) interface{} {
+	r.resolveExpr(expr.Object)
+	r.optional(expr.Optional, func() { r.resolveExpr(expr.Key) })
+	return nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Key)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	r.resolveExpr(expr.Expr)
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	r.resolveExpr(expr.Left)
+	r.branch(func() { r.resolveExpr(expr.Right) })
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitAwaitExpr(expr *expression.Await) interface{} {
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	r.resolveFunction(expr.Function, expr.Function.Params, expr.Function.Body)
+	return nil
+}
+
+func (r *Resolver) VisitVariableExpr(expr *expression.Variable) interface{} {
+	r.reference(expr, expr.Name, Read)
+	return nil
+}
+
+func (r *Resolver) resolveStmt(stmt expression.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) resolveExpr(expr expression.Expr) {
+	expr.Accept(r)
+}
+
+// resolveFunction resolves a body that runs whenever fn is called, if ever,
+// in a scope holding its parameters.
+func (r *Resolver) resolveFunction(fn expression.Stmt, params []token.Token, body []expression.Stmt) {
+	enclosingFunction, enclosingLoops := r.function, r.loops
+	r.function, r.loops = fn, nil
+	r.branch(func() {
+		r.beginScope()
+		for _, param := range params {
+			r.declare(param, Parameter)
+		}
+		for _, stmt := range body {
+			r.resolveStmt(stmt)
+		}
+		r.endScope()
+	})
+	r.function, r.loops = enclosingFunction, enclosingLoops
+}
+
+// branch resolves code that may or may not run in a fresh region.
+func (r *Resolver) branch(resolve func()) {
+	enclosing := r.region
+	r.regions++
+	r.region = r.regions
+	resolve()
+	r.region = enclosing
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]*Binding{})
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) declare(name token.Token, kind BindingKind) {
+	depth := len(r.scopes) - 1
+	binding := &Binding{
+		Kind:     kind,
+		Name:     name,
+		Depth:    depth,
+		Loops:    append([]expression.Stmt(nil), r.loops...),
+		Function: r.function,
+	}
+	for i := depth - 1; i >= 0; i-- {
+		if outer, ok := r.scopes[i][name.Lexeme]; ok {
+			binding.Shadows = outer
+			break
+		}
+	}
+	r.scopes[depth][name.Lexeme] = binding
+	r.resolution.Bindings = append(r.resolution.Bindings, binding)
+}
+
+func (r *Resolver) reference(expr expression.Expr, name token.Token, kind ReferenceKind) {
+	r.seq++
+	ref := &Reference{
+		Kind:     kind,
+		Name:     name,
+		Expr:     expr,
+		Depth:    len(r.scopes) - 1,
+		Seq:      r.seq,
+		Loops:    append([]expression.Stmt(nil), r.loops...),
+		Function: r.function,
+		Region:   r.region,
+	}
+	r.resolution.References = append(r.resolution.References, ref)
+
+	// Globals are looked up once the whole program has been seen.
+	for i := len(r.scopes) - 1; i > 0; i-- {
+		if binding, ok := r.scopes[i][name.Lexeme]; ok {
+			r.bind(ref, binding)
+			return
+		}
+	}
+}
+
+func (r *Resolver) bind(ref *Reference, binding *Binding) {
+	ref.Binding = binding
+	binding.References = append(binding.References, ref)
+}
+
+func (r *Resolver) resolveGlobals() {
+	globals := r.scopes[0]
+	for _, ref := range r.resolution.References {
+		if ref.Binding != nil {
+			continue
+		}
+		if binding, ok := globals[ref.Name.Lexeme]; ok {
+			r.bind(ref, binding)
+		} else {
+			r.resolution.Unresolved = append(r.resolution.Unresolved, ref)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"interpreter/internal/token"
+)
+
+// addScripts seeds f with the conformance scripts.
+func addScripts(f *testing.F) {
+	root := filepath.Join("..", "..", "cmd", "myinterpreter", "testdata")
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".lox") {
+			if source, err := os.ReadFile(path); err == nil {
+				f.Add(string(source))
+			}
+		}
+		return nil
+	})
+}
+
+func FuzzScan(f *testing.F) {
+	addScripts(f)
+	f.Add("\"unterminated")
+	f.Add("1.")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens, err := NewScanner(source).ScanTokens()
+		if err != nil {
+			var scanErr ScanError
+			if !errors.As(err, &scanErr) {
+				t.Fatalf("error %v (%T) is not a ScanError", err, err)
+			}
+			return
+		}
+		if len(tokens) == 0 || tokens[len(tokens)-1].Type != token.EOF {
+			t.Fatalf("tokens do not end with EOF: %v", tokens)
+		}
+	})
+}
@@ -9,11 +9,27 @@ import (
 )
 
 type Scanner struct {
-	source  string
-	tokens  []token.Token
-	start   int
-	current int
-	line    int
+	source    string
+	tokens    []token.Token
+	start     int
+	current   int
+	line      int
+	lineStart int
+	// startLine and column are where the token being scanned starts.
+	startLine int
+	column    int
+}
+
+// ScanError reports a lexical error at the start of the offending token,
+// which for an unterminated string is its opening quote.
+type ScanError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ScanError) Error() string {
+	return fmt.Sprintf("%s at line %d", e.Message, e.Line)
 }
 
 var HadError = false
@@ -22,22 +38,41 @@ var keywords map[string]token.TokenType
 func init() {
 	keywords = map[string]token.TokenType{
 		"and":    token.AND,
+		"async":  token.ASYNC,
+		"await":  token.AWAIT,
+		"case":   token.CASE,
 		"class":  token.CLASS,
 		"else":   token.ELSE,
 		"false":  token.FALSE,
 		"for":    token.FOR,
 		"fun":    token.FUN,
 		"if":     token.IF,
+		"import": token.IMPORT,
+		"in":     token.IN,
+		"match":  token.MATCH,
 		"nil":    token.NIL,
 		"or":     token.OR,
 		"print":  token.PRINT,
 		"return": token.RETURN,
+		"select": token.SELECT,
+		"spawn":  token.SPAWN,
 		"super":  token.SUPER,
 		"this":   token.THIS,
 		"true":   token.TRUE,
 		"var":    token.VAR,
 		"while":  token.WHILE,
+		"yield":  token.YIELD,
+	}
+}
+
+// IsKeyword reports whether tokenType is produced for a reserved word.
+func IsKeyword(tokenType token.TokenType) bool {
+	for _, keyword := range keywords {
+		if keyword == tokenType {
+			return true
+		}
 	}
+	return false
 }
 
 func NewScanner(source string) *Scanner {
@@ -53,12 +88,14 @@ func NewScanner(source string) *Scanner {
 func (s *Scanner) ScanTokens() ([]token.Token, error) {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startLine = s.line
+		s.column = s.start - s.lineStart + 1
 		if err := s.scanToken(); err != nil {
 			return nil, err
 		}
 	}
 
-	s.tokens = append(s.tokens, token.Token{Type: token.EOF, Lexeme: "", Literal: nil, Line: s.line})
+	s.tokens = append(s.tokens, token.Token{Type: token.EOF, Lexeme: "", Literal: nil, Line: s.line, Column: s.current - s.lineStart + 1})
 	return s.tokens, nil
 }
 
@@ -73,18 +110,60 @@ func (s *Scanner) scanToken() error {
 		s.addToken(token.LEFT_BRACE)
 	case '}':
 		s.addToken(token.RIGHT_BRACE)
+	case '[':
+		s.addToken(token.LEFT_BRACKET)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET)
 	case ',':
 		s.addToken(token.COMMA)
 	case '.':
-		s.addToken(token.DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(token.DOT_DOT_DOT)
+		} else {
+			s.addToken(token.DOT)
+		}
 	case '-':
-		s.addToken(token.MINUS)
+		if s.match('-') {
+			s.addToken(token.MINUS_MINUS)
+		} else if s.match('=') {
+			s.addToken(token.MINUS_EQUAL)
+		} else {
+			s.addToken(token.MINUS)
+		}
 	case '+':
-		s.addToken(token.PLUS)
+		if s.match('+') {
+			s.addToken(token.PLUS_PLUS)
+		} else if s.match('=') {
+			s.addToken(token.PLUS_EQUAL)
+		} else {
+			s.addToken(token.PLUS)
+		}
 	case ';':
 		s.addToken(token.SEMICOLON)
 	case '*':
-		s.addToken(token.STAR)
+		if s.match('*') {
+			s.addToken(token.STAR_STAR)
+		} else if s.match('=') {
+			s.addToken(token.STAR_EQUAL)
+		} else {
+			s.addToken(token.STAR)
+		}
+	case '%':
+		if s.match('=') {
+			s.addToken(token.PERCENT_EQUAL)
+		} else {
+			s.addToken(token.PERCENT)
+		}
+	case '&':
+		s.addToken(token.AMPERSAND)
+	case '|':
+		s.addToken(token.PIPE)
+	case '^':
+		s.addToken(token.CARET)
+	case '~':
+		s.addToken(token.TILDE)
 	case '!':
 		if s.match('=') {
 			s.addToken(token.BANG_EQUAL)
@@ -94,17 +173,23 @@ func (s *Scanner) scanToken() error {
 	case '=':
 		if s.match('=') {
 			s.addToken(token.EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(token.ARROW)
 		} else {
 			s.addToken(token.EQUAL)
 		}
 	case '<':
-		if s.match('=') {
+		if s.match('<') {
+			s.addToken(token.LESS_LESS)
+		} else if s.match('=') {
 			s.addToken(token.LESS_EQUAL)
 		} else {
 			s.addToken(token.LESS)
 		}
 	case '>':
-		if s.match('=') {
+		if s.match('>') {
+			s.addToken(token.GREATER_GREATER)
+		} else if s.match('=') {
 			s.addToken(token.GREATER_EQUAL)
 		} else {
 			s.addToken(token.GREATER)
@@ -115,21 +200,29 @@ func (s *Scanner) scanToken() error {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+		} else if s.match('=') {
+			s.addToken(token.SLASH_EQUAL)
 		} else {
 			s.addToken(token.SLASH)
 		}
 	case ' ', '\r', '\t':
 		// Ignore whitespace.
 	case '\n':
-		s.line++
-	case 'o':
-		if s.match('r') {
-			s.addToken(token.OR)
-		}
+		s.newLine()
 	case '"':
 		return s.string()
 	case '?':
-		s.addToken(token.QUESTION_MARK)
+		if s.match('.') {
+			s.addToken(token.QUESTION_DOT)
+		} else if s.match('?') {
+			if s.match('=') {
+				s.addToken(token.QUESTION_QUESTION_EQUAL)
+			} else {
+				s.addToken(token.QUESTION_QUESTION)
+			}
+		} else {
+			s.addToken(token.QUESTION_MARK)
+		}
 	case ':':
 		s.addToken(token.COLON)
 	default:
@@ -140,7 +233,7 @@ func (s *Scanner) scanToken() error {
 		} else if isAlpha(c) {
 			s.identifier()
 		} else {
-			return fmt.Errorf("unexpected character: %c at line %d", c, s.line)
+			return s.error(fmt.Sprintf("unexpected character: %c", c))
 		}
 	}
 
@@ -148,6 +241,9 @@ func (s *Scanner) scanToken() error {
 }
 
 func (s *Scanner) advance() byte {
+	if s.isAtEnd() {
+		return 0
+	}
 	s.current++
 	return s.source[s.current-1]
 }
@@ -163,9 +259,19 @@ func (s *Scanner) addTokenWithLiteral(tokenType token.TokenType, literal interfa
 		Lexeme:  text,
 		Literal: literal,
 		Line:    s.line,
+		Column:  s.column,
 	})
 }
 
+func (s *Scanner) newLine() {
+	s.line++
+	s.lineStart = s.current
+}
+
+func (s *Scanner) error(message string) ScanError {
+	return ScanError{Line: s.startLine, Column: s.column, Message: message}
+}
+
 func (s *Scanner) match(expected byte) bool {
 	if s.isAtEnd() {
 		return false
@@ -184,6 +290,10 @@ func (s *Scanner) peek() byte {
 	return s.source[s.current]
 }
 
+func (s *Scanner) previous() byte {
+	return s.source[s.current-1]
+}
+
 func (s *Scanner) peekNext() byte {
 	if s.current+1 >= len(s.source) {
 		return 0
@@ -193,14 +303,14 @@ func (s *Scanner) peekNext() byte {
 
 func (s *Scanner) string() error {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
+		if s.previous() == '\n' {
+			s.newLine()
+		}
 	}
 
 	if s.isAtEnd() {
-		return fmt.Errorf("unterminated string at line %d", s.line)
+		return s.error("unterminated string")
 	}
 
 	// The closing ".
@@ -230,7 +340,7 @@ func (s *Scanner) number() error {
 
 	value, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
 	if err != nil {
-		return fmt.Errorf("error parsing number at line %d: %v", s.line, err)
+		return s.error(fmt.Sprintf("error parsing number: %v", err))
 	}
 
 	s.addTokenWithLiteral(token.NUMBER, value)
@@ -16,87 +16,199 @@ func TestScanner_ScanTokens(t *testing.T) {
 		{
 			name:  "Empty input",
 			input: "",
-			want:  []token.Token{{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1}},
+			want:  []token.Token{{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 1}},
 		},
 		{
 			name:  "Single character tokens",
 			input: "(){},.-+;*",
 			want: []token.Token{
-				{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1},
-				{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
-				{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1},
-				{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1},
-				{Type: token.COMMA, Lexeme: ",", Line: 1},
-				{Type: token.DOT, Lexeme: ".", Line: 1},
-				{Type: token.MINUS, Lexeme: "-", Line: 1},
-				{Type: token.PLUS, Lexeme: "+", Line: 1},
-				{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
-				{Type: token.STAR, Lexeme: "*", Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1, Column: 1},
+				{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1, Column: 2},
+				{Type: token.LEFT_BRACE, Lexeme: "{", Line: 1, Column: 3},
+				{Type: token.RIGHT_BRACE, Lexeme: "}", Line: 1, Column: 4},
+				{Type: token.COMMA, Lexeme: ",", Line: 1, Column: 5},
+				{Type: token.DOT, Lexeme: ".", Line: 1, Column: 6},
+				{Type: token.MINUS, Lexeme: "-", Line: 1, Column: 7},
+				{Type: token.PLUS, Lexeme: "+", Line: 1, Column: 8},
+				{Type: token.SEMICOLON, Lexeme: ";", Line: 1, Column: 9},
+				{Type: token.STAR, Lexeme: "*", Line: 1, Column: 10},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 11},
 			},
 		},
 		{
 			name:  "One or two character tokens",
 			input: "! != = == < <= > >=",
 			want: []token.Token{
-				{Type: token.BANG, Lexeme: "!", Line: 1},
-				{Type: token.BANG_EQUAL, Lexeme: "!=", Line: 1},
-				{Type: token.EQUAL, Lexeme: "=", Line: 1},
-				{Type: token.EQUAL_EQUAL, Lexeme: "==", Line: 1},
-				{Type: token.LESS, Lexeme: "<", Line: 1},
-				{Type: token.LESS_EQUAL, Lexeme: "<=", Line: 1},
-				{Type: token.GREATER, Lexeme: ">", Line: 1},
-				{Type: token.GREATER_EQUAL, Lexeme: ">=", Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.BANG, Lexeme: "!", Line: 1, Column: 1},
+				{Type: token.BANG_EQUAL, Lexeme: "!=", Line: 1, Column: 3},
+				{Type: token.EQUAL, Lexeme: "=", Line: 1, Column: 6},
+				{Type: token.EQUAL_EQUAL, Lexeme: "==", Line: 1, Column: 8},
+				{Type: token.LESS, Lexeme: "<", Line: 1, Column: 11},
+				{Type: token.LESS_EQUAL, Lexeme: "<=", Line: 1, Column: 13},
+				{Type: token.GREATER, Lexeme: ">", Line: 1, Column: 16},
+				{Type: token.GREATER_EQUAL, Lexeme: ">=", Line: 1, Column: 18},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 20},
+			},
+		},
+		{
+			name:  "Arrow",
+			input: "=> =>= ==>",
+			want: []token.Token{
+				{Type: token.ARROW, Lexeme: "=>", Line: 1, Column: 1},
+				{Type: token.ARROW, Lexeme: "=>", Line: 1, Column: 4},
+				{Type: token.EQUAL, Lexeme: "=", Line: 1, Column: 6},
+				{Type: token.EQUAL_EQUAL, Lexeme: "==", Line: 1, Column: 8},
+				{Type: token.GREATER, Lexeme: ">", Line: 1, Column: 10},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 11},
+			},
+		},
+		{
+			name:  "Compound operators",
+			input: "+= -= *= /= %= ++ -- +++",
+			want: []token.Token{
+				{Type: token.PLUS_EQUAL, Lexeme: "+=", Line: 1, Column: 1},
+				{Type: token.MINUS_EQUAL, Lexeme: "-=", Line: 1, Column: 4},
+				{Type: token.STAR_EQUAL, Lexeme: "*=", Line: 1, Column: 7},
+				{Type: token.SLASH_EQUAL, Lexeme: "/=", Line: 1, Column: 10},
+				{Type: token.PERCENT_EQUAL, Lexeme: "%=", Line: 1, Column: 13},
+				{Type: token.PLUS_PLUS, Lexeme: "++", Line: 1, Column: 16},
+				{Type: token.MINUS_MINUS, Lexeme: "--", Line: 1, Column: 19},
+				{Type: token.PLUS_PLUS, Lexeme: "++", Line: 1, Column: 22},
+				{Type: token.PLUS, Lexeme: "+", Line: 1, Column: 24},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 25},
+			},
+		},
+		{
+			name:  "Bitwise and exponent operators",
+			input: "% & | ^ ~ ** * << <= >> >=",
+			want: []token.Token{
+				{Type: token.PERCENT, Lexeme: "%", Line: 1, Column: 1},
+				{Type: token.AMPERSAND, Lexeme: "&", Line: 1, Column: 3},
+				{Type: token.PIPE, Lexeme: "|", Line: 1, Column: 5},
+				{Type: token.CARET, Lexeme: "^", Line: 1, Column: 7},
+				{Type: token.TILDE, Lexeme: "~", Line: 1, Column: 9},
+				{Type: token.STAR_STAR, Lexeme: "**", Line: 1, Column: 11},
+				{Type: token.STAR, Lexeme: "*", Line: 1, Column: 14},
+				{Type: token.LESS_LESS, Lexeme: "<<", Line: 1, Column: 16},
+				{Type: token.LESS_EQUAL, Lexeme: "<=", Line: 1, Column: 19},
+				{Type: token.GREATER_GREATER, Lexeme: ">>", Line: 1, Column: 22},
+				{Type: token.GREATER_EQUAL, Lexeme: ">=", Line: 1, Column: 25},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 27},
+			},
+		},
+		{
+			name:  "Brackets and in",
+			input: "for (x in [1]) inner",
+			want: []token.Token{
+				{Type: token.FOR, Lexeme: "for", Line: 1, Column: 1},
+				{Type: token.LEFT_PAREN, Lexeme: "(", Line: 1, Column: 5},
+				{Type: token.IDENTIFIER, Lexeme: "x", Line: 1, Column: 6},
+				{Type: token.IN, Lexeme: "in", Line: 1, Column: 8},
+				{Type: token.LEFT_BRACKET, Lexeme: "[", Line: 1, Column: 11},
+				{Type: token.NUMBER, Lexeme: "1", Literal: float64(1), Line: 1, Column: 12},
+				{Type: token.RIGHT_BRACKET, Lexeme: "]", Line: 1, Column: 13},
+				{Type: token.RIGHT_PAREN, Lexeme: ")", Line: 1, Column: 14},
+				{Type: token.IDENTIFIER, Lexeme: "inner", Line: 1, Column: 16},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 21},
 			},
 		},
 		{
 			name:  "Comments",
 			input: "// This is a comment\n5",
 			want: []token.Token{
-				{Type: token.NUMBER, Lexeme: "5", Literal: float64(5), Line: 2},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 2},
+				{Type: token.NUMBER, Lexeme: "5", Literal: float64(5), Line: 2, Column: 1},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 2, Column: 2},
 			},
 		},
 		{
 			name:  "Strings",
 			input: "\"Hello, World!\"",
 			want: []token.Token{
-				{Type: token.STRING, Lexeme: "\"Hello, World!\"", Literal: "Hello, World!", Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.STRING, Lexeme: "\"Hello, World!\"", Literal: "Hello, World!", Line: 1, Column: 1},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 16},
 			},
 		},
 		{
 			name:  "Numbers",
 			input: "123 45.67",
 			want: []token.Token{
-				{Type: token.NUMBER, Lexeme: "123", Literal: float64(123), Line: 1},
-				{Type: token.NUMBER, Lexeme: "45.67", Literal: 45.67, Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.NUMBER, Lexeme: "123", Literal: float64(123), Line: 1, Column: 1},
+				{Type: token.NUMBER, Lexeme: "45.67", Literal: 45.67, Line: 1, Column: 5},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 10},
 			},
 		},
 		{
 			name:  "Keywords and identifiers",
 			input: "var language = \"next\";",
 			want: []token.Token{
-				{Type: token.VAR, Lexeme: "var", Line: 1},
-				{Type: token.IDENTIFIER, Lexeme: "language", Line: 1},
-				{Type: token.EQUAL, Lexeme: "=", Line: 1},
-				{Type: token.STRING, Lexeme: "\"next\"", Literal: "next", Line: 1},
-				{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.VAR, Lexeme: "var", Line: 1, Column: 1},
+				{Type: token.IDENTIFIER, Lexeme: "language", Line: 1, Column: 5},
+				{Type: token.EQUAL, Lexeme: "=", Line: 1, Column: 14},
+				{Type: token.STRING, Lexeme: "\"next\"", Literal: "next", Line: 1, Column: 16},
+				{Type: token.SEMICOLON, Lexeme: ";", Line: 1, Column: 22},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 23},
 			},
 		},
 		{
 			name:  "Ternary operator",
 			input: "true ? 1 : 2",
 			want: []token.Token{
-				{Type: token.TRUE, Lexeme: "true", Line: 1},
-				{Type: token.QUESTION_MARK, Lexeme: "?", Line: 1},
-				{Type: token.NUMBER, Lexeme: "1", Literal: float64(1), Line: 1},
-				{Type: token.COLON, Lexeme: ":", Line: 1},
-				{Type: token.NUMBER, Lexeme: "2", Literal: float64(2), Line: 1},
-				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1},
+				{Type: token.TRUE, Lexeme: "true", Line: 1, Column: 1},
+				{Type: token.QUESTION_MARK, Lexeme: "?", Line: 1, Column: 6},
+				{Type: token.NUMBER, Lexeme: "1", Literal: float64(1), Line: 1, Column: 8},
+				{Type: token.COLON, Lexeme: ":", Line: 1, Column: 10},
+				{Type: token.NUMBER, Lexeme: "2", Literal: float64(2), Line: 1, Column: 12},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 13},
+			},
+		},
+		{
+			name:  "Null-safe operators",
+			input: "a?.b ?? c ??= d?e:f",
+			want: []token.Token{
+				{Type: token.IDENTIFIER, Lexeme: "a", Line: 1, Column: 1},
+				{Type: token.QUESTION_DOT, Lexeme: "?.", Line: 1, Column: 2},
+				{Type: token.IDENTIFIER, Lexeme: "b", Line: 1, Column: 4},
+				{Type: token.QUESTION_QUESTION, Lexeme: "??", Line: 1, Column: 6},
+				{Type: token.IDENTIFIER, Lexeme: "c", Line: 1, Column: 9},
+				{Type: token.QUESTION_QUESTION_EQUAL, Lexeme: "??=", Line: 1, Column: 11},
+				{Type: token.IDENTIFIER, Lexeme: "d", Line: 1, Column: 15},
+				{Type: token.QUESTION_MARK, Lexeme: "?", Line: 1, Column: 16},
+				{Type: token.IDENTIFIER, Lexeme: "e", Line: 1, Column: 17},
+				{Type: token.COLON, Lexeme: ":", Line: 1, Column: 18},
+				{Type: token.IDENTIFIER, Lexeme: "f", Line: 1, Column: 19},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 20},
+			},
+		},
+		{
+			name:  "Rest element",
+			input: "[a, ...b]",
+			want: []token.Token{
+				{Type: token.LEFT_BRACKET, Lexeme: "[", Line: 1, Column: 1},
+				{Type: token.IDENTIFIER, Lexeme: "a", Line: 1, Column: 2},
+				{Type: token.COMMA, Lexeme: ",", Line: 1, Column: 3},
+				{Type: token.DOT_DOT_DOT, Lexeme: "...", Line: 1, Column: 5},
+				{Type: token.IDENTIFIER, Lexeme: "b", Line: 1, Column: 8},
+				{Type: token.RIGHT_BRACKET, Lexeme: "]", Line: 1, Column: 9},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 10},
+			},
+		},
+		{
+			name:  "Columns restart on each line",
+			input: "a\n  \"b\nc\" d",
+			want: []token.Token{
+				{Type: token.IDENTIFIER, Lexeme: "a", Line: 1, Column: 1},
+				{Type: token.STRING, Lexeme: "\"b\nc\"", Literal: "b\nc", Line: 3, Column: 3},
+				{Type: token.IDENTIFIER, Lexeme: "d", Line: 3, Column: 4},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 3, Column: 5},
+			},
+		},
+		{
+			name:  "Identifiers starting with o",
+			input: "or other",
+			want: []token.Token{
+				{Type: token.OR, Lexeme: "or", Line: 1, Column: 1},
+				{Type: token.IDENTIFIER, Lexeme: "other", Line: 1, Column: 4},
+				{Type: token.EOF, Lexeme: "", Literal: nil, Line: 1, Column: 9},
 			},
 		},
 		{
@@ -179,3 +291,20 @@ func TestScanner_peekNext(t *testing.T) {
 		t.Errorf("Scanner.peekNext() = %v, want %v", got, 'c')
 	}
 }
+
+func TestScanErrorPosition(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ScanError
+	}{
+		{"var a;\n  @", ScanError{Line: 2, Column: 3, Message: "unexpected character: @"}},
+		// An unterminated string is reported where it starts.
+		{"print 1;\nprint \"one\ntwo\nthree", ScanError{Line: 2, Column: 7, Message: "unterminated string"}},
+	}
+	for _, tt := range tests {
+		_, err := NewScanner(tt.input).ScanTokens()
+		if err != tt.want {
+			t.Errorf("ScanTokens(%q) error = %#v, want %#v", tt.input, err, tt.want)
+		}
+	}
+}
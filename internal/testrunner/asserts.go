@@ -0,0 +1,60 @@
+package testrunner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"interpreter/internal/interpreter"
+)
+
+// asserts are defined in the global scope of every test.
+var asserts = []*interpreter.NativeFunction{
+	{Name: "assert", Params: 1, Fn: assert},
+	{Name: "assertEqual", Params: 2, Fn: assertEqual},
+	{Name: "assertThrows", Params: 1, Fn: assertThrows},
+}
+
+func assert(i *interpreter.Interpreter, arguments []interface{}) (interface{}, error) {
+	if !interpreter.Truthy(arguments[0]) {
+		return nil, fmt.Errorf("assert failed: got %s", show(arguments[0]))
+	}
+	return nil, nil
+}
+
+// assertEqual takes the actual value first and the expected value second.
+func assertEqual(i *interpreter.Interpreter, arguments []interface{}) (interface{}, error) {
+	actual, expected := arguments[0], arguments[1]
+	if interpreter.Equal(actual, expected) {
+		return nil, nil
+	}
+	return nil, errors.New("assertEqual failed:\n" + diff(show(expected), show(actual)))
+}
+
+// assertThrows calls a function without arguments and returns the message
+// of the runtime error it raises.
+func assertThrows(i *interpreter.Interpreter, arguments []interface{}) (interface{}, error) {
+	fn, ok := arguments[0].(interpreter.Callable)
+	if !ok {
+		return nil, fmt.Errorf("assertThrows expects a function, got %s", show(arguments[0]))
+	}
+	_, err := i.Call(fn, nil)
+	if err == nil {
+		return nil, errors.New("assertThrows failed: no runtime error was raised")
+	}
+	var runtimeErr interpreter.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return runtimeErr.Message, nil
+	}
+	return err.Error(), nil
+}
+
+// show formats a value for a failure message, quoting single-line strings
+// so that "1" and 1 can be told apart. Multi-line strings are left as they
+// are to be diffed line by line.
+func show(value interface{}) string {
+	if s, ok := value.(string); ok && !strings.Contains(s, "\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return interpreter.Stringify(value)
+}
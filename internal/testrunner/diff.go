@@ -0,0 +1,49 @@
+package testrunner
+
+import (
+	"strings"
+)
+
+// diff describes how got differs from want. Single lines are shown side by
+// side; anything longer gets a line-based diff.
+func diff(want, got string) string {
+	if !strings.Contains(want, "\n") && !strings.Contains(got, "\n") {
+		return "want: " + want + "\ngot:  " + got
+	}
+
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- want\n+++ got")
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("\n  " + a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("\n- " + a[i])
+			i++
+		default:
+			sb.WriteString("\n+ " + b[j])
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteText prints the results in the style of go test: failures in full,
+// then a line per file. Verbose also lists passing tests and their output.
+func WriteText(w io.Writer, results []FileResult, verbose bool) {
+	for _, file := range results {
+		if file.Err != nil {
+			fmt.Fprintf(w, "%s\n", indent(file.Path+": "+file.Err.Error()))
+		}
+		for _, t := range file.Tests {
+			if verbose {
+				fmt.Fprintf(w, "=== RUN   %s\n", t.Name)
+			}
+			if t.Passed && !verbose {
+				continue
+			}
+			status := "PASS"
+			if !t.Passed {
+				status = "FAIL"
+			}
+			fmt.Fprintf(w, "--- %s: %s (%.2fs)\n", status, t.Name, t.Duration.Seconds())
+			if t.Output != "" {
+				fmt.Fprint(w, indent(strings.TrimSuffix(t.Output, "\n")), "\n")
+			}
+			if !t.Passed {
+				fmt.Fprint(w, indent(file.Path+":"+t.Failure), "\n")
+			}
+		}
+
+		status := "ok  "
+		if !file.Passed() {
+			status = "FAIL"
+		}
+		note := ""
+		if file.Err == nil && len(file.Tests) == 0 {
+			note = " [no tests to run]"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.3fs%s\n", status, file.Path, file.Duration.Seconds(), note)
+	}
+}
+
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Error    *junitError `xml:"error,omitempty"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitError struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the results as JUnit XML with a test suite per file.
+// A file that failed to load is reported as a suite error.
+func WriteJUnit(w io.Writer, results []FileResult) error {
+	suites := junitSuites{}
+	for _, file := range results {
+		suite := junitSuite{
+			Name: file.Path,
+			Time: fmt.Sprintf("%.3f", file.Duration.Seconds()),
+		}
+		if file.Err != nil {
+			suite.Errors = 1
+			suite.Error = &junitError{Message: file.Err.Error()}
+		}
+		for _, t := range file.Tests {
+			c := junitCase{
+				Name:      t.Name,
+				Classname: file.Path,
+				Time:      fmt.Sprintf("%.3f", t.Duration.Seconds()),
+				SystemOut: t.Output,
+			}
+			if !t.Passed {
+				suite.Failures++
+				// The message is the first line of the failure without
+				// its line number, which the text keeps, or the colon
+				// introducing the details that follow.
+				message, _, _ := strings.Cut(t.Failure, "\n")
+				if _, rest, ok := strings.Cut(message, ": "); ok {
+					message = rest
+				}
+				message = strings.TrimSuffix(message, ":")
+				c.Failure = &junitFailure{Message: message, Text: file.Path + ":" + t.Failure}
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,255 @@
+// Package testrunner discovers and runs the tests written in *_test.lox
+// files: `test "name" { ... }` blocks and top-level functions without
+// parameters whose names start with "test".
+package testrunner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+	"interpreter/internal/module"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// Options control which tests run and how.
+type Options struct {
+	// Run selects the tests whose names it matches. Nil runs every test.
+	Run *regexp.Regexp
+	// Parallel is the number of files run at once. Tests within a file
+	// always run one after another.
+	Parallel int
+	// SearchPath lists the directories searched for imported modules that
+	// are not found next to the importing file.
+	SearchPath []string
+}
+
+// Result is the outcome of a single test.
+type Result struct {
+	Name     string
+	Line     int
+	Passed   bool
+	Failure  string
+	Output   string
+	Duration time.Duration
+}
+
+// FileResult holds the results of one test file. Err is set when the file
+// could not be read or parsed, in which case no tests ran.
+type FileResult struct {
+	Path     string
+	Tests    []Result
+	Err      error
+	Duration time.Duration
+}
+
+// Passed reports whether the file loaded and every selected test passed.
+func (f FileResult) Passed() bool {
+	if f.Err != nil {
+		return false
+	}
+	for _, t := range f.Tests {
+		if !t.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// test is a test found in a file.
+type test struct {
+	name string
+	line int
+	run  func(i *interpreter.Interpreter) error
+}
+
+// Discover expands the given files and directories into the list of test
+// files to run. Directories are searched recursively for *_test.lox files;
+// files named explicitly are taken as they are.
+func Discover(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, "_test.lox") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run runs the test files, several at a time, and returns their results in
+// the order the files were given.
+func Run(files []string, opts Options) []FileResult {
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+
+	results := make([]FileResult, len(files))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				results[index] = RunFile(files[index], opts)
+			}
+		}()
+	}
+	for index := range files {
+		indices <- index
+	}
+	close(indices)
+	wg.Wait()
+	return results
+}
+
+// RunFile runs the selected tests of one file.
+func RunFile(path string, opts Options) FileResult {
+	start := time.Now()
+	result := FileResult{Path: path}
+	source, err := os.ReadFile(path)
+	if err == nil {
+		result.Tests, err = runSource(path, string(source), opts)
+	}
+	result.Err = err
+	result.Duration = time.Since(start)
+	return result
+}
+
+func runSource(path, source string, opts Options) ([]Result, error) {
+	loader, err := module.NewOSLoader(opts.SearchPath)
+	if err != nil {
+		return nil, err
+	}
+	modulePath, err := module.FSPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	setup, tests := collect(statements)
+	var results []Result
+	for _, t := range tests {
+		if opts.Run != nil && !opts.Run.MatchString(t.name) {
+			continue
+		}
+		results = append(results, runTest(t, setup, loader, modulePath))
+	}
+	return results, nil
+}
+
+// collect splits the top level of a file into the statements every test
+// runs first and the tests themselves.
+func collect(statements []expression.Stmt) ([]expression.Stmt, []test) {
+	var setup []expression.Stmt
+	var tests []test
+	for _, stmt := range statements {
+		switch s := stmt.(type) {
+		case *expression.Test:
+			tests = append(tests, test{
+				name: s.Name.Literal.(string),
+				line: s.Line(),
+				run:  func(i *interpreter.Interpreter) error { return i.RunTest(s) },
+			})
+			continue
+		case *expression.Function:
+			if strings.HasPrefix(s.Name.Lexeme, "test") && len(s.Params) == 0 {
+				name := s.Name.Lexeme
+				tests = append(tests, test{
+					name: name,
+					line: s.Line(),
+					run: func(i *interpreter.Interpreter) error {
+						fn, _ := i.Global(name)
+						_, err := i.Call(fn.(interpreter.Callable), nil)
+						return err
+					},
+				})
+			}
+		}
+		setup = append(setup, stmt)
+	}
+	return setup, tests
+}
+
+// runTest runs the file's top level and then the test in an interpreter of
+// its own, so no test sees the globals left behind by another.
+func runTest(t test, setup []expression.Stmt, loader *module.Loader, modulePath string) Result {
+	start := time.Now()
+	var output bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.SetOutput(&output)
+	for _, native := range asserts {
+		i.Define(native.Name, native)
+	}
+	i.SetLoader(loader, modulePath)
+
+	err := i.Interpret(setup)
+	if err == nil {
+		err = t.run(i)
+	}
+
+	result := Result{
+		Name:     t.name,
+		Line:     t.line,
+		Passed:   err == nil,
+		Output:   output.String(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Failure = failure(err, t.line)
+	}
+	return result
+}
+
+// failure formats err as "line: message", using the line of the runtime
+// error when there is one.
+func failure(err error, line int) string {
+	message := err.Error()
+	var runtimeErr interpreter.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		message = runtimeErr.Message
+		if runtimeErr.Token.Line > 0 {
+			line = runtimeErr.Token.Line
+		}
+	}
+	return fmt.Sprintf("%d: %s", line, message)
+}
@@ -0,0 +1,172 @@
+package testrunner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const mathTest = `fun add(a, b) {
+  return a + b;
+}
+var counter = 0;
+
+test "adds" {
+  counter = counter + 1;
+  assertEqual(add(1, 2), 3);
+}
+
+test "globals are fresh" {
+  counter = counter + 1;
+  assertEqual(counter, 1);
+}
+
+test "fails" {
+  print "debug output";
+  assertEqual(add("a", "b"), "ba");
+}
+
+fun makeCounter() {
+  var n = 0;
+  fun next() {
+    n = n + 1;
+    return n;
+  }
+  return next;
+}
+
+fun testClosures() {
+  var next = makeCounter();
+  next();
+  assertEqual(next(), 2);
+}
+
+fun boom() {
+  return nil + 1;
+}
+
+fun testThrows() {
+  assertEqual(assertThrows(boom), "Operands must be two numbers or two strings.");
+}
+
+fun helper(x) {
+  assert(x);
+}
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.lox":     mathTest,
+		"lib.lox":           "var x = 1;",
+		"nested/a_test.lox": `test "a" {}`,
+		"nested/b_test.txt": "",
+	})
+	files, err := Discover([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if got, want := strings.Join(names, " "), "math_test.lox nested/a_test.lox"; got != want {
+		t.Errorf("Discover = %s, want %s", got, want)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"math_test.lox": mathTest})
+	result := RunFile(filepath.Join(dir, "math_test.lox"), Options{})
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+
+	want := map[string]bool{
+		"adds":              true,
+		"globals are fresh": true,
+		"fails":             false,
+		"testClosures":      true,
+		"testThrows":        true,
+	}
+	if len(result.Tests) != len(want) {
+		t.Fatalf("ran %d tests, want %d: %+v", len(result.Tests), len(want), result.Tests)
+	}
+	for _, r := range result.Tests {
+		if passed, ok := want[r.Name]; !ok || r.Passed != passed {
+			t.Errorf("%s: passed = %v, failure %q", r.Name, r.Passed, r.Failure)
+		}
+	}
+
+	failed := result.Tests[2]
+	if failed.Output != "debug output\n" {
+		t.Errorf("output = %q", failed.Output)
+	}
+	if want := "18: assertEqual failed:\nwant: \"ba\"\ngot:  \"ab\""; failed.Failure != want {
+		t.Errorf("failure = %q, want %q", failed.Failure, want)
+	}
+
+	var junit bytes.Buffer
+	if err := WriteJUnit(&junit, []FileResult{result}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<failure message="assertEqual failed">`; !strings.Contains(junit.String(), want) {
+		t.Errorf("JUnit report is missing %q:\n%s", want, junit.String())
+	}
+}
+
+func TestRunFilterAndErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.lox": mathTest,
+		"b_test.lox": `test "broken" { assert(1; }`,
+	})
+	files, _ := Discover([]string{dir})
+	results := Run(files, Options{Run: regexp.MustCompile("^add"), Parallel: 2})
+
+	if len(results[0].Tests) != 1 || results[0].Tests[0].Name != "adds" || !results[0].Passed() {
+		t.Errorf("filtered results = %+v", results[0].Tests)
+	}
+	if results[1].Err == nil || results[1].Passed() {
+		t.Errorf("expected a parse error for the broken file, got %+v", results[1])
+	}
+
+	var junit bytes.Buffer
+	if err := WriteJUnit(&junit, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuites tests="1" failures="0" errors="1">`,
+		`<testcase name="adds" classname="` + files[0] + `"`,
+		`<error message="Expect &#39;)&#39; after arguments. at line 1`,
+	} {
+		if !strings.Contains(junit.String(), want) {
+			t.Errorf("JUnit report is missing %q:\n%s", want, junit.String())
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc", "a\nx\nc")
+	want := "--- want\n+++ got\n  a\n- b\n+ x\n  c"
+	if got != want {
+		t.Errorf("diff =\n%s\nwant\n%s", got, want)
+	}
+}
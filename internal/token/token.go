@@ -12,6 +12,8 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
 	DOT
 	MINUS
@@ -21,6 +23,11 @@ const (
 	STAR
 	QUESTION_MARK
 	COLON
+	PERCENT
+	AMPERSAND
+	PIPE
+	CARET
+	TILDE
 
 	// One or two character tokens.
 	BANG
@@ -31,6 +38,21 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	ARROW
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PERCENT_EQUAL
+	PLUS_PLUS
+	MINUS_MINUS
+	STAR_STAR
+	LESS_LESS
+	GREATER_GREATER
+	QUESTION_DOT
+	QUESTION_QUESTION
+	QUESTION_QUESTION_EQUAL
+	DOT_DOT_DOT
 
 	// Literals.
 	IDENTIFIER
@@ -39,21 +61,30 @@ const (
 
 	// Keywords.
 	AND
+	ASYNC
+	AWAIT
+	CASE
 	CLASS
 	ELSE
 	FALSE
 	FUN
 	FOR
 	IF
+	IMPORT
+	IN
+	MATCH
 	NIL
 	OR
 	PRINT
 	RETURN
+	SELECT
+	SPAWN
 	SUPER
 	THIS
 	TRUE
 	VAR
 	WHILE
+	YIELD
 
 	EOF
 )
@@ -66,6 +97,9 @@ type Token struct {
 	Lexeme  string
 	Literal interface{}
 	Line    int
+	// Column is the 1-based byte offset of the lexeme's first character
+	// within the line it starts on.
+	Column int
 }
 
 func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int) Token {
@@ -105,6 +139,8 @@ func (tt TokenType) String() string {
 		"RIGHT_PAREN",
 		"LEFT_BRACE",
 		"RIGHT_BRACE",
+		"LEFT_BRACKET",
+		"RIGHT_BRACKET",
 		"COMMA",
 		"DOT",
 		"MINUS",
@@ -114,6 +150,11 @@ func (tt TokenType) String() string {
 		"STAR",
 		"QUESTION_MARK",
 		"COLON",
+		"PERCENT",
+		"AMPERSAND",
+		"PIPE",
+		"CARET",
+		"TILDE",
 		"BANG",
 		"BANG_EQUAL",
 		"EQUAL",
@@ -122,25 +163,49 @@ func (tt TokenType) String() string {
 		"GREATER_EQUAL",
 		"LESS",
 		"LESS_EQUAL",
+		"ARROW",
+		"PLUS_EQUAL",
+		"MINUS_EQUAL",
+		"STAR_EQUAL",
+		"SLASH_EQUAL",
+		"PERCENT_EQUAL",
+		"PLUS_PLUS",
+		"MINUS_MINUS",
+		"STAR_STAR",
+		"LESS_LESS",
+		"GREATER_GREATER",
+		"QUESTION_DOT",
+		"QUESTION_QUESTION",
+		"QUESTION_QUESTION_EQUAL",
+		"DOT_DOT_DOT",
 		"IDENTIFIER",
 		"STRING",
 		"NUMBER",
 		"AND",
+		"ASYNC",
+		"AWAIT",
+		"CASE",
 		"CLASS",
 		"ELSE",
 		"FALSE",
 		"FUN",
 		"FOR",
 		"IF",
+		"IMPORT",
+		"IN",
+		"MATCH",
 		"NIL",
 		"OR",
 		"PRINT",
 		"RETURN",
+		"SELECT",
+		"SPAWN",
 		"SUPER",
 		"THIS",
 		"TRUE",
 		"VAR",
 		"WHILE",
+		"YIELD",
 		"EOF",
 	}[tt]
 }
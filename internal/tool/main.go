@@ -15,26 +15,48 @@ func main() {
 
 	defineAst(outputDir, "Expr", []string{
 		"Assign   : Name Token.Token, Value Expr",
+		"Destructure : Pattern Target, Equals Token.Token, Value Expr",
+		"Compound : Target Expr, Operator Token.Token, Value Expr",
+		"Increment : Target Expr, Operator Token.Token, Prefix bool",
 		"Binary   : Left Expr, Operator Token.Token, Right Expr",
-		"Ternary   : Condition Expr, TrueExpression Expr, FalseExpression Expr",
+		"Call     : Callee Expr, Paren Token.Token, Arguments []Expr, Optional bool",
+		"Ternary   : Condition Expr, Operator Token.Token, TrueExpression Expr, FalseExpression Expr",
+		"Get      : Object Expr, Name Token.Token, Optional bool",
+		"Index    : Object Expr, Bracket Token.Token, Key Expr, Optional bool",
 		"Grouping : Expr Expr",
 		"Literal  : Value interface{}",
 		"Logical : Left Expr, Operator Token.Token, Right Expr",
 		"Unary    : Operator Token.Token, Right Expr",
 		"Variable : Name Token.Token",
-	})
+		"Lambda   : Keyword Token.Token, Function *Function",
+		"List     : Bracket Token.Token, Elements []Expr",
+		"Map      : Brace Token.Token, Keys []Expr, Values []Expr",
+		"Await    : Keyword Token.Token, Value Expr",
+	}, false)
 
 	defineAst(outputDir, "Stmt", []string{
 		"Expression:  Expr Expr",
 		"Print: Expression Expr",
-		"Var:  Name Token.Token, Initializer Expr",
+		"Var:  Name Token.Token, Type *TypeAnnotation, Initializer Expr",
+		"VarDestructure: Pattern Target, Initializer Expr",
 		"While: Condition Expr, Body Stmt",
+		"ForIn: Keyword Token.Token, Name Token.Token, Iterable Expr, Body Stmt",
 		"Block: Statements []Stmt",
 		"If: Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
-	})
+		"Function: Name Token.Token, Params []Token.Token, ParamTypes []*TypeAnnotation, ReturnType *TypeAnnotation, Body []Stmt, Async bool",
+		"Return: Keyword Token.Token, Value Expr",
+		"Yield: Keyword Token.Token, Value Expr",
+		"Test: Name Token.Token, Body []Stmt",
+		"Import: Keyword Token.Token, Path Token.Token, Name Token.Token",
+		"Match: Keyword Token.Token, Subject Expr, Cases []*MatchCase",
+		"Spawn: Keyword Token.Token, Call *Call",
+		"Select: Keyword Token.Token, Cases []*SelectCase",
+	}, true)
 }
 
-func defineAst(outputDir, baseName string, types []string) {
+// defineAst writes the node types for baseName. Positioned nodes also record
+// the source line they start on and expose it through Line().
+func defineAst(outputDir, baseName string, types []string, positioned bool) {
 	path := outputDir + "/" + baseName + ".go"
 	file, err := os.Create(path)
 	if err != nil {
@@ -50,6 +72,9 @@ func defineAst(outputDir, baseName string, types []string) {
 	defineVisitor(file, baseName, types)
 	fmt.Fprintln(file, "type", baseName, "interface{")
 	fmt.Fprintf(file, "	Accept(visitor %sVisitor) interface{}\n", baseName)
+	if positioned {
+		fmt.Fprintln(file, "	Line() int")
+	}
 	fmt.Fprintln(file, "}")
 	fmt.Fprintln(file)
 
@@ -57,12 +82,15 @@ func defineAst(outputDir, baseName string, types []string) {
 		parts := strings.Split(t, ":")
 		className := strings.TrimSpace(parts[0])
 		fields := strings.TrimSpace(parts[1])
-		defineType(file, baseName, className, fields)
+		if positioned {
+			fields += ", line int"
+		}
+		defineType(file, baseName, className, fields, positioned)
 
 	}
 }
 
-func defineType(file *os.File, baseName, className, fieldList string) {
+func defineType(file *os.File, baseName, className, fieldList string, positioned bool) {
 	fmt.Fprintf(file, "type %s struct {\n", className)
 	fields := strings.Split(fieldList, ", ")
 	for _, field := range fields {
@@ -86,6 +114,13 @@ func defineType(file *os.File, baseName, className, fieldList string) {
 	fmt.Fprintf(file, "    return visitor.Visit%s%s(e)\n", className, baseName)
 	fmt.Fprintln(file, "}")
 	fmt.Fprintln(file)
+
+	if positioned {
+		fmt.Fprintf(file, "func (e *%s) Line() int {\n", className)
+		fmt.Fprintln(file, "    return e.line")
+		fmt.Fprintln(file, "}")
+		fmt.Fprintln(file)
+	}
 }
 
 func defineVisitor(file *os.File, baseName string, types []string) {
@@ -0,0 +1,536 @@
+// Package transpile translates parsed scripts into source code for other
+// languages.
+package transpile
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/resolver"
+	"interpreter/internal/token"
+)
+
+// UnsupportedError reports a statement the target cannot express.
+type UnsupportedError struct {
+	Line    int
+	Feature string
+	Target  string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("[line %d] %s cannot be transpiled to %s.", e.Line, e.Feature, e.Target)
+}
+
+// Go translates a script into a Go main package built on the loxrt runtime.
+// Variables are looked up in the scopes the resolver finds, as in the
+// interpreter, so the program prints the same output and stops with the
+// same runtime errors. Test blocks are left out. name is mentioned in the
+// generated header.
+func Go(statements []expression.Stmt, name string) (string, error) {
+	g := &goGenerator{out: &strings.Builder{}, distances: resolver.Resolve(statements).Distances()}
+	fmt.Fprintf(g.out, "// Code generated by myinterpreter transpile from %s. DO NOT EDIT.\n\n", name)
+	g.out.WriteString("package main\n\nimport \"interpreter/loxrt\"\n\nfunc main() {\n\tloxrt.Run(func(env *loxrt.Env) {\n")
+	if err := g.statements(statements); err != nil {
+		return "", err
+	}
+	g.out.WriteString("\t})\n}\n")
+
+	source, err := format.Source([]byte(g.out.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting generated Go: %v", err)
+	}
+	return string(source), nil
+}
+
+type goGenerator struct {
+	out *strings.Builder
+	err error
+	// distances says how many scopes out each variable use finds its
+	// variable.
+	distances map[resolver.Key]int
+	// yields is set while translating the body of a generator, where a
+	// return statement ends the body without a value.
+	yields bool
+	// loops counts the for-in loops around the statement being translated
+	// in the current function, which a return statement stops.
+	loops int
+}
+
+func (g *goGenerator) statements(statements []expression.Stmt) error {
+	for _, stmt := range statements {
+		stmt.Accept(g)
+		if g.err != nil {
+			return g.err
+		}
+	}
+	return nil
+}
+
+func (g *goGenerator) line(format string, args ...interface{}) {
+	fmt.Fprintf(g.out, format+"\n", args...)
+}
+
+func (g *goGenerator) expr(expr expression.Expr) string {
+	return expr.Accept(g).(string)
+}
+
+// scopeOf returns the scope that expr finds name in.
+func (g *goGenerator) scopeOf(expr expression.Expr, name token.Token) string {
+	if distance := g.distances[resolver.Key{Expr: expr, Name: name.Lexeme}]; distance > 0 {
+		return fmt.Sprintf("env.At(%d)", distance)
+	}
+	return "env"
+}
+
+// block writes statements in a Go block with a scope of their own.
+func (g *goGenerator) block(statements []expression.Stmt) {
+	g.line("{")
+	g.line("env := loxrt.NewEnv(env)")
+	g.line("_ = env")
+	g.statements(statements)
+	g.line("}")
+}
+
+// body writes a single statement as the body of an if or while.
+func (g *goGenerator) body(stmt expression.Stmt) {
+	if block, ok := stmt.(*expression.Block); ok {
+		g.line("env := loxrt.NewEnv(env)")
+		g.line("_ = env")
+		g.statements(block.Statements)
+		return
+	}
+	g.statements([]expression.Stmt{stmt})
+}
+
+func (g *goGenerator) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	g.line("_ = %s", g.expr(stmt.Expr))
+	return nil
+}
+
+func (g *goGenerator) VisitPrintStmt(stmt *expression.Print) interface{} {
+	g.line("loxrt.Print(%s)", g.expr(stmt.Expression))
+	return nil
+}
+
+func (g *goGenerator) VisitVarStmt(stmt *expression.Var) interface{} {
+	value := "nil"
+	if stmt.Initializer != nil {
+		value = g.expr(stmt.Initializer)
+	}
+	g.line("env.Define(%s, %s)", strconv.Quote(stmt.Name.Lexeme), value)
+	return nil
+}
+
+func (g *goGenerator) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	g.line("env.Destructure(%s, %s, nil)", g.pattern(stmt.Pattern), g.expr(stmt.Initializer))
+	return nil
+}
+
+// pattern translates the target of a destructuring into a loxrt.Pattern.
+func (g *goGenerator) pattern(target expression.Target) string {
+	switch t := target.(type) {
+	case *expression.ListTarget:
+		parts := []string{strconv.Itoa(t.Bracket.Line), `""`}
+		if t.Rest != nil {
+			parts[1] = strconv.Quote(t.Rest.Lexeme)
+		}
+		for _, element := range t.Elements {
+			parts = append(parts, g.pattern(element))
+		}
+		return fmt.Sprintf("loxrt.ListPattern(%s)", strings.Join(parts, ", "))
+	case *expression.MapTarget:
+		parts := []string{strconv.Itoa(t.Brace.Line)}
+		for i, value := range t.Values {
+			parts = append(parts, fmt.Sprintf("loxrt.Key(%s, %d, %s)", strconv.Quote(t.Key(i)), t.Keys[i].Line, g.pattern(value)))
+		}
+		return fmt.Sprintf("loxrt.MapPattern(%s)", strings.Join(parts, ", "))
+	}
+	return fmt.Sprintf("loxrt.NamePattern(%s, %d)", strconv.Quote(target.String()), target.Line())
+}
+
+func (g *goGenerator) VisitWhileStmt(stmt *expression.While) interface{} {
+	g.line("for loxrt.Truthy(%s) {", g.expr(stmt.Condition))
+	g.body(stmt.Body)
+	g.line("}")
+	return nil
+}
+
+// VisitForInStmt defines the loop variable in a fresh scope on each
+// iteration, and stops the iterator once the loop ends.
+func (g *goGenerator) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	stop := fmt.Sprintf("stop%d", g.loops)
+	g.line("{")
+	g.line("next, %s := loxrt.Iterate(%s, %d)", stop, g.expr(stmt.Iterable), stmt.Line())
+	g.line("for {")
+	g.line("value, ok := next()")
+	g.line("if !ok {")
+	g.line("break")
+	g.line("}")
+	g.line("env := loxrt.NewEnv(env)")
+	g.line("env.Define(%s, value)", strconv.Quote(stmt.Name.Lexeme))
+	g.loops++
+	g.statements([]expression.Stmt{stmt.Body})
+	g.loops--
+	g.line("}")
+	g.line("%s()", stop)
+	g.line("}")
+	return nil
+}
+
+func (g *goGenerator) VisitBlockStmt(stmt *expression.Block) interface{} {
+	g.block(stmt.Statements)
+	return nil
+}
+
+func (g *goGenerator) VisitIfStmt(stmt *expression.If) interface{} {
+	g.line("if loxrt.Truthy(%s) {", g.expr(stmt.Condition))
+	g.body(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		g.line("} else {")
+		g.body(stmt.ElseBranch)
+	}
+	g.line("}")
+	return nil
+}
+
+// VisitMatchStmt tries each case in turn in a scope of its own, which holds
+// the names its patterns bind.
+func (g *goGenerator) VisitMatchStmt(stmt *expression.Match) interface{} {
+	g.line("{")
+	g.line("subject := %s", g.expr(stmt.Subject))
+	g.line("matched := false")
+	g.line("_, _ = subject, matched")
+	for _, c := range stmt.Cases {
+		g.line("if !matched {")
+		g.line("env := loxrt.NewEnv(env)")
+		g.line("_ = env")
+		var tests []string
+		for _, pattern := range c.Patterns {
+			switch p := pattern.(type) {
+			case *expression.LiteralPattern:
+				tests = append(tests, fmt.Sprintf("loxrt.Truthy(loxrt.Equal(subject, %s))", g.VisitLiteralExpr(expression.NewLiteral(p.Value))))
+			case *expression.BindingPattern:
+				g.line("env.Define(%s, subject)", strconv.Quote(p.Name.Lexeme))
+				tests = append(tests, "true")
+			case *expression.DestructurePattern:
+				tests = append(tests, fmt.Sprintf("env.Match(%s, subject)", g.pattern(p.Target)))
+			default:
+				tests = append(tests, "true")
+			}
+		}
+		condition := "(" + strings.Join(tests, " || ") + ")"
+		if c.Guard != nil {
+			condition += fmt.Sprintf(" && loxrt.Truthy(%s)", g.expr(c.Guard))
+		}
+		g.line("if %s {", condition)
+		g.line("matched = true")
+		g.body(c.Body)
+		g.line("}")
+		g.line("}")
+	}
+	g.line("}")
+	return nil
+}
+
+func (g *goGenerator) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	g.line("env.Define(%s, %s)", strconv.Quote(stmt.Name.Lexeme), g.function(stmt))
+	return nil
+}
+
+func (g *goGenerator) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	return g.function(expr.Function)
+}
+
+// function translates a function into a loxrt.NewFunction call closing over
+// the current scope.
+func (g *goGenerator) function(fn *expression.Function) string {
+	if fn.Async && g.err == nil {
+		g.err = &UnsupportedError{Line: fn.Line(), Feature: "async function", Target: "Go"}
+	}
+	params := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		params[i] = strconv.Quote(param.Lexeme)
+	}
+
+	enclosing, yields, loops := g.out, g.yields, g.loops
+	g.out, g.yields, g.loops = &strings.Builder{}, expression.IsGenerator(fn.Body), 0
+	if g.yields {
+		g.line("loxrt.NewGenerator(%s, []string{%s}, env, func(env *loxrt.Env, yield func(loxrt.Value)) {", strconv.Quote(fn.Name.Lexeme), strings.Join(params, ", "))
+		g.statements(fn.Body)
+	} else {
+		g.line("loxrt.NewFunction(%s, []string{%s}, env, func(env *loxrt.Env) loxrt.Value {", strconv.Quote(fn.Name.Lexeme), strings.Join(params, ", "))
+		g.statements(fn.Body)
+		if n := len(fn.Body); n == 0 || !isReturn(fn.Body[n-1]) {
+			g.line("return nil")
+		}
+	}
+	g.out.WriteString("})")
+	body := g.out.String()
+	g.out, g.yields, g.loops = enclosing, yields, loops
+	return body
+}
+
+func isReturn(stmt expression.Stmt) bool {
+	_, ok := stmt.(*expression.Return)
+	return ok
+}
+
+// VisitReturnStmt stops the iterators of the for-in loops it leaves, from the
+// innermost out.
+func (g *goGenerator) VisitReturnStmt(stmt *expression.Return) interface{} {
+	value := "nil"
+	if stmt.Value != nil && !g.yields {
+		value = g.expr(stmt.Value)
+	}
+	if g.loops > 0 {
+		g.line("{")
+		if value != "nil" {
+			g.line("result := %s", value)
+			value = "result"
+		}
+		for loop := g.loops - 1; loop >= 0; loop-- {
+			g.line("stop%d()", loop)
+		}
+	}
+	if g.yields {
+		g.line("return")
+	} else {
+		g.line("return %s", value)
+	}
+	if g.loops > 0 {
+		g.line("}")
+	}
+	return nil
+}
+
+func (g *goGenerator) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value == nil {
+		g.line("yield(nil)")
+	} else {
+		g.line("yield(%s)", g.expr(stmt.Value))
+	}
+	return nil
+}
+
+func (g *goGenerator) VisitTestStmt(stmt *expression.Test) interface{} {
+	return nil
+}
+
+func (g *goGenerator) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "spawn", Target: "Go"}
+	}
+	return nil
+}
+
+func (g *goGenerator) VisitSelectStmt(stmt *expression.Select) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "select", Target: "Go"}
+	}
+	return nil
+}
+
+func (g *goGenerator) VisitImportStmt(stmt *expression.Import) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "import", Target: "Go"}
+	}
+	return nil
+}
+
+func (g *goGenerator) VisitAssignExpr(expr *expression.Assign) interface{} {
+	return fmt.Sprintf("%s.Assign(%s, %s, %d)", g.scopeOf(expr, expr.Name), strconv.Quote(expr.Name.Lexeme), g.expr(expr.Value), expr.Name.Line)
+}
+
+func (g *goGenerator) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, name := range expr.Pattern.Names() {
+		if !seen[name.Lexeme] {
+			seen[name.Lexeme] = true
+			scopes = append(scopes, fmt.Sprintf("%s: %s", strconv.Quote(name.Lexeme), g.scopeOf(expr, name)))
+		}
+	}
+	return fmt.Sprintf("env.Destructure(%s, %s, map[string]*loxrt.Env{%s})", g.pattern(expr.Pattern), g.expr(expr.Value), strings.Join(scopes, ", "))
+}
+
+func (g *goGenerator) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	if element, ok := expr.Target.(*expression.Index); ok {
+		object, key, line := g.expr(element.Object), g.expr(element.Key), element.Bracket.Line
+		if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+			return fmt.Sprintf("loxrt.CoalesceIndex(%s, %s, %d, %s)", object, key, line, g.thunk(expr.Value))
+		}
+		operator := expr.BinaryOperator()
+		return fmt.Sprintf("loxrt.UpdateIndex(%s, %s, %d, loxrt.%s, %d, func() loxrt.Value { return %s })",
+			object, key, line, goBinary[operator.Type], operator.Line, g.expr(expr.Value))
+	}
+	name := expr.Target.(*expression.Variable).Name
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		return fmt.Sprintf("%s.Coalesce(%s, %d, %s)", g.scopeOf(expr.Target, name), strconv.Quote(name.Lexeme), name.Line, g.thunk(expr.Value))
+	}
+	operator := expr.BinaryOperator()
+	return fmt.Sprintf("%s.Update(%s, %d, loxrt.%s, %d, func() loxrt.Value { return %s })",
+		g.scopeOf(expr.Target, name), strconv.Quote(name.Lexeme), name.Line, goBinary[operator.Type], operator.Line, g.expr(expr.Value))
+}
+
+func (g *goGenerator) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	if element, ok := expr.Target.(*expression.Index); ok {
+		return fmt.Sprintf("loxrt.IncrementIndex(%s, %s, %d, %s, %t, %d)",
+			g.expr(element.Object), g.expr(element.Key), element.Bracket.Line, goFloat(expr.Delta()), expr.Prefix, expr.Operator.Line)
+	}
+	name := expr.Target.(*expression.Variable).Name
+	return fmt.Sprintf("%s.Increment(%s, %d, %s, %t, %d)", g.scopeOf(expr.Target, name), strconv.Quote(name.Lexeme), name.Line, goFloat(expr.Delta()), expr.Prefix, expr.Operator.Line)
+}
+
+var goBinary = map[token.TokenType]string{
+	token.PLUS:            "Add",
+	token.MINUS:           "Subtract",
+	token.STAR:            "Multiply",
+	token.SLASH:           "Divide",
+	token.PERCENT:         "Modulo",
+	token.STAR_STAR:       "Power",
+	token.AMPERSAND:       "BitAnd",
+	token.PIPE:            "BitOr",
+	token.CARET:           "BitXor",
+	token.LESS_LESS:       "ShiftLeft",
+	token.GREATER_GREATER: "ShiftRight",
+	token.GREATER:         "Greater",
+	token.GREATER_EQUAL:   "GreaterEqual",
+	token.LESS:            "Less",
+	token.LESS_EQUAL:      "LessEqual",
+}
+
+func (g *goGenerator) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	left, right := g.expr(expr.Left), g.expr(expr.Right)
+	switch expr.Operator.Type {
+	case token.EQUAL_EQUAL:
+		return fmt.Sprintf("loxrt.Equal(%s, %s)", left, right)
+	case token.BANG_EQUAL:
+		return fmt.Sprintf("loxrt.NotEqual(%s, %s)", left, right)
+	case token.COMMA:
+		return fmt.Sprintf("loxrt.Comma(%s, %s)", left, right)
+	}
+	return fmt.Sprintf("loxrt.%s(%s, %s, %d)", goBinary[expr.Operator.Type], left, right, expr.Operator.Line)
+}
+
+func (g *goGenerator) VisitCallExpr(expr *expression.Call) interface{} {
+	args := []string{g.expr(expr.Callee), strconv.Itoa(expr.Paren.Line)}
+	for _, argument := range expr.Arguments {
+		args = append(args, g.expr(argument))
+	}
+	if expr.Optional {
+		args[0] = "callee"
+		return g.optional(expr.Callee, "callee", fmt.Sprintf("loxrt.Call(%s)", strings.Join(args, ", ")))
+	}
+	return fmt.Sprintf("loxrt.Call(%s)", strings.Join(args, ", "))
+}
+
+// optional wraps access, which reads object through the parameter name, so
+// that it only runs when object is not nil.
+func (g *goGenerator) optional(object expression.Expr, name, access string) string {
+	return fmt.Sprintf("loxrt.Optional(%s, func(%s loxrt.Value) loxrt.Value { return %s })", g.expr(object), name, access)
+}
+
+func (g *goGenerator) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	return fmt.Sprintf("loxrt.Ternary(%s, %s, %s)", g.expr(expr.Condition), g.thunk(expr.TrueExpression), g.thunk(expr.FalseExpression))
+}
+
+// thunk wraps an expression that may not be evaluated.
+func (g *goGenerator) thunk(expr expression.Expr) string {
+	return fmt.Sprintf("func() loxrt.Value { return %s }", g.expr(expr))
+}
+
+func (g *goGenerator) VisitListExpr(expr *expression.List) interface{} {
+	elements := make([]string, len(expr.Elements))
+	for i, element := range expr.Elements {
+		elements[i] = g.expr(element)
+	}
+	return fmt.Sprintf("loxrt.NewList(%s)", strings.Join(elements, ", "))
+}
+
+func (g *goGenerator) VisitMapExpr(expr *expression.Map) interface{} {
+	var entries []string
+	for i, key := range expr.Keys {
+		entries = append(entries, g.expr(key), g.expr(expr.Values[i]))
+	}
+	return fmt.Sprintf("loxrt.NewMap(%s)", strings.Join(entries, ", "))
+}
+
+func (g *goGenerator) VisitGetExpr(expr *expression.Get) interface{} {
+	if expr.Optional {
+		return g.optional(expr.Object, "object", fmt.Sprintf("loxrt.GetProperty(object, %s, %d)", strconv.Quote(expr.Name.Lexeme), expr.Name.Line))
+	}
+	return fmt.Sprintf("loxrt.GetProperty(%s, %s, %d)", g.expr(expr.Object), strconv.Quote(expr.Name.Lexeme), expr.Name.Line)
+}
+
+func (g *goGenerator) VisitIndexExpr(expr *expression.Index) interface{} {
+	if expr.Optional {
+		return g.optional(expr.Object, "object", fmt.Sprintf("loxrt.Index(object, %s, %d)", g.expr(expr.Key), expr.Bracket.Line))
+	}
+	return fmt.Sprintf("loxrt.Index(%s, %s, %d)", g.expr(expr.Object), g.expr(expr.Key), expr.Bracket.Line)
+}
+
+func (g *goGenerator) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	return fmt.Sprintf("loxrt.AssignIndex(%s, %s, %s, %d)", g.expr(expr.Object), g.expr(expr.Key), g.expr(expr.Value), expr.Bracket.Line)
+}
+
+func (g *goGenerator) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	return g.expr(expr.Expr)
+}
+
+func (g *goGenerator) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return goFloat(value)
+	case string:
+		return strconv.Quote(value)
+	}
+	return "nil"
+}
+
+// goFloat writes a number so that Go gives it the type float64.
+func goFloat(value float64) string {
+	s := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func (g *goGenerator) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	function := "And"
+	switch expr.Operator.Type {
+	case token.OR:
+		function = "Or"
+	case token.QUESTION_QUESTION:
+		function = "Coalesce"
+	}
+	return fmt.Sprintf("loxrt.%s(%s, %s)", function, g.expr(expr.Left), g.thunk(expr.Right))
+}
+
+func (g *goGenerator) VisitAwaitExpr(expr *expression.Await) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: expr.Keyword.Line, Feature: "await", Target: "Go"}
+	}
+	return "nil"
+}
+
+func (g *goGenerator) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	if expr.Operator.Type == token.BANG {
+		return fmt.Sprintf("loxrt.Not(%s)", g.expr(expr.Right))
+	}
+	if expr.Operator.Type == token.TILDE {
+		return fmt.Sprintf("loxrt.BitNot(%s, %d)", g.expr(expr.Right), expr.Operator.Line)
+	}
+	return fmt.Sprintf("loxrt.Negate(%s, %d)", g.expr(expr.Right), expr.Operator.Line)
+}
+
+func (g *goGenerator) VisitVariableExpr(expr *expression.Variable) interface{} {
+	return fmt.Sprintf("%s.Get(%s, %d)", g.scopeOf(expr, expr.Name), strconv.Quote(expr.Name.Lexeme), expr.Name.Line)
+}
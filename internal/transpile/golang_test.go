@@ -0,0 +1,94 @@
+package transpile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGoMatchesInterpreter builds the Go translation of every script in one
+// module and checks that each binary behaves like the interpreter.
+func TestGoMatchesInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	goMod := "module transpiled\n\ngo 1.22\n\nrequire interpreter v0.0.0\n\nreplace interpreter => " + root + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var built []script
+	for _, s := range scripts(t) {
+		statements, _ := parse(s.source)
+		source, err := Go(statements, s.name)
+		var unsupported *UnsupportedError
+		if errors.As(err, &unsupported) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Go() error = %v", s.name, err)
+		}
+		pkg := filepath.Join(dir, strings.TrimSuffix(s.name, ".lox"))
+		if err := os.Mkdir(pkg, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(pkg, "main.go"), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		built = append(built, s)
+	}
+
+	bin := filepath.Join(dir, "bin")
+	build := exec.Command("go", "build", "-o", bin+string(filepath.Separator), "./...")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOWORK=off")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	for _, s := range built {
+		t.Run(s.name, func(t *testing.T) {
+			want := interpret(s.source)
+			if got := runBinary(t, filepath.Join(bin, strings.TrimSuffix(s.name, ".lox"))); got != want {
+				t.Errorf("transpiled program = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func runBinary(t *testing.T, path string, args ...string) outcome {
+	t.Helper()
+	var stdout, stderr strings.Builder
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return outcome{stdout.String(), stderr.String(), exit.ExitCode()}
+	case err != nil:
+		t.Fatal(err)
+	}
+	return outcome{stdout.String(), stderr.String(), 0}
+}
+
+func TestGoRejectsImports(t *testing.T) {
+	statements, _ := parse("print 1;\nimport \"util\";\n")
+	_, err := Go(statements, "script.lox")
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Line != 2 {
+		t.Errorf("Go() error = %v, want an UnsupportedError at line 2", err)
+	}
+}
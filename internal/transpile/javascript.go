@@ -0,0 +1,506 @@
+package transpile
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/resolver"
+	"interpreter/internal/token"
+)
+
+// jsRuntime is copied into every translation so that it runs on its own.
+//
+//go:embed loxrt.js
+var jsRuntime string
+
+// JavaScript translates a script into a standalone ES2020 program that runs
+// under Node or in a browser. Like the Go translation it looks variables up
+// in the scopes the resolver finds and raises the interpreter's runtime
+// errors, written to the console's error output and, under Node, with exit
+// status 70. Test blocks are left out. name is mentioned in the generated
+// header.
+func JavaScript(statements []expression.Stmt, name string) (string, error) {
+	g := &jsGenerator{out: &strings.Builder{}, distances: resolver.Resolve(statements).Distances()}
+	fmt.Fprintf(g.out, "// Code generated by myinterpreter transpile from %s. DO NOT EDIT.\n\"use strict\";\n\n", name)
+	g.out.WriteString(jsRuntime)
+	g.out.WriteString("\nrun((env0) => {\n")
+	g.indent = 1
+	if err := g.statements(statements); err != nil {
+		return "", err
+	}
+	g.out.WriteString("});\n")
+	return g.out.String(), nil
+}
+
+type jsGenerator struct {
+	out    *strings.Builder
+	err    error
+	indent int
+	// depth numbers the scopes so that each has its own variable: a block
+	// cannot declare env in terms of the env it shadows.
+	depth int
+	// distances says how many scopes out each variable use finds its
+	// variable.
+	distances map[resolver.Key]int
+}
+
+func (g *jsGenerator) env() string {
+	return fmt.Sprintf("env%d", g.depth)
+}
+
+// scopeOf returns the scope that expr finds name in. Scopes are numbered
+// as the resolver counts them, so that is the one distance levels out.
+func (g *jsGenerator) scopeOf(expr expression.Expr, name token.Token) string {
+	return fmt.Sprintf("env%d", g.depth-g.distances[resolver.Key{Expr: expr, Name: name.Lexeme}])
+}
+
+func (g *jsGenerator) statements(statements []expression.Stmt) error {
+	for _, stmt := range statements {
+		stmt.Accept(g)
+		if g.err != nil {
+			return g.err
+		}
+	}
+	return nil
+}
+
+func (g *jsGenerator) line(format string, args ...interface{}) {
+	g.out.WriteString(strings.Repeat("  ", g.indent))
+	fmt.Fprintf(g.out, format+"\n", args...)
+}
+
+func (g *jsGenerator) expr(expr expression.Expr) string {
+	return expr.Accept(g).(string)
+}
+
+// scope writes statements in a new scope, inside braces opened by the caller.
+func (g *jsGenerator) scope(statements []expression.Stmt) {
+	outer := g.env()
+	g.depth++
+	g.indent++
+	g.line("const %s = new Env(%s);", g.env(), outer)
+	g.statements(statements)
+	g.indent--
+	g.depth--
+}
+
+// body writes a single statement as the body of an if or while.
+func (g *jsGenerator) body(stmt expression.Stmt) {
+	if block, ok := stmt.(*expression.Block); ok {
+		g.scope(block.Statements)
+		return
+	}
+	g.indent++
+	g.statements([]expression.Stmt{stmt})
+	g.indent--
+}
+
+func (g *jsGenerator) VisitExpressionStmt(stmt *expression.Expression) interface{} {
+	g.line("%s;", g.expr(stmt.Expr))
+	return nil
+}
+
+func (g *jsGenerator) VisitPrintStmt(stmt *expression.Print) interface{} {
+	g.line("print(%s);", g.expr(stmt.Expression))
+	return nil
+}
+
+func (g *jsGenerator) VisitVarStmt(stmt *expression.Var) interface{} {
+	value := "null"
+	if stmt.Initializer != nil {
+		value = g.expr(stmt.Initializer)
+	}
+	g.line("%s.define(%s, %s);", g.env(), jsString(stmt.Name.Lexeme), value)
+	return nil
+}
+
+func (g *jsGenerator) VisitVarDestructureStmt(stmt *expression.VarDestructure) interface{} {
+	g.line("%s.destructure(%s, %s, null);", g.env(), g.pattern(stmt.Pattern), g.expr(stmt.Initializer))
+	return nil
+}
+
+// pattern translates the target of a destructuring into the object literal
+// the runtime's destructure reads.
+func (g *jsGenerator) pattern(target expression.Target) string {
+	switch t := target.(type) {
+	case *expression.ListTarget:
+		elements := make([]string, len(t.Elements))
+		for i, element := range t.Elements {
+			elements[i] = g.pattern(element)
+		}
+		rest := "null"
+		if t.Rest != nil {
+			rest = jsString(t.Rest.Lexeme)
+		}
+		return fmt.Sprintf("{elements: [%s], rest: %s, line: %d}", strings.Join(elements, ", "), rest, t.Bracket.Line)
+	case *expression.MapTarget:
+		entries := make([]string, len(t.Values))
+		for i, value := range t.Values {
+			entries[i] = fmt.Sprintf("{key: %s, line: %d, target: %s}", jsString(t.Key(i)), t.Keys[i].Line, g.pattern(value))
+		}
+		return fmt.Sprintf("{entries: [%s], line: %d}", strings.Join(entries, ", "), t.Brace.Line)
+	}
+	return fmt.Sprintf("{name: %s, line: %d}", jsString(target.String()), target.Line())
+}
+
+func (g *jsGenerator) VisitWhileStmt(stmt *expression.While) interface{} {
+	g.line("while (truthy(%s)) {", g.expr(stmt.Condition))
+	g.body(stmt.Body)
+	g.line("}")
+	return nil
+}
+
+// VisitForInStmt defines the loop variable in a fresh scope on each
+// iteration.
+func (g *jsGenerator) VisitForInStmt(stmt *expression.ForIn) interface{} {
+	g.line("for (const value of iterate(%s, %d)) {", g.expr(stmt.Iterable), stmt.Line())
+	outer := g.env()
+	g.depth++
+	g.indent++
+	g.line("const %s = new Env(%s);", g.env(), outer)
+	g.line("%s.define(%s, value);", g.env(), jsString(stmt.Name.Lexeme))
+	g.indent--
+	g.body(stmt.Body)
+	g.depth--
+	g.line("}")
+	return nil
+}
+
+func (g *jsGenerator) VisitBlockStmt(stmt *expression.Block) interface{} {
+	g.line("{")
+	g.scope(stmt.Statements)
+	g.line("}")
+	return nil
+}
+
+func (g *jsGenerator) VisitIfStmt(stmt *expression.If) interface{} {
+	g.line("if (truthy(%s)) {", g.expr(stmt.Condition))
+	g.body(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		g.line("} else {")
+		g.body(stmt.ElseBranch)
+	}
+	g.line("}")
+	return nil
+}
+
+// VisitMatchStmt tries each case in turn in a scope of its own, which holds
+// the names its patterns bind.
+func (g *jsGenerator) VisitMatchStmt(stmt *expression.Match) interface{} {
+	g.line("{")
+	g.indent++
+	g.line("const subject = %s;", g.expr(stmt.Subject))
+	g.line("let matched = false;")
+	for _, c := range stmt.Cases {
+		g.line("if (!matched) {")
+		outer := g.env()
+		g.depth++
+		g.indent++
+		g.line("const %s = new Env(%s);", g.env(), outer)
+		var tests []string
+		for _, pattern := range c.Patterns {
+			switch p := pattern.(type) {
+			case *expression.LiteralPattern:
+				tests = append(tests, fmt.Sprintf("subject === %s", g.VisitLiteralExpr(expression.NewLiteral(p.Value))))
+			case *expression.BindingPattern:
+				g.line("%s.define(%s, subject);", g.env(), jsString(p.Name.Lexeme))
+				tests = append(tests, "true")
+			case *expression.DestructurePattern:
+				tests = append(tests, fmt.Sprintf("%s.match(%s, subject)", g.env(), g.pattern(p.Target)))
+			default:
+				tests = append(tests, "true")
+			}
+		}
+		condition := "(" + strings.Join(tests, " || ") + ")"
+		if c.Guard != nil {
+			condition += fmt.Sprintf(" && truthy(%s)", g.expr(c.Guard))
+		}
+		g.line("if (%s) {", condition)
+		g.indent++
+		g.line("matched = true;")
+		g.indent--
+		g.body(c.Body)
+		g.line("}")
+		g.indent--
+		g.depth--
+		g.line("}")
+	}
+	g.indent--
+	g.line("}")
+	return nil
+}
+
+func (g *jsGenerator) VisitFunctionStmt(stmt *expression.Function) interface{} {
+	g.line("%s.define(%s, %s);", g.env(), jsString(stmt.Name.Lexeme), g.function(stmt))
+	return nil
+}
+
+func (g *jsGenerator) VisitLambdaExpr(expr *expression.Lambda) interface{} {
+	return g.function(expr.Function)
+}
+
+// function translates a function into a LoxFunction closing over the
+// current scope.
+func (g *jsGenerator) function(fn *expression.Function) string {
+	if fn.Async && g.err == nil {
+		g.err = &UnsupportedError{Line: fn.Line(), Feature: "async function", Target: "JavaScript"}
+	}
+	params := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		params[i] = jsString(param.Lexeme)
+	}
+
+	enclosing := g.out
+	g.out = &strings.Builder{}
+	outer := g.env()
+	g.depth++
+	if expression.IsGenerator(fn.Body) {
+		// A generator's body is a JavaScript generator function, which
+		// runs lazily just like the interpreter's.
+		fmt.Fprintf(g.out, "new LoxGeneratorFunction(%s, [%s], %s, function* (%s) {\n", jsString(fn.Name.Lexeme), strings.Join(params, ", "), outer, g.env())
+	} else {
+		fmt.Fprintf(g.out, "new LoxFunction(%s, [%s], %s, (%s) => {\n", jsString(fn.Name.Lexeme), strings.Join(params, ", "), outer, g.env())
+	}
+	g.indent++
+	g.statements(fn.Body)
+	if n := len(fn.Body); n == 0 || !isReturn(fn.Body[n-1]) {
+		g.line("return null;")
+	}
+	g.indent--
+	g.depth--
+	g.out.WriteString(strings.Repeat("  ", g.indent) + "})")
+	body := g.out.String()
+	g.out = enclosing
+	return body
+}
+
+func (g *jsGenerator) VisitReturnStmt(stmt *expression.Return) interface{} {
+	if stmt.Value == nil {
+		g.line("return null;")
+	} else {
+		g.line("return %s;", g.expr(stmt.Value))
+	}
+	return nil
+}
+
+func (g *jsGenerator) VisitYieldStmt(stmt *expression.Yield) interface{} {
+	if stmt.Value == nil {
+		g.line("yield null;")
+	} else {
+		g.line("yield %s;", g.expr(stmt.Value))
+	}
+	return nil
+}
+
+func (g *jsGenerator) VisitTestStmt(stmt *expression.Test) interface{} {
+	return nil
+}
+
+func (g *jsGenerator) VisitSpawnStmt(stmt *expression.Spawn) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "spawn", Target: "JavaScript"}
+	}
+	return nil
+}
+
+func (g *jsGenerator) VisitSelectStmt(stmt *expression.Select) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "select", Target: "JavaScript"}
+	}
+	return nil
+}
+
+func (g *jsGenerator) VisitImportStmt(stmt *expression.Import) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: stmt.Line(), Feature: "import", Target: "JavaScript"}
+	}
+	return nil
+}
+
+func (g *jsGenerator) VisitAssignExpr(expr *expression.Assign) interface{} {
+	return fmt.Sprintf("%s.assign(%s, %s, %d)", g.scopeOf(expr, expr.Name), jsString(expr.Name.Lexeme), g.expr(expr.Value), expr.Name.Line)
+}
+
+func (g *jsGenerator) VisitDestructureExpr(expr *expression.Destructure) interface{} {
+	var scopes []string
+	for _, name := range expr.Pattern.Names() {
+		scopes = append(scopes, fmt.Sprintf("[%s, %s]", jsString(name.Lexeme), g.scopeOf(expr, name)))
+	}
+	return fmt.Sprintf("%s.destructure(%s, %s, new Map([%s]))", g.env(), g.pattern(expr.Pattern), g.expr(expr.Value), strings.Join(scopes, ", "))
+}
+
+func (g *jsGenerator) VisitCompoundExpr(expr *expression.Compound) interface{} {
+	if element, ok := expr.Target.(*expression.Index); ok {
+		object, key, line := g.expr(element.Object), g.expr(element.Key), element.Bracket.Line
+		if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+			return fmt.Sprintf("coalesceIndex(%s, %s, %d, () => %s)", object, key, line, g.expr(expr.Value))
+		}
+		operator := expr.BinaryOperator()
+		return fmt.Sprintf("updateIndex(%s, %s, %d, %s, %d, () => %s)",
+			object, key, line, jsBinary[operator.Type], operator.Line, g.expr(expr.Value))
+	}
+	name := expr.Target.(*expression.Variable).Name
+	if expr.Operator.Type == token.QUESTION_QUESTION_EQUAL {
+		return fmt.Sprintf("%s.coalesce(%s, %d, () => %s)", g.scopeOf(expr.Target, name), jsString(name.Lexeme), name.Line, g.expr(expr.Value))
+	}
+	operator := expr.BinaryOperator()
+	return fmt.Sprintf("%s.update(%s, %d, %s, %d, () => %s)",
+		g.scopeOf(expr.Target, name), jsString(name.Lexeme), name.Line, jsBinary[operator.Type], operator.Line, g.expr(expr.Value))
+}
+
+func (g *jsGenerator) VisitIncrementExpr(expr *expression.Increment) interface{} {
+	if element, ok := expr.Target.(*expression.Index); ok {
+		return fmt.Sprintf("incrementIndex(%s, %s, %d, %v, %t, %d)",
+			g.expr(element.Object), g.expr(element.Key), element.Bracket.Line, expr.Delta(), expr.Prefix, expr.Operator.Line)
+	}
+	name := expr.Target.(*expression.Variable).Name
+	return fmt.Sprintf("%s.increment(%s, %d, %v, %t, %d)", g.scopeOf(expr.Target, name), jsString(name.Lexeme), name.Line, expr.Delta(), expr.Prefix, expr.Operator.Line)
+}
+
+var jsBinary = map[token.TokenType]string{
+	token.PLUS:            "add",
+	token.MINUS:           "subtract",
+	token.STAR:            "multiply",
+	token.SLASH:           "divide",
+	token.PERCENT:         "modulo",
+	token.STAR_STAR:       "power",
+	token.AMPERSAND:       "bitAnd",
+	token.PIPE:            "bitOr",
+	token.CARET:           "bitXor",
+	token.LESS_LESS:       "shiftLeft",
+	token.GREATER_GREATER: "shiftRight",
+	token.GREATER:         "greater",
+	token.GREATER_EQUAL:   "greaterEqual",
+	token.LESS:            "less",
+	token.LESS_EQUAL:      "lessEqual",
+}
+
+func (g *jsGenerator) VisitBinaryExpr(expr *expression.Binary) interface{} {
+	left, right := g.expr(expr.Left), g.expr(expr.Right)
+	switch expr.Operator.Type {
+	case token.EQUAL_EQUAL:
+		return fmt.Sprintf("(%s === %s)", left, right)
+	case token.BANG_EQUAL:
+		return fmt.Sprintf("(%s !== %s)", left, right)
+	case token.COMMA:
+		return fmt.Sprintf("(%s, %s, null)", left, right)
+	}
+	return fmt.Sprintf("%s(%s, %s, %d)", jsBinary[expr.Operator.Type], left, right, expr.Operator.Line)
+}
+
+func (g *jsGenerator) VisitCallExpr(expr *expression.Call) interface{} {
+	args := []string{g.expr(expr.Callee), strconv.Itoa(expr.Paren.Line)}
+	for _, argument := range expr.Arguments {
+		args = append(args, g.expr(argument))
+	}
+	if expr.Optional {
+		args[0] = "callee"
+		return g.optional(expr.Callee, "callee", fmt.Sprintf("call(%s)", strings.Join(args, ", ")))
+	}
+	return fmt.Sprintf("call(%s)", strings.Join(args, ", "))
+}
+
+// optional wraps access, which reads object through the parameter name, so
+// that it only runs when object is not nil.
+func (g *jsGenerator) optional(object expression.Expr, name, access string) string {
+	return fmt.Sprintf("optional(%s, (%s) => %s)", g.expr(object), name, access)
+}
+
+func (g *jsGenerator) VisitTernaryExpr(expr *expression.Ternary) interface{} {
+	return fmt.Sprintf("(truthy(%s) ? %s : %s)", g.expr(expr.Condition), g.expr(expr.TrueExpression), g.expr(expr.FalseExpression))
+}
+
+func (g *jsGenerator) VisitListExpr(expr *expression.List) interface{} {
+	elements := make([]string, len(expr.Elements))
+	for i, element := range expr.Elements {
+		elements[i] = g.expr(element)
+	}
+	return fmt.Sprintf("new LoxList([%s])", strings.Join(elements, ", "))
+}
+
+func (g *jsGenerator) VisitMapExpr(expr *expression.Map) interface{} {
+	var entries []string
+	for i, key := range expr.Keys {
+		entries = append(entries, g.expr(key), g.expr(expr.Values[i]))
+	}
+	return fmt.Sprintf("new LoxMap([%s])", strings.Join(entries, ", "))
+}
+
+func (g *jsGenerator) VisitGetExpr(expr *expression.Get) interface{} {
+	if expr.Optional {
+		return g.optional(expr.Object, "object", fmt.Sprintf("getProperty(object, %s, %d)", jsString(expr.Name.Lexeme), expr.Name.Line))
+	}
+	return fmt.Sprintf("getProperty(%s, %s, %d)", g.expr(expr.Object), jsString(expr.Name.Lexeme), expr.Name.Line)
+}
+
+func (g *jsGenerator) VisitIndexExpr(expr *expression.Index) interface{} {
+	if expr.Optional {
+		return g.optional(expr.Object, "object", fmt.Sprintf("index(object, %s, %d)", g.expr(expr.Key), expr.Bracket.Line))
+	}
+	return fmt.Sprintf("index(%s, %s, %d)", g.expr(expr.Object), g.expr(expr.Key), expr.Bracket.Line)
+}
+
+func (g *jsGenerator) VisitSetIndexExpr(expr *expression.SetIndex) interface{} {
+	return fmt.Sprintf("assignIndex(%s, %s, %s, %d)", g.expr(expr.Object), g.expr(expr.Key), g.expr(expr.Value), expr.Bracket.Line)
+}
+
+func (g *jsGenerator) VisitGroupingExpr(expr *expression.Grouping) interface{} {
+	return g.expr(expr.Expr)
+}
+
+func (g *jsGenerator) VisitLiteralExpr(expr *expression.Literal) interface{} {
+	switch value := expr.Value.(type) {
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case string:
+		return jsString(value)
+	}
+	return "null"
+}
+
+// jsString quotes s as a JavaScript string literal.
+func jsString(s string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (g *jsGenerator) VisitLogicalExpr(expr *expression.Logical) interface{} {
+	function := "and"
+	switch expr.Operator.Type {
+	case token.OR:
+		function = "or"
+	case token.QUESTION_QUESTION:
+		function = "coalesce"
+	}
+	return fmt.Sprintf("%s(%s, () => %s)", function, g.expr(expr.Left), g.expr(expr.Right))
+}
+
+func (g *jsGenerator) VisitAwaitExpr(expr *expression.Await) interface{} {
+	if g.err == nil {
+		g.err = &UnsupportedError{Line: expr.Keyword.Line, Feature: "await", Target: "JavaScript"}
+	}
+	return "null"
+}
+
+func (g *jsGenerator) VisitUnaryExpr(expr *expression.Unary) interface{} {
+	if expr.Operator.Type == token.BANG {
+		return fmt.Sprintf("!truthy(%s)", g.expr(expr.Right))
+	}
+	if expr.Operator.Type == token.TILDE {
+		return fmt.Sprintf("bitNot(%s, %d)", g.expr(expr.Right), expr.Operator.Line)
+	}
+	return fmt.Sprintf("negate(%s, %d)", g.expr(expr.Right), expr.Operator.Line)
+}
+
+func (g *jsGenerator) VisitVariableExpr(expr *expression.Variable) interface{} {
+	return fmt.Sprintf("%s.get(%s, %d)", g.scopeOf(expr, expr.Name), jsString(expr.Name.Lexeme), expr.Name.Line)
+}
@@ -0,0 +1,82 @@
+package transpile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestJavaScriptMatchesInterpreter runs the JavaScript translation of every
+// script with node and checks that it behaves like the interpreter.
+func TestJavaScriptMatchesInterpreter(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	dir := t.TempDir()
+	for _, s := range scripts(t) {
+		statements, _ := parse(s.source)
+		source, err := JavaScript(statements, s.name)
+		var unsupported *UnsupportedError
+		if errors.As(err, &unsupported) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: JavaScript() error = %v", s.name, err)
+		}
+
+		t.Run(s.name, func(t *testing.T) {
+			path := filepath.Join(dir, s.name+".js")
+			if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			want := interpret(s.source)
+			if got := runBinary(t, node, path); got != want {
+				t.Errorf("transpiled program = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJavaScriptRejectsImports(t *testing.T) {
+	statements, _ := parse("import \"util\" as u;\n")
+	_, err := JavaScript(statements, "script.lox")
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Line != 1 {
+		t.Errorf("JavaScript() error = %v, want an UnsupportedError at line 1", err)
+	}
+}
+
+// TestJavaScriptRunsWithoutNode runs a translation in a context that has a
+// console but none of Node's globals, as a browser page would.
+func TestJavaScriptRunsWithoutNode(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	statements, _ := parse("print 1 + 2;\nprint nil + 1;\n")
+	source, err := JavaScript(statements, "script.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.js")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	harness := filepath.Join(dir, "harness.js")
+	const sandbox = `const fs = require("fs"), vm = require("vm");
+vm.runInNewContext(fs.readFileSync(process.argv[2], "utf8"), {console});
+`
+	if err := os.WriteFile(harness, []byte(sandbox), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := outcome{"3\n", "Operands must be two numbers or two strings.\n[line 2]\n", 0}
+	if got := runBinary(t, node, harness, path); got != want {
+		t.Errorf("transpiled program = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,476 @@
+// Runtime support for Lox scripts transpiled to JavaScript, reproducing the
+// interpreter's semantics.
+
+class LoxError extends Error {
+  constructor(message, line) {
+    super(message);
+    this.line = line;
+  }
+}
+
+function fail(line, message) {
+  throw new LoxError(message, line);
+}
+
+// NativeError is thrown by a native function and reported on the line of
+// the call.
+class NativeError extends Error {}
+
+class Env {
+  constructor(enclosing) {
+    this.enclosing = enclosing;
+    this.values = new Map();
+  }
+
+  define(name, value) {
+    this.values.set(name, value);
+  }
+
+  get(name, line) {
+    for (let env = this; env !== null; env = env.enclosing) {
+      if (env.values.has(name)) return env.values.get(name);
+    }
+    fail(line, `Undefined variable '${name}'.`);
+  }
+
+  assign(name, value, line) {
+    for (let env = this; env !== null; env = env.enclosing) {
+      if (env.values.has(name)) {
+        env.values.set(name, value);
+        return value;
+      }
+    }
+    fail(line, `Undefined variable '${name}'.`);
+  }
+
+  update(name, line, op, opLine, operand) {
+    const current = this.get(name, line);
+    return this.assign(name, op(current, operand(), opLine), line);
+  }
+
+  // coalesce assigns the value of operand to name, evaluating it only when
+  // name holds nil.
+  coalesce(name, line, operand) {
+    const current = this.get(name, line);
+    return current !== null ? current : this.assign(name, operand(), line);
+  }
+
+  // destructure takes value apart as pattern describes and returns value.
+  // It defines new variables when scopes is null and otherwise assigns
+  // existing ones, each from the scope scopes holds for its name.
+  destructure(pattern, value, scopes) {
+    bind(pattern, value, (name, element, line) => {
+      if (scopes === null) this.define(name, element);
+      else scopes.get(name).assign(name, element, line);
+    });
+    return value;
+  }
+
+  // match reports whether value has the shape of a list or map pattern in
+  // a match case and, if so, defines the names it binds other than _.
+  match(pattern, value) {
+    if (!fits(pattern, value)) return false;
+    bind(pattern, value, (name, element) => {
+      if (name !== "_") this.define(name, element);
+    });
+    return true;
+  }
+
+  increment(name, line, delta, prefix, opLine) {
+    const old = this.get(name, line);
+    if (typeof old !== "number") fail(opLine, "Operand must be a number.");
+    this.assign(name, old + delta, line);
+    return prefix ? old + delta : old;
+  }
+}
+
+class LoxFunction {
+  constructor(name, params, closure, body, native = false) {
+    this.name = name;
+    this.params = params;
+    this.closure = closure;
+    this.body = body;
+    this.native = native;
+  }
+
+  invoke(args) {
+    if (this.native) return this.body(...args);
+    const env = new Env(this.closure);
+    this.params.forEach((param, i) => env.define(param, args[i]));
+    return this.body(env);
+  }
+
+  toString() {
+    if (this.native) return "<native fn>";
+    return this.name === "" ? "<fn>" : `<fn ${this.name}>`;
+  }
+}
+
+// LoxGeneratorFunction is a function that yields. Calling it returns a
+// LoxGenerator without running any of the body.
+class LoxGeneratorFunction extends LoxFunction {
+  invoke(args) {
+    return new LoxGenerator(this.name, super.invoke(args));
+  }
+}
+
+class LoxGenerator {
+  constructor(name, body) {
+    this.name = name;
+    this.body = body;
+    this.running = false;
+  }
+
+  // next resumes the body until it yields or finishes.
+  next(line) {
+    if (this.running) fail(line, "Generator is already running.");
+    this.running = true;
+    try {
+      return this.body.next();
+    } finally {
+      this.running = false;
+    }
+  }
+
+  // close finishes the body early, as when a for-in loop is left before the
+  // end. A running generator, whose own body is leaving the loop, is left
+  // alone.
+  close() {
+    if (!this.running) this.body.return();
+  }
+
+  toString() {
+    return this.name === "" ? "<generator>" : `<generator ${this.name}>`;
+  }
+}
+
+const maxCallDepth = 10000;
+let depth = 0;
+
+function call(callee, line, ...args) {
+  if (!(callee instanceof LoxFunction)) fail(line, "Can only call functions and classes.");
+  if (args.length !== callee.params.length) {
+    fail(line, `Expected ${callee.params.length} arguments but got ${args.length}.`);
+  }
+  if (depth >= maxCallDepth) fail(line, "Stack overflow.");
+  depth++;
+  try {
+    return callee.invoke(args);
+  } catch (e) {
+    // JavaScript's own stack may run out before maxCallDepth is reached.
+    if (e instanceof RangeError) fail(line, "Stack overflow.");
+    if (e instanceof NativeError) fail(line, e.message);
+    throw e;
+  } finally {
+    depth--;
+  }
+}
+
+function getProperty(object, name, line) {
+  if (object instanceof LoxMap) return index(object, name, line);
+  fail(line, "Only modules and maps have properties.");
+}
+
+function truthy(value) {
+  if (value === null) return false;
+  if (typeof value === "boolean") return value;
+  return true;
+}
+
+// formatNumber matches Go's %g: the shortest representation, in exponent
+// form with at least two exponent digits when the exponent is below -4 or at
+// least 6.
+function formatNumber(n) {
+  if (Number.isNaN(n)) return "NaN";
+  if (n === Infinity) return "+Inf";
+  if (n === -Infinity) return "-Inf";
+  if (Object.is(n, -0)) return "-0";
+  const [mantissa, exponent] = n.toExponential().split("e");
+  const exp = Number(exponent);
+  if (exp < -4 || exp >= 6) {
+    const digits = String(Math.abs(exp)).padStart(2, "0");
+    return `${mantissa}e${exp < 0 ? "-" : "+"}${digits}`;
+  }
+  return String(n);
+}
+
+function stringify(value) {
+  if (value === null) return "nil";
+  if (typeof value === "number") return formatNumber(value);
+  return String(value);
+}
+
+class LoxList {
+  constructor(elements) {
+    this.elements = elements;
+  }
+
+  toString() {
+    return `[${this.elements.map(stringify).join(", ")}]`;
+  }
+}
+
+// LoxMap is built from entries alternating between keys and values. Its keys
+// keep the order they were first set in.
+class LoxMap {
+  constructor(entries) {
+    this.values = new Map();
+    for (let i = 0; i < entries.length; i += 2) this.values.set(entries[i], entries[i + 1]);
+  }
+
+  toString() {
+    const parts = [...this.values].map(([key, value]) => `${stringify(key)}: ${stringify(value)}`);
+    return `{${parts.join(", ")}}`;
+  }
+}
+
+class LoxRange {
+  constructor(start, end, step) {
+    this.start = start;
+    this.end = end;
+    this.step = step;
+  }
+
+  toString() {
+    return `range(${formatNumber(this.start)}, ${formatNumber(this.end)}, ${formatNumber(this.step)})`;
+  }
+}
+
+function range(start, end, step) {
+  if ([start, end, step].some((n) => typeof n !== "number")) throw new NativeError("Range arguments must be numbers.");
+  if (step === 0) throw new NativeError("Range step must not be zero.");
+  return new LoxRange(start, end, step);
+}
+
+// index looks up an element of a list by its position or a value of a map by
+// its key. A key a map lacks gives nil.
+function index(object, key, line) {
+  if (object instanceof LoxList) {
+    if (!integer(key)) fail(line, "List index must be an integer.");
+    if (key < 0 || key >= object.elements.length) {
+      fail(line, `Index ${stringify(key)} is out of range for a list of length ${object.elements.length}.`);
+    }
+    return object.elements[key];
+  }
+  if (object instanceof LoxMap) {
+    const value = object.values.get(key);
+    return value === undefined ? null : value;
+  }
+  fail(line, "Can only index lists and maps.");
+}
+
+// assignIndex stores value as the element of object under key and returns
+// it, as in a[i] = v. A list must already have that position.
+function assignIndex(object, key, value, line) {
+  index(object, key, line);
+  setIndex(object, key, value);
+  return value;
+}
+
+// updateIndex applies op to the element of object under key and operand,
+// stores the result there and returns it, as in a[i] += v.
+function updateIndex(object, key, line, op, opLine, operand) {
+  const value = op(index(object, key, line), operand(), opLine);
+  setIndex(object, key, value);
+  return value;
+}
+
+// coalesceIndex stores the value of operand as the element of object under
+// key, evaluating it only when the element is nil.
+function coalesceIndex(object, key, line, operand) {
+  const current = index(object, key, line);
+  if (current !== null) return current;
+  const value = operand();
+  setIndex(object, key, value);
+  return value;
+}
+
+// incrementIndex adds delta to the element of object under key, returning
+// the new value if prefix is set and the old one otherwise.
+function incrementIndex(object, key, line, delta, prefix, opLine) {
+  const old = index(object, key, line);
+  if (typeof old !== "number") fail(opLine, "Operand must be a number.");
+  setIndex(object, key, old + delta);
+  return prefix ? old + delta : old;
+}
+
+// setIndex stores value as the element of object under key, which index has
+// already read.
+function setIndex(object, key, value) {
+  if (object instanceof LoxList) object.elements[key] = value;
+  else object.values.set(key, value);
+}
+
+// bind takes value apart as a destructuring pattern describes and hands each
+// piece to store. A pattern is a name, a list of elements with an optional
+// rest, or map entries each reading a key into a target.
+function bind(pattern, value, store) {
+  if (pattern.name !== undefined) {
+    store(pattern.name, value, pattern.line);
+  } else if (pattern.elements !== undefined) {
+    if (!(value instanceof LoxList)) fail(pattern.line, "Expected a list to destructure.");
+    const want = pattern.elements.length, got = value.elements.length;
+    if (pattern.rest === null && got !== want) {
+      fail(pattern.line, `Expected ${want} elements to destructure but got ${got}.`);
+    } else if (got < want) {
+      fail(pattern.line, `Expected at least ${want} elements to destructure but got ${got}.`);
+    }
+    pattern.elements.forEach((element, i) => bind(element, value.elements[i], store));
+    if (pattern.rest !== null) store(pattern.rest, new LoxList(value.elements.slice(want)), pattern.line);
+  } else {
+    if (!(value instanceof LoxMap)) fail(pattern.line, "Expected a map to destructure.");
+    for (const entry of pattern.entries) {
+      if (!value.values.has(entry.key)) fail(entry.line, `Map has no key '${entry.key}'.`);
+      bind(entry.target, value.values.get(entry.key), store);
+    }
+  }
+}
+
+// fits reports whether value has the shape a destructuring pattern
+// describes, so that bind cannot fail.
+function fits(pattern, value) {
+  if (pattern.name !== undefined) return true;
+  if (pattern.elements !== undefined) {
+    if (!(value instanceof LoxList)) return false;
+    const want = pattern.elements.length, got = value.elements.length;
+    if (got < want || (pattern.rest === null && got !== want)) return false;
+    return pattern.elements.every((element, i) => fits(element, value.elements[i]));
+  }
+  if (!(value instanceof LoxMap)) return false;
+  return pattern.entries.every((entry) => value.values.has(entry.key) && fits(entry.target, value.values.get(entry.key)));
+}
+
+// optional applies access to object unless it is nil, for a?.b, a?.[i] and
+// f?.().
+function optional(object, access) {
+  return object === null ? null : access(object);
+}
+
+// iterate yields the values a for-in loop on line visits. Transpiled scripts
+// have no modules, so the only iterators beyond the built-in values are maps
+// holding the functions of the iterator protocol: iter, or hasNext and next.
+function* iterate(value, line, allowIter = true) {
+  if (value instanceof LoxMap) {
+    const method = (name) => {
+      const fn = value.values.get(name);
+      return fn instanceof LoxFunction ? fn : null;
+    };
+    const iter = method("iter"), hasNext = method("hasNext"), next = method("next");
+    if (iter !== null && allowIter) {
+      yield* iterate(call(iter, line), line, false);
+      return;
+    }
+    if (hasNext !== null && next !== null) {
+      while (truthy(call(hasNext, line))) yield call(next, line);
+      return;
+    }
+  }
+  if (typeof value === "string") {
+    yield* value;
+  } else if (value instanceof LoxRange) {
+    for (let i = 0; ; i++) {
+      const n = value.start + i * value.step;
+      if (value.step > 0 ? n >= value.end : n <= value.end) return;
+      yield n;
+    }
+  } else if (value instanceof LoxList) {
+    for (let i = 0; i < value.elements.length; i++) yield value.elements[i];
+  } else if (value instanceof LoxMap) {
+    yield* [...value.values.keys()];
+  } else if (value instanceof LoxGenerator) {
+    try {
+      for (let result = value.next(line); !result.done; result = value.next(line)) yield result.value;
+    } finally {
+      value.close();
+    }
+  } else {
+    fail(line, "Can only iterate over strings, ranges, lists, maps, generators, channels and iterators.");
+  }
+}
+
+// Output goes through the console, so that a translated script runs in a
+// browser page as well as under Node, where the console writes to stdout and
+// stderr. A page can replace console.log and console.error to capture it.
+function print(value) {
+  console.log(stringify(value));
+}
+
+function numbers(a, b, line) {
+  if (typeof a !== "number" || typeof b !== "number") fail(line, "Operands must be numbers.");
+}
+
+function add(a, b, line) {
+  if (typeof a === "number" && typeof b === "number") return a + b;
+  if (typeof a === "string" && typeof b === "string") return a + b;
+  fail(line, "Operands must be two numbers or two strings.");
+}
+
+function subtract(a, b, line) { numbers(a, b, line); return a - b; }
+function multiply(a, b, line) { numbers(a, b, line); return a * b; }
+function divide(a, b, line) { numbers(a, b, line); return a / b; }
+function modulo(a, b, line) { numbers(a, b, line); return a % b; }
+function power(a, b, line) { numbers(a, b, line); return a ** b; }
+
+// Bitwise operators work on 64-bit integers, as they do in the interpreter,
+// rather than on JavaScript's 32-bit ones.
+function integer(value) {
+  return typeof value === "number" && Number.isInteger(value) && value >= -(2 ** 63) && value < 2 ** 63;
+}
+
+function integers(a, b, line) {
+  if (!integer(a) || !integer(b)) fail(line, "Operands must be integers.");
+  return [BigInt(a), BigInt(b)];
+}
+
+function shift(a, b, line) {
+  const [x, y] = integers(a, b, line);
+  if (y < 0n) fail(line, "Shift count must not be negative.");
+  return [x, y > 64n ? 64n : y];
+}
+
+function int64(value) { return Number(BigInt.asIntN(64, value)); }
+function bitAnd(a, b, line) { const [x, y] = integers(a, b, line); return int64(x & y); }
+function bitOr(a, b, line) { const [x, y] = integers(a, b, line); return int64(x | y); }
+function bitXor(a, b, line) { const [x, y] = integers(a, b, line); return int64(x ^ y); }
+function shiftLeft(a, b, line) { const [x, y] = shift(a, b, line); return int64(x << y); }
+function shiftRight(a, b, line) { const [x, y] = shift(a, b, line); return int64(x >> y); }
+
+function bitNot(value, line) {
+  if (!integer(value)) fail(line, "Operand must be an integer.");
+  return int64(~BigInt(value));
+}
+
+function greater(a, b, line) { numbers(a, b, line); return a > b; }
+function greaterEqual(a, b, line) { numbers(a, b, line); return a >= b; }
+function less(a, b, line) { numbers(a, b, line); return a < b; }
+function lessEqual(a, b, line) { numbers(a, b, line); return a <= b; }
+
+function negate(value, line) {
+  if (typeof value !== "number") fail(line, "Operand must be a number.");
+  return -value;
+}
+
+function and(left, right) {
+  return truthy(left) ? right() : left;
+}
+
+function or(left, right) {
+  return truthy(left) ? left : right();
+}
+
+function coalesce(left, right) {
+  return left !== null ? left : right();
+}
+
+function run(script) {
+  const globals = new Env(null);
+  globals.define("clock", new LoxFunction("clock", [], null, () => Date.now() / 1000, true));
+  globals.define("range", new LoxFunction("range", ["start", "end", "step"], null, range, true));
+  try {
+    script(globals);
+  } catch (e) {
+    if (!(e instanceof LoxError)) throw e;
+    console.error(`${e.message}\n[line ${e.line}]`);
+    if (typeof process !== "undefined") process.exitCode = 70;
+  }
+}
@@ -0,0 +1,81 @@
+package transpile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+// script is a program whose translations must behave like the interpreter.
+type script struct {
+	name   string
+	source string
+}
+
+// scripts returns the conformance scripts that parse, along with cases for
+// corners of the semantics that they do not cover.
+func scripts(t *testing.T) []script {
+	t.Helper()
+	all := []script{
+		{"static_scope", "var a = \"global\";\n{\n  fun show() { print a; }\n  show();\n  var a = \"block\";\n  show();\n}\n"},
+		{"closures_share_scope", "fun make() {\n  var n = 0;\n  fun inc() { n = n + 1; return n; }\n  return inc;\n}\nvar c = make();\nc(); print c(); print make()();\n"},
+		{"comma_and_ternary", "print (1, 2);\nprint nil ? 1 : false ? 2 : 3;\nprint 1 and nil or \"x\";\n"},
+		{"numbers", "print 1/3; print 1e10 * 1e15; print -0; print 0/0 == 0/0; print 10/4; print 100000 * 10;\nprint 0/0; print 1/0; print -1/0; print 123456; print 0.00001;\n"},
+		{"equality", "fun f() {}\nprint f == f; print \"a\" == \"a\"; print nil == false; print 1 == \"1\";\n"},
+		{"add_error", "print \"a\" + \"b\";\nprint \"a\" +\n 1;\n"},
+		{"truthiness", "print !0; print !\"\"; print !nil; if (0) print \"zero is true\";\n"},
+		{"skips_tests", "test \"not run\" { print 1; }\nprint 2;\n"},
+	}
+
+	paths, err := filepath.Glob("../../cmd/myinterpreter/testdata/*/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no testdata found: %v", err)
+	}
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, script{filepath.Base(filepath.Dir(path)) + "_" + filepath.Base(path), string(source)})
+	}
+
+	var parsed []script
+	for _, s := range all {
+		if _, ok := parse(s.source); ok {
+			parsed = append(parsed, s)
+		}
+	}
+	return parsed
+}
+
+func parse(source string) ([]expression.Stmt, bool) {
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		return nil, false
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	return statements, err == nil
+}
+
+// outcome is what running a program printed and how it ended.
+type outcome struct {
+	stdout, stderr string
+	code           int
+}
+
+func interpret(source string) outcome {
+	statements, _ := parse(source)
+	var stdout bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.SetOutput(&stdout)
+	if err := i.Interpret(statements); err != nil {
+		return outcome{stdout.String(), err.Error() + "\n", 70}
+	}
+	return outcome{stdout.String(), "", 0}
+}
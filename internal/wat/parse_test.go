@@ -0,0 +1,522 @@
+package wat
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// This file holds a small parser and evaluator for the text format the
+// backend writes, so that tests can check modules without a WebAssembly
+// runtime.
+
+// node is an atom or a parenthesized list.
+type node struct {
+	atom string
+	list []*node
+}
+
+func (n *node) isList(head string) bool {
+	return n.list != nil && len(n.list) > 0 && n.list[0].atom == head
+}
+
+func tokenize(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		switch c := source[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], ";;"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(source) && source[j] != '"' {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, source[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(source) && !strings.ContainsRune(" \t\n\r()\";", rune(source[j])) {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func parseWAT(source string) (*node, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	var parse func() (*node, error)
+	parse = func() (*node, error) {
+		if pos >= len(tokens) {
+			return nil, fmt.Errorf("unexpected end of module")
+		}
+		tok := tokens[pos]
+		pos++
+		switch tok {
+		case ")":
+			return nil, fmt.Errorf("unexpected )")
+		case "(":
+			n := &node{list: []*node{}}
+			for pos < len(tokens) && tokens[pos] != ")" {
+				child, err := parse()
+				if err != nil {
+					return nil, err
+				}
+				n.list = append(n.list, child)
+			}
+			if pos >= len(tokens) {
+				return nil, fmt.Errorf("missing )")
+			}
+			pos++
+			return n, nil
+		}
+		return &node{atom: tok}, nil
+	}
+	root, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("text after the module")
+	}
+	if !root.isList("module") {
+		return nil, fmt.Errorf("expected (module ...)")
+	}
+	return root, nil
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected a string, got %s", s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// instruction is one instruction of a function body with its immediate.
+type instruction struct {
+	op  string
+	arg string
+	// match is the index of the else or end closing a block, loop or if,
+	// and of the end following an else.
+	match int
+}
+
+type function struct {
+	name    string
+	params  []string
+	results int
+	locals  []string
+	code    []instruction
+	// host implements imported functions.
+	host bool
+}
+
+type module struct {
+	functions map[string]*function
+	memory    []byte
+	exports   map[string]string
+}
+
+// immediates says which instructions take an immediate.
+var immediates = map[string]bool{
+	"local.get": true, "local.set": true, "local.tee": true, "call": true,
+	"br": true, "br_if": true, "i32.const": true, "i64.const": true,
+}
+
+var plain = map[string]bool{
+	"drop": true, "select": true, "unreachable": true, "else": true, "end": true,
+	"i32.eqz": true, "i32.and": true, "i64.eq": true, "i64.ne": true, "i64.and": true,
+	"f64.add": true, "f64.sub": true, "f64.mul": true, "f64.div": true, "f64.neg": true,
+	"f64.eq": true, "f64.ne": true, "f64.lt": true, "f64.le": true, "f64.gt": true, "f64.ge": true,
+	"f64.reinterpret_i64": true, "i64.reinterpret_f64": true,
+}
+
+// load checks the structure of a parsed module: every instruction known,
+// blocks balanced, and every local, label and function defined.
+func load(root *node) (*module, error) {
+	m := &module{functions: map[string]*function{}, exports: map[string]string{}}
+	var bodies []*node
+	for _, field := range root.list[1:] {
+		switch {
+		case field.isList("import"):
+			f := field.list[3]
+			if len(field.list) != 4 || !f.isList("func") {
+				return nil, fmt.Errorf("unsupported import")
+			}
+			name, _ := unquote(field.list[2].atom)
+			fn := &function{name: f.list[1].atom, host: true}
+			if fn.name != "$"+name {
+				return nil, fmt.Errorf("import %s bound to %s", name, fn.name)
+			}
+			for _, p := range f.list[2:] {
+				if p.isList("param") {
+					fn.params = append(fn.params, p.list[1:][0].atom)
+					for range p.list[2:] {
+						fn.params = append(fn.params, "")
+					}
+				}
+			}
+			m.functions[fn.name] = fn
+		case field.isList("memory"):
+			pages, err := strconv.Atoi(field.list[len(field.list)-1].atom)
+			if err != nil {
+				return nil, err
+			}
+			m.memory = make([]byte, pages*65536)
+		case field.isList("data"):
+			offset := field.list[1]
+			if !offset.isList("i32.const") {
+				return nil, fmt.Errorf("unsupported data offset")
+			}
+			at, err := strconv.Atoi(offset.list[1].atom)
+			if err != nil {
+				return nil, err
+			}
+			data, err := unquote(field.list[2].atom)
+			if err != nil {
+				return nil, err
+			}
+			if at+len(data) > len(m.memory) {
+				return nil, fmt.Errorf("data outside memory")
+			}
+			copy(m.memory[at:], data)
+		case field.isList("func"):
+			bodies = append(bodies, field)
+			name := field.list[1].atom
+			if _, ok := m.functions[name]; ok {
+				return nil, fmt.Errorf("function %s defined twice", name)
+			}
+			m.functions[name] = &function{name: name}
+		default:
+			return nil, fmt.Errorf("unsupported module field")
+		}
+	}
+
+	for _, body := range bodies {
+		if err := m.loadFunction(body); err != nil {
+			return nil, fmt.Errorf("%s: %v", body.list[1].atom, err)
+		}
+	}
+	return m, nil
+}
+
+func (m *module) loadFunction(body *node) error {
+	fn := m.functions[body.list[1].atom]
+	locals := map[string]bool{}
+	rest := body.list[2:]
+	for len(rest) > 0 && rest[0].list != nil {
+		field := rest[0]
+		rest = rest[1:]
+		switch {
+		case field.isList("export"):
+			name, err := unquote(field.list[1].atom)
+			if err != nil {
+				return err
+			}
+			m.exports[name] = fn.name
+		case field.isList("param"):
+			fn.params = append(fn.params, field.list[1].atom)
+			locals[field.list[1].atom] = true
+		case field.isList("result"):
+			fn.results++
+		case field.isList("local"):
+			fn.locals = append(fn.locals, field.list[1].atom)
+			locals[field.list[1].atom] = true
+		default:
+			return fmt.Errorf("unexpected function field")
+		}
+	}
+
+	type open struct {
+		index int
+		label string
+	}
+	var stack []open
+	for i := 0; i < len(rest); i++ {
+		n := rest[i]
+		if n.list != nil {
+			return fmt.Errorf("folded instructions are not expected")
+		}
+		in := instruction{op: n.atom}
+		switch {
+		case immediates[in.op]:
+			i++
+			if i == len(rest) || rest[i].list != nil {
+				return fmt.Errorf("%s needs an immediate", in.op)
+			}
+			in.arg = rest[i].atom
+		case in.op == "block" || in.op == "loop":
+			if i+1 < len(rest) && strings.HasPrefix(rest[i+1].atom, "$") {
+				i++
+				in.arg = rest[i].atom
+			}
+		case in.op == "if":
+			if i+1 < len(rest) && rest[i+1].isList("result") {
+				i++
+				in.arg = "result"
+			}
+		case !plain[in.op]:
+			return fmt.Errorf("unknown instruction %s", in.op)
+		}
+
+		index := len(fn.code)
+		switch in.op {
+		case "block", "loop", "if":
+			stack = append(stack, open{index, in.arg})
+		case "else":
+			if len(stack) == 0 || fn.code[stack[len(stack)-1].index].op != "if" {
+				return fmt.Errorf("else outside if")
+			}
+			fn.code[stack[len(stack)-1].index].match = index
+			stack[len(stack)-1].index = index
+		case "end":
+			if len(stack) == 0 {
+				return fmt.Errorf("unbalanced end")
+			}
+			fn.code[stack[len(stack)-1].index].match = index
+			stack = stack[:len(stack)-1]
+		case "local.get", "local.set", "local.tee":
+			if !locals[in.arg] {
+				return fmt.Errorf("undefined local %s", in.arg)
+			}
+		case "call":
+			if m.functions[in.arg] == nil {
+				return fmt.Errorf("undefined function %s", in.arg)
+			}
+		case "br", "br_if":
+			found := false
+			for _, o := range stack {
+				found = found || o.label == in.arg
+			}
+			if !found {
+				return fmt.Errorf("undefined label %s", in.arg)
+			}
+		}
+		fn.code = append(fn.code, in)
+	}
+	if len(stack) != 0 {
+		return fmt.Errorf("unclosed block")
+	}
+	return nil
+}
+
+// trap is raised by unreachable and by the error import.
+type trap struct {
+	message string
+}
+
+// run calls the exported main function. print receives each printed value
+// and error the message and line of a runtime error, before the trap.
+func (m *module) run(print func(uint64), fail func(message string, line int)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t, ok := r.(trap)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("trap: %s", t.message)
+		}
+	}()
+	main := m.functions[m.exports["main"]]
+	if main == nil {
+		return fmt.Errorf("no main export")
+	}
+	m.call(main, nil, print, fail)
+	return nil
+}
+
+func (m *module) call(fn *function, args []uint64, print func(uint64), fail func(string, int)) []uint64 {
+	if fn.host {
+		switch fn.name {
+		case "$print":
+			print(args[0])
+		case "$error":
+			fail(string(m.memory[args[0]:args[0]+args[1]]), int(args[2]))
+		}
+		return nil
+	}
+
+	locals := map[string]uint64{}
+	for i, param := range fn.params {
+		locals[param] = args[i]
+	}
+	for _, local := range fn.locals {
+		locals[local] = 0
+	}
+
+	var stack []uint64
+	pop := func() uint64 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v uint64) { stack = append(stack, v) }
+	boolean := func(b bool) {
+		if b {
+			push(1)
+		} else {
+			push(0)
+		}
+	}
+	f64 := func() float64 { return math.Float64frombits(pop()) }
+	binary := func(op func(a, b float64) float64) {
+		b, a := f64(), f64()
+		push(math.Float64bits(op(a, b)))
+	}
+	compare := func(op func(a, b float64) bool) {
+		b, a := f64(), f64()
+		boolean(op(a, b))
+	}
+
+	// labels holds the enclosing blocks and loops by name.
+	type label struct {
+		name  string
+		start int
+		end   int
+		loop  bool
+	}
+	var labels []label
+	branch := func(name string) int {
+		for len(labels) > 0 {
+			l := labels[len(labels)-1]
+			if l.name == name {
+				if l.loop {
+					return l.start
+				}
+				labels = labels[:len(labels)-1]
+				return l.end
+			}
+			labels = labels[:len(labels)-1]
+		}
+		panic(trap{"branch to unknown label " + name})
+	}
+
+	for pc := 0; pc < len(fn.code); pc++ {
+		in := fn.code[pc]
+		switch in.op {
+		case "i32.const", "i64.const":
+			v, err := strconv.ParseUint(in.arg, 0, 64)
+			if err != nil {
+				n, err := strconv.ParseInt(in.arg, 0, 64)
+				if err != nil {
+					panic(trap{"bad constant " + in.arg})
+				}
+				v = uint64(n)
+			}
+			push(v)
+		case "local.get":
+			push(locals[in.arg])
+		case "local.set":
+			locals[in.arg] = pop()
+		case "local.tee":
+			locals[in.arg] = stack[len(stack)-1]
+		case "drop":
+			pop()
+		case "select":
+			c, b, a := pop(), pop(), pop()
+			if uint32(c) != 0 {
+				push(a)
+			} else {
+				push(b)
+			}
+		case "call":
+			callee := m.functions[in.arg]
+			args := make([]uint64, len(callee.params))
+			for i := len(args) - 1; i >= 0; i-- {
+				args[i] = pop()
+			}
+			stack = append(stack, m.call(callee, args, print, fail)...)
+		case "unreachable":
+			panic(trap{"unreachable"})
+		case "block":
+			labels = append(labels, label{name: in.arg, end: in.match})
+		case "loop":
+			labels = append(labels, label{name: in.arg, start: pc, end: in.match, loop: true})
+		case "if":
+			if uint32(pop()) == 0 {
+				pc = in.match
+			}
+		case "else":
+			pc = in.match
+		case "end":
+			if len(labels) > 0 && labels[len(labels)-1].end == pc {
+				labels = labels[:len(labels)-1]
+			}
+		case "br":
+			pc = branch(in.arg)
+		case "br_if":
+			if uint32(pop()) != 0 {
+				pc = branch(in.arg)
+			}
+		case "i32.eqz":
+			boolean(uint32(pop()) == 0)
+		case "i32.and":
+			b, a := pop(), pop()
+			push(uint64(uint32(a) & uint32(b)))
+		case "i64.eq":
+			boolean(pop() == pop())
+		case "i64.ne":
+			boolean(pop() != pop())
+		case "i64.and":
+			push(pop() & pop())
+		case "f64.add":
+			binary(func(a, b float64) float64 { return a + b })
+		case "f64.sub":
+			binary(func(a, b float64) float64 { return a - b })
+		case "f64.mul":
+			binary(func(a, b float64) float64 { return a * b })
+		case "f64.div":
+			binary(func(a, b float64) float64 { return a / b })
+		case "f64.neg":
+			push(math.Float64bits(-f64()))
+		case "f64.eq":
+			compare(func(a, b float64) bool { return a == b })
+		case "f64.ne":
+			compare(func(a, b float64) bool { return a != b })
+		case "f64.lt":
+			compare(func(a, b float64) bool { return a < b })
+		case "f64.le":
+			compare(func(a, b float64) bool { return a <= b })
+		case "f64.gt":
+			compare(func(a, b float64) bool { return a > b })
+		case "f64.ge":
+			compare(func(a, b float64) bool { return a >= b })
+		case "f64.reinterpret_i64", "i64.reinterpret_f64":
+		}
+	}
+	results := stack[len(stack)-fn.results:]
+	return results
+}
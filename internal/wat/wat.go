@@ -0,0 +1,595 @@
+// Package wat lowers the numeric and control-flow subset of Lox to the
+// WebAssembly text format.
+//
+// Every value is an i64 holding a NaN-boxed double: numbers are stored as
+// their bits, with NaN results canonicalized, and nil, false and true are
+// quiet NaNs tagged 1, 2 and 3 in the low bits. The module imports two
+// functions from the host under "lox":
+//
+//	print (param i64)          print a value the way the interpreter does
+//	error (param i32 i32 i32)  report a runtime error, given the offset and
+//	                           length of its message in the exported memory
+//	                           and its line; the module traps afterwards
+//
+// It exports that memory and a "main" function running the script.
+//
+// Variables are resolved when the script is compiled. Without functions a
+// name means the nearest declaration that precedes it in the source, which
+// is what the interpreter finds when it looks the name up at run time.
+package wat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/token"
+)
+
+// The boxed representations of the values that are not numbers.
+const (
+	QNaN  uint64 = 0x7ffc000000000000
+	Nil          = QNaN | 1
+	False        = QNaN | 2
+	True         = QNaN | 3
+	// CanonicalNaN is the only NaN a boxed number holds.
+	CanonicalNaN uint64 = 0x7ff8000000000000
+)
+
+// Box returns the representation of a number.
+func Box(n float64) uint64 {
+	if math.IsNaN(n) {
+		return CanonicalNaN
+	}
+	return math.Float64bits(n)
+}
+
+// UnsupportedError reports a construct outside the subset the backend
+// handles.
+type UnsupportedError struct {
+	Line    int
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("[line %d] %s cannot be compiled to WebAssembly.", e.Line, e.Feature)
+}
+
+// Compile returns a module running statements. Test blocks are left out, as
+// evaluate leaves them out.
+func Compile(statements []expression.Stmt) (module string, err error) {
+	g := &generator{
+		scopes:   []map[string]string{{}},
+		declared: map[string]int{},
+		messages: map[string]int{},
+		indent:   2,
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			unsupported, ok := r.(*UnsupportedError)
+			if !ok {
+				panic(r)
+			}
+			module, err = "", unsupported
+		}
+	}()
+	for _, message := range runtimeMessages {
+		g.message(message)
+	}
+	for _, stmt := range statements {
+		g.stmt(stmt)
+	}
+	return g.module(), nil
+}
+
+// runtimeMessages are the errors the runtime helpers raise, stored first.
+var runtimeMessages = []string{
+	"Operand must be a number.",
+	"Operands must be numbers.",
+	"Operands must be two numbers or two strings.",
+}
+
+type generator struct {
+	code   strings.Builder
+	indent int
+
+	// scopes maps the names declared so far in each enclosing block to
+	// their locals, innermost last.
+	scopes []map[string]string
+	locals []string
+	// declared counts the locals created for each name.
+	declared map[string]int
+	temps    int
+	labels   int
+	// line is the line of the statement being compiled.
+	line int
+
+	data     strings.Builder
+	size     int
+	messages map[string]int
+}
+
+func (g *generator) emit(format string, args ...interface{}) {
+	g.code.WriteString(strings.Repeat("  ", g.indent))
+	fmt.Fprintf(&g.code, format+"\n", args...)
+}
+
+func (g *generator) unsupported(line int, feature string) {
+	panic(&UnsupportedError{Line: line, Feature: feature})
+}
+
+// message returns the offset of message in memory, storing it if needed.
+func (g *generator) message(message string) int {
+	if offset, ok := g.messages[message]; ok {
+		return offset
+	}
+	offset := g.size
+	g.messages[message] = offset
+	g.data.WriteString(message)
+	g.size += len(message)
+	return offset
+}
+
+// fail reports a runtime error and traps.
+func (g *generator) fail(message string, line int) {
+	g.emit("i32.const %d", g.message(message))
+	g.emit("i32.const %d", len(message))
+	g.emit("i32.const %d", line)
+	g.emit("call $error")
+	g.emit("unreachable")
+}
+
+func (g *generator) constant(bits uint64, comment string) {
+	g.emit("i64.const %#x ;; %s", bits, comment)
+}
+
+func (g *generator) local(prefix string) string {
+	g.declared[prefix]++
+	name := "$" + prefix
+	if n := g.declared[prefix]; n > 1 {
+		name += "_" + strconv.Itoa(n)
+	}
+	g.locals = append(g.locals, name)
+	return name
+}
+
+func (g *generator) lookup(name string) (string, bool) {
+	for i := len(g.scopes) - 1; i >= 0; i-- {
+		if local, ok := g.scopes[i][name]; ok {
+			return local, true
+		}
+	}
+	return "", false
+}
+
+func (g *generator) module() string {
+	var b strings.Builder
+	b.WriteString("(module\n")
+	b.WriteString("  (import \"lox\" \"print\" (func $print (param i64)))\n")
+	b.WriteString("  (import \"lox\" \"error\" (func $error (param i32 i32 i32)))\n")
+	pages := g.size/65536 + 1
+	fmt.Fprintf(&b, "  (memory (export \"memory\") %d)\n", pages)
+	fmt.Fprintf(&b, "  (data (i32.const 0) %s)\n", quote(g.data.String()))
+	b.WriteString(runtime)
+	b.WriteString("  (func $main (export \"main\")\n")
+	for _, local := range g.locals {
+		fmt.Fprintf(&b, "    (local %s i64)\n", local)
+	}
+	b.WriteString(g.code.String())
+	b.WriteString("  )\n)\n")
+	return b.String()
+}
+
+// quote writes s as a WAT string, escaping everything outside printable
+// ASCII.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			fmt.Fprintf(&b, "\\%02x", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (g *generator) stmt(stmt expression.Stmt) {
+	g.line = stmt.Line()
+	switch s := stmt.(type) {
+	case *expression.Expression:
+		g.expr(s.Expr)
+		g.emit("drop")
+	case *expression.Print:
+		g.expr(s.Expression)
+		g.emit("call $print")
+	case *expression.Var:
+		if s.Initializer != nil {
+			g.expr(s.Initializer)
+		} else {
+			g.constant(Nil, "nil")
+		}
+		scope := g.scopes[len(g.scopes)-1]
+		local, ok := scope[s.Name.Lexeme]
+		if !ok {
+			local = g.local(s.Name.Lexeme)
+			scope[s.Name.Lexeme] = local
+		}
+		g.emit("local.set %s", local)
+	case *expression.Block:
+		g.block(s.Statements)
+	case *expression.If:
+		g.expr(s.Condition)
+		g.emit("call $truthy")
+		g.emit("if")
+		g.nested(s.ThenBranch)
+		if s.ElseBranch != nil {
+			g.emit("else")
+			g.nested(s.ElseBranch)
+		}
+		g.emit("end")
+	case *expression.While:
+		g.labels++
+		exit, loop := fmt.Sprintf("$exit%d", g.labels), fmt.Sprintf("$loop%d", g.labels)
+		g.emit("block %s", exit)
+		g.indent++
+		g.emit("loop %s", loop)
+		g.indent++
+		g.expr(s.Condition)
+		g.emit("call $truthy")
+		g.emit("i32.eqz")
+		g.emit("br_if %s", exit)
+		g.stmt(s.Body)
+		g.emit("br %s", loop)
+		g.indent--
+		g.emit("end")
+		g.indent--
+		g.emit("end")
+	case *expression.Test:
+	case *expression.Function:
+		g.unsupported(s.Line(), "Function declaration")
+	case *expression.Return:
+		g.unsupported(s.Line(), "Return statement")
+	case *expression.Import:
+		g.unsupported(s.Line(), "Import")
+	case *expression.Match:
+		g.unsupported(s.Line(), "Match statement")
+	case *expression.ForIn:
+		g.unsupported(s.Line(), "For-in loop")
+	case *expression.Spawn:
+		g.unsupported(s.Line(), "Spawn statement")
+	case *expression.Select:
+		g.unsupported(s.Line(), "Select statement")
+	case *expression.VarDestructure:
+		g.unsupported(s.Line(), "Destructuring declaration")
+	default:
+		g.unsupported(stmt.Line(), fmt.Sprintf("%T", stmt))
+	}
+}
+
+func (g *generator) block(statements []expression.Stmt) {
+	g.scopes = append(g.scopes, map[string]string{})
+	for _, stmt := range statements {
+		g.stmt(stmt)
+	}
+	g.scopes = g.scopes[:len(g.scopes)-1]
+}
+
+// nested writes a branch of an if, indented.
+func (g *generator) nested(stmt expression.Stmt) {
+	g.indent++
+	g.stmt(stmt)
+	g.indent--
+}
+
+// binaryHelpers are the runtime functions implementing binary operators.
+var binaryHelpers = map[token.TokenType]string{
+	token.PLUS:          "$add",
+	token.MINUS:         "$subtract",
+	token.STAR:          "$multiply",
+	token.SLASH:         "$divide",
+	token.GREATER:       "$greater",
+	token.GREATER_EQUAL: "$greater_equal",
+	token.LESS:          "$less",
+	token.LESS_EQUAL:    "$less_equal",
+}
+
+func (g *generator) expr(expr expression.Expr) {
+	switch e := expr.(type) {
+	case *expression.Literal:
+		switch value := e.Value.(type) {
+		case nil:
+			g.constant(Nil, "nil")
+		case bool:
+			if value {
+				g.constant(True, "true")
+			} else {
+				g.constant(False, "false")
+			}
+		case float64:
+			g.constant(Box(value), strconv.FormatFloat(value, 'g', -1, 64))
+		default:
+			g.unsupported(g.line, "String literal")
+		}
+	case *expression.Grouping:
+		g.expr(e.Expr)
+	case *expression.Variable:
+		if local, ok := g.lookup(e.Name.Lexeme); ok {
+			g.emit("local.get %s", local)
+			return
+		}
+		g.fail(fmt.Sprintf("Undefined variable '%s'.", e.Name.Lexeme), e.Name.Line)
+	case *expression.Assign:
+		g.expr(e.Value)
+		if local, ok := g.lookup(e.Name.Lexeme); ok {
+			g.emit("local.tee %s", local)
+			return
+		}
+		g.emit("drop")
+		g.fail(fmt.Sprintf("Undefined variable '%s'.", e.Name.Lexeme), e.Name.Line)
+	case *expression.Unary:
+		if e.Operator.Type == token.TILDE {
+			g.unsupported(e.Operator.Line, "Operator '~'")
+		}
+		g.expr(e.Right)
+		if e.Operator.Type == token.BANG {
+			g.emit("call $not")
+			return
+		}
+		g.emit("i32.const %d", e.Operator.Line)
+		g.emit("call $negate")
+	case *expression.Binary:
+		helper, ok := binaryHelpers[e.Operator.Type]
+		switch e.Operator.Type {
+		case token.EQUAL_EQUAL, token.BANG_EQUAL, token.COMMA:
+		default:
+			if !ok {
+				g.unsupported(e.Operator.Line, fmt.Sprintf("Operator '%s'", e.Operator.Lexeme))
+			}
+		}
+		g.expr(e.Left)
+		g.expr(e.Right)
+		switch e.Operator.Type {
+		case token.EQUAL_EQUAL:
+			g.emit("call $equal")
+		case token.BANG_EQUAL:
+			g.emit("call $equal")
+			g.emit("call $not")
+		case token.COMMA:
+			g.emit("drop")
+			g.emit("drop")
+			g.constant(Nil, "nil")
+		default:
+			g.emit("i32.const %d", e.Operator.Line)
+			g.emit("call %s", helper)
+		}
+	case *expression.Logical:
+		g.expr(e.Left)
+		g.temps++
+		temp := g.local(fmt.Sprintf("tmp%d", g.temps))
+		g.emit("local.tee %s", temp)
+		if e.Operator.Type == token.QUESTION_QUESTION {
+			// a ?? b takes b exactly when a equals nil.
+			g.constant(Nil, "nil")
+			g.emit("call $equal")
+			g.emit("call $truthy")
+			g.emit("if (result i64)")
+			g.indented(func() { g.expr(e.Right) })
+			g.emit("else")
+			g.indented(func() { g.emit("local.get %s", temp) })
+			g.emit("end")
+			return
+		}
+		g.emit("call $truthy")
+		if e.Operator.Type == token.OR {
+			g.emit("if (result i64)")
+			g.indented(func() { g.emit("local.get %s", temp) })
+			g.emit("else")
+			g.indented(func() { g.expr(e.Right) })
+		} else {
+			g.emit("if (result i64)")
+			g.indented(func() { g.expr(e.Right) })
+			g.emit("else")
+			g.indented(func() { g.emit("local.get %s", temp) })
+		}
+		g.emit("end")
+	case *expression.Ternary:
+		g.expr(e.Condition)
+		g.emit("call $truthy")
+		g.emit("if (result i64)")
+		g.indented(func() { g.expr(e.TrueExpression) })
+		g.emit("else")
+		g.indented(func() { g.expr(e.FalseExpression) })
+		g.emit("end")
+	case *expression.Call:
+		g.unsupported(e.Paren.Line, "Call")
+	case *expression.Get:
+		g.unsupported(e.Name.Line, "Property access")
+	case *expression.Index:
+		g.unsupported(e.Bracket.Line, "Index")
+	case *expression.SetIndex:
+		g.unsupported(e.Bracket.Line, "Element assignment")
+	case *expression.Lambda:
+		g.unsupported(e.Function.Line(), "Lambda")
+	case *expression.Compound:
+		g.unsupported(e.Operator.Line, "Compound assignment")
+	case *expression.Increment:
+		g.unsupported(e.Operator.Line, "Increment")
+	case *expression.List:
+		g.unsupported(e.Bracket.Line, "List")
+	case *expression.Map:
+		g.unsupported(e.Brace.Line, "Map")
+	case *expression.Await:
+		g.unsupported(e.Keyword.Line, "Await")
+	case *expression.Destructure:
+		g.unsupported(e.Equals.Line, "Destructuring assignment")
+	default:
+		g.unsupported(g.line, fmt.Sprintf("%T", expr))
+	}
+}
+
+func (g *generator) indented(write func()) {
+	g.indent++
+	write()
+	g.indent--
+}
+
+// runtime holds the helpers the generated code calls. Arithmetic and
+// comparison take the operator's line for their runtime errors.
+var runtime = func() string {
+	var b strings.Builder
+	constants := map[string]string{
+		"QNAN":  fmt.Sprintf("%#x", QNaN),
+		"NIL":   fmt.Sprintf("%#x", Nil),
+		"FALSE": fmt.Sprintf("%#x", False),
+		"TRUE":  fmt.Sprintf("%#x", True),
+		"CANON": fmt.Sprintf("%#x", CanonicalNaN),
+	}
+	offset := 0
+	for i, message := range runtimeMessages {
+		constants[fmt.Sprintf("MSG%d", i)] = fmt.Sprintf("i32.const %d\n      i32.const %d", offset, len(message))
+		offset += len(message)
+	}
+
+	b.WriteString(`  (func $truthy (param $v i64) (result i32)
+    local.get $v
+    i64.const NIL
+    i64.ne
+    local.get $v
+    i64.const FALSE
+    i64.ne
+    i32.and)
+  (func $not (param $v i64) (result i64)
+    local.get $v
+    call $truthy
+    i32.eqz
+    call $bool)
+  (func $bool (param $b i32) (result i64)
+    i64.const TRUE
+    i64.const FALSE
+    local.get $b
+    select)
+  (func $is_number (param $v i64) (result i32)
+    local.get $v
+    i64.const QNAN
+    i64.and
+    i64.const QNAN
+    i64.ne)
+  (func $box (param $n f64) (result i64)
+    local.get $n
+    local.get $n
+    f64.ne
+    if (result i64)
+      i64.const CANON
+    else
+      local.get $n
+      i64.reinterpret_f64
+    end)
+  (func $equal (param $a i64) (param $b i64) (result i64)
+    local.get $a
+    call $is_number
+    local.get $b
+    call $is_number
+    i32.and
+    if (result i64)
+      local.get $a
+      f64.reinterpret_i64
+      local.get $b
+      f64.reinterpret_i64
+      f64.eq
+      call $bool
+    else
+      local.get $a
+      local.get $b
+      i64.eq
+      call $bool
+    end)
+  (func $negate (param $v i64) (param $line i32) (result i64)
+    local.get $v
+    call $is_number
+    i32.eqz
+    if
+      MSG0
+      local.get $line
+      call $error
+      unreachable
+    end
+    local.get $v
+    f64.reinterpret_i64
+    f64.neg
+    call $box)
+  (func $numbers (param $a i64) (param $b i64) (param $line i32)
+    local.get $a
+    call $is_number
+    local.get $b
+    call $is_number
+    i32.and
+    i32.eqz
+    if
+      MSG1
+      local.get $line
+      call $error
+      unreachable
+    end)
+  (func $add (param $a i64) (param $b i64) (param $line i32) (result i64)
+    local.get $a
+    call $is_number
+    local.get $b
+    call $is_number
+    i32.and
+    i32.eqz
+    if
+      MSG2
+      local.get $line
+      call $error
+      unreachable
+    end
+    local.get $a
+    f64.reinterpret_i64
+    local.get $b
+    f64.reinterpret_i64
+    f64.add
+    call $box)
+`)
+	arithmetic := []struct{ name, op, result string }{
+		{"subtract", "f64.sub", "call $box"},
+		{"multiply", "f64.mul", "call $box"},
+		{"divide", "f64.div", "call $box"},
+		{"greater", "f64.gt", "call $bool"},
+		{"greater_equal", "f64.ge", "call $bool"},
+		{"less", "f64.lt", "call $bool"},
+		{"less_equal", "f64.le", "call $bool"},
+	}
+	for _, a := range arithmetic {
+		fmt.Fprintf(&b, `  (func $%s (param $a i64) (param $b i64) (param $line i32) (result i64)
+    local.get $a
+    local.get $b
+    local.get $line
+    call $numbers
+    local.get $a
+    f64.reinterpret_i64
+    local.get $b
+    f64.reinterpret_i64
+    %s
+    %s)
+`, a.name, a.op, a.result)
+	}
+
+	// Replace longer names first so that MSG1 does not match MSG10.
+	names := make([]string, 0, len(constants))
+	for name := range constants {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	s := b.String()
+	for _, name := range names {
+		s = strings.ReplaceAll(s, name, constants[name])
+	}
+	return s
+}()
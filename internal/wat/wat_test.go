@@ -0,0 +1,179 @@
+package wat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"interpreter/internal/expression"
+	"interpreter/internal/interpreter"
+	"interpreter/internal/parser"
+	"interpreter/internal/scanner"
+)
+
+func parse(t *testing.T, source string) []expression.Stmt {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statements
+}
+
+// stringify is the host's print: it unboxes a value and formats it the way
+// the interpreter does.
+func stringify(v uint64) string {
+	switch v {
+	case Nil:
+		return "nil"
+	case True:
+		return "true"
+	case False:
+		return "false"
+	}
+	return interpreter.Stringify(math.Float64frombits(v))
+}
+
+// execute compiles source, checks the module's structure and runs it,
+// returning what it printed and the runtime error it reported, formatted as
+// the interpreter formats them.
+func execute(t *testing.T, source string) (string, string) {
+	t.Helper()
+	text, err := Compile(parse(t, source))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	root, err := parseWAT(text)
+	if err != nil {
+		t.Fatalf("invalid module: %v\n%s", err, text)
+	}
+	m, err := load(root)
+	if err != nil {
+		t.Fatalf("invalid module: %v\n%s", err, text)
+	}
+
+	var stdout strings.Builder
+	var failure string
+	err = m.run(func(v uint64) {
+		stdout.WriteString(stringify(v) + "\n")
+	}, func(message string, line int) {
+		failure = fmt.Sprintf("%s\n[line %d]", message, line)
+	})
+	if (err != nil) != (failure != "") {
+		t.Fatalf("run() error = %v after error import %q", err, failure)
+	}
+	return stdout.String(), failure
+}
+
+func interpret(t *testing.T, source string) (string, string) {
+	t.Helper()
+	var stdout bytes.Buffer
+	i := interpreter.NewInterpreter()
+	i.SetOutput(&stdout)
+	if err := i.Interpret(parse(t, source)); err != nil {
+		return stdout.String(), err.Error()
+	}
+	return stdout.String(), ""
+}
+
+func TestFibonacciMilestone(t *testing.T) {
+	source, err := os.ReadFile("../../test.tt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotOut, gotErr := execute(t, string(source))
+	wantOut, wantErr := interpret(t, string(source))
+	if gotOut != wantOut || gotErr != wantErr {
+		t.Errorf("module printed\n%s%s\nwant\n%s%s", gotOut, gotErr, wantOut, wantErr)
+	}
+	if !strings.HasSuffix(gotOut, "6765\n") {
+		t.Errorf("module printed %q, want the Fibonacci numbers below 10000", gotOut)
+	}
+}
+
+func TestMatchesInterpreter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"Arithmetic", "print 1 + 2 * 3 - 4 / 8; print -(2 - 5); print 10 / 4; print 1 / 3;"},
+		{"Special numbers", "print 0 / 0; print 1 / 0; print -1 / 0; print -0; print 0/0 == 0/0; print 0 == -0;"},
+		{"Comparison and equality", "print 1 < 2; print 2 <= 1; print 3 > 3; print 3 >= 3; print nil == nil; print nil == false; print 1 != true;"},
+		{"Truthiness", "print !nil; print !0; print !!true; if (0) print 1; else print 2;"},
+		{"Logical", "print nil or 2; print 1 and nil; print false or nil or 3; print 1 and 2 and false;"},
+		{"Short circuit", "var a = 1; false and (a = 2); true or (a = 3); print a;"},
+		{"Coalesce", "var a = 1; print nil ?? 2; print false ?? 2; print a ?? (a = 3); print a;"},
+		{"Ternary and comma", "print true ? 1 : 2; print nil ? 1 : false ? 2 : 3; print (1, 2);"},
+		{"Scopes", "var a = 1; { print a; var a = 2; print a; { a = 3; var a = 4; } print a; } print a; var a = 5; print a;"},
+		{"Loops", "var n = 0; for (var i = 0; i < 5; i = i + 1) { var sq = i * i; n = n + sq; } print n; while (n > 1) n = n / 2; print n;"},
+		{"Operand error", "print 1;\nprint -nil;"},
+		{"Operands error", "var a = true;\nprint 1 <\n a;"},
+		{"Add error", "print 1 + nil;"},
+		{"Undefined variable", "print a;\nvar a = 1;"},
+		{"Undefined assignment", "{ var b = 1; }\nb = 2;"},
+		{"Loop body scope", "var i = 0; while (i < 2) { if (i == 1) print b; var b = i; i = i + 1; }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOut, gotErr := execute(t, tt.source)
+			wantOut, wantErr := interpret(t, tt.source)
+			if gotOut != wantOut || gotErr != wantErr {
+				t.Errorf("module printed\n%s%s\nwant\n%s%s", gotOut, gotErr, wantOut, wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		line   int
+	}{
+		{"String", "print 1;\nprint \"s\";", 2},
+		{"Function", "fun f() {}", 1},
+		{"Call", "var c = 1;\n\nc();", 3},
+		{"Import", "import \"m\";", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(parse(t, tt.source))
+			var unsupported *UnsupportedError
+			if !errors.As(err, &unsupported) || unsupported.Line != tt.line {
+				t.Errorf("Compile() error = %v, want an UnsupportedError at line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestParserRejectsMalformedModules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"Unbalanced parentheses", "(module (func $main)"},
+		{"Unknown instruction", "(module (func $main i64.frobnicate))"},
+		{"Undefined local", "(module (func $main local.get $x drop))"},
+		{"Undefined label", "(module (func $main block $a br $b end))"},
+		{"Unclosed block", "(module (func $main loop $l))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseWAT(tt.source)
+			if err == nil {
+				_, err = load(root)
+			}
+			if err == nil {
+				t.Error("module accepted")
+			}
+		})
+	}
+}
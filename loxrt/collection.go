@@ -0,0 +1,371 @@
+package loxrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List is the value of a list literal.
+type List struct {
+	Elements []Value
+}
+
+// NewList returns a list of elements.
+func NewList(elements ...Value) *List {
+	return &List{Elements: elements}
+}
+
+func (l *List) String() string {
+	parts := make([]string, len(l.Elements))
+	for i, element := range l.Elements {
+		parts[i] = Stringify(element)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// Map is the value of a map literal. Its keys keep the order they were first
+// set in.
+type Map struct {
+	keys   []Value
+	values map[Value]Value
+}
+
+// NewMap returns a map of entries, which alternate between keys and values.
+func NewMap(entries ...Value) *Map {
+	m := &Map{values: map[Value]Value{}}
+	for i := 0; i < len(entries); i += 2 {
+		if _, ok := m.values[entries[i]]; !ok {
+			m.keys = append(m.keys, entries[i])
+		}
+		m.values[entries[i]] = entries[i+1]
+	}
+	return m
+}
+
+// method returns the function stored under name, for the iterator protocol.
+func (m *Map) method(name string) (Value, bool) {
+	fn, ok := m.values[name].(*Function)
+	return fn, ok
+}
+
+func (m *Map) String() string {
+	parts := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		parts[i] = Stringify(key) + ": " + Stringify(m.values[key])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Range is the value of range(start, end, step).
+type Range struct {
+	Start, End, Step float64
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("range(%s, %s, %s)", Stringify(r.Start), Stringify(r.End), Stringify(r.Step))
+}
+
+func newRange(arguments []Value) Value {
+	var bounds [3]float64
+	for i, argument := range arguments {
+		number, ok := argument.(float64)
+		if !ok {
+			panic(nativeError("Range arguments must be numbers."))
+		}
+		bounds[i] = number
+	}
+	if bounds[2] == 0 {
+		panic(nativeError("Range step must not be zero."))
+	}
+	return &Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+}
+
+// Index looks up an element of a list by its position or a value of a map by
+// its key. A key a map lacks gives nil.
+func Index(object, key Value, line int) Value {
+	switch o := object.(type) {
+	case *List:
+		position, ok := integer(key)
+		if !ok {
+			fail(line, "List index must be an integer.")
+		}
+		if position < 0 || position >= int64(len(o.Elements)) {
+			fail(line, fmt.Sprintf("Index %s is out of range for a list of length %d.", Stringify(key), len(o.Elements)))
+		}
+		return o.Elements[position]
+	case *Map:
+		return o.values[key]
+	}
+	fail(line, "Can only index lists and maps.")
+	return nil
+}
+
+// AssignIndex stores value as the element of object under key and returns
+// it, as in a[i] = v. A list must already have that position.
+func AssignIndex(object, key, value Value, line int) Value {
+	Index(object, key, line)
+	setIndex(object, key, value)
+	return value
+}
+
+// UpdateIndex applies op to the element of object under key and operand,
+// stores the result there and returns it, as in a[i] += v.
+func UpdateIndex(object, key Value, line int, op func(a, b Value, line int) Value, opLine int, operand func() Value) Value {
+	value := op(Index(object, key, line), operand(), opLine)
+	setIndex(object, key, value)
+	return value
+}
+
+// CoalesceIndex stores the value of operand as the element of object under
+// key, evaluating it only when the element is nil.
+func CoalesceIndex(object, key Value, line int, operand func() Value) Value {
+	if current := Index(object, key, line); current != nil {
+		return current
+	}
+	value := operand()
+	setIndex(object, key, value)
+	return value
+}
+
+// IncrementIndex adds delta to the element of object under key, returning
+// the new value if prefix is set and the old one otherwise.
+func IncrementIndex(object, key Value, line int, delta float64, prefix bool, opLine int) Value {
+	old, ok := Index(object, key, line).(float64)
+	if !ok {
+		fail(opLine, "Operand must be a number.")
+	}
+	setIndex(object, key, old+delta)
+	if prefix {
+		return old + delta
+	}
+	return old
+}
+
+// setIndex stores value as the element of object under key, which Index has
+// already read.
+func setIndex(object, key, value Value) {
+	switch o := object.(type) {
+	case *List:
+		position, _ := integer(key)
+		o.Elements[position] = value
+	case *Map:
+		if _, ok := o.values[key]; !ok {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = value
+	}
+}
+
+// Optional applies access to object unless it is nil, for a?.b, a?.[i] and
+// f?.().
+func Optional(object Value, access func(Value) Value) Value {
+	if object == nil {
+		return nil
+	}
+	return access(object)
+}
+
+// Iterate returns a function producing the values a for-in loop on line
+// visits in turn: the characters of a string, the numbers of a range, the
+// elements of a list, the keys of a map or the values a generator yields. It
+// reports false once there are none left. Transpiled scripts have no
+// modules, so the only other iterators are maps holding the functions of the
+// iterator protocol: iter, or hasNext and next. The loop calls stop when it
+// is left, which closes a generator it had not run to the end.
+func Iterate(value Value, line int) (next func() (Value, bool), stop func()) {
+	next, stop = iterate(value, line, true)
+	if g := current; g != nil {
+		g.loops = append(g.loops, stop)
+		loop := stop
+		stop = func() {
+			g.loops = g.loops[:len(g.loops)-1]
+			loop()
+		}
+	}
+	return next, stop
+}
+
+// noStop is the stop function of iterators with nothing to release.
+func noStop() {}
+
+func iterate(value Value, line int, allowIter bool) (func() (Value, bool), func()) {
+	if m, ok := value.(*Map); ok {
+		if iter, ok := m.method("iter"); ok && allowIter {
+			return iterate(Call(iter, line), line, false)
+		}
+		hasNext, hasNextOk := m.method("hasNext")
+		next, nextOk := m.method("next")
+		if hasNextOk && nextOk {
+			return func() (Value, bool) {
+				if !Truthy(Call(hasNext, line)) {
+					return nil, false
+				}
+				return Call(next, line), true
+			}, noStop
+		}
+	}
+	index := 0
+	switch v := value.(type) {
+	case string:
+		characters := []rune(v)
+		return func() (Value, bool) {
+			if index >= len(characters) {
+				return nil, false
+			}
+			index++
+			return string(characters[index-1]), true
+		}, noStop
+	case *Range:
+		return func() (Value, bool) {
+			number := v.Start + float64(index)*v.Step
+			if (v.Step > 0 && number >= v.End) || (v.Step < 0 && number <= v.End) {
+				return nil, false
+			}
+			index++
+			return number, true
+		}, noStop
+	case *List:
+		return func() (Value, bool) {
+			if index >= len(v.Elements) {
+				return nil, false
+			}
+			index++
+			return v.Elements[index-1], true
+		}, noStop
+	case *Map:
+		keys := append([]Value(nil), v.keys...)
+		return func() (Value, bool) {
+			if index >= len(keys) {
+				return nil, false
+			}
+			index++
+			return keys[index-1], true
+		}, noStop
+	case *Generator:
+		return func() (Value, bool) {
+			return v.next(line)
+		}, v.close
+	}
+	fail(line, "Can only iterate over strings, ranges, lists, maps, generators, channels and iterators.")
+	return nil, nil
+}
+
+// Pattern is the target of a destructuring declaration or assignment, built
+// with NamePattern, ListPattern and MapPattern.
+type Pattern interface {
+	// fits reports whether value has the shape the pattern describes, so
+	// that bind cannot fail.
+	fits(value Value) bool
+	bind(value Value, store func(name string, value Value, line int))
+}
+
+type namePattern struct {
+	name string
+	line int
+}
+
+// NamePattern stores the whole value in name.
+func NamePattern(name string, line int) Pattern {
+	return namePattern{name, line}
+}
+
+func (p namePattern) fits(value Value) bool { return true }
+
+func (p namePattern) bind(value Value, store func(string, Value, int)) {
+	store(p.name, value, p.line)
+}
+
+type listPattern struct {
+	line     int
+	rest     string
+	elements []Pattern
+}
+
+// ListPattern takes a list apart by position. Unless rest is empty, it names
+// the variable receiving a list of the elements after the others, and
+// otherwise the list must have exactly as many elements as the pattern.
+func ListPattern(line int, rest string, elements ...Pattern) Pattern {
+	return listPattern{line, rest, elements}
+}
+
+func (p listPattern) fits(value Value) bool {
+	list, ok := value.(*List)
+	if !ok || len(list.Elements) < len(p.elements) || p.rest == "" && len(list.Elements) != len(p.elements) {
+		return false
+	}
+	for i, element := range p.elements {
+		if !element.fits(list.Elements[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p listPattern) bind(value Value, store func(string, Value, int)) {
+	list, ok := value.(*List)
+	if !ok {
+		fail(p.line, "Expected a list to destructure.")
+	}
+	if n := len(list.Elements); p.rest == "" && n != len(p.elements) {
+		fail(p.line, fmt.Sprintf("Expected %d elements to destructure but got %d.", len(p.elements), n))
+	} else if n < len(p.elements) {
+		fail(p.line, fmt.Sprintf("Expected at least %d elements to destructure but got %d.", len(p.elements), n))
+	}
+	for i, element := range p.elements {
+		element.bind(list.Elements[i], store)
+	}
+	if p.rest != "" {
+		rest := append([]Value{}, list.Elements[len(p.elements):]...)
+		store(p.rest, NewList(rest...), p.line)
+	}
+}
+
+// Entry is the part of a map pattern reading one key.
+type Entry struct {
+	key    string
+	line   int
+	target Pattern
+}
+
+// Key reads key, on line, into target.
+func Key(key string, line int, target Pattern) Entry {
+	return Entry{key, line, target}
+}
+
+type mapPattern struct {
+	line    int
+	entries []Entry
+}
+
+// MapPattern takes a map apart by key. The map must have every key.
+func MapPattern(line int, entries ...Entry) Pattern {
+	return mapPattern{line, entries}
+}
+
+func (p mapPattern) fits(value Value) bool {
+	m, ok := value.(*Map)
+	if !ok {
+		return false
+	}
+	for _, entry := range p.entries {
+		element, ok := m.values[entry.key]
+		if !ok || !entry.target.fits(element) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p mapPattern) bind(value Value, store func(string, Value, int)) {
+	m, ok := value.(*Map)
+	if !ok {
+		fail(p.line, "Expected a map to destructure.")
+	}
+	for _, entry := range p.entries {
+		element, ok := m.values[entry.key]
+		if !ok {
+			fail(entry.line, fmt.Sprintf("Map has no key '%s'.", entry.key))
+		}
+		entry.target.bind(element, store)
+	}
+}
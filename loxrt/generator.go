@@ -0,0 +1,129 @@
+package loxrt
+
+import "fmt"
+
+// Generator is the value of a call to a function that yields. Its body runs
+// lazily on a goroutine of its own, taking turns with the for-in loop asking
+// for values so that only one of them runs at a time. A loop left before the
+// end closes the generator, unwinding the body so that its goroutine exits.
+type Generator struct {
+	Name    string
+	body    func(yield func(Value))
+	resume  chan struct{}
+	results chan generatorResult
+	cancel  chan struct{}
+	running bool
+	done    bool
+	// loops holds the stop functions of the for-in loops running in the
+	// body, innermost last, for closing it to stop as it unwinds.
+	loops []func()
+}
+
+// current is the generator whose body is running, if any. Bodies take turns
+// with the code resuming them, so there is only ever one.
+var current *Generator
+
+// generatorResult is a yielded value, the end of the body when ok is false,
+// or a panic to re-raise in the loop.
+type generatorResult struct {
+	value   Value
+	ok      bool
+	failure interface{}
+}
+
+// generatorClosed unwinds the body of a generator that has been closed.
+type generatorClosed struct{}
+
+// NewGenerator declares a script function that yields. Calling it binds the
+// parameters in a new scope enclosed by closure and returns a Generator
+// whose body runs in that scope.
+func NewGenerator(name string, params []string, closure *Env, body func(env *Env, yield func(Value))) *Function {
+	return &Function{Name: name, Params: params, Fn: func(arguments []Value) Value {
+		env := NewEnv(closure)
+		for i, param := range params {
+			env.Define(param, arguments[i])
+		}
+		return &Generator{Name: name, body: func(yield func(Value)) { body(env, yield) }}
+	}}
+}
+
+func (g *Generator) String() string {
+	if g.Name == "" {
+		return "<generator>"
+	}
+	return fmt.Sprintf("<generator %s>", g.Name)
+}
+
+// next runs the body until it yields a value, or reports false once it has
+// finished. A runtime error in the body is raised here.
+func (g *Generator) next(line int) (Value, bool) {
+	if g.done {
+		return nil, false
+	}
+	if g.running {
+		fail(line, "Generator is already running.")
+	}
+	if g.resume == nil {
+		g.start()
+	}
+	g.running = true
+	resumer := current
+	current = g
+	g.resume <- struct{}{}
+	result := <-g.results
+	current = resumer
+	g.running = false
+	if result.failure != nil {
+		g.done = true
+		panic(result.failure)
+	}
+	if !result.ok {
+		g.done = true
+	}
+	return result.value, result.ok
+}
+
+func (g *Generator) start() {
+	g.resume = make(chan struct{})
+	g.results = make(chan generatorResult)
+	g.cancel = make(chan struct{})
+	go func() {
+		<-g.resume
+		defer func() {
+			r := recover()
+			if r == (generatorClosed{}) {
+				for n := len(g.loops) - 1; n >= 0; n-- {
+					g.loops[n]()
+				}
+				r = nil
+			}
+			if r != nil {
+				g.results <- generatorResult{failure: r}
+				return
+			}
+			g.results <- generatorResult{}
+		}()
+		g.body(func(value Value) {
+			g.results <- generatorResult{value: value, ok: true}
+			select {
+			case <-g.resume:
+			case <-g.cancel:
+				panic(generatorClosed{})
+			}
+		})
+	}()
+}
+
+// close finishes the generator early, waiting for the body to unwind if it
+// has started. A running generator, whose own body is leaving the loop, is
+// left alone.
+func (g *Generator) close() {
+	if g.done || g.running {
+		return
+	}
+	g.done = true
+	if g.resume != nil {
+		close(g.cancel)
+		<-g.results
+	}
+}
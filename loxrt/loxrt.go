@@ -0,0 +1,466 @@
+// Package loxrt is the runtime support for Lox scripts transpiled to Go. It
+// reproduces the interpreter's semantics: values are nil, bool, float64,
+// string, *Function, *List, *Map, *Range or *Generator; a variable is looked up by name, starting from the scope that the translation found declaring it;
+// and runtime errors stop the program with the interpreter's messages.
+package loxrt
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Value is any Lox value.
+type Value = interface{}
+
+// Stdout receives the output of print statements.
+var Stdout io.Writer = os.Stdout
+
+// Error is a runtime error raised at a source line.
+type Error struct {
+	Message string
+	Line    int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s\n[line %d]", e.Message, e.Line)
+}
+
+func fail(line int, message string) {
+	panic(&Error{Message: message, Line: line})
+}
+
+// Run executes a transpiled script. A runtime error is printed to stderr and
+// ends the process with status 70, as evaluate does.
+func Run(script func(env *Env)) {
+	if err := Execute(script); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(70)
+	}
+}
+
+// Execute executes a transpiled script in a fresh global scope and returns
+// the runtime error that stopped it, if any.
+func Execute(script func(env *Env)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	depth = 0
+	script(Globals())
+	return nil
+}
+
+// Env is a scope mapping names to values.
+type Env struct {
+	enclosing *Env
+	values    map[string]Value
+}
+
+func NewEnv(enclosing *Env) *Env {
+	return &Env{enclosing: enclosing, values: map[string]Value{}}
+}
+
+// Globals returns a global scope holding the native functions.
+func Globals() *Env {
+	env := NewEnv(nil)
+	env.Define("clock", &Function{Name: "clock", native: true, Fn: func([]Value) Value {
+		return float64(time.Now().UnixNano()) / float64(time.Second)
+	}})
+	env.Define("range", &Function{Name: "range", Params: []string{"start", "end", "step"}, native: true, Fn: newRange})
+	return env
+}
+
+func (e *Env) Define(name string, value Value) {
+	e.values[name] = value
+}
+
+// At returns the scope distance levels out from e, stopping at the global
+// scope.
+func (e *Env) At(distance int) *Env {
+	env := e
+	for ; distance > 0 && env.enclosing != nil; distance-- {
+		env = env.enclosing
+	}
+	return env
+}
+
+// Get returns the value of name in the nearest scope defining it.
+func (e *Env) Get(name string, line int) Value {
+	for env := e; env != nil; env = env.enclosing {
+		if value, ok := env.values[name]; ok {
+			return value
+		}
+	}
+	fail(line, fmt.Sprintf("Undefined variable '%s'.", name))
+	return nil
+}
+
+// Assign sets name in the nearest scope defining it and returns value.
+func (e *Env) Assign(name string, value Value, line int) Value {
+	for env := e; env != nil; env = env.enclosing {
+		if _, ok := env.values[name]; ok {
+			env.values[name] = value
+			return value
+		}
+	}
+	fail(line, fmt.Sprintf("Undefined variable '%s'.", name))
+	return nil
+}
+
+// Update applies op to name's value and then to operand's, stores the result
+// in name and returns it. Variable errors are reported on line and operand
+// errors on opLine.
+func (e *Env) Update(name string, line int, op func(a, b Value, line int) Value, opLine int, operand func() Value) Value {
+	value := op(e.Get(name, line), operand(), opLine)
+	return e.Assign(name, value, line)
+}
+
+// Coalesce assigns the value of operand to name, evaluating it only when name
+// holds nil.
+func (e *Env) Coalesce(name string, line int, operand func() Value) Value {
+	if current := e.Get(name, line); current != nil {
+		return current
+	}
+	return e.Assign(name, operand(), line)
+}
+
+// Destructure takes value apart as pattern describes and returns value. It
+// defines new variables when scopes is nil and otherwise assigns existing
+// ones, each from the scope scopes holds for its name.
+func (e *Env) Destructure(pattern Pattern, value Value, scopes map[string]*Env) Value {
+	pattern.bind(value, func(name string, value Value, line int) {
+		if scopes == nil {
+			e.Define(name, value)
+		} else {
+			scopes[name].Assign(name, value, line)
+		}
+	})
+	return value
+}
+
+// Match reports whether value has the shape of a list or map pattern in a
+// match case and, if so, defines the names it binds other than _.
+func (e *Env) Match(pattern Pattern, value Value) bool {
+	if !pattern.fits(value) {
+		return false
+	}
+	pattern.bind(value, func(name string, value Value, line int) {
+		if name != "_" {
+			e.Define(name, value)
+		}
+	})
+	return true
+}
+
+// Increment adds delta to the number in name. It returns the new value, or
+// the old one for a postfix operator.
+func (e *Env) Increment(name string, line int, delta float64, prefix bool, opLine int) Value {
+	old, ok := e.Get(name, line).(float64)
+	if !ok {
+		fail(opLine, "Operand must be a number.")
+	}
+	e.Assign(name, old+delta, line)
+	if prefix {
+		return old + delta
+	}
+	return old
+}
+
+// Function is a function declared in a script, or a native one.
+type Function struct {
+	Name   string
+	Params []string
+	Fn     func(arguments []Value) Value
+	native bool
+}
+
+// NewFunction declares a script function whose body runs in a new scope
+// enclosed by closure, holding the parameters.
+func NewFunction(name string, params []string, closure *Env, body func(env *Env) Value) *Function {
+	return &Function{Name: name, Params: params, Fn: func(arguments []Value) Value {
+		env := NewEnv(closure)
+		for i, param := range params {
+			env.Define(param, arguments[i])
+		}
+		return body(env)
+	}}
+}
+
+func (f *Function) String() string {
+	if f.native {
+		return "<native fn>"
+	}
+	if f.Name == "" {
+		return "<fn>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
+
+// maxCallDepth matches the interpreter's limit on nested calls.
+const maxCallDepth = 10000
+
+var depth int
+
+// Call calls callee with arguments from a call whose closing parenthesis is
+// on line.
+func Call(callee Value, line int, arguments ...Value) Value {
+	function, ok := callee.(*Function)
+	if !ok {
+		fail(line, "Can only call functions and classes.")
+	}
+	arity := len(function.Params)
+	if len(arguments) != arity {
+		fail(line, fmt.Sprintf("Expected %d arguments but got %d.", arity, len(arguments)))
+	}
+	if depth >= maxCallDepth {
+		fail(line, "Stack overflow.")
+	}
+	depth++
+	defer func() { depth-- }()
+	if function.native {
+		defer func() {
+			if r := recover(); r != nil {
+				if message, ok := r.(nativeError); ok {
+					fail(line, string(message))
+				}
+				panic(r)
+			}
+		}()
+	}
+	return function.Fn(arguments)
+}
+
+// nativeError is raised by a native function and reported on the line of
+// the call.
+type nativeError string
+
+// GetProperty reads a property, which transpiled scripts, having no
+// modules, only find on maps: the value stored under the name.
+func GetProperty(object Value, name string, line int) Value {
+	if m, ok := object.(*Map); ok {
+		return m.values[name]
+	}
+	fail(line, "Only modules and maps have properties.")
+	return nil
+}
+
+// Print writes a value the way the print statement does.
+func Print(value Value) {
+	fmt.Fprintln(Stdout, Stringify(value))
+}
+
+// Stringify formats a value the way print displays it.
+func Stringify(value Value) string {
+	if value == nil {
+		return "nil"
+	}
+	if number, ok := value.(float64); ok {
+		return fmt.Sprintf("%g", number)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Truthy reports whether a value counts as true in a condition: everything
+// but nil and false does.
+func Truthy(value Value) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// Equal reports whether two values are equal as the == operator sees them.
+func Equal(a, b Value) Value {
+	return a == b
+}
+
+func NotEqual(a, b Value) Value {
+	return a != b
+}
+
+func Not(value Value) Value {
+	return !Truthy(value)
+}
+
+func Negate(value Value, line int) Value {
+	number, ok := value.(float64)
+	if !ok {
+		fail(line, "Operand must be a number.")
+	}
+	return -number
+}
+
+func BitNot(value Value, line int) Value {
+	x, ok := integer(value)
+	if !ok {
+		fail(line, "Operand must be an integer.")
+	}
+	return float64(^x)
+}
+
+// integer converts a number with no fractional part that fits in 64 bits.
+func integer(value Value) (int64, bool) {
+	number, ok := value.(float64)
+	if !ok || number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
+
+func integers(a, b Value, line int) (int64, int64) {
+	x, xOk := integer(a)
+	y, yOk := integer(b)
+	if !xOk || !yOk {
+		fail(line, "Operands must be integers.")
+	}
+	return x, y
+}
+
+func shift(a, b Value, line int) (int64, int64) {
+	x, y := integers(a, b, line)
+	if y < 0 {
+		fail(line, "Shift count must not be negative.")
+	}
+	return x, y
+}
+
+func numbers(a, b Value, line int) (float64, float64) {
+	x, xOk := a.(float64)
+	y, yOk := b.(float64)
+	if !xOk || !yOk {
+		fail(line, "Operands must be numbers.")
+	}
+	return x, y
+}
+
+func Add(a, b Value, line int) Value {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			return x + y
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return x + y
+		}
+	}
+	fail(line, "Operands must be two numbers or two strings.")
+	return nil
+}
+
+func Subtract(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x - y
+}
+
+func Multiply(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x * y
+}
+
+func Divide(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x / y
+}
+
+func Modulo(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return math.Mod(x, y)
+}
+
+func Power(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return math.Pow(x, y)
+}
+
+func BitAnd(a, b Value, line int) Value {
+	x, y := integers(a, b, line)
+	return float64(x & y)
+}
+
+func BitOr(a, b Value, line int) Value {
+	x, y := integers(a, b, line)
+	return float64(x | y)
+}
+
+func BitXor(a, b Value, line int) Value {
+	x, y := integers(a, b, line)
+	return float64(x ^ y)
+}
+
+func ShiftLeft(a, b Value, line int) Value {
+	x, y := shift(a, b, line)
+	return float64(x << y)
+}
+
+func ShiftRight(a, b Value, line int) Value {
+	x, y := shift(a, b, line)
+	return float64(x >> y)
+}
+
+func Greater(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x > y
+}
+
+func GreaterEqual(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x >= y
+}
+
+func Less(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x < y
+}
+
+func LessEqual(a, b Value, line int) Value {
+	x, y := numbers(a, b, line)
+	return x <= y
+}
+
+// Comma evaluates to nil once both operands have been evaluated.
+func Comma(a, b Value) Value {
+	return nil
+}
+
+// And and Or evaluate right only when left does not decide the result.
+func And(left Value, right func() Value) Value {
+	if !Truthy(left) {
+		return left
+	}
+	return right()
+}
+
+func Or(left Value, right func() Value) Value {
+	if Truthy(left) {
+		return left
+	}
+	return right()
+}
+
+// Coalesce evaluates to left unless it is nil.
+func Coalesce(left Value, right func() Value) Value {
+	if left != nil {
+		return left
+	}
+	return right()
+}
+
+// Ternary evaluates one of its branches depending on condition.
+func Ternary(condition Value, then, otherwise func() Value) Value {
+	if Truthy(condition) {
+		return then()
+	}
+	return otherwise()
+}